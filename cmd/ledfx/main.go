@@ -5,23 +5,33 @@ import (
 	"net"
 	"net/http"
 	_ "net/http/pprof" //nolint:gosec
+	"net/url"
 	"os"
 	"os/signal"
 	"runtime"
 	"sync"
 	"syscall"
+	"time"
 
+	"github.com/LedFx/ledfx/pkg/api"
 	"github.com/LedFx/ledfx/pkg/audio"
+	"github.com/LedFx/ledfx/pkg/audio/audiobridge"
+	"github.com/LedFx/ledfx/pkg/auth"
 	"github.com/LedFx/ledfx/pkg/bridgeapi"
 	"github.com/LedFx/ledfx/pkg/color"
 	"github.com/LedFx/ledfx/pkg/config"
 	"github.com/LedFx/ledfx/pkg/constants"
 	"github.com/LedFx/ledfx/pkg/controller"
 	"github.com/LedFx/ledfx/pkg/device"
+	"github.com/LedFx/ledfx/pkg/dmx"
 	"github.com/LedFx/ledfx/pkg/effect"
 	"github.com/LedFx/ledfx/pkg/event"
 	"github.com/LedFx/ledfx/pkg/frontend"
+	"github.com/LedFx/ledfx/pkg/loader"
 	"github.com/LedFx/ledfx/pkg/logger"
+	"github.com/LedFx/ledfx/pkg/mqtt"
+	"github.com/LedFx/ledfx/pkg/osc"
+	"github.com/LedFx/ledfx/pkg/sequencer"
 	"github.com/LedFx/ledfx/pkg/util"
 	"github.com/LedFx/ledfx/pkg/websocket"
 
@@ -38,7 +48,7 @@ func init() {
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	go func() {
 		<-c
-		event.Invoke(event.Shutdown, map[string]interface{}{})
+		event.Invoke(event.ShutdownData{})
 	}()
 }
 
@@ -46,7 +56,11 @@ func main() {
 	settings := config.GetSettings()
 	logger.Logger.SetLevel(logrus.Level(5 - settings.LogLevel))
 	hostport := net.JoinHostPort(settings.Host, fmt.Sprint(settings.Port))
-	url := fmt.Sprintf("http://%s", hostport)
+	scheme := "http"
+	if settings.TLS {
+		scheme = "https"
+	}
+	url := fmt.Sprintf("%s://%s", scheme, hostport)
 	logger.Logger.Info("Info message logging enabled")
 	logger.Logger.Debug("Debug message logging enabled")
 
@@ -61,22 +75,21 @@ func main() {
 	}
 
 	// TODO: handle profiler flags
-	config.AllowSaving = false
-	err := effect.LoadFromConfig()
+	err := loader.Load()
 	if err != nil {
-		logger.Logger.WithField("context", "Load Effects from Config").Fatal(err)
+		logger.Logger.WithField("context", "Load from Config").Fatal(err)
 	}
-	err = device.LoadFromConfig()
-	if err != nil {
-		logger.Logger.WithField("context", "Load Devices from Config").Fatal(err)
+
+	// Connect to Home Assistant, if enabled
+	mqtt.Start()
+	// Listen for OSC, if enabled
+	if err = osc.Start(); err != nil {
+		logger.Logger.WithField("context", "OSC").Error(err)
 	}
-	err = controller.LoadFromConfig()
-	if err != nil {
-		logger.Logger.WithField("context", "Load Controllers from Config").Fatal(err)
+	// Listen for DMX from a lighting console, if enabled
+	if err = dmx.Start(); err != nil {
+		logger.Logger.WithField("context", "DMX Input").Error(err)
 	}
-	controller.LoadConnectionsFromConfig()
-	controller.LoadStatesFromConfig()
-	config.AllowSaving = true
 
 	// Handle WLED scanning
 	if !settings.NoScan {
@@ -87,11 +100,22 @@ func main() {
 
 	// Add routes
 	mux := http.DefaultServeMux
-	effect.NewAPI(mux)
-	device.NewAPI(mux)
-	controller.NewAPI(mux)
-	config.NewAPI(mux)
-	color.NewAPI(mux)
+	// the versioned api, documented at /api/v1/openapi.json
+	v1 := api.NewRouter("/api/v1")
+	effect.NewV1API(v1)
+	device.NewV1API(v1)
+	controller.NewV1API(v1)
+	config.NewV1API(v1)
+	loader.NewV1API(v1)
+	auth.NewV1API(v1)
+	audio.NewV1API(v1)
+	audiobridge.NewV1API(v1)
+	color.NewV1API(v1)
+	mqtt.NewV1API(v1)
+	osc.NewV1API(v1)
+	dmx.NewV1API(v1)
+	sequencer.NewV1API(v1)
+	v1.Serve(mux)
 	frontend.NewServer(mux)
 	websocket.Serve(mux)
 	bridgeServer, err := bridgeapi.NewServer(audio.Analyzer.BufferCallback, mux)
@@ -100,6 +124,11 @@ func main() {
 		logger.Logger.WithField("context", "AudioBridge").Fatalf("Error initializing AudioBridge server: %v", err)
 	} else {
 		defer bridgeServer.Br.Stop()
+		bridgeServer.Br.SetSampleRateCallback(audio.Analyzer.SetSampleRate)
+		bridgeServer.Br.SetStereoCallback(audio.Analyzer.StereoBufferCallback)
+		sequencer.RegisterClock(sequencer.ClockYoutube, func(string) (time.Duration, error) {
+			return bridgeServer.Br.Controller().YouTube().TimeElapsed()
+		})
 		logger.Logger.WithField("context", "AudioBridge").Info("Initialised AudioBridge server")
 	}
 	// if err := bridgeServer.Br.StartAirPlayInput("LedFx", 7000); err != nil {
@@ -111,16 +140,41 @@ func main() {
 			logger.Logger.WithField("context", "AudioBridge").Errorf("Error starting local input: %v\n", err)
 		}
 	}
+	audiobridge.LoadSourcesFromConfig()
 
 	// Start web server
 	wg.Add(1)
-	logger.Logger.WithField("context", "HTTP Listener").Infof("Starting LedFx HTTP Server at %s", hostport)
-	go func() {
-		defer wg.Done()
-		if err := http.ListenAndServe(hostport, setHeaders(mux)); err != nil {
-			logger.Logger.WithField("context", "HTTP Listener").Fatalf("Error listening and serving: %v", err)
+	handler := setHeaders(auth.Middleware(mux))
+	if settings.TLS {
+		cert, key, err := config.TLSFiles()
+		if err != nil {
+			logger.Logger.WithField("context", "HTTPS Listener").Fatalf("Error loading TLS certificate: %v", err)
 		}
-	}()
+		logger.Logger.WithField("context", "HTTPS Listener").Infof("Starting LedFx HTTPS Server at %s", hostport)
+		go func() {
+			defer wg.Done()
+			if err := http.ListenAndServeTLS(hostport, cert, key, handler); err != nil {
+				logger.Logger.WithField("context", "HTTPS Listener").Fatalf("Error listening and serving: %v", err)
+			}
+		}()
+		if settings.Redirect != 0 {
+			redirectHostport := net.JoinHostPort(settings.Host, fmt.Sprint(settings.Redirect))
+			logger.Logger.WithField("context", "HTTP Listener").Infof("Redirecting HTTP at %s to HTTPS", redirectHostport)
+			go func() {
+				if err := http.ListenAndServe(redirectHostport, redirectHTTPS(settings.Port)); err != nil {
+					logger.Logger.WithField("context", "HTTP Listener").Errorf("Error redirecting to HTTPS: %v", err)
+				}
+			}()
+		}
+	} else {
+		logger.Logger.WithField("context", "HTTP Listener").Infof("Starting LedFx HTTP Server at %s", hostport)
+		go func() {
+			defer wg.Done()
+			if err := http.ListenAndServe(hostport, handler); err != nil {
+				logger.Logger.WithField("context", "HTTP Listener").Fatalf("Error listening and serving: %v", err)
+			}
+		}()
+	}
 
 	// Print the cli logo and welcome message
 	if !settings.NoLogo {
@@ -170,18 +224,55 @@ func shutdown() {
 	audio.Analyzer.Cleanup()
 	audio.Terminate()
 
+	// stop any show mid-ramp
+	sequencer.Pause()
+
+	// let Home Assistant know LedFx is offline
+	mqtt.Stop()
+
 	// kill systray
 	if !config.GetSettings().NoTray {
 		logger.Logger.WithField("context", "Shutdown Handler").Info("Shutting down Systray")
 		systray.Quit()
 	}
+
+	// save any config changes still waiting to be written
+	if err := config.Flush(); err != nil {
+		logger.Logger.WithField("context", "Shutdown Handler").Error(err)
+	}
 	os.Exit(0)
 }
 
+// redirects requests to the same host and path over HTTPS
+func redirectHTTPS(port int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		target := url.URL{
+			Scheme:   "https",
+			Host:     net.JoinHostPort(host, fmt.Sprint(port)),
+			Path:     r.URL.Path,
+			RawQuery: r.URL.RawQuery,
+		}
+		http.Redirect(w, r, target.String(), http.StatusMovedPermanently)
+	})
+}
+
 func setHeaders(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		//anyone can make a CORS request (not recommended in production)
-		w.Header().Set("Access-Control-Allow-Origin", "*")
+		//only allowed origins can make a CORS request. anyone can if none are configured
+		origin, allowed := auth.AllowedOrigin(r)
+		if !allowed {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte("origin not allowed"))
+			return
+		}
+		if origin != "" {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Vary", "Origin")
+		}
 		//only allow GET, PUT, POST, DELETE and OPTIONS
 		w.Header().Set("Access-Control-Allow-Methods", "GET, PUT, POST, DELETE, OPTIONS")
 		//Since I was building a REST API that returned JSON, I set the content type to JSON here.
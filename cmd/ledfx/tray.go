@@ -2,7 +2,6 @@ package main
 
 import (
 	_ "embed"
-	"fmt"
 	"os"
 
 	"github.com/LedFx/ledfx/pkg/event"
@@ -24,11 +23,11 @@ func StartTray(url string) func() {
 			for {
 				select {
 				case <-mOpen.ClickedCh:
-					util.OpenBrowser(fmt.Sprintf("http://%s/#/?newCore=1", url))
+					util.OpenBrowser(url + "/#/?newCore=1")
 				case <-mGithub.ClickedCh:
 					util.OpenBrowser("https://github.com/LedFx/ledfx_rewrite")
 				case <-mQuit.ClickedCh:
-					event.Invoke(event.Shutdown, map[string]interface{}{})
+					event.Invoke(event.ShutdownData{})
 					return
 				}
 			}
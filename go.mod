@@ -20,6 +20,7 @@ require (
 	github.com/ritchie46/GOPHY v0.0.0-20170315173114-9b8a7f05cfa1
 	github.com/spf13/pflag v1.0.5
 	go.uber.org/atomic v1.9.0
+	golang.org/x/crypto v0.0.0-20220525230936-793ad666bf5e
 	golang.org/x/image v0.0.0-20220601225756-64ec528b34cd
 	golang.org/x/text v0.3.8-0.20211105212822-18b340fc7af2
 )
@@ -55,15 +56,14 @@ require (
 	golang.org/x/term v0.0.0-20220526004731-065cf7ba2467 // indirect
 	golang.org/x/tools v0.1.11-0.20220413170336-afc6aad76eb1 // indirect
 	golang.org/x/xerrors v0.0.0-20220517211312-f3a8303e98df // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
-	github.com/dop251/goja v0.0.0-20220516123900-4418d4575a41 // indirect
+	github.com/dop251/goja v0.0.0-20220516123900-4418d4575a41
 	github.com/kkdai/youtube/v2 v2.7.15
 	github.com/schollz/progressbar/v3 v3.8.6
-	golang.org/x/crypto v0.0.0-20220525230936-793ad666bf5e // indirect
-	golang.org/x/net v0.0.0-20220531201128-c960675eff93 // indirect
+	golang.org/x/net v0.0.0-20220531201128-c960675eff93
 	golang.org/x/sys v0.0.0-20220520151302-bc2c85ada10a // indirect
 )
 
@@ -0,0 +1,60 @@
+// Helpers for testing the routes packages add to the versioned api
+package apitest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// A request, and the status it should get
+type Case struct {
+	Method string
+	Path   string
+	Body   string
+	Code   int
+}
+
+// Makes a request, returning the status and the json object sent back. Other responses decode to an empty map
+func Do(h http.Handler, method, path, body string) (int, map[string]interface{}) {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+	res := map[string]interface{}{}
+	json.NewDecoder(w.Body).Decode(&res)
+	return w.Code, res
+}
+
+// Makes each request in order, checking its status, and that errors are sent as json
+func Run(t *testing.T, h http.Handler, cases []Case) {
+	t.Helper()
+	for _, c := range cases {
+		code, res := Do(h, c.Method, c.Path, c.Body)
+		if code != c.Code {
+			t.Errorf("%s %s: expected %d, got %d %v", c.Method, c.Path, c.Code, code, res)
+		}
+		if code >= http.StatusBadRequest && res["error"] == nil {
+			t.Errorf("%s %s: expected a json error, got %v", c.Method, c.Path, res)
+		}
+	}
+}
+
+// Gets the router's OpenAPI document, failing if any of the paths or schemas are missing from it
+func Documents(t *testing.T, h http.Handler, prefix string, paths, schemas []string) {
+	t.Helper()
+	_, doc := Do(h, http.MethodGet, prefix+"/openapi.json", "")
+	documented, _ := doc["paths"].(map[string]interface{})
+	for _, p := range paths {
+		if _, ok := documented[p]; !ok {
+			t.Errorf("Expected %s to be documented", p)
+		}
+	}
+	components, _ := doc["components"].(map[string]interface{})
+	documentedSchemas, _ := components["schemas"].(map[string]interface{})
+	for _, name := range schemas {
+		if _, ok := documentedSchemas[name]; !ok {
+			t.Errorf("Expected the %s schema to be documented", name)
+		}
+	}
+}
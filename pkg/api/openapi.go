@@ -0,0 +1,269 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/LedFx/ledfx/pkg/constants"
+)
+
+// The OpenAPI document of the router's routes
+func (rt *Router) OpenAPI() map[string]interface{} {
+	paths := map[string]interface{}{}
+	tags := map[string]bool{}
+	for _, route := range rt.sortedRoutes() {
+		item, ok := paths[route.Path].(map[string]interface{})
+		if !ok {
+			item = map[string]interface{}{}
+			paths[route.Path] = item
+		}
+		item[strings.ToLower(route.Method)] = route.operation()
+		if route.Tag != "" {
+			tags[route.Tag] = true
+		}
+	}
+	tagList := []interface{}{}
+	for _, tag := range sortedKeys(tags) {
+		tagList = append(tagList, map[string]interface{}{"name": tag})
+	}
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "LedFx API",
+			"version": constants.VERSION,
+			"license": map[string]interface{}{"name": "GPL-3.0-or-later"},
+		},
+		"servers": []interface{}{map[string]interface{}{"url": rt.prefix}},
+		"tags":    tagList,
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"schemas": rt.schemas,
+			"securitySchemes": map[string]interface{}{
+				"token":    map[string]interface{}{"type": "http", "scheme": "bearer"},
+				"password": map[string]interface{}{"type": "http", "scheme": "basic"},
+			},
+		},
+		// auth is only needed when it's enabled
+		"security": []interface{}{
+			map[string]interface{}{},
+			map[string]interface{}{"token": []string{}},
+			map[string]interface{}{"password": []string{}},
+		},
+	}
+}
+
+func (r *Route) operation() map[string]interface{} {
+	op := map[string]interface{}{
+		"operationId": operationID(r.Method, r.Path),
+		"summary":     r.Summary,
+	}
+	if r.Tag != "" {
+		op["tags"] = []string{r.Tag}
+	}
+	params := []interface{}{}
+	for _, s := range r.segments {
+		if name, isParam := paramName(s); isParam {
+			params = append(params, map[string]interface{}{
+				"name":     name,
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]interface{}{"type": "string"},
+			})
+		}
+	}
+	for _, name := range sortedKeys(r.query) {
+		params = append(params, map[string]interface{}{
+			"name":        name,
+			"in":          "query",
+			"description": r.query[name],
+			"schema":      map[string]interface{}{"type": "string"},
+		})
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+	if r.request != nil {
+		op["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  jsonContent(r.request),
+		}
+	}
+	success := map[string]interface{}{"description": http.StatusText(r.status)}
+	if r.response != nil && r.status != http.StatusNoContent {
+		success["content"] = jsonContent(r.response)
+	}
+	op["responses"] = map[string]interface{}{
+		fmt.Sprint(r.status): success,
+		"default": map[string]interface{}{
+			"description": "Error",
+			"content":     jsonContent(Ref("Error")),
+		},
+	}
+	return op
+}
+
+// eg. GET /effects/{id} -> get_effects_id
+func operationID(method, path string) string {
+	id := strings.ToLower(method)
+	for _, s := range strings.Split(strings.Trim(path, "/"), "/") {
+		s = strings.Trim(s, "{}")
+		s = strings.NewReplacer(".", "_", "-", "_").Replace(s)
+		if s != "" {
+			id += "_" + s
+		}
+	}
+	return id
+}
+
+func jsonContent(schema interface{}) map[string]interface{} {
+	return map[string]interface{}{"application/json": map[string]interface{}{"schema": schema}}
+}
+
+// Refers to a schema added to the router with Schema
+func Ref(name string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+// A schema for an array of a schema
+func ArrayOf(schema interface{}) map[string]interface{} {
+	return map[string]interface{}{"type": "array", "items": schema}
+}
+
+// A schema for an object keyed by id, eg. effects by their id
+func MapOf(schema interface{}) map[string]interface{} {
+	return map[string]interface{}{"type": "object", "additionalProperties": schema}
+}
+
+// Builds a schema for a value's type from its json tags
+func TypeSchema(v interface{}) map[string]interface{} {
+	return typeSchema(reflect.TypeOf(v))
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func typeSchema(t reflect.Type) map[string]interface{} {
+	if t == nil {
+		return map[string]interface{}{}
+	}
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return typeSchema(t.Elem())
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return ArrayOf(typeSchema(t.Elem()))
+	case reflect.Map:
+		return MapOf(typeSchema(t.Elem()))
+	case reflect.Struct:
+		props := map[string]interface{}{}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name := strings.Split(f.Tag.Get("json"), ",")[0]
+			if f.PkgPath != "" || name == "-" {
+				continue
+			}
+			if f.Anonymous && name == "" {
+				// embedded structs' fields are inlined, as json does
+				for k, v := range typeSchema(f.Type)["properties"].(map[string]interface{}) {
+					props[k] = v
+				}
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			s := typeSchema(f.Type)
+			if desc := f.Tag.Get("description"); desc != "" {
+				s["description"] = desc
+			}
+			props[name] = s
+		}
+		return map[string]interface{}{"type": "object", "properties": props}
+	}
+	// interfaces can be anything
+	return map[string]interface{}{}
+}
+
+/*
+Converts a config schema made by util.CreateSchema into a json schema,
+so config types are documented with the same defaults and validation as the web interface sees.
+*/
+func ConfigSchema(schema map[string]interface{}) map[string]interface{} {
+	props := map[string]interface{}{}
+	required := []string{}
+	for key, value := range schema {
+		entry, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		p := map[string]interface{}{}
+		switch entry["type"] {
+		case "string":
+			p["type"] = "string"
+		case "bool":
+			p["type"] = "boolean"
+		case "int":
+			p["type"] = "integer"
+		case "float64":
+			p["type"] = "number"
+		case "list":
+			p["type"] = "array"
+			p["items"] = map[string]interface{}{"type": "string"}
+		}
+		for _, k := range []string{"title", "description", "default"} {
+			if v, ok := entry[k]; ok {
+				p[k] = v
+			}
+		}
+		if validation, ok := entry["validation"].(map[string]interface{}); ok {
+			if v, ok := validation["min"]; ok {
+				p["minimum"] = v
+			}
+			if v, ok := validation["max"]; ok {
+				p["maximum"] = v
+			}
+			if v, ok := validation["min_length"]; ok {
+				p["minLength"] = v
+			}
+			if v, ok := validation["oneof"]; ok {
+				p["enum"] = v
+			}
+			if v, ok := validation["special"]; ok {
+				p["format"] = v
+			}
+		}
+		if req, _ := entry["required"].(bool); req {
+			required = append(required, key)
+		}
+		props[key] = p
+	}
+	s := map[string]interface{}{"type": "object", "properties": props}
+	if len(required) > 0 {
+		sort.Strings(required)
+		s["required"] = required
+	}
+	return s
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
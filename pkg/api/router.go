@@ -0,0 +1,283 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/LedFx/ledfx/pkg/logger"
+)
+
+/*
+A router for a versioned api. Routes have path params in braces, eg. /effects/{id},
+and handlers return a value to send as json, or an error. Errors are sent as json too:
+
+	{"error": {"code": "not_found", "message": "cannot retrieve effect of id: x"}}
+
+Each route is documented as it's registered, so the OpenAPI document always matches the routes.
+*/
+type Router struct {
+	prefix  string
+	routes  []*Route
+	schemas map[string]interface{} // OpenAPI components, by name
+}
+
+// Handles a request, returning the response to send as json. nil sends no content
+type Handler func(r *Request) (interface{}, error)
+
+type Route struct {
+	Method   string
+	Path     string
+	Summary  string
+	Tag      string
+	status   int
+	request  interface{} // schema of the request body
+	response interface{} // schema of the response body
+	query    map[string]string
+	segments []string
+	handler  Handler
+}
+
+// A request, with its path params
+type Request struct {
+	*http.Request
+	params map[string]string
+}
+
+// An error with the status and code to send
+type Error struct {
+	Status  int         `json:"-"`
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Header  http.Header `json:"-"` // headers to send with the error, eg. Retry-After
+}
+
+type errorResponse struct {
+	Error *Error `json:"error"`
+}
+
+// Error codes
+const (
+	CodeBadRequest       = "bad_request"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeTooManyRequests  = "too_many_requests"
+	CodeInternal         = "internal_error"
+)
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func NewError(status int, code string, err error) *Error {
+	return &Error{Status: status, Code: code, Message: err.Error()}
+}
+
+// The request was invalid, eg. its body or config didn't validate
+func BadRequest(err error) *Error {
+	return NewError(http.StatusBadRequest, CodeBadRequest, err)
+}
+
+// The id in the path doesn't exist
+func NotFound(err error) *Error {
+	return NewError(http.StatusNotFound, CodeNotFound, err)
+}
+
+// Writes an error as json. Errors which aren't an *Error are internal errors
+func WriteError(w http.ResponseWriter, err error) {
+	var e *Error
+	if !errors.As(err, &e) {
+		e = NewError(http.StatusInternalServerError, CodeInternal, err)
+		logger.Logger.WithField("context", "API").Error(err)
+	}
+	for k, v := range e.Header {
+		w.Header()[k] = v
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(e.Status)
+	json.NewEncoder(w).Encode(errorResponse{Error: e})
+}
+
+func NewRouter(prefix string) *Router {
+	r := &Router{
+		prefix:  strings.TrimSuffix(prefix, "/"),
+		schemas: map[string]interface{}{},
+	}
+	r.Schema("Error", TypeSchema(errorResponse{}))
+	r.Get("/openapi.json", func(*Request) (interface{}, error) {
+		return r.OpenAPI(), nil
+	}).Doc("API", "Get this OpenAPI document").Returns(map[string]interface{}{"type": "object"})
+	return r
+}
+
+// Serves the router's routes on the mux
+func (rt *Router) Serve(mux *http.ServeMux) {
+	mux.Handle(rt.prefix+"/", rt)
+}
+
+func (rt *Router) Get(path string, h Handler) *Route {
+	return rt.Handle(http.MethodGet, path, h)
+}
+
+func (rt *Router) Post(path string, h Handler) *Route {
+	return rt.Handle(http.MethodPost, path, h)
+}
+
+func (rt *Router) Put(path string, h Handler) *Route {
+	return rt.Handle(http.MethodPut, path, h)
+}
+
+func (rt *Router) Delete(path string, h Handler) *Route {
+	return rt.Handle(http.MethodDelete, path, h)
+}
+
+// Adds a route. Routes are matched in the order they're added
+func (rt *Router) Handle(method, path string, h Handler) *Route {
+	route := &Route{
+		Method:   method,
+		Path:     path,
+		status:   http.StatusOK,
+		segments: strings.Split(strings.Trim(path, "/"), "/"),
+		handler:  h,
+	}
+	if method == http.MethodDelete {
+		route.status = http.StatusNoContent
+	}
+	rt.routes = append(rt.routes, route)
+	return route
+}
+
+// Adds a named schema to the OpenAPI components, to refer to with Ref
+func (rt *Router) Schema(name string, schema interface{}) {
+	rt.schemas[name] = schema
+}
+
+// Documents the route with the tag it's grouped by, and a summary of what it does
+func (r *Route) Doc(tag, summary string) *Route {
+	r.Tag, r.Summary = tag, summary
+	return r
+}
+
+// Documents the schema of the request body
+func (r *Route) Accepts(schema interface{}) *Route {
+	r.request = schema
+	return r
+}
+
+// Documents the schema of the response
+func (r *Route) Returns(schema interface{}) *Route {
+	r.response = schema
+	return r
+}
+
+// Documents a query param
+func (r *Route) Query(name, description string) *Route {
+	if r.query == nil {
+		r.query = map[string]string{}
+	}
+	r.query[name] = description
+	return r
+}
+
+// Sets the status of successful responses, eg. 201 for routes which create things
+func (r *Route) Status(status int) *Route {
+	r.status = status
+	return r
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	segments := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, rt.prefix), "/"), "/")
+	allowed := []string{}
+	for _, route := range rt.routes {
+		params, ok := route.match(segments)
+		if !ok {
+			continue
+		}
+		if route.Method != r.Method {
+			allowed = append(allowed, route.Method)
+			continue
+		}
+		rt.serve(route, w, &Request{Request: r, params: params})
+		return
+	}
+	if len(allowed) > 0 {
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		WriteError(w, NewError(http.StatusMethodNotAllowed, CodeMethodNotAllowed, fmt.Errorf("%s is not allowed on %s", r.Method, r.URL.Path)))
+		return
+	}
+	WriteError(w, NotFound(fmt.Errorf("no route for %s", r.URL.Path)))
+}
+
+func (rt *Router) serve(route *Route, w http.ResponseWriter, r *Request) {
+	res, err := route.handler(r)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	if res == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	b, err := json.Marshal(res)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(route.status)
+	w.Write(b)
+}
+
+// matches path segments to the route, returning the path params
+func (r *Route) match(segments []string) (map[string]string, bool) {
+	if len(segments) != len(r.segments) {
+		return nil, false
+	}
+	params := map[string]string{}
+	for i, s := range r.segments {
+		if name, isParam := paramName(s); isParam {
+			if segments[i] == "" {
+				return nil, false
+			}
+			params[name] = segments[i]
+		} else if s != segments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+func paramName(segment string) (string, bool) {
+	if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+		return segment[1 : len(segment)-1], true
+	}
+	return "", false
+}
+
+// A path param
+func (r *Request) Param(name string) string {
+	return r.params[name]
+}
+
+// Decodes the json body of the request. Errors are bad requests
+func (r *Request) Decode(v interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return BadRequest(fmt.Errorf("invalid request body: %w", err))
+	}
+	return nil
+}
+
+// the routes, sorted by path then method, for documenting
+func (rt *Router) sortedRoutes() []*Route {
+	routes := make([]*Route, len(rt.routes))
+	copy(routes, rt.routes)
+	sort.SliceStable(routes, func(i, j int) bool {
+		return routes[i].Path < routes[j].Path
+	})
+	return routes
+}
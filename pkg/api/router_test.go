@@ -0,0 +1,131 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type thing struct {
+	Name string `json:"name" description:"Name of the thing"`
+}
+
+func testRouter() *Router {
+	things := map[string]thing{"a": {Name: "A"}}
+	r := NewRouter("/api/v1")
+	r.Schema("Thing", TypeSchema(thing{}))
+	r.Get("/things/{id}", func(req *Request) (interface{}, error) {
+		t, ok := things[req.Param("id")]
+		if !ok {
+			return nil, NotFound(errors.New("no thing " + req.Param("id")))
+		}
+		return t, nil
+	}).Doc("Things", "Get a thing").Returns(Ref("Thing"))
+	r.Post("/things", func(req *Request) (interface{}, error) {
+		t := thing{}
+		if err := req.Decode(&t); err != nil {
+			return nil, err
+		}
+		return t, nil
+	}).Doc("Things", "Create a thing").Accepts(Ref("Thing")).Returns(Ref("Thing")).Status(http.StatusCreated)
+	r.Delete("/things/{id}", func(req *Request) (interface{}, error) {
+		return nil, nil
+	}).Doc("Things", "Delete a thing")
+	r.Get("/broken", func(req *Request) (interface{}, error) {
+		return nil, errors.New("oops")
+	})
+	return r
+}
+
+func TestRouter(t *testing.T) {
+	r := testRouter()
+	cases := []struct {
+		method string
+		path   string
+		body   string
+		code   int
+		error  string
+	}{
+		{http.MethodGet, "/api/v1/things/a", "", http.StatusOK, ""},
+		{http.MethodGet, "/api/v1/things/b", "", http.StatusNotFound, CodeNotFound},
+		{http.MethodGet, "/api/v1/things/a/b", "", http.StatusNotFound, CodeNotFound},
+		{http.MethodPut, "/api/v1/things/a", "", http.StatusMethodNotAllowed, CodeMethodNotAllowed},
+		{http.MethodPost, "/api/v1/things", `{"name": "B"}`, http.StatusCreated, ""},
+		{http.MethodPost, "/api/v1/things", `{"name":`, http.StatusBadRequest, CodeBadRequest},
+		{http.MethodDelete, "/api/v1/things/a", "", http.StatusNoContent, ""},
+		{http.MethodGet, "/api/v1/broken", "", http.StatusInternalServerError, CodeInternal},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(c.method, c.path, strings.NewReader(c.body)))
+		if w.Code != c.code {
+			t.Errorf("%s %s: expected %d, got %d", c.method, c.path, c.code, w.Code)
+		}
+		if c.error == "" {
+			continue
+		}
+		res := errorResponse{}
+		if err := json.NewDecoder(w.Body).Decode(&res); err != nil || res.Error == nil || res.Error.Code != c.error {
+			t.Errorf("%s %s: expected a %s error, got %+v %v", c.method, c.path, c.error, res.Error, err)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/api/v1/things/a", nil))
+	if allow := w.Header().Get("Allow"); allow != "GET, DELETE" {
+		t.Errorf("Expected the allowed methods, got %s", allow)
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	w := httptest.NewRecorder()
+	testRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/openapi.json", nil))
+	doc := map[string]interface{}{}
+	if err := json.NewDecoder(w.Body).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	paths := doc["paths"].(map[string]interface{})
+	op, ok := paths["/things/{id}"].(map[string]interface{})["get"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected GET /things/{id} to be documented, got %v", paths)
+	}
+	if op["operationId"] != "get_things_id" || len(op["parameters"].([]interface{})) != 1 {
+		t.Errorf("Expected the path param to be documented, got %v", op)
+	}
+	if _, ok := paths["/things"].(map[string]interface{})["post"].(map[string]interface{})["responses"].(map[string]interface{})["201"]; !ok {
+		t.Error("Expected the created status to be documented")
+	}
+	schemas := doc["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	if _, ok := schemas["Thing"]; !ok {
+		t.Error("Expected the schema to be documented")
+	}
+}
+
+func TestConfigSchema(t *testing.T) {
+	s := ConfigSchema(map[string]interface{}{
+		"brightness": map[string]interface{}{
+			"type":       "float64",
+			"default":    1.0,
+			"required":   true,
+			"validation": map[string]interface{}{"min": 0, "max": 1},
+		},
+		"mode": map[string]interface{}{
+			"type":       "string",
+			"validation": map[string]interface{}{"oneof": []string{"a", "b"}},
+		},
+	})
+	props := s["properties"].(map[string]interface{})
+	b := props["brightness"].(map[string]interface{})
+	if b["type"] != "number" || b["minimum"] != 0 || b["maximum"] != 1 || b["default"] != 1.0 {
+		t.Errorf("Expected brightness to be a number from 0 to 1, got %v", b)
+	}
+	if m := props["mode"].(map[string]interface{}); m["type"] != "string" || len(m["enum"].([]string)) != 2 {
+		t.Errorf("Expected mode to be an enum, got %v", m)
+	}
+	if req := s["required"].([]string); len(req) != 1 || req[0] != "brightness" {
+		t.Errorf("Expected brightness to be required, got %v", s["required"])
+	}
+}
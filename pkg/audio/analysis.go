@@ -3,35 +3,34 @@ package audio
 import (
 	"fmt"
 	"math"
+	"sync"
 	"time"
 
+	"github.com/LedFx/ledfx/pkg/config"
+	"github.com/LedFx/ledfx/pkg/event"
 	log "github.com/LedFx/ledfx/pkg/logger"
 
 	"github.com/LedFx/aubio-go"
 )
 
 const (
-	// fft and buffer
-	FftSize     uint    = 4096
-	BufferSize  uint    = 1024
-	SampleRate  uint    = 44100
-	RefreshRate float64 = float64(SampleRate) / float64(BufferSize)
+	// used when neither the audio source nor the config give a sample rate
+	defaultSampleRate uint = 44100
 	// volume normalisation streams
 	streamConstant float64 = 0.1
 	streamPow      float64 = 1
 )
 
-var (
-	normStreamSlowLen  float64 = RefreshRate * 3
-	normStreamFastLen  float64 = RefreshRate * 2
-	reactStreamSlowLen float64 = RefreshRate * 3
-	reactStreamFastLen float64 = RefreshRate * 0.05
-)
-
-// Singleton analyzer
+// Analyzer for the default audio source
 var Analyzer *analyzer
 
 type analyzer struct {
+	mu          sync.Mutex
+	ID          string              // id of the audio source this analyzer is fed by
+	config      config.AudioConfig  // analysis parameters
+	sourceRate  uint                // sample rate reported by the audio source. 0 if unknown
+	sampleRate  uint                // sample rate the analysis is running at
+	fftSize     uint                // size of the fft window
 	bufSize     int                 // size of buffer (mono, single channel)
 	buf         *aubio.SimpleBuffer // aubio buffer
 	data        []float32           // audio buffer as f32
@@ -39,48 +38,147 @@ type analyzer struct {
 	onset       *aubio.Onset        // detects percussive onsets
 	pvoc        *aubio.PhaseVoc     // transforms audio data to fft
 	melbanks    map[string]*melbank // a melbank for each effect
+	stereo      *stereoAnalysis     // per channel analysis. nil unless the source gives stereo audio
+	recorder    *Recorder           // records incoming buffers. nil unless recording
+	freed       bool                // set by cleanup, so late buffers are not analysed with freed memory
+	silence     silenceDetector     // tracks quiet audio
 	RecentOnset time.Time           // onset for effects
 	Vol         volumeStream        // volume stream source for effects. includes a normalised volume and a timestep.
 }
 
 func init() {
-	Analyzer = &analyzer{}
-	initialise(int(BufferSize))
+	Analyzer = newAnalyzer(DefaultSource)
+	analyzers[DefaultSource] = Analyzer
+	// apply any changes made to the audio config
+	event.Subscribe(event.AudioUpdate, func(e *event.Event) {
+		configureAnalyzers()
+	})
+}
+
+// Applies the audio config to every analyzer. Safe to call alongside the audio update subscriber,
+// as analyzers which already have the config are left alone.
+func configureAnalyzers() {
+	for _, a := range getAnalyzers() {
+		a.Configure(config.GetAudio())
+	}
 }
 
-func initialise(bufSize int) {
+func newAnalyzer(id string) *analyzer {
+	a := &analyzer{
+		ID:     id,
+		config: config.GetAudio(),
+	}
+	a.initialise(a.config.BufferSize)
+	return a
+}
+
+func (a *analyzer) initialise(bufSize int) {
 	uintBufSize := uint(bufSize)
-	Analyzer.bufSize = bufSize
-	Analyzer.buf = aubio.NewSimpleBuffer(uintBufSize)
-	Analyzer.data = make([]float32, uintBufSize)
-	Analyzer.melbanks = make(map[string]*melbank)
-	Analyzer.RecentOnset = time.Now()
-	Analyzer.Vol = NewVolumeStream()
+	a.fftSize = uint(a.config.FftSize)
+	// aubio needs the fft window to be at least as large as the hop size
+	if a.fftSize < uintBufSize {
+		a.fftSize = uintBufSize
+	}
+	a.sampleRate = a.resolveSampleRate()
+	a.bufSize = bufSize
+	a.buf = aubio.NewSimpleBuffer(uintBufSize)
+	a.data = make([]float32, uintBufSize)
+	a.melbanks = make(map[string]*melbank)
+	a.RecentOnset = time.Now()
+	a.Vol = NewVolumeStream(a.refreshRate())
+	a.freed = false
 	var err error
 
-	// Create EQ filter. Magic numbers to balance the audio. Boosts the bass and mid, dampens the highs.
-	if Analyzer.eq, err = aubio.NewFilterBiquad(1, -2, 1, -2, 1, uintBufSize); err != nil {
+	// Create EQ filter. The default coefficients boost the bass and mid, and dampen the highs.
+	if a.eq, err = aubio.NewFilterBiquad(a.config.EqB0, a.config.EqB1, a.config.EqB2, a.config.EqA1, a.config.EqA2, uintBufSize); err != nil {
 		log.Logger.WithField("context", "Audio Analyzer Init").Fatalf("Error creating new Aubio EQ Filter: %v", err)
 	}
 
 	// Create onset
-	if Analyzer.onset, err = aubio.NewOnset(aubio.HFC, FftSize, uintBufSize, SampleRate); err != nil {
+	if a.onset, err = aubio.NewOnset(aubio.HFC, a.fftSize, uintBufSize, a.sampleRate); err != nil {
 		log.Logger.WithField("context", "Audio Analyzer Init").Fatalf("Error creating new Aubio Onset: %v", err)
 	}
 
 	// Create pvoc
-	if Analyzer.pvoc, err = aubio.NewPhaseVoc(FftSize, uintBufSize); err != nil {
+	if a.pvoc, err = aubio.NewPhaseVoc(a.fftSize, uintBufSize); err != nil {
 		log.Logger.WithField("context", "Audio Analyzer Init").Fatalf("Error creating new Aubio Pvoc: %v", err)
 	}
 
 }
 
+// The audio source's own sample rate takes priority, as that is what the audio really is.
+func (a *analyzer) resolveSampleRate() uint {
+	switch {
+	case a.sourceRate != 0:
+		return a.sourceRate
+	case a.config.SampleRate != 0:
+		return uint(a.config.SampleRate)
+	default:
+		return defaultSampleRate
+	}
+}
+
+// Applies a new audio config, reinitialising the analysis if anything changed
+func (a *analyzer) Configure(c config.AudioConfig) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.config == c {
+		return
+	}
+	a.config = c
+	log.Logger.WithField("context", "Audio Analyzer").Info("Audio config changed. Reinitialising.")
+	a.reinitialise(a.bufSize)
+}
+
+// Tells the analyzer the sample rate of the audio it is receiving.
+// Should be called by audio sources whenever they start.
+func (a *analyzer) SetSampleRate(rate uint) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.sourceRate == rate {
+		return
+	}
+	a.sourceRate = rate
+	if a.resolveSampleRate() == a.sampleRate {
+		return
+	}
+	log.Logger.WithField("context", "Audio Analyzer").Infof("Audio sample rate changed [%d->%d]. Reinitialising.", a.sampleRate, a.resolveSampleRate())
+	a.reinitialise(a.bufSize)
+}
+
+// Sample rate the analysis is running at
+func (a *analyzer) SampleRate() uint {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.sampleRate
+}
+
+// Size of the audio buffers the analysis is running on
+func (a *analyzer) BufferSize() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.bufSize
+}
+
+// Number of audio buffers analysed per second
+func (a *analyzer) RefreshRate() float64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.refreshRate()
+}
+
+// caller must hold the lock
+func (a *analyzer) refreshRate() float64 {
+	return float64(a.sampleRate) / float64(a.bufSize)
+}
+
 type melbankArgs struct {
 	min       uint
 	max       uint
 	intensity float64
 }
 
+// Frees and recreates the analysis. Caller must hold the lock.
 func (a *analyzer) reinitialise(bufSize int) {
 	mels := make(map[string]melbankArgs)
 	for id := range a.melbanks {
@@ -96,11 +194,12 @@ func (a *analyzer) reinitialise(bufSize int) {
 	a.onset.Free()
 	a.pvoc.Free()
 	for id := range a.melbanks {
-		a.DeleteMelbank(id)
+		a.deleteMelbank(id)
 	}
-	initialise(bufSize)
+	a.freeStereo()
+	a.initialise(bufSize)
 	for id, args := range mels {
-		a.NewMelbank(id, args.min, args.max, args.intensity)
+		a.newMelbank(id, args.min, args.max, args.intensity)
 	}
 
 }
@@ -108,6 +207,16 @@ func (a *analyzer) reinitialise(bufSize int) {
 // Takes a mono audio buffer and performs analysis.
 // Should be called around 60fps for smooth audio data for effects to use
 func (a *analyzer) BufferCallback(buf Buffer) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.freed {
+		return
+	}
+	if a.recorder != nil {
+		if err := a.recorder.Write(buf); err != nil {
+			log.Logger.WithField("context", "Audio Recorder").Errorf("Error recording audio buffer: %v", err)
+		}
+	}
 	// if the buffer changes size, we need to clean up and reinitialise
 	if len(buf) != a.bufSize {
 		log.Logger.WithField("context", "Audio Analyzer").Warnf("Audio buffer changed size [%d->%d]. Reinitialising.", a.bufSize, len(buf))
@@ -126,6 +235,7 @@ func (a *analyzer) BufferCallback(buf Buffer) {
 
 	// update volume normaliser
 	a.Vol.update(aubio.DbSpl(a.buf))
+	a.detectSilence()
 
 	// Perform FFT of each audio stream
 	a.eq.DoOutplace(a.buf)
@@ -144,14 +254,22 @@ func (a *analyzer) BufferCallback(buf Buffer) {
 }
 
 func (a *analyzer) Cleanup() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 	a.eq.Free()
 	a.buf.Free()
 	a.onset.Free()
 	a.pvoc.Free()
 
 	for id := range a.melbanks {
-		a.DeleteMelbank(id)
+		a.deleteMelbank(id)
 	}
+	a.freeStereo()
+	if a.recorder != nil {
+		a.recorder.Close()
+		a.recorder = nil
+	}
+	a.freed = true
 }
 
 // convenience method to get the melbank data
@@ -161,28 +279,49 @@ func (a *analyzer) GetMelbankData(id string) ([]float64, error) {
 }
 
 func (a *analyzer) GetMelbank(id string) (mb *melbank, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 	mb, ok := a.melbanks[id]
 	if !ok {
-		err = fmt.Errorf("cannot find melbank registered for effect %s", id)
+		err = fmt.Errorf("cannot find melbank registered for effect %s on audio source %s", id, a.ID)
 	}
 	return mb, err
 }
 
 func (a *analyzer) DeleteMelbank(id string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.deleteMelbank(id)
+}
+
+func (a *analyzer) deleteMelbank(id string) {
 	if mb, ok := a.melbanks[id]; ok {
 		log.Logger.WithField("context", "Audio Analysis").Debugf("Deleted melbank for effect %s", id)
 		mb.Free()
 		delete(a.melbanks, id)
 	}
+	if a.stereo != nil {
+		for _, ca := range a.stereo.channels {
+			if ca != nil {
+				ca.deleteMelbank(id)
+			}
+		}
+	}
 }
 
 func (a *analyzer) NewMelbank(id string, min_freq, max_freq uint, intensity float64) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.newMelbank(id, min_freq, max_freq, intensity)
+}
+
+func (a *analyzer) newMelbank(id string, min_freq, max_freq uint, intensity float64) error {
 	// if a melbank is already registered to this effect id, kill it and warn
 	if _, ok := a.melbanks[id]; ok {
 		log.Logger.WithField("context", "Audio Analysis").Debugf("Effect %s attempted to create a new melbank but already has one registered", id)
-		a.DeleteMelbank(id)
+		a.deleteMelbank(id)
 	}
-	mb, err := newMelbank(min_freq, max_freq, intensity, a.bufSize)
+	mb, err := newMelbank(min_freq, max_freq, intensity, a.fftSize, a.sampleRate)
 	if err == nil {
 		log.Logger.WithField("context", "Audio Analysis").Debugf("Registered new melbank for effect %s", id)
 		a.melbanks[id] = mb
@@ -195,9 +334,15 @@ type volumeStream struct {
 	normStream  stream
 	Volume      float64
 	Timestep    float64
+	Level       float64 // level of the latest buffer in dB
 }
 
-func NewVolumeStream() volumeStream {
+// Creates a volume stream for audio analysed refreshRate times per second
+func NewVolumeStream(refreshRate float64) volumeStream {
+	normStreamSlowLen := refreshRate * 3
+	normStreamFastLen := refreshRate * 2
+	reactStreamSlowLen := refreshRate * 3
+	reactStreamFastLen := math.Max(refreshRate*0.05, 1)
 	return volumeStream{
 		reactStream: newStream(int(reactStreamFastLen), int(reactStreamSlowLen)),
 		normStream:  newStream(int(normStreamFastLen), int(normStreamSlowLen)),
@@ -207,6 +352,7 @@ func NewVolumeStream() volumeStream {
 }
 
 func (vs *volumeStream) update(volume float64) {
+	vs.Level = volume
 	vs.reactStream.update(volume)
 	vs.normStream.update(volume)
 	vs.Volume = math.Min(vs.reactStream.volume*vs.normStream.volume+1e-5, 1) // 0 < vol <= 1
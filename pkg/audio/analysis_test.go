@@ -1,7 +1,18 @@
 package audio
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/LedFx/ledfx/pkg/api"
+	"github.com/LedFx/ledfx/pkg/config"
+	"github.com/LedFx/ledfx/pkg/event"
 )
 
 var testAudio Buffer = make(Buffer, 735)
@@ -19,3 +30,262 @@ func BenchmarkAnalysis(t *testing.B) {
 	}
 	Analyzer.Cleanup()
 }
+
+func TestAnalysisConfigure(t *testing.T) {
+	Analyzer.NewMelbank("totally_valid_id", uint(20), uint(20000), 0.7)
+	c := Analyzer.config
+	c.FftSize = 2048
+	c.EqA1 = -1.5
+	Analyzer.Configure(c)
+	if Analyzer.fftSize != 2048 {
+		t.Errorf("Analyzer did not apply fft size: expected 2048 but got %d", Analyzer.fftSize)
+	}
+	if _, err := Analyzer.GetMelbank("totally_valid_id"); err != nil {
+		t.Error("Melbanks should survive reinitialisation")
+	}
+	Analyzer.SetSampleRate(48000)
+	if Analyzer.SampleRate() != 48000 {
+		t.Errorf("Analyzer did not apply source sample rate: expected 48000 but got %d", Analyzer.SampleRate())
+	}
+	Analyzer.BufferCallback(testAudio)
+	Analyzer.Cleanup()
+}
+
+func TestConfigureWhileReading(t *testing.T) {
+	a, err := NewAnalyzer("configured")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer DeleteAnalyzer("configured")
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			a.SetSampleRate(uint(44100 + i%2*3900))
+		}
+	}()
+	for {
+		select {
+		case <-done:
+			if a.RefreshRate() != float64(a.SampleRate())/float64(a.BufferSize()) {
+				t.Error("Expected the refresh rate to match the sample rate and buffer size")
+			}
+			return
+		default:
+			a.RefreshRate()
+			a.SampleRate()
+			a.BufferSize()
+		}
+	}
+}
+
+func TestAudioAPI(t *testing.T) {
+	defer config.DisableSaving()()
+	prev := config.GetAudio()
+	defer config.SetAudio(map[string]interface{}{"fft_size": prev.FftSize})
+	mux := http.NewServeMux()
+	r := api.NewRouter("/api/v1")
+	NewV1API(r)
+	r.Serve(mux)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/api/v1/audio", strings.NewReader(`{"fft_size": 8192}`)))
+	info := analyzerInfo{}
+	if err := json.NewDecoder(w.Body).Decode(&info); err != nil {
+		t.Fatal(err)
+	}
+	if info.FftSize != 8192 {
+		t.Errorf("Expected the response to have the new fft size, got %d", info.FftSize)
+	}
+}
+
+func TestNamedAnalyzers(t *testing.T) {
+	if _, err := NewAnalyzer(DefaultSource); err == nil {
+		t.Error("Should not be able to replace the default analyzer")
+	}
+	a, err := NewAnalyzer("line_in")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if GetAnalyzer("line_in") != a {
+		t.Error("Did not get the named analyzer")
+	}
+	if GetAnalyzer("not_a_source") != Analyzer {
+		t.Error("Unknown sources should fall back to the default analyzer")
+	}
+	a.NewMelbank("totally_valid_id", uint(20), uint(20000), 0.7)
+	a.BufferCallback(testAudio)
+	DeleteMelbanks("totally_valid_id")
+	if _, err := a.GetMelbank("totally_valid_id"); err == nil {
+		t.Error("Melbank should be deleted from every analyzer")
+	}
+	DeleteAnalyzer("line_in")
+	if GetAnalyzer("line_in") != Analyzer {
+		t.Error("Deleted analyzer should fall back to the default analyzer")
+	}
+}
+
+func TestStereoAnalysis(t *testing.T) {
+	a, err := NewAnalyzer("stereo_in")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer DeleteAnalyzer("stereo_in")
+	if a.IsStereo() {
+		t.Error("Analyzer should be mono until stereo audio arrives")
+	}
+	a.NewMelbank("totally_valid_id", uint(20), uint(20000), 0.7)
+	left := make(Buffer, a.BufferSize())
+	right := make(Buffer, a.BufferSize())
+	for i := range left {
+		left[i] = int16((i % 64) * 200)
+	}
+	for i := 0; i < 10; i++ {
+		a.BufferCallback(left)
+		a.StereoBufferCallback(left, right)
+	}
+	if !a.IsStereo() {
+		t.Fatal("Analyzer should be stereo after stereo audio arrives")
+	}
+	if a.Balance() >= 0 {
+		t.Errorf("Balance should lean left with a silent right channel, got %f", a.Balance())
+	}
+	if a.Width() <= 0 {
+		t.Errorf("Width should be positive for different channels, got %f", a.Width())
+	}
+	if _, err := a.GetChannelMelbank("totally_valid_id", Right); err != nil {
+		t.Error(err)
+	}
+	if _, err := a.GetChannelMelbank("totally_valid_id", 2); err == nil {
+		t.Error("Should not get a melbank for an invalid channel")
+	}
+	stereo := Buffer{1, 3, 5, 7}
+	l, r := stereo.SplitStereo()
+	if l[0] != 1 || l[1] != 5 || r[0] != 3 || r[1] != 7 {
+		t.Errorf("Stereo buffer split incorrectly: %v %v", l, r)
+	}
+	if mono := stereo.DownmixStereo(); mono[0] != 2 || mono[1] != 6 {
+		t.Errorf("Stereo buffer downmixed incorrectly: %v", mono)
+	}
+}
+
+func TestRecordingReplay(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test"+recordingExt)
+	a, err := NewAnalyzer("recorded")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer DeleteAnalyzer("recorded")
+	if err = a.StartRecording(filename); err != nil {
+		t.Fatal(err)
+	}
+	buf := make(Buffer, a.BufferSize())
+	for i := 0; i < 5; i++ {
+		buf[0] = int16(i)
+		a.BufferCallback(buf)
+	}
+	count, err := a.StopRecording()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 5 {
+		t.Errorf("Expected 5 recorded buffers but got %d", count)
+	}
+
+	rec, err := LoadRecording(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.SampleRate != a.SampleRate() {
+		t.Errorf("Expected recording sample rate %d but got %d", a.SampleRate(), rec.SampleRate)
+	}
+	if len(rec.Buffers) != 5 {
+		t.Fatalf("Expected 5 buffers in recording but got %d", len(rec.Buffers))
+	}
+	for i, rb := range rec.Buffers {
+		if rb.Buffer[0] != int16(i) || len(rb.Buffer) != a.BufferSize() {
+			t.Errorf("Buffer %d was not recorded faithfully", i)
+		}
+		if i > 0 && rb.Offset < rec.Buffers[i-1].Offset {
+			t.Errorf("Buffer %d has a timestamp before the previous buffer", i)
+		}
+	}
+
+	// replaying as fast as possible gives every buffer, in order
+	played := []int16{}
+	rec.Play(func(b Buffer) { played = append(played, b[0]) }, 0, make(chan struct{}))
+	for i, x := range played {
+		if x != int16(i) {
+			t.Errorf("Buffer %d replayed out of order", i)
+		}
+	}
+	if len(played) != 5 {
+		t.Errorf("Expected 5 replayed buffers but got %d", len(played))
+	}
+
+	// a corrupt sample count is refused rather than allocated
+	corrupt := filepath.Join(t.TempDir(), "corrupt"+recordingExt)
+	header := append([]byte(recordingMagic), 0x44, 0xac, 0, 0)
+	buffer := []byte{0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff, 0xff, 0xff}
+	if err = os.WriteFile(corrupt, append(header, buffer...), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = LoadRecording(corrupt); err == nil || !strings.Contains(err.Error(), "larger than") {
+		t.Errorf("Expected an oversized buffer to be refused, got %v", err)
+	}
+}
+
+func TestSilenceDetection(t *testing.T) {
+	a, err := NewAnalyzer("silence")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer DeleteAnalyzer("silence")
+	started := make(chan bool, 1)
+	ended := make(chan bool, 1)
+	unsubStart := event.Subscribe(event.SilenceStart, func(e *event.Event) {
+		if e.Data.(event.SilenceStartData).Source == "silence" {
+			started <- true
+		}
+	})
+	defer unsubStart()
+	unsubEnd := event.Subscribe(event.SilenceEnd, func(e *event.Event) {
+		if e.Data.(event.SilenceEndData).Source == "silence" {
+			ended <- true
+		}
+	})
+	defer unsubEnd()
+
+	defer config.DisableSaving()()
+	buf := make(Buffer, a.BufferSize())
+	// every buffer is below the threshold, so one window of buffers makes silence
+	config.SetSilence(map[string]interface{}{"threshold": 0, "window": 1})
+	window := int(a.RefreshRate())
+	for i := 0; i < window-1; i++ {
+		a.BufferCallback(buf)
+	}
+	if a.IsSilent() {
+		t.Error("Analyzer should not be silent before the window has passed")
+	}
+	a.BufferCallback(buf)
+	a.BufferCallback(buf)
+	if !a.IsSilent() {
+		t.Fatal("Analyzer should be silent after the window has passed")
+	}
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Error("Silence start event was not invoked")
+	}
+
+	// every buffer is above the threshold, so audio returns straight away
+	config.SetSilence(map[string]interface{}{"threshold": -120})
+	a.BufferCallback(buf)
+	if a.IsSilent() {
+		t.Error("Analyzer should not be silent once audio returns")
+	}
+	select {
+	case <-ended:
+	case <-time.After(time.Second):
+		t.Error("Silence end event was not invoked")
+	}
+}
@@ -0,0 +1,85 @@
+package audio
+
+import (
+	"fmt"
+	"sync"
+
+	log "github.com/LedFx/ledfx/pkg/logger"
+)
+
+// ID of the audio source fed by the audio bridge. Always exists.
+const DefaultSource = "default"
+
+// maps audio source ids to the analyzer fed by that source
+var analyzers = map[string]*analyzer{}
+var analyzersMu sync.Mutex
+
+// Creates an analyzer for a named audio source.
+// If an analyzer exists with this id, it will be destroyed and replaced.
+func NewAnalyzer(id string) (*analyzer, error) {
+	if id == "" || id == DefaultSource {
+		return nil, fmt.Errorf("audio source id must not be empty or '%s'", DefaultSource)
+	}
+	DeleteAnalyzer(id)
+	a := newAnalyzer(id)
+	analyzersMu.Lock()
+	analyzers[id] = a
+	analyzersMu.Unlock()
+	log.Logger.WithField("context", "Audio Analysis").Infof("Created analyzer for audio source %s", id)
+	return a, nil
+}
+
+// Get the analyzer for an audio source.
+// Falls back to the default analyzer if the source does not exist, so effects always have audio.
+func GetAnalyzer(id string) *analyzer {
+	analyzersMu.Lock()
+	defer analyzersMu.Unlock()
+	if a, ok := analyzers[id]; ok {
+		return a
+	}
+	return Analyzer
+}
+
+// Kill the analyzer for an audio source. The default analyzer cannot be deleted.
+func DeleteAnalyzer(id string) {
+	if id == DefaultSource {
+		return
+	}
+	analyzersMu.Lock()
+	a, ok := analyzers[id]
+	delete(analyzers, id)
+	analyzersMu.Unlock()
+	if !ok {
+		return
+	}
+	a.Cleanup()
+	log.Logger.WithField("context", "Audio Analysis").Infof("Deleted analyzer for audio source %s", id)
+}
+
+// Get the ids of all audio sources with an analyzer, including the default
+func GetAnalyzerIDs() []string {
+	analyzersMu.Lock()
+	defer analyzersMu.Unlock()
+	ids := []string{}
+	for id := range analyzers {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Deletes the melbank registered for an effect on every analyzer
+func DeleteMelbanks(id string) {
+	for _, a := range getAnalyzers() {
+		a.DeleteMelbank(id)
+	}
+}
+
+func getAnalyzers() []*analyzer {
+	analyzersMu.Lock()
+	defer analyzersMu.Unlock()
+	as := make([]*analyzer, 0, len(analyzers))
+	for _, a := range analyzers {
+		as = append(as, a)
+	}
+	return as
+}
@@ -0,0 +1,201 @@
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/LedFx/ledfx/pkg/api"
+	"github.com/LedFx/ledfx/pkg/config"
+	"github.com/LedFx/ledfx/pkg/logger"
+)
+
+type buffersJSON struct {
+	Buffers int `json:"buffers" description:"Buffers recorded"`
+}
+
+// The audio config, along with the values the analyzer is actually running with
+type analyzerInfo struct {
+	Config      config.AudioConfig `json:"config"`
+	SampleRate  uint               `json:"sample_rate"`
+	BufferSize  int                `json:"buffer_size"`
+	FftSize     uint               `json:"fft_size"`
+	RefreshRate float64            `json:"refresh_rate"`
+	Stereo      bool               `json:"stereo"`
+	Silent      bool               `json:"silent"`
+}
+
+func (a *analyzer) info() analyzerInfo {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return analyzerInfo{
+		Config:      a.config,
+		SampleRate:  a.sampleRate,
+		BufferSize:  a.bufSize,
+		FftSize:     a.fftSize,
+		RefreshRate: a.refreshRate(),
+		Stereo:      a.stereoActive(),
+		Silent:      a.silence.silent,
+	}
+}
+
+type recordingRequest struct {
+	Source string `json:"source"`
+	Name   string `json:"name"`
+}
+
+type replayRequest struct {
+	ID    string  `json:"id"`
+	Name  string  `json:"name"`
+	Speed float64 `json:"speed"`
+	Loop  bool    `json:"loop"`
+}
+
+func NewV1API(r *api.Router) {
+	schema, err := config.AudioSchema()
+	if err != nil {
+		logger.Logger.WithField("context", "Audio API").Error(err)
+	}
+	r.Schema("AudioConfig", api.ConfigSchema(schema))
+	infoSchema := api.TypeSchema(analyzerInfo{})
+	infoSchema["properties"].(map[string]interface{})["config"] = api.Ref("AudioConfig")
+	r.Schema("Audio", infoSchema)
+	schema, err = config.SilenceSchema()
+	if err != nil {
+		logger.Logger.WithField("context", "Silence API").Error(err)
+	}
+	r.Schema("SilenceConfig", api.ConfigSchema(schema))
+
+	r.Get("/audio", func(*api.Request) (interface{}, error) {
+		return Analyzer.info(), nil
+	}).Doc("Audio", "Get the audio config and the values the analyzer is running with").Returns(api.Ref("Audio"))
+
+	r.Put("/audio", func(req *api.Request) (interface{}, error) {
+		c := map[string]interface{}{}
+		if err := req.Decode(&c); err != nil {
+			return nil, err
+		}
+		if err := config.SetAudio(c); err != nil {
+			return nil, api.BadRequest(err)
+		}
+		// configured here to respond with what the analyzers run with, as subscribers apply the config later
+		configureAnalyzers()
+		return Analyzer.info(), nil
+	}).Doc("Audio", "Update the audio config. A changed buffer size shows once the capture restarts").
+		Accepts(api.Ref("AudioConfig")).Returns(api.Ref("Audio"))
+
+	r.Get("/audio/schema", func(*api.Request) (interface{}, error) {
+		return config.AudioSchema()
+	}).Doc("Audio", "Get the schema of the audio config, for building forms").Returns(map[string]interface{}{"type": "object"})
+
+	r.Get("/audio/silence", func(*api.Request) (interface{}, error) {
+		return config.GetSilence(), nil
+	}).Doc("Audio", "Get the silence detection config").Returns(api.Ref("SilenceConfig"))
+
+	r.Put("/audio/silence", func(req *api.Request) (interface{}, error) {
+		c := map[string]interface{}{}
+		if err := req.Decode(&c); err != nil {
+			return nil, err
+		}
+		if err := config.SetSilence(c); err != nil {
+			return nil, api.BadRequest(err)
+		}
+		return config.GetSilence(), nil
+	}).Doc("Audio", "Update the silence detection config").
+		Accepts(api.Ref("SilenceConfig")).Returns(api.Ref("SilenceConfig"))
+
+	r.Get("/audio/silence/schema", func(*api.Request) (interface{}, error) {
+		return config.SilenceSchema()
+	}).Doc("Audio", "Get the schema of the silence detection config, for building forms").Returns(map[string]interface{}{"type": "object"})
+
+	r.Get("/audio/recordings", func(*api.Request) (interface{}, error) {
+		return GetRecordingNames()
+	}).Doc("Audio", "Get the names of saved recordings").Returns(api.ArrayOf(map[string]interface{}{"type": "string"}))
+
+	r.Post("/audio/recordings", func(req *api.Request) (interface{}, error) {
+		data := recordingRequest{Source: DefaultSource}
+		if err := req.Decode(&data); err != nil {
+			return nil, err
+		}
+		if !hasSource(data.Source) {
+			return nil, api.BadRequest(fmt.Errorf("audio source %s does not exist", data.Source))
+		}
+		filename, err := RecordingPath(data.Name)
+		if err != nil {
+			return nil, api.BadRequest(err)
+		}
+		if err = GetAnalyzer(data.Source).StartRecording(filename); err != nil {
+			return nil, err
+		}
+		return data, nil
+	}).Doc("Audio", "Start recording an audio source, replacing any recording of it").
+		Accepts(api.TypeSchema(recordingRequest{})).Returns(api.TypeSchema(recordingRequest{})).Status(http.StatusCreated)
+
+	r.Delete("/audio/recordings", func(req *api.Request) (interface{}, error) {
+		source := req.URL.Query().Get("source")
+		if source == "" {
+			source = DefaultSource
+		}
+		if !hasSource(source) {
+			return nil, api.NotFound(fmt.Errorf("audio source %s does not exist", source))
+		}
+		count, err := GetAnalyzer(source).StopRecording()
+		if err != nil {
+			return nil, err
+		}
+		return buffersJSON{Buffers: count}, nil
+	}).Doc("Audio", "Stop recording an audio source").Query("source", "Audio source to stop recording, the default source if not given").
+		Returns(api.TypeSchema(buffersJSON{})).Status(http.StatusOK)
+
+	r.Get("/audio/replays", func(*api.Request) (interface{}, error) {
+		return GetReplayIDs(), nil
+	}).Doc("Audio", "Get the ids of running replays").Returns(api.ArrayOf(map[string]interface{}{"type": "string"}))
+
+	r.Post("/audio/replays", func(req *api.Request) (interface{}, error) {
+		data := replayRequest{Speed: 1}
+		if err := req.Decode(&data); err != nil {
+			return nil, err
+		}
+		filename, err := RecordingPath(data.Name)
+		if err != nil {
+			return nil, api.BadRequest(err)
+		}
+		rec, err := LoadRecording(filename)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, api.NotFound(fmt.Errorf("recording %s does not exist", data.Name))
+		}
+		if err != nil {
+			return nil, api.BadRequest(err)
+		}
+		if err = StartReplay(data.ID, rec, data.Speed, data.Loop); err != nil {
+			return nil, api.BadRequest(err)
+		}
+		return data, nil
+	}).Doc("Audio", "Replay a recording as a named audio source").
+		Accepts(api.TypeSchema(replayRequest{})).Returns(api.TypeSchema(replayRequest{})).Status(http.StatusCreated)
+
+	r.Delete("/audio/replays/{id}", func(req *api.Request) (interface{}, error) {
+		id := req.Param("id")
+		for _, running := range GetReplayIDs() {
+			if running == id {
+				StopReplay(id)
+				return nil, nil
+			}
+		}
+		return nil, api.NotFound(fmt.Errorf("replay %s does not exist", id))
+	}).Doc("Audio", "Stop a replay")
+}
+
+// whether there's an analyzer for the audio source
+func hasSource(id string) bool {
+	if id == DefaultSource {
+		return true
+	}
+	for _, a := range GetAnalyzerIDs() {
+		if a == id {
+			return true
+		}
+	}
+	return false
+}
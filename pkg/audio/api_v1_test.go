@@ -0,0 +1,39 @@
+package audio
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/LedFx/ledfx/pkg/api"
+	"github.com/LedFx/ledfx/pkg/api/apitest"
+	"github.com/LedFx/ledfx/pkg/config"
+)
+
+func TestV1API(t *testing.T) {
+	defer config.DisableSaving()()
+	prev := config.GetAudio()
+	defer config.SetAudio(map[string]interface{}{"fft_size": prev.FftSize})
+	r := api.NewRouter("/api/v1")
+	NewV1API(r)
+
+	apitest.Run(t, r, []apitest.Case{
+		{Method: http.MethodGet, Path: "/api/v1/audio", Code: http.StatusOK},
+		{Method: http.MethodPut, Path: "/api/v1/audio", Body: `{"fft_size": 8192}`, Code: http.StatusOK},
+		{Method: http.MethodPut, Path: "/api/v1/audio", Body: `{"fft_size": 3}`, Code: http.StatusBadRequest},
+		{Method: http.MethodGet, Path: "/api/v1/audio/schema", Code: http.StatusOK},
+		{Method: http.MethodGet, Path: "/api/v1/audio/silence", Code: http.StatusOK},
+		{Method: http.MethodGet, Path: "/api/v1/audio/silence/schema", Code: http.StatusOK},
+		{Method: http.MethodGet, Path: "/api/v1/audio/recordings", Code: http.StatusOK},
+		{Method: http.MethodPost, Path: "/api/v1/audio/recordings", Body: `{"source": "nope", "name": "test"}`, Code: http.StatusBadRequest},
+		{Method: http.MethodPost, Path: "/api/v1/audio/recordings", Body: `{"name": "../test"}`, Code: http.StatusBadRequest},
+		{Method: http.MethodDelete, Path: "/api/v1/audio/recordings?source=nope", Code: http.StatusNotFound},
+		{Method: http.MethodDelete, Path: "/api/v1/audio/recordings", Code: http.StatusOK},
+		{Method: http.MethodGet, Path: "/api/v1/audio/replays", Code: http.StatusOK},
+		{Method: http.MethodPost, Path: "/api/v1/audio/replays", Body: `{"id": "replayed", "name": "v1_missing"}`, Code: http.StatusNotFound},
+		{Method: http.MethodDelete, Path: "/api/v1/audio/replays/nope", Code: http.StatusNotFound},
+	})
+	if fft := Analyzer.info().FftSize; fft != 8192 {
+		t.Errorf("Expected the new fft size to be applied, got %d", fft)
+	}
+	apitest.Documents(t, r, "/api/v1", []string{"/audio", "/audio/replays/{id}"}, []string{"Audio", "AudioConfig", "SilenceConfig"})
+}
@@ -15,7 +15,7 @@ import (
 func init() {
 	err := portaudio.Initialize()
 	if err != nil {
-		log.Logger.Fatalf("PortAudio Initialization: %w", err)
+		log.Logger.Fatalf("PortAudio Initialization: %v", err)
 	}
 }
 
@@ -250,6 +250,28 @@ func (b Buffer) Stereo2Mono() Buffer {
 	return mono
 }
 
+// Splits an interleaved stereo buffer into its left and right channels
+func (b Buffer) SplitStereo() (left, right Buffer) {
+	monoLen := len(b) / 2
+	left = Buffer(make([]int16, monoLen))
+	right = Buffer(make([]int16, monoLen))
+	for i := 0; i < monoLen; i++ {
+		left[i] = b[i*2]
+		right[i] = b[(i*2)+1]
+	}
+	return left, right
+}
+
+// Averages an interleaved stereo buffer down to mono
+func (b Buffer) DownmixStereo() Buffer {
+	monoLen := len(b) / 2
+	mono := Buffer(make([]int16, monoLen))
+	for i := 0; i < monoLen; i++ {
+		mono[i] = int16((int32(b[i*2]) + int32(b[(i*2)+1])) / 2)
+	}
+	return mono
+}
+
 func (b Buffer) WriteTo(filename string) error {
 	fi, err := os.OpenFile(filename, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0777)
 	if err != nil {
@@ -8,6 +8,8 @@ import (
 )
 
 func (br *Bridge) StartAirPlayInput(name string, port int) error {
+	br.mu.Lock()
+	defer br.mu.Unlock()
 	if br.inputType != -1 {
 		br.closeInput()
 	}
@@ -25,11 +27,12 @@ func (br *Bridge) StartAirPlayInput(name string, port int) error {
 	if err := br.airplay.server.Start(); err != nil {
 		return fmt.Errorf("error starting AirPlay server: %w", err)
 	}
+	br.reportSampleRate(streamSampleRate)
 	return nil
 }
 
 func (br *Bridge) AddAirPlayOutput(searchKey string, searchType AirPlaySearchType) error {
-	if br.inputType == -1 {
+	if br.input() == -1 {
 		return fmt.Errorf("an input source is required before an output source can be initialized")
 	}
 
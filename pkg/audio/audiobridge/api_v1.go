@@ -0,0 +1,39 @@
+package audiobridge
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/LedFx/ledfx/pkg/api"
+)
+
+func NewV1API(r *api.Router) {
+	r.Schema("AudioSource", api.TypeSchema(SourceInfo{}))
+
+	r.Get("/audio/sources", func(*api.Request) (interface{}, error) {
+		return GetSources(), nil
+	}).Doc("Audio", "Get the running audio sources").Returns(api.ArrayOf(api.Ref("AudioSource")))
+
+	r.Post("/audio/sources", func(req *api.Request) (interface{}, error) {
+		info := SourceInfo{}
+		if err := req.Decode(&info); err != nil {
+			return nil, err
+		}
+		if err := StartSource(info.ID, info.DeviceID); err != nil {
+			return nil, api.BadRequest(err)
+		}
+		return info, nil
+	}).Doc("Audio", "Start an audio source on a local device, replacing any source with its id").
+		Accepts(api.Ref("AudioSource")).Returns(api.Ref("AudioSource")).Status(http.StatusCreated)
+
+	r.Delete("/audio/sources/{id}", func(req *api.Request) (interface{}, error) {
+		id := req.Param("id")
+		for _, s := range GetSources() {
+			if s.ID == id {
+				StopSource(id)
+				return nil, nil
+			}
+		}
+		return nil, api.NotFound(fmt.Errorf("audio source %s does not exist", id))
+	}).Doc("Audio", "Stop an audio source")
+}
@@ -0,0 +1,23 @@
+package audiobridge
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/LedFx/ledfx/pkg/api"
+	"github.com/LedFx/ledfx/pkg/api/apitest"
+	"github.com/LedFx/ledfx/pkg/config"
+)
+
+func TestV1API(t *testing.T) {
+	defer config.DisableSaving()()
+	r := api.NewRouter("/api/v1")
+	NewV1API(r)
+
+	apitest.Run(t, r, []apitest.Case{
+		{Method: http.MethodGet, Path: "/api/v1/audio/sources", Code: http.StatusOK},
+		{Method: http.MethodPost, Path: "/api/v1/audio/sources", Body: `{"id": "v1_source"}`, Code: http.StatusBadRequest},
+		{Method: http.MethodDelete, Path: "/api/v1/audio/sources/nope", Code: http.StatusNotFound},
+	})
+	apitest.Documents(t, r, "/api/v1", []string{"/audio/sources", "/audio/sources/{id}"}, []string{"AudioSource"})
+}
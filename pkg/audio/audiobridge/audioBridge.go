@@ -5,9 +5,13 @@ import (
 
 	"github.com/LedFx/ledfx/pkg/audio"
 	"github.com/LedFx/ledfx/pkg/audio/audiobridge/assets"
+	"github.com/LedFx/ledfx/pkg/event"
 	log "github.com/LedFx/ledfx/pkg/logger"
 )
 
+// YouTube and AirPlay audio always arrives at this sample rate
+const streamSampleRate uint = 44100
+
 // NewBridge initializes a new bridge between a source and destination audio device.
 func NewBridge(bufferCallback func(buf audio.Buffer)) (br *Bridge, err error) {
 	br = &Bridge{
@@ -29,9 +33,27 @@ func NewBridge(bufferCallback func(buf audio.Buffer)) (br *Bridge, err error) {
 	}
 
 	br.ctl = br.newController()
+	// local capture needs to be reopened if the audio config changes its stream parameters
+	br.unsubAudio = event.Subscribe(event.AudioUpdate, br.handleAudioUpdate)
 	return br, nil
 }
 
+// SetSampleRateCallback sets a callback which is told the sample rate of the input whenever an input is started.
+func (br *Bridge) SetSampleRateCallback(cb func(rate uint)) {
+	br.sampleRateCallback = cb
+}
+
+// SetStereoCallback sets a callback which is given the left and right channels of stereo local captures.
+func (br *Bridge) SetStereoCallback(cb func(left, right audio.Buffer)) {
+	br.stereoCallback = cb
+}
+
+func (br *Bridge) reportSampleRate(rate uint) {
+	if br.sampleRateCallback != nil {
+		br.sampleRateCallback(rate)
+	}
+}
+
 func (cbw *CallbackWrapper) Write(p []byte) (int, error) {
 	cbw.Callback(audio.BytesToAudioBuffer(p))
 	return len(p), nil
@@ -52,6 +74,13 @@ func (br *Bridge) Stop() {
 			br.done <- true
 		}()
 	}()
+	if br.unsubAudio != nil {
+		br.unsubAudio()
+	}
+	// an audio update already being handled mustn't reopen the capture once it's stopped
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	br.stopped = true
 	if br.airplay != nil {
 		log.Logger.WithField("context", "Audio Bridge").Warnf("Stopping AirPlay handler...")
 		br.airplay.Stop()
@@ -63,6 +92,14 @@ func (br *Bridge) Stop() {
 	}
 }
 
+// the input type, for callers not holding the lock
+func (br *Bridge) input() inputType {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	return br.inputType
+}
+
+// Caller must hold the lock.
 func (br *Bridge) closeInput() {
 	switch br.inputType {
 	case inputTypeAirPlayServer:
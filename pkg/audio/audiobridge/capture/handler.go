@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/LedFx/ledfx/pkg/audio"
+	"github.com/LedFx/ledfx/pkg/config"
 	log "github.com/LedFx/ledfx/pkg/logger"
 
 	"github.com/LedFx/portaudio"
@@ -11,11 +12,17 @@ import (
 
 type Handler struct {
 	*portaudio.Stream
-	byteWriter *audio.AsyncMultiWriter
-	stopped    bool
+	byteWriter     *audio.AsyncMultiWriter
+	stereoCallback func(left, right audio.Buffer)
+	sampleRate     float64
+	bufferSize     int
+	channels       int
+	stopped        bool
 }
 
-func NewHandler(id string, byteWriter *audio.AsyncMultiWriter) (h *Handler, err error) {
+// Opens a capture on a local device. If stereo capture is enabled in the audio config and the device
+// supports it, stereoCallback is given each channel, while the byte writer still gets the mono downmix.
+func NewHandler(id string, byteWriter *audio.AsyncMultiWriter, stereoCallback func(left, right audio.Buffer)) (h *Handler, err error) {
 	audioDevice, err := audio.GetDeviceByID(id)
 	if err != nil {
 		return nil, err
@@ -26,23 +33,41 @@ func NewHandler(id string, byteWriter *audio.AsyncMultiWriter) (h *Handler, err
 		return nil, fmt.Errorf("error getting PortAudio device info: %w", err)
 	}
 
+	// use the device's own sample rate unless the config asks for a specific one
+	audioConfig := config.GetAudio()
+	h = &Handler{
+		byteWriter:     byteWriter,
+		stereoCallback: stereoCallback,
+		sampleRate:     dev.DefaultSampleRate,
+		bufferSize:     audioConfig.BufferSize,
+		channels:       1,
+	}
+	if audioConfig.SampleRate != 0 {
+		h.sampleRate = float64(audioConfig.SampleRate)
+	}
+	callback := h.monoCallback
+	if audioConfig.Stereo {
+		if dev.MaxInputChannels >= 2 {
+			h.channels = 2
+			callback = h.stereoBufferCallback
+		} else {
+			log.Logger.WithField("context", "Local Capture Init").Warnf("Device '%s' has %d input channel(s). Capturing mono.", audioDevice.Name, dev.MaxInputChannels)
+		}
+	}
+
 	p := portaudio.StreamParameters{
 		Input: portaudio.StreamDeviceParameters{
 			Device:   dev,
-			Channels: 1,
+			Channels: h.channels,
 			Latency:  0,
 		},
 		Output:          portaudio.StreamDeviceParameters{},
-		SampleRate:      dev.DefaultSampleRate,
-		FramesPerBuffer: int(audio.BufferSize),
+		SampleRate:      h.sampleRate,
+		FramesPerBuffer: h.bufferSize,
 	}
 
-	h = &Handler{
-		byteWriter: byteWriter,
-	}
-
-	log.Logger.WithField("context", "Local Capture Init").Debugf("Opening stream...")
-	if h.Stream, err = portaudio.OpenStream(p, h.monoCallback); err != nil {
+	log.Logger.WithField("context", "Local Capture Init").Debugf("Opening stream... (%dCH, %d samples @%vhz)", h.channels, h.bufferSize, h.sampleRate)
+	if h.Stream, err = portaudio.OpenStream(p, callback); err != nil {
 		return nil, fmt.Errorf("error opening stream: %w", err)
 	}
 
@@ -58,6 +83,14 @@ func (h *Handler) monoCallback(in audio.Buffer) {
 	h.byteWriter.Write(in.AsBytes())
 }
 
+// Stereo buffers arrive interleaved
+func (h *Handler) stereoBufferCallback(in audio.Buffer) {
+	h.byteWriter.Write(in.DownmixStereo().AsBytes())
+	if h.stereoCallback != nil {
+		h.stereoCallback(in.SplitStereo())
+	}
+}
+
 func (h *Handler) Quit() {
 	h.stopped = true
 	log.Logger.WithField("context", "Capture Handler").Debug("Aborting stream...")
@@ -70,3 +103,15 @@ func (h *Handler) Quit() {
 func (h *Handler) Stopped() bool {
 	return h.stopped
 }
+
+func (h *Handler) SampleRate() float64 {
+	return h.sampleRate
+}
+
+func (h *Handler) BufferSize() int {
+	return h.bufferSize
+}
+
+func (h *Handler) Channels() int {
+	return h.channels
+}
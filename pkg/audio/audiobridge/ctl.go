@@ -24,7 +24,7 @@ type Controller struct {
 }
 
 func (c *Controller) InputType() string {
-	return c.br.inputType.String()
+	return c.br.input().String()
 }
 func (c *Controller) Outputs() []OutputInfo {
 	outputs := make([]OutputInfo, len(c.br.outputs))
@@ -109,6 +109,8 @@ func (ytc *YoutubeController) IsPlaying() (bool, error) {
 
 // Local returns a *LocalController
 func (c *Controller) Local() *LocalController {
+	c.br.mu.Lock()
+	defer c.br.mu.Unlock()
 	return &LocalController{
 		handler: c.br.local,
 	}
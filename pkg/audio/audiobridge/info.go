@@ -11,7 +11,7 @@ type Info struct {
 }
 
 func (i *Info) InputType() string {
-	switch i.br.inputType {
+	switch input := i.br.input(); input {
 	case inputTypeYoutube:
 		return "youtube"
 	case inputTypeLocal:
@@ -21,7 +21,7 @@ func (i *Info) InputType() string {
 	case -1:
 		return "unspecified"
 	default:
-		return fmt.Sprintf("unknown (%d)", i.br.inputType)
+		return fmt.Sprintf("unknown (%d)", input)
 	}
 }
 
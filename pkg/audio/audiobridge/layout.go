@@ -2,6 +2,7 @@ package audiobridge
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/LedFx/ledfx/pkg/audio"
 )
@@ -9,13 +10,19 @@ import (
 // Bridge can wire up an audio source to multiple destinations
 // seamlessly and with minimal delay.
 type Bridge struct {
+	// guards the input and local handler. API calls and audio config updates arrive on different goroutines
+	mu        sync.Mutex
 	inputType inputType
+	stopped   bool
 
-	bufferCallback func(buf audio.Buffer)
-	byteWriter     *audio.AsyncMultiWriter
+	bufferCallback     func(buf audio.Buffer)
+	sampleRateCallback func(rate uint)
+	stereoCallback     func(left, right audio.Buffer)
+	byteWriter         *audio.AsyncMultiWriter
+	unsubAudio         func()
 
 	airplay *AirPlayHandler
-	local   *LocalHandler
+	local   *LocalHandler // guarded by mu
 	youtube *YoutubeHandler
 
 	ctl *Controller
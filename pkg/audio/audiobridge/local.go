@@ -6,12 +6,15 @@ import (
 	"github.com/LedFx/ledfx/pkg/audio/audiobridge/capture"
 	"github.com/LedFx/ledfx/pkg/audio/audiobridge/playback"
 	"github.com/LedFx/ledfx/pkg/config"
+	"github.com/LedFx/ledfx/pkg/event"
 	log "github.com/LedFx/ledfx/pkg/logger"
 )
 
 type LocalHandler struct {
-	playback playback.Handler
-	capture  *capture.Handler
+	playback    playback.Handler
+	capture     *capture.Handler
+	audioConfig config.AudioConfig // audio config the capture was opened with
+	deviceID    string             // local device the capture was opened on
 }
 
 func newLocalHandler() *LocalHandler {
@@ -19,6 +22,18 @@ func newLocalHandler() *LocalHandler {
 }
 
 func (br *Bridge) StartLocalInput(id string) (err error) {
+	br.mu.Lock()
+	err = br.startLocalCapture(id)
+	br.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	config.SetLocalInput(id)
+	return nil
+}
+
+// Opens a capture on a local device without making it the configured local input. Caller must hold the lock.
+func (br *Bridge) startLocalCapture(id string) (err error) {
 	if br.inputType != -1 {
 		br.closeInput()
 	}
@@ -34,15 +49,37 @@ func (br *Bridge) StartLocalInput(id string) (err error) {
 	}
 
 	log.Logger.WithField("context", "Local Capture Init").Infof("Initializing new capture handler...")
-	if br.local.capture, err = capture.NewHandler(id, br.byteWriter); err != nil {
+	br.local.audioConfig = config.GetAudio()
+	br.local.deviceID = id
+	if br.local.capture, err = capture.NewHandler(id, br.byteWriter, br.stereoCallback); err != nil {
 		return fmt.Errorf("error initializing new capture handler: %w", err)
 	}
-	config.SetLocalInput(id)
+	br.reportSampleRate(uint(br.local.capture.SampleRate()))
 
 	return nil
 }
 
+// Reopens the local capture if the buffer size, sample rate or channels in the audio config have changed
+func (br *Bridge) handleAudioUpdate(e *event.Event) {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	if br.stopped || br.inputType != inputTypeLocal || br.local == nil || br.local.capture == nil {
+		return
+	}
+	c := config.GetAudio()
+	prev := br.local.audioConfig
+	if c.BufferSize == prev.BufferSize && c.SampleRate == prev.SampleRate && c.Stereo == prev.Stereo {
+		return
+	}
+	log.Logger.WithField("context", "Local Capture Init").Info("Audio config changed. Restarting capture...")
+	if err := br.startLocalCapture(br.local.deviceID); err != nil {
+		log.Logger.WithField("context", "Local Capture Init").Errorf("Error restarting local input: %v", err)
+	}
+}
+
 func (br *Bridge) AddLocalOutput() (err error) {
+	br.mu.Lock()
+	defer br.mu.Unlock()
 	if br.local == nil {
 		br.local = newLocalHandler()
 	}
@@ -0,0 +1,106 @@
+package audiobridge
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/LedFx/ledfx/pkg/audio"
+	"github.com/LedFx/ledfx/pkg/config"
+	log "github.com/LedFx/ledfx/pkg/logger"
+)
+
+// Named audio sources. Each source captures a local device through its own bridge,
+// feeding an analyzer of the same id. The default source is the main bridge and is not managed here.
+var sources = map[string]*Bridge{}
+var sourcesMu sync.Mutex
+
+type SourceInfo struct {
+	ID       string `json:"id"`
+	DeviceID string `json:"device_id"`
+}
+
+// Starts capturing a local device as a named audio source and saves it to config.
+// If a source exists with this id, it will be stopped and replaced.
+func StartSource(id, deviceID string) error {
+	if err := startSource(id, deviceID); err != nil {
+		return err
+	}
+	return config.SetAudioSource(id, deviceID)
+}
+
+func startSource(id, deviceID string) (err error) {
+	if deviceID == "" {
+		return fmt.Errorf("audio source %s needs a device id", id)
+	}
+	stopSource(id)
+	analyzer, err := audio.NewAnalyzer(id)
+	if err != nil {
+		return err
+	}
+	br, err := NewBridge(analyzer.BufferCallback)
+	if err != nil {
+		audio.DeleteAnalyzer(id)
+		return fmt.Errorf("error creating bridge for audio source %s: %w", id, err)
+	}
+	br.SetSampleRateCallback(analyzer.SetSampleRate)
+	br.SetStereoCallback(analyzer.StereoBufferCallback)
+	br.mu.Lock()
+	err = br.startLocalCapture(deviceID)
+	br.mu.Unlock()
+	if err != nil {
+		br.Stop()
+		audio.DeleteAnalyzer(id)
+		return fmt.Errorf("error starting audio source %s: %w", id, err)
+	}
+	sourcesMu.Lock()
+	sources[id] = br
+	sourcesMu.Unlock()
+	log.Logger.WithField("context", "Audio Sources").Infof("Started audio source %s on device %s", id, deviceID)
+	return nil
+}
+
+// Stops a named audio source and removes it from config.
+// Effects reacting to it fall back to the default source.
+func StopSource(id string) {
+	stopSource(id)
+	config.DeleteAudioSource(id)
+}
+
+func stopSource(id string) {
+	sourcesMu.Lock()
+	br, exists := sources[id]
+	delete(sources, id)
+	sourcesMu.Unlock()
+	if exists {
+		br.Stop()
+		log.Logger.WithField("context", "Audio Sources").Infof("Stopped audio source %s", id)
+	}
+	audio.DeleteAnalyzer(id)
+}
+
+// Get the running named audio sources
+func GetSources() []SourceInfo {
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
+	infos := []SourceInfo{}
+	for id, br := range sources {
+		info := SourceInfo{ID: id}
+		br.mu.Lock()
+		if br.local != nil {
+			info.DeviceID = br.local.deviceID
+		}
+		br.mu.Unlock()
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// Starts all audio sources saved in config.
+// A source which fails to start is logged and skipped, so a missing device doesn't stop LedFx loading.
+func LoadSourcesFromConfig() {
+	for id, deviceID := range config.GetAudioSources() {
+		if err := startSource(id, deviceID); err != nil {
+			log.Logger.WithField("context", "Audio Sources").Errorf("Error loading audio source %s: %v", id, err)
+		}
+	}
+}
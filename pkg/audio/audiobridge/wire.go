@@ -9,7 +9,7 @@ import (
 )
 
 func (br *Bridge) wireAirPlayOutput(client *airplay2.Client) (err error) {
-	switch br.inputType {
+	switch input := br.input(); input {
 	case -1:
 		err = fmt.Errorf("input source has not been defined")
 	case inputTypeAirPlayServer:
@@ -19,7 +19,7 @@ func (br *Bridge) wireAirPlayOutput(client *airplay2.Client) (err error) {
 	case inputTypeYoutube:
 		err = br.AddOutputWriter(client, client.WriterID())
 	default:
-		err = fmt.Errorf("unrecognized input type '%d'", br.inputType)
+		err = fmt.Errorf("unrecognized input type '%d'", input)
 	}
 	if err != nil {
 		br.outputs = append(br.outputs, &OutputInfo{
@@ -9,6 +9,8 @@ type YoutubeHandler struct {
 }
 
 func (br *Bridge) StartYoutubeInput() error {
+	br.mu.Lock()
+	defer br.mu.Unlock()
 	if br.inputType != -1 {
 		br.closeInput()
 	}
@@ -20,5 +22,6 @@ func (br *Bridge) StartYoutubeInput() error {
 			handler: youtube.NewHandler(br.byteWriter),
 		}
 	}
+	br.reportSampleRate(streamSampleRate)
 	return nil
 }
@@ -27,11 +27,11 @@ type melbank struct {
 	SmoothFilter *math_utils.ExpFilterSlice
 }
 
-// Specify the min and max frequencies
-func newMelbank(min, max uint, intensity float64, bufSize int) (*melbank, error) {
+// Specify the min and max frequencies, and the fft window size and sample rate of the audio analysis
+func newMelbank(min, max uint, intensity float64, fftSize, sampleRate uint) (*melbank, error) {
 
 	mb := &melbank{
-		fb:           aubio.NewFilterBank(melBins, uint(bufSize)),
+		fb:           aubio.NewFilterBank(melBins, fftSize),
 		Min:          int(min),
 		Max:          int(max),
 		Intensity:    intensity,
@@ -57,7 +57,7 @@ func newMelbank(min, max uint, intensity float64, bufSize int) (*melbank, error)
 		freqs[i] = MelToHz(freqs[i])
 	}
 	// set and normalise the bands
-	mb.fb.SetTriangleBands(aubio.NewSimpleBufferData(melBins+2, freqs), SampleRate)
+	mb.fb.SetTriangleBands(aubio.NewSimpleBufferData(melBins+2, freqs), sampleRate)
 	mb.fb.NormalizeCoeffs()
 	// save the freqs for reference
 	mb.Freqs = freqs
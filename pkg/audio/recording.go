@@ -0,0 +1,360 @@
+package audio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LedFx/ledfx/pkg/constants"
+	log "github.com/LedFx/ledfx/pkg/logger"
+)
+
+/*
+Recordings hold the raw buffers given to an analyzer, with the time each arrived.
+File layout, all little endian:
+	header: magic "LFXREC1\n", sample rate (uint32)
+	buffer: time since recording started in ns (int64), sample count (uint32), samples (int16 each)
+*/
+
+const recordingMagic = "LFXREC1\n"
+const recordingExt = ".lfxrec"
+
+// Most samples a recorded buffer may hold. Well above the largest buffer size, stereo,
+// so a corrupt sample count can't make loading allocate gigabytes.
+const maxRecordedSamples = 1 << 16
+
+// A buffer in a recording, and when it arrived relative to the start of the recording
+type RecordedBuffer struct {
+	Offset time.Duration
+	Buffer Buffer
+}
+
+type Recording struct {
+	SampleRate uint
+	Buffers    []RecordedBuffer
+}
+
+// Writes buffers to a recording file as they arrive
+type Recorder struct {
+	mu    sync.Mutex
+	file  *os.File
+	w     *bufio.Writer
+	start time.Time
+	count int
+}
+
+// Path of a named recording in the LedFx config directory
+func RecordingPath(name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\.`) {
+		return "", fmt.Errorf("invalid recording name '%s'", name)
+	}
+	return filepath.Join(constants.GetOsConfigDir(), "recordings", name+recordingExt), nil
+}
+
+// Names of the recordings in the LedFx config directory
+func GetRecordingNames() ([]string, error) {
+	names := []string{}
+	files, err := os.ReadDir(filepath.Join(constants.GetOsConfigDir(), "recordings"))
+	if errors.Is(err, os.ErrNotExist) {
+		return names, nil
+	}
+	if err != nil {
+		return names, err
+	}
+	for _, f := range files {
+		if !f.IsDir() && filepath.Ext(f.Name()) == recordingExt {
+			names = append(names, strings.TrimSuffix(f.Name(), recordingExt))
+		}
+	}
+	return names, nil
+}
+
+// Creates a recording file, overwriting any existing file
+func NewRecorder(filename string, sampleRate uint) (*Recorder, error) {
+	if err := os.MkdirAll(filepath.Dir(filename), 0744); err != nil {
+		return nil, fmt.Errorf("error creating recording directory: %w", err)
+	}
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error creating recording '%s': %w", filename, err)
+	}
+	r := &Recorder{
+		file:  file,
+		w:     bufio.NewWriter(file),
+		start: time.Now(),
+	}
+	r.w.WriteString(recordingMagic)
+	if err = binary.Write(r.w, binary.LittleEndian, uint32(sampleRate)); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// Appends a buffer to the recording, timestamped with the time since the recording started
+func (r *Recorder) Write(buf Buffer) error {
+	return r.WriteAt(time.Since(r.start), buf)
+}
+
+// Appends a buffer to the recording with a given timestamp
+func (r *Recorder) WriteAt(offset time.Duration, buf Buffer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.w == nil {
+		return errors.New("recording is closed")
+	}
+	if err := binary.Write(r.w, binary.LittleEndian, int64(offset)); err != nil {
+		return err
+	}
+	if err := binary.Write(r.w, binary.LittleEndian, uint32(len(buf))); err != nil {
+		return err
+	}
+	if err := binary.Write(r.w, binary.LittleEndian, []int16(buf)); err != nil {
+		return err
+	}
+	r.count++
+	return nil
+}
+
+// Number of buffers recorded so far
+func (r *Recorder) Count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.count
+}
+
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.w == nil {
+		return nil
+	}
+	err := r.w.Flush()
+	r.w = nil
+	if closeErr := r.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Reads a whole recording file
+func LoadRecording(filename string) (*Recording, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error opening recording '%s': %w", filename, err)
+	}
+	defer file.Close()
+	r := bufio.NewReader(file)
+
+	magic := make([]byte, len(recordingMagic))
+	if _, err = io.ReadFull(r, magic); err != nil || string(magic) != recordingMagic {
+		return nil, fmt.Errorf("'%s' is not a LedFx audio recording", filename)
+	}
+	var sampleRate uint32
+	if err = binary.Read(r, binary.LittleEndian, &sampleRate); err != nil {
+		return nil, fmt.Errorf("error reading recording header: %w", err)
+	}
+	rec := &Recording{SampleRate: uint(sampleRate)}
+	for {
+		var offset int64
+		var length uint32
+		err = binary.Read(r, binary.LittleEndian, &offset)
+		if errors.Is(err, io.EOF) {
+			return rec, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading recording: %w", err)
+		}
+		if err = binary.Read(r, binary.LittleEndian, &length); err != nil {
+			return nil, fmt.Errorf("error reading recording: %w", err)
+		}
+		if length > maxRecordedSamples {
+			return nil, fmt.Errorf("error reading recording: buffer of %d samples is larger than %d", length, maxRecordedSamples)
+		}
+		buf := make(Buffer, length)
+		if err = binary.Read(r, binary.LittleEndian, []int16(buf)); err != nil {
+			return nil, fmt.Errorf("error reading recording: %w", err)
+		}
+		rec.Buffers = append(rec.Buffers, RecordedBuffer{Offset: time.Duration(offset), Buffer: buf})
+	}
+}
+
+// Length of the recording
+func (rec *Recording) Duration() time.Duration {
+	if len(rec.Buffers) == 0 {
+		return 0
+	}
+	return rec.Buffers[len(rec.Buffers)-1].Offset
+}
+
+/*
+Feeds the recording to a callback, keeping the recorded timing.
+A speed of 1 plays in real time, 2 twice as fast, etc.
+A speed of 0 feeds every buffer immediately, so replay is deterministic for tests.
+Returns early if stop is closed.
+*/
+func (rec *Recording) Play(callback func(buf Buffer), speed float64, stop <-chan struct{}) {
+	start := time.Now()
+	for _, rb := range rec.Buffers {
+		if speed > 0 {
+			wait := time.Duration(float64(rb.Offset)/speed) - time.Since(start)
+			if wait > 0 {
+				select {
+				case <-stop:
+					return
+				case <-time.After(wait):
+				}
+			}
+		}
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		callback(rb.Buffer)
+	}
+}
+
+// Starts recording the buffers given to the analyzer. Any running recording is stopped.
+func (a *analyzer) StartRecording(filename string) error {
+	a.StopRecording()
+	r, err := NewRecorder(filename, a.SampleRate())
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	a.recorder = r
+	a.mu.Unlock()
+	log.Logger.WithField("context", "Audio Recorder").Infof("Recording audio source %s to %s", a.ID, filename)
+	return nil
+}
+
+// Stops recording, returning the number of buffers recorded
+func (a *analyzer) StopRecording() (int, error) {
+	a.mu.Lock()
+	r := a.recorder
+	a.recorder = nil
+	a.mu.Unlock()
+	if r == nil {
+		return 0, nil
+	}
+	log.Logger.WithField("context", "Audio Recorder").Infof("Stopped recording audio source %s", a.ID)
+	return r.Count(), r.Close()
+}
+
+// Whether the analyzer is being recorded
+func (a *analyzer) Recording() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.recorder != nil
+}
+
+// a running replay. start is reset each time a looping replay starts over
+type replay struct {
+	stop  chan struct{}
+	start time.Time
+	speed float64
+	rec   *Recording
+}
+
+// running replays, by the id of the audio source they feed
+var replays = map[string]*replay{}
+var replaysMu sync.Mutex
+
+/*
+Starts replaying a recording as a named audio source. Effects can react to it by its id.
+If a replay or source exists with this id, it is replaced. The source is removed when the replay ends,
+unless loop is set.
+*/
+func StartReplay(id string, rec *Recording, speed float64, loop bool) error {
+	StopReplay(id)
+	a, err := NewAnalyzer(id)
+	if err != nil {
+		return err
+	}
+	if rec.SampleRate != 0 {
+		a.SetSampleRate(rec.SampleRate)
+	}
+	stop := make(chan struct{})
+	r := &replay{stop: stop, speed: speed, rec: rec}
+	replaysMu.Lock()
+	replays[id] = r
+	replaysMu.Unlock()
+	go func() {
+		for {
+			replaysMu.Lock()
+			r.start = time.Now()
+			replaysMu.Unlock()
+			rec.Play(a.BufferCallback, speed, stop)
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if !loop {
+				break
+			}
+		}
+		replaysMu.Lock()
+		if replays[id] == r {
+			delete(replays, id)
+			replaysMu.Unlock()
+			DeleteAnalyzer(id)
+			log.Logger.WithField("context", "Audio Replay").Infof("Finished replay on audio source %s", id)
+			return
+		}
+		replaysMu.Unlock()
+	}()
+	log.Logger.WithField("context", "Audio Replay").Infof("Replaying %d buffers on audio source %s at %vx speed", len(rec.Buffers), id, speed)
+	return nil
+}
+
+// Stops a replay and removes its audio source
+func StopReplay(id string) {
+	replaysMu.Lock()
+	r, exists := replays[id]
+	delete(replays, id)
+	replaysMu.Unlock()
+	if !exists {
+		return
+	}
+	close(r.stop)
+	DeleteAnalyzer(id)
+	log.Logger.WithField("context", "Audio Replay").Infof("Stopped replay on audio source %s", id)
+}
+
+// Get the ids of the audio sources fed by replays
+func GetReplayIDs() []string {
+	replaysMu.Lock()
+	defer replaysMu.Unlock()
+	ids := []string{}
+	for id := range replays {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Position of a replay in its recording. Replays at speed 0 have no position in time.
+func ReplayPosition(id string) (time.Duration, error) {
+	replaysMu.Lock()
+	defer replaysMu.Unlock()
+	r, exists := replays[id]
+	if !exists {
+		return 0, fmt.Errorf("no replay on audio source %s", id)
+	}
+	if r.speed <= 0 {
+		return 0, fmt.Errorf("replay on audio source %s is not playing in real time", id)
+	}
+	pos := time.Duration(float64(time.Since(r.start)) * r.speed)
+	if d := r.rec.Duration(); pos > d {
+		pos = d
+	}
+	return pos, nil
+}
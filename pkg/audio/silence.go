@@ -0,0 +1,54 @@
+package audio
+
+import (
+	"time"
+
+	"github.com/LedFx/ledfx/pkg/config"
+	"github.com/LedFx/ledfx/pkg/event"
+	log "github.com/LedFx/ledfx/pkg/logger"
+)
+
+// Counts consecutive quiet buffers. Counting buffers rather than wall time keeps detection
+// deterministic when audio is replayed faster than real time.
+type silenceDetector struct {
+	quietBuffers int
+	silent       bool
+	since        time.Time // when the current silence started
+}
+
+// Checks the latest volume level against the silence config. Caller must hold the lock.
+// Events are delivered asynchronously, so listeners are free to use the analyzer.
+func (a *analyzer) detectSilence() {
+	c := config.GetSilence()
+	sd := &a.silence
+	if a.Vol.Level >= float64(c.Threshold) {
+		sd.quietBuffers = 0
+		if sd.silent {
+			sd.silent = false
+			duration := time.Since(sd.since)
+			log.Logger.WithField("context", "Audio Analysis").Infof("Audio returned on source %s after %v of silence", a.ID, duration.Round(time.Second))
+			event.Invoke(event.SilenceEndData{
+				Source:   a.ID,
+				Duration: duration.Seconds(),
+			})
+		}
+		return
+	}
+	sd.quietBuffers++
+	if sd.silent || float64(sd.quietBuffers) < float64(c.Window)*a.refreshRate() {
+		return
+	}
+	sd.silent = true
+	sd.since = time.Now()
+	log.Logger.WithField("context", "Audio Analysis").Infof("Audio source %s went silent", a.ID)
+	event.Invoke(event.SilenceStartData{
+		Source: a.ID,
+	})
+}
+
+// Whether the audio source is currently silent
+func (a *analyzer) IsSilent() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.silence.silent
+}
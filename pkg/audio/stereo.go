@@ -0,0 +1,242 @@
+package audio
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	log "github.com/LedFx/ledfx/pkg/logger"
+	"github.com/LedFx/ledfx/pkg/math_utils"
+
+	"github.com/LedFx/aubio-go"
+)
+
+// Audio channels of a stereo source
+const (
+	Left  int = 0
+	Right int = 1
+)
+
+// stereo analysis is dropped if no stereo audio has arrived for this long, eg. the source went back to mono
+const stereoTimeout = 500 * time.Millisecond
+
+// Analysis of one channel of a stereo source. Runs alongside the mono analysis.
+type channelAnalysis struct {
+	buf      *aubio.SimpleBuffer // aubio buffer
+	data     []float32           // audio buffer as f32
+	eq       *aubio.Filter       // balances the volume across freqs
+	pvoc     *aubio.PhaseVoc     // transforms audio data to fft
+	melbanks map[string]*melbank // a melbank for each effect, mirroring the mono melbanks
+	Vol      volumeStream        // normalised volume of this channel
+	rms      float64             // raw level of the latest buffer
+}
+
+type stereoAnalysis struct {
+	channels  [2]*channelAnalysis
+	balance   *math_utils.ExpFilter // -1 (left) to 1 (right)
+	width     *math_utils.ExpFilter // 0 (mono) to 1 (out of phase)
+	updatedAt time.Time             // time the latest stereo buffer arrived
+}
+
+func (a *analyzer) newChannelAnalysis() (*channelAnalysis, error) {
+	uintBufSize := uint(a.bufSize)
+	ca := &channelAnalysis{
+		buf:      aubio.NewSimpleBuffer(uintBufSize),
+		data:     make([]float32, uintBufSize),
+		melbanks: make(map[string]*melbank),
+		Vol:      NewVolumeStream(a.refreshRate()),
+	}
+	var err error
+	if ca.eq, err = aubio.NewFilterBiquad(a.config.EqB0, a.config.EqB1, a.config.EqB2, a.config.EqA1, a.config.EqA2, uintBufSize); err != nil {
+		ca.buf.Free()
+		return nil, fmt.Errorf("error creating new Aubio EQ Filter: %w", err)
+	}
+	if ca.pvoc, err = aubio.NewPhaseVoc(a.fftSize, uintBufSize); err != nil {
+		ca.buf.Free()
+		ca.eq.Free()
+		return nil, fmt.Errorf("error creating new Aubio Pvoc: %w", err)
+	}
+	return ca, nil
+}
+
+func (ca *channelAnalysis) free() {
+	ca.buf.Free()
+	ca.eq.Free()
+	ca.pvoc.Free()
+	for id, mb := range ca.melbanks {
+		mb.Free()
+		delete(ca.melbanks, id)
+	}
+}
+
+func (ca *channelAnalysis) deleteMelbank(id string) {
+	if mb, ok := ca.melbanks[id]; ok {
+		mb.Free()
+		delete(ca.melbanks, id)
+	}
+}
+
+func (ca *channelAnalysis) do(buf Buffer, a *analyzer) {
+	var sumSquares float64
+	for i := 0; i < a.bufSize; i++ {
+		ca.data[i] = float32(buf[i])
+		sumSquares += float64(buf[i]) * float64(buf[i])
+	}
+	ca.rms = math.Sqrt(sumSquares / float64(a.bufSize))
+	ca.buf.SetDataFast(ca.data)
+	ca.Vol.update(aubio.DbSpl(ca.buf))
+	ca.eq.DoOutplace(ca.buf)
+	ca.pvoc.Do(ca.eq.Buffer())
+
+	// keep a melbank for each mono melbank, with the same parameters
+	for id, mono := range a.melbanks {
+		mb, ok := ca.melbanks[id]
+		if ok && (mb.Min != mono.Min || mb.Max != mono.Max || mb.Intensity != mono.Intensity) {
+			ca.deleteMelbank(id)
+			ok = false
+		}
+		if !ok {
+			var err error
+			if mb, err = newMelbank(uint(mono.Min), uint(mono.Max), mono.Intensity, a.fftSize, a.sampleRate); err != nil {
+				log.Logger.WithField("context", "Audio Analysis").Warnf("Error creating channel melbank for effect %s: %v", id, err)
+				continue
+			}
+			ca.melbanks[id] = mb
+		}
+		mb.Do(ca.pvoc.Grain())
+	}
+	for id := range ca.melbanks {
+		if _, ok := a.melbanks[id]; !ok {
+			ca.deleteMelbank(id)
+		}
+	}
+}
+
+// Takes the left and right channels of a stereo audio buffer and analyses each.
+// The mono analysis is not affected, sources should still give a downmixed buffer to BufferCallback.
+func (a *analyzer) StereoBufferCallback(left, right Buffer) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	// the mono path owns the buffer size. skip until both agree.
+	if a.freed || len(left) != a.bufSize || len(right) != a.bufSize {
+		return
+	}
+	if a.stereo == nil {
+		a.stereo = &stereoAnalysis{
+			balance: math_utils.NewExpFilter(0.2, 0.2),
+			width:   math_utils.NewExpFilter(0.2, 0.2),
+		}
+	}
+	s := a.stereo
+	for i := range s.channels {
+		if s.channels[i] != nil {
+			continue
+		}
+		var err error
+		if s.channels[i], err = a.newChannelAnalysis(); err != nil {
+			log.Logger.WithField("context", "Audio Analysis").Errorf("Error creating stereo analysis: %v", err)
+			a.freeStereo()
+			return
+		}
+	}
+	s.channels[Left].do(left, a)
+	s.channels[Right].do(right, a)
+
+	// balance compares the level of each channel
+	l, r := s.channels[Left].rms, s.channels[Right].rms
+	if l+r > 0 {
+		s.balance.Update((r - l) / (r + l))
+	} else {
+		s.balance.Update(0)
+	}
+	// width compares the side (difference) to the mid (sum) signal
+	var mid, side float64
+	for i := 0; i < a.bufSize; i++ {
+		m := (float64(left[i]) + float64(right[i])) / 2
+		d := (float64(left[i]) - float64(right[i])) / 2
+		mid += m * m
+		side += d * d
+	}
+	mid, side = math.Sqrt(mid), math.Sqrt(side)
+	if mid+side > 0 {
+		s.width.Update(side / (mid + side))
+	} else {
+		s.width.Update(0)
+	}
+	s.updatedAt = time.Now()
+}
+
+// Frees the stereo analysis. Caller must hold the lock.
+func (a *analyzer) freeStereo() {
+	if a.stereo == nil {
+		return
+	}
+	for _, ca := range a.stereo.channels {
+		if ca != nil {
+			ca.free()
+		}
+	}
+	a.stereo = nil
+}
+
+// whether stereo audio is currently arriving. Caller must hold the lock.
+func (a *analyzer) stereoActive() bool {
+	return a.stereo != nil && a.stereo.channels[Left] != nil && time.Since(a.stereo.updatedAt) < stereoTimeout
+}
+
+// Whether the audio source is currently giving stereo audio
+func (a *analyzer) IsStereo() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.stereoActive()
+}
+
+// Get the melbank of an effect for one channel.
+// Falls back to the mono melbank if the source is not stereo.
+func (a *analyzer) GetChannelMelbank(id string, channel int) (*melbank, error) {
+	if channel != Left && channel != Right {
+		return nil, fmt.Errorf("invalid audio channel %d", channel)
+	}
+	a.mu.Lock()
+	if a.stereoActive() {
+		mb, ok := a.stereo.channels[channel].melbanks[id]
+		a.mu.Unlock()
+		if ok {
+			return mb, nil
+		}
+	} else {
+		a.mu.Unlock()
+	}
+	return a.GetMelbank(id)
+}
+
+// Get the normalised volume of one channel. Falls back to the mono volume if the source is not stereo.
+func (a *analyzer) ChannelVolume(channel int) float64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if (channel == Left || channel == Right) && a.stereoActive() {
+		return a.stereo.channels[channel].Vol.Volume
+	}
+	return a.Vol.Volume
+}
+
+// Stereo balance from -1 (all left) to 1 (all right). 0 if the source is not stereo.
+func (a *analyzer) Balance() float64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.stereoActive() {
+		return 0
+	}
+	return a.stereo.balance.Value
+}
+
+// Stereo width from 0 (mono) to 1 (channels out of phase). Uncorrelated channels sit around 0.5.
+// 0 if the source is not stereo.
+func (a *analyzer) Width() float64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.stereoActive() {
+		return 0
+	}
+	return a.stereo.width.Value
+}
@@ -0,0 +1,220 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/LedFx/ledfx/pkg/api"
+	"github.com/LedFx/ledfx/pkg/config"
+)
+
+type statusResponse struct {
+	Enabled bool   `json:"enabled"`
+	Role    string `json:"role"` // role of the request, empty if it isn't authenticated
+}
+
+type loginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type loginResponse struct {
+	Token   string    `json:"token"`
+	Role    string    `json:"role"`
+	Expires time.Time `json:"expires"`
+}
+
+type authResponse struct {
+	Enabled        bool     `json:"enabled"`
+	AllowedOrigins []string `json:"allowed_origins"`
+}
+
+type tokenRequest struct {
+	Name string `json:"name"`
+	Role string `json:"role"`
+}
+
+type tokenResponse struct {
+	Name    string    `json:"name"`
+	Role    string    `json:"role"`
+	Created time.Time `json:"created"`
+	Token   string    `json:"token,omitempty"` // only given when the token is created
+}
+
+type userRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+}
+
+type userResponse struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+}
+
+func getAuth() authResponse {
+	a := config.GetAuth()
+	return authResponse{Enabled: a.Enabled, AllowedOrigins: a.AllowedOrigins}
+}
+
+// the tokens, with the token which was just created, if any
+func getTokens(created *tokenResponse) []tokenResponse {
+	tokens := []tokenResponse{}
+	for _, t := range config.GetAuth().Tokens {
+		r := tokenResponse{Name: t.Name, Role: t.Role, Created: t.Created}
+		if created != nil && created.Name == t.Name {
+			r.Token = created.Token
+		}
+		tokens = append(tokens, r)
+	}
+	return tokens
+}
+
+func getUsers() []userResponse {
+	users := []userResponse{}
+	for _, u := range config.GetAuth().Users {
+		users = append(users, userResponse{Username: u.Username, Role: u.Role})
+	}
+	return users
+}
+
+// the address a request came from, without its port
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func NewV1API(r *api.Router) {
+	r.Schema("Token", api.TypeSchema(tokenResponse{}))
+	r.Schema("User", api.TypeSchema(userResponse{}))
+
+	r.Get("/auth/status", func(req *api.Request) (interface{}, error) {
+		role, _ := Authenticate(req.Request)
+		return statusResponse{Enabled: config.GetAuth().Enabled, Role: role}, nil
+	}).Doc("Auth", "Get whether auth is enabled, and the role of the request").Returns(api.TypeSchema(statusResponse{}))
+
+	r.Post("/auth/login", func(req *api.Request) (interface{}, error) {
+		addr := remoteHost(req.Request)
+		if wait, ok := LoginAllowed(addr); !ok {
+			return nil, tooManyLogins(wait)
+		}
+		var l loginRequest
+		if err := req.Decode(&l); err != nil {
+			return nil, err
+		}
+		token, role, expires, err := Login(l.Username, l.Password)
+		LoginAttempted(addr, err == nil)
+		if err != nil {
+			return nil, api.NewError(http.StatusUnauthorized, api.CodeUnauthorized, err)
+		}
+		return loginResponse{Token: token, Role: role, Expires: expires}, nil
+	}).Doc("Auth", "Log in with a username and password, for a session token").
+		Accepts(api.TypeSchema(loginRequest{})).Returns(api.TypeSchema(loginResponse{}))
+
+	r.Delete("/auth/login", func(req *api.Request) (interface{}, error) {
+		Logout(strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer "))
+		return nil, nil
+	}).Doc("Auth", "Log out of the session given as the bearer token")
+
+	r.Get("/auth", func(*api.Request) (interface{}, error) {
+		return getAuth(), nil
+	}).Doc("Auth", "Get whether auth is enabled and the allowed origins").Returns(api.TypeSchema(authResponse{}))
+
+	r.Put("/auth", func(req *api.Request) (interface{}, error) {
+		c := map[string]interface{}{}
+		if err := req.Decode(&c); err != nil {
+			return nil, err
+		}
+		if err := config.SetAuth(c); err != nil {
+			return nil, api.BadRequest(err)
+		}
+		return getAuth(), nil
+	}).Doc("Auth", "Update whether auth is enabled and the allowed origins").
+		Accepts(api.TypeSchema(authResponse{})).Returns(api.TypeSchema(authResponse{}))
+
+	r.Get("/auth/tokens", func(*api.Request) (interface{}, error) {
+		return getTokens(nil), nil
+	}).Doc("Auth", "Get the api tokens. The tokens themselves aren't kept").Returns(api.ArrayOf(api.Ref("Token")))
+
+	r.Post("/auth/tokens", func(req *api.Request) (interface{}, error) {
+		var t tokenRequest
+		if err := req.Decode(&t); err != nil {
+			return nil, err
+		}
+		if t.Role == "" {
+			t.Role = config.RoleRead
+		}
+		token, err := config.CreateToken(t.Name, t.Role)
+		if err != nil {
+			return nil, api.BadRequest(err)
+		}
+		return getTokens(&tokenResponse{Name: t.Name, Token: token}), nil
+	}).Doc("Auth", "Create an api token. It's only given in this response").
+		Accepts(api.TypeSchema(tokenRequest{})).Returns(api.ArrayOf(api.Ref("Token"))).Status(http.StatusCreated)
+
+	r.Delete("/auth/tokens/{name}", func(req *api.Request) (interface{}, error) {
+		name := req.Param("name")
+		if !hasToken(name) {
+			return nil, api.NotFound(fmt.Errorf("token %s does not exist", name))
+		}
+		if err := config.RevokeToken(name); err != nil {
+			return nil, api.BadRequest(err)
+		}
+		return nil, nil
+	}).Doc("Auth", "Revoke an api token")
+
+	r.Get("/auth/users", func(*api.Request) (interface{}, error) {
+		return getUsers(), nil
+	}).Doc("Auth", "Get the users").Returns(api.ArrayOf(api.Ref("User")))
+
+	r.Post("/auth/users", func(req *api.Request) (interface{}, error) {
+		var u userRequest
+		if err := req.Decode(&u); err != nil {
+			return nil, err
+		}
+		if u.Username == "" {
+			return nil, api.BadRequest(errors.New("username is required"))
+		}
+		if err := config.SetUser(u.Username, u.Password, u.Role); err != nil {
+			return nil, api.BadRequest(err)
+		}
+		return getUsers(), nil
+	}).Doc("Auth", "Create a user, or change their password and role").
+		Accepts(api.TypeSchema(userRequest{})).Returns(api.ArrayOf(api.Ref("User")))
+
+	r.Delete("/auth/users/{username}", func(req *api.Request) (interface{}, error) {
+		username := req.Param("username")
+		if !hasUser(username) {
+			return nil, api.NotFound(fmt.Errorf("user %s does not exist", username))
+		}
+		if err := config.DeleteUser(username); err != nil {
+			return nil, api.BadRequest(err)
+		}
+		return nil, nil
+	}).Doc("Auth", "Delete a user")
+}
+
+func hasToken(name string) bool {
+	for _, t := range config.GetAuth().Tokens {
+		if t.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func hasUser(username string) bool {
+	for _, u := range config.GetAuth().Users {
+		if u.Username == username {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/LedFx/ledfx/pkg/api"
+	"github.com/LedFx/ledfx/pkg/api/apitest"
+	"github.com/LedFx/ledfx/pkg/config"
+)
+
+func TestV1API(t *testing.T) {
+	defer config.DisableSaving()()
+	r := api.NewRouter("/api/v1")
+	NewV1API(r)
+	defer func() {
+		loginsMu.Lock()
+		failedLogins = map[string]*loginFailures{}
+		loginsMu.Unlock()
+	}()
+
+	apitest.Run(t, r, []apitest.Case{
+		{Method: http.MethodGet, Path: "/api/v1/auth/status", Code: http.StatusOK},
+		{Method: http.MethodGet, Path: "/api/v1/auth", Code: http.StatusOK},
+		{Method: http.MethodPut, Path: "/api/v1/auth", Body: `{"enabled": true}`, Code: http.StatusBadRequest},
+		{Method: http.MethodPost, Path: "/api/v1/auth/tokens", Body: `{"name": "v1token"}`, Code: http.StatusCreated},
+		{Method: http.MethodGet, Path: "/api/v1/auth/tokens", Code: http.StatusOK},
+		{Method: http.MethodDelete, Path: "/api/v1/auth/tokens/v1token", Code: http.StatusNoContent},
+		{Method: http.MethodDelete, Path: "/api/v1/auth/tokens/v1token", Code: http.StatusNotFound},
+		{Method: http.MethodPost, Path: "/api/v1/auth/users", Body: `{"username": "v1", "password": "short"}`, Code: http.StatusBadRequest},
+		{Method: http.MethodPost, Path: "/api/v1/auth/users", Body: `{"username": "v1", "password": "correct horse", "role": "read"}`, Code: http.StatusOK},
+		{Method: http.MethodPost, Path: "/api/v1/auth/login", Body: `{"username": "v1", "password": "correct horse"}`, Code: http.StatusOK},
+		{Method: http.MethodPost, Path: "/api/v1/auth/login", Body: `{"username": "v1", "password": "wrong horse"}`, Code: http.StatusUnauthorized},
+		{Method: http.MethodDelete, Path: "/api/v1/auth/users/v1", Code: http.StatusNoContent},
+		{Method: http.MethodDelete, Path: "/api/v1/auth/users/v1", Code: http.StatusNotFound},
+	})
+
+	// the rate limit says when to retry
+	for i := 0; i < maxLoginFailures; i++ {
+		apitest.Do(r, http.MethodPost, "/api/v1/auth/login", `{"username": "v1", "password": "wrong horse"}`)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", strings.NewReader(`{}`)))
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected logins to be rate limited with a time to retry, got %d %v", w.Code, w.Header())
+	}
+	apitest.Documents(t, r, "/api/v1", []string{"/auth/login", "/auth/tokens/{name}", "/auth/users/{username}"}, []string{"Token", "User"})
+}
+
+func TestV1Middleware(t *testing.T) {
+	defer config.DisableSaving()()
+	r := api.NewRouter("/api/v1")
+	NewV1API(r)
+	admin, err := config.CreateToken("v1 admin", config.RoleAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read, err := config.CreateToken("v1 read", config.RoleRead)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = config.SetAuth(map[string]interface{}{"enabled": true}); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		config.SetAuth(map[string]interface{}{"enabled": false})
+		config.RevokeToken("v1 admin")
+		config.RevokeToken("v1 read")
+	}()
+	h := Middleware(r)
+	cases := []struct {
+		path  string
+		token string
+		code  int
+	}{
+		{"/api/v1/auth/status", "", http.StatusOK},
+		{"/api/v1/auth", "", http.StatusUnauthorized},
+		{"/api/v1/auth", read, http.StatusOK},
+		{"/api/v1/auth/tokens", read, http.StatusForbidden},
+		{"/api/v1/auth/tokens", admin, http.StatusOK},
+		{"/api/v1/config/export", read, http.StatusForbidden},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, c.path, nil)
+		if c.token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		}
+		h.ServeHTTP(w, req)
+		if w.Code != c.code {
+			t.Errorf("GET %s: expected %d, got %d", c.path, c.code, w.Code)
+		}
+	}
+}
@@ -0,0 +1,329 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LedFx/ledfx/pkg/api"
+	"github.com/LedFx/ledfx/pkg/config"
+	"github.com/LedFx/ledfx/pkg/logger"
+)
+
+/*
+Optional access control for the api and websocket. When auth is enabled in config, requests
+need an api token or a user's password, given as a header:
+	Authorization: Bearer <token>
+	Authorization: Basic <base64 of username:password>
+Browsers can't set headers on websockets, so a websocket upgrade can give its token as ?token=<token>.
+Users can log in for a session token, so their password isn't sent with every request.
+Addresses which fail to log in too often, by either means, are made to wait.
+Tokens and users have a role: read can only view, admin can do anything.
+The web interface itself is served to anyone, so it can ask for a login.
+*/
+
+// How long a login lasts
+var sessionLifetime = 24 * time.Hour
+
+// Paths which need auth, by prefix
+var protectedPaths = []string{"/api/", "/websocket", "/debug/"}
+
+// Paths anyone can use, to find out about auth and log in
+var publicPaths = []string{"/api/v1/auth/status", "/api/v1/auth/login"}
+
+// Paths which only admins can use, even to read, because they reveal secrets
+var adminPaths = []string{
+	"/api/v1/auth/tokens", "/api/v1/auth/users", "/api/v1/config/export", "/api/v1/config/quarantine", "/api/v1/mqtt",
+	"/debug/",
+}
+
+// Paths of websockets, whose upgrades may give a token in the url
+var websocketPaths = []string{"/websocket"}
+
+// Failed logins allowed from an address within loginWindow, before it must wait for the window to pass
+const maxLoginFailures = 5
+
+var loginWindow = time.Minute
+
+var (
+	errUnauthorized = errors.New("a valid token or username and password is needed")
+	errForbidden    = errors.New("this needs an admin token or user")
+)
+
+type session struct {
+	username string
+	expires  time.Time
+}
+
+var (
+	sessionsMu sync.Mutex
+	sessions   = map[string]session{} // by hash of the session token
+)
+
+type loginFailures struct {
+	count int
+	since time.Time // time of the first failure in the window
+}
+
+var (
+	loginsMu     sync.Mutex
+	failedLogins = map[string]*loginFailures{} // by remote address
+)
+
+type roleKey struct{}
+
+// Checks requests to protected paths are authenticated with a role allowed to make them
+func Middleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !hasPrefix(r.URL.Path, protectedPaths) || hasPrefix(r.URL.Path, publicPaths) {
+			h.ServeHTTP(w, r)
+			return
+		}
+		role, err := Authenticate(r)
+		if err != nil {
+			logger.Logger.WithField("context", "Auth").Debugf("Refused %s %s from %s: %v", r.Method, r.URL.Path, r.RemoteAddr, err)
+			var e *api.Error
+			if errors.As(err, &e) {
+				refuse(w, r, e)
+				return
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="LedFx"`)
+			refuse(w, r, api.NewError(http.StatusUnauthorized, api.CodeUnauthorized, err))
+			return
+		}
+		if !permitted(role, r) {
+			logger.Logger.WithField("context", "Auth").Debugf("Refused %s %s from %s: %v", r.Method, r.URL.Path, r.RemoteAddr, errForbidden)
+			refuse(w, r, api.NewError(http.StatusForbidden, api.CodeForbidden, errForbidden))
+			return
+		}
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), roleKey{}, role)))
+	})
+}
+
+// the versioned api's errors are json, the rest are plain text
+func refuse(w http.ResponseWriter, r *http.Request, e *api.Error) {
+	if strings.HasPrefix(r.URL.Path, "/api/v1/") {
+		api.WriteError(w, e)
+		return
+	}
+	for k, v := range e.Header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(e.Status)
+	w.Write([]byte(e.Message))
+}
+
+/*
+Gets the role of a request from its token, session or password.
+Passwords count towards the address's failed logins, the same as logging in.
+Everyone is an admin while auth is disabled.
+*/
+func Authenticate(r *http.Request) (role string, err error) {
+	if !config.GetAuth().Enabled {
+		return config.RoleAdmin, nil
+	}
+	header := r.Header.Get("Authorization")
+	if strings.HasPrefix(header, "Bearer ") {
+		return checkToken(strings.TrimPrefix(header, "Bearer "))
+	}
+	if username, password, ok := r.BasicAuth(); ok {
+		addr := remoteHost(r)
+		if wait, ok := LoginAllowed(addr); !ok {
+			return "", tooManyLogins(wait)
+		}
+		role, ok := config.CheckPassword(username, password)
+		LoginAttempted(addr, ok)
+		if !ok {
+			return "", errUnauthorized
+		}
+		return role, nil
+	}
+	if token := r.URL.Query().Get("token"); token != "" && isWebsocketUpgrade(r) {
+		return checkToken(token)
+	}
+	return "", errUnauthorized
+}
+
+// The role of a request which has been through the middleware
+func RequestRole(r *http.Request) string {
+	if role, ok := r.Context().Value(roleKey{}).(string); ok {
+		return role
+	}
+	role, _ := Authenticate(r)
+	return role
+}
+
+/*
+Gets the Access-Control-Allow-Origin for a request, and whether its origin is allowed.
+Requests from LedFx's own web interface, or without an origin, are always allowed.
+Any origin is allowed if no origins are configured.
+*/
+func AllowedOrigin(r *http.Request) (string, bool) {
+	allowed := config.GetAuth().AllowedOrigins
+	if len(allowed) == 0 {
+		return "*", true
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return "", true
+	}
+	if u, err := url.Parse(origin); err == nil && u.Host == r.Host {
+		return origin, true
+	}
+	for _, o := range allowed {
+		if strings.EqualFold(strings.TrimSuffix(o, "/"), origin) {
+			return origin, true
+		}
+	}
+	return "", false
+}
+
+// For websocket upgraders
+func CheckOrigin(r *http.Request) bool {
+	_, ok := AllowedOrigin(r)
+	if !ok {
+		logger.Logger.WithField("context", "Auth").Warnf("Refused websocket from origin %s", r.Header.Get("Origin"))
+	}
+	return ok
+}
+
+// Logs in a user, returning a session token to use instead of their password
+func Login(username, password string) (token string, role string, expires time.Time, err error) {
+	role, ok := config.CheckPassword(username, password)
+	if !ok {
+		return "", "", expires, errors.New("wrong username or password")
+	}
+	b := make([]byte, 32)
+	if _, err = rand.Read(b); err != nil {
+		return "", "", expires, err
+	}
+	token = "lfs_" + hex.EncodeToString(b)
+	expires = time.Now().Add(sessionLifetime)
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+	// forget expired sessions while we're here
+	for hash, s := range sessions {
+		if time.Now().After(s.expires) {
+			delete(sessions, hash)
+		}
+	}
+	sessions[hashToken(token)] = session{username: username, expires: expires}
+	logger.Logger.WithField("context", "Auth").Infof("%s logged in", username)
+	return token, role, expires, nil
+}
+
+/*
+Checks whether an address may try to log in. If it has failed too often,
+it gets how long until it may try again.
+*/
+func LoginAllowed(addr string) (wait time.Duration, ok bool) {
+	loginsMu.Lock()
+	defer loginsMu.Unlock()
+	f, exists := failedLogins[addr]
+	if !exists || f.count < maxLoginFailures {
+		return 0, true
+	}
+	wait = time.Until(f.since.Add(loginWindow))
+	if wait <= 0 {
+		delete(failedLogins, addr)
+		return 0, true
+	}
+	return wait, false
+}
+
+// The error for an address which must wait before trying to log in again
+func tooManyLogins(wait time.Duration) *api.Error {
+	e := api.NewError(http.StatusTooManyRequests, api.CodeTooManyRequests, errors.New("too many failed logins, try again later"))
+	e.Header = http.Header{"Retry-After": {strconv.Itoa(int(math.Ceil(wait.Seconds())))}}
+	return e
+}
+
+// Records the result of a login from an address. Success forgets its failures
+func LoginAttempted(addr string, success bool) {
+	loginsMu.Lock()
+	defer loginsMu.Unlock()
+	if success {
+		delete(failedLogins, addr)
+		return
+	}
+	// forget failures whose window has passed while we're here
+	for a, f := range failedLogins {
+		if time.Since(f.since) > loginWindow {
+			delete(failedLogins, a)
+		}
+	}
+	f, exists := failedLogins[addr]
+	if !exists {
+		f = &loginFailures{since: time.Now()}
+		failedLogins[addr] = f
+	}
+	f.count++
+	if f.count == maxLoginFailures {
+		logger.Logger.WithField("context", "Auth").Warnf("Too many failed logins from %s, refusing logins for %v", addr, loginWindow)
+	}
+}
+
+// Ends a session
+func Logout(token string) {
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+	delete(sessions, hashToken(token))
+}
+
+// checks an api token or session token
+func checkToken(token string) (string, error) {
+	if _, role, ok := config.CheckToken(token); ok {
+		return role, nil
+	}
+	sessionsMu.Lock()
+	s, ok := sessions[hashToken(token)]
+	sessionsMu.Unlock()
+	if !ok || time.Now().After(s.expires) {
+		return "", errUnauthorized
+	}
+	// the user may have been deleted or had their role changed since logging in
+	for _, u := range config.GetAuth().Users {
+		if u.Username == s.username {
+			return u.Role, nil
+		}
+	}
+	return "", errUnauthorized
+}
+
+// whether a role may make a request
+func permitted(role string, r *http.Request) bool {
+	if role == config.RoleAdmin {
+		return true
+	}
+	if hasPrefix(r.URL.Path, adminPaths) {
+		return false
+	}
+	return r.Method == http.MethodGet || r.Method == http.MethodHead
+}
+
+func isWebsocketUpgrade(r *http.Request) bool {
+	return hasPrefix(r.URL.Path, websocketPaths) && strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
+func hasPrefix(path string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
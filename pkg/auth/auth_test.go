@@ -0,0 +1,205 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/LedFx/ledfx/pkg/api"
+	"github.com/LedFx/ledfx/pkg/config"
+)
+
+func testServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	r := api.NewRouter("/api/v1")
+	NewV1API(r)
+	r.Serve(mux)
+	mux.HandleFunc("/api/things", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(RequestRole(r)))
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
+	server := httptest.NewServer(Middleware(mux))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func do(t *testing.T, method, url string, setup func(r *http.Request)) int {
+	r, err := http.NewRequest(method, url, strings.NewReader("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if setup != nil {
+		setup(r)
+	}
+	res, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	return res.StatusCode
+}
+
+func bearer(token string) func(r *http.Request) {
+	return func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+token) }
+}
+
+func TestAuth(t *testing.T) {
+	defer config.DisableSaving()()
+	server := testServer(t)
+
+	// everyone is an admin until auth is enabled
+	if code := do(t, http.MethodPost, server.URL+"/api/things", nil); code != http.StatusOK {
+		t.Errorf("Expected open access while auth is disabled, got %d", code)
+	}
+	if err := config.SetAuth(map[string]interface{}{"enabled": true}); err == nil {
+		t.Fatal("Expected enabling auth without an admin to fail")
+	}
+
+	admin, err := config.CreateToken("test admin", config.RoleAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read, err := config.CreateToken("test read", config.RoleRead)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = config.SetUser("tester", "correct horse", config.RoleRead); err != nil {
+		t.Fatal(err)
+	}
+	if err = config.SetAuth(map[string]interface{}{"enabled": true}); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		config.SetAuth(map[string]interface{}{"enabled": false})
+		config.RevokeToken("test admin")
+		config.RevokeToken("test read")
+		config.DeleteUser("tester")
+	}()
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		setup  func(r *http.Request)
+		code   int
+	}{
+		{"no credentials", http.MethodGet, "/api/things", nil, http.StatusUnauthorized},
+		{"wrong token", http.MethodGet, "/api/things", bearer("lfx_nope"), http.StatusUnauthorized},
+		{"web interface", http.MethodGet, "/index.html", nil, http.StatusOK},
+		{"status", http.MethodGet, "/api/v1/auth/status", nil, http.StatusOK},
+		{"read gets", http.MethodGet, "/api/things", bearer(read), http.StatusOK},
+		{"read posts", http.MethodPost, "/api/things", bearer(read), http.StatusForbidden},
+		{"read lists tokens", http.MethodGet, "/api/v1/auth/tokens", bearer(read), http.StatusForbidden},
+		{"admin posts", http.MethodPost, "/api/things", bearer(admin), http.StatusOK},
+		{"admin lists tokens", http.MethodGet, "/api/v1/auth/tokens", bearer(admin), http.StatusOK},
+		{"token param", http.MethodGet, "/api/things?token=" + read, nil, http.StatusUnauthorized},
+		{"websocket token param", http.MethodGet, "/websocket?token=" + read, func(r *http.Request) { r.Header.Set("Upgrade", "websocket") }, http.StatusOK},
+		{"read gets mqtt", http.MethodGet, "/api/v1/mqtt", bearer(read), http.StatusForbidden},
+		{"read gets quarantine", http.MethodGet, "/api/v1/config/quarantine", bearer(read), http.StatusForbidden},
+		{"password", http.MethodGet, "/api/things", func(r *http.Request) { r.SetBasicAuth("tester", "correct horse") }, http.StatusOK},
+		{"wrong password", http.MethodGet, "/api/things", func(r *http.Request) { r.SetBasicAuth("tester", "wrong horse") }, http.StatusUnauthorized},
+	}
+	for _, c := range cases {
+		if code := do(t, c.method, server.URL+c.path, c.setup); code != c.code {
+			t.Errorf("%s: expected %d, got %d", c.name, c.code, code)
+		}
+	}
+
+	// sessions last until logout, and follow the user's role
+	token, role, _, err := Login("tester", "correct horse")
+	if err != nil || role != config.RoleRead {
+		t.Fatalf("Expected to log in as read, got %s %v", role, err)
+	}
+	if code := do(t, http.MethodGet, server.URL+"/api/things", bearer(token)); code != http.StatusOK {
+		t.Errorf("Expected the session to work, got %d", code)
+	}
+	if err = config.DeleteUser("tester"); err != nil {
+		t.Fatal(err)
+	}
+	if code := do(t, http.MethodGet, server.URL+"/api/things", bearer(token)); code != http.StatusUnauthorized {
+		t.Errorf("Expected the session to end with the user, got %d", code)
+	}
+	Logout(token)
+
+	// repeated failed logins are refused for a while, even with the right password
+	forgetFailures := func() {
+		loginsMu.Lock()
+		failedLogins = map[string]*loginFailures{}
+		loginsMu.Unlock()
+	}
+	forgetFailures()
+	if err = config.SetUser("tester", "correct horse", config.RoleRead); err != nil {
+		t.Fatal(err)
+	}
+	login := func(password string) int {
+		res, err := http.Post(server.URL+"/api/v1/auth/login", "application/json", strings.NewReader(`{"username": "tester", "password": "`+password+`"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+	for i := 0; i < maxLoginFailures; i++ {
+		if code := login("wrong horse"); code != http.StatusUnauthorized {
+			t.Errorf("Expected a wrong password to be refused, got %d", code)
+		}
+	}
+	if code := login("correct horse"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected logins to be rate limited, got %d", code)
+	}
+	forgetFailures()
+	if code := login("correct horse"); code != http.StatusOK {
+		t.Errorf("Expected to log in once the limit is lifted, got %d", code)
+	}
+
+	// and so are passwords given with any other request
+	basic := func(password string) func(r *http.Request) {
+		return func(r *http.Request) { r.SetBasicAuth("tester", password) }
+	}
+	for i := 0; i < maxLoginFailures; i++ {
+		if code := do(t, http.MethodGet, server.URL+"/api/things", basic("wrong horse")); code != http.StatusUnauthorized {
+			t.Errorf("Expected a wrong password to be refused, got %d", code)
+		}
+	}
+	if code := do(t, http.MethodGet, server.URL+"/api/things", basic("correct horse")); code != http.StatusTooManyRequests {
+		t.Errorf("Expected passwords to be rate limited, got %d", code)
+	}
+	if code := login("correct horse"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected wrong passwords to limit logins too, got %d", code)
+	}
+	forgetFailures()
+
+	if err = config.RevokeToken("test admin"); err == nil {
+		t.Error("Expected revoking the last admin token to fail while auth is enabled")
+	}
+}
+
+func TestAllowedOrigin(t *testing.T) {
+	defer config.DisableSaving()()
+
+	r := httptest.NewRequest(http.MethodGet, "http://ledfx.local:8080/api/things", nil)
+	r.Header.Set("Origin", "http://evil.example")
+	if origin, ok := AllowedOrigin(r); !ok || origin != "*" {
+		t.Errorf("Expected any origin to be allowed by default, got %s %v", origin, ok)
+	}
+
+	if err := config.SetAuth(map[string]interface{}{"allowed_origins": []string{"http://localhost:3000"}}); err != nil {
+		t.Fatal(err)
+	}
+	defer config.SetAuth(map[string]interface{}{"allowed_origins": []string{}})
+	if _, ok := AllowedOrigin(r); ok || CheckOrigin(r) {
+		t.Error("Expected an unlisted origin to be refused")
+	}
+	r.Header.Set("Origin", "http://localhost:3000")
+	if origin, ok := AllowedOrigin(r); !ok || origin != "http://localhost:3000" {
+		t.Errorf("Expected a listed origin to be allowed, got %s %v", origin, ok)
+	}
+	r.Header.Set("Origin", "http://ledfx.local:8080")
+	if _, ok := AllowedOrigin(r); !ok {
+		t.Error("Expected the web interface's own origin to be allowed")
+	}
+	if err := config.SetAuth(map[string]interface{}{"allowed_origins": []string{"not a url"}}); err == nil {
+		t.Error("Expected an invalid origin to be refused")
+	}
+}
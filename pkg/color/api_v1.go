@@ -0,0 +1,16 @@
+package color
+
+import (
+	"github.com/LedFx/ledfx/pkg/api"
+)
+
+type colorsJSON struct {
+	Colors   map[string]string `json:"colors" description:"Hex colors, by name"`
+	Palettes map[string]string `json:"palettes" description:"Palettes, by name"`
+}
+
+func NewV1API(r *api.Router) {
+	r.Get("/colors", func(*api.Request) (interface{}, error) {
+		return colorsJSON{Colors: LedFxColors, Palettes: LedFxPalettes}, nil
+	}).Doc("Colors", "Get the named colors and palettes").Returns(api.TypeSchema(colorsJSON{}))
+}
@@ -0,0 +1,19 @@
+package color
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/LedFx/ledfx/pkg/api"
+	"github.com/LedFx/ledfx/pkg/api/apitest"
+)
+
+func TestV1API(t *testing.T) {
+	r := api.NewRouter("/api/v1")
+	NewV1API(r)
+	apitest.Run(t, r, []apitest.Case{
+		{Method: http.MethodGet, Path: "/api/v1/colors", Code: http.StatusOK},
+		{Method: http.MethodPut, Path: "/api/v1/colors", Code: http.StatusMethodNotAllowed},
+	})
+	apitest.Documents(t, r, "/api/v1", []string{"/colors"}, nil)
+}
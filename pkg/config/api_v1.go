@@ -0,0 +1,44 @@
+package config
+
+import (
+	"github.com/LedFx/ledfx/pkg/api"
+	"github.com/LedFx/ledfx/pkg/logger"
+)
+
+func NewV1API(r *api.Router) {
+	schema, err := SettingsSchema()
+	if err != nil {
+		logger.Logger.WithField("context", "Settings API").Error(err)
+	}
+	r.Schema("Settings", api.ConfigSchema(schema))
+	r.Schema("QuarantinedEntry", api.TypeSchema(QuarantinedEntry{}))
+
+	r.Get("/settings", func(*api.Request) (interface{}, error) {
+		// the active settings, including environment variables and command line flags
+		return GetSettings(), nil
+	}).Doc("Settings", "Get the active settings").Returns(api.Ref("Settings"))
+
+	r.Put("/settings", func(req *api.Request) (interface{}, error) {
+		settings := map[string]interface{}{}
+		if err := req.Decode(&settings); err != nil {
+			return nil, err
+		}
+		if err := SetSettings(settings); err != nil {
+			return nil, api.BadRequest(err)
+		}
+		return GetSettings(), nil
+	}).Doc("Settings", "Update the settings. Only the settings given are changed").
+		Accepts(api.Ref("Settings")).Returns(api.Ref("Settings"))
+
+	r.Get("/settings/schema", func(*api.Request) (interface{}, error) {
+		return SettingsSchema()
+	}).Doc("Settings", "Get the schema of the settings, for building forms").Returns(map[string]interface{}{"type": "object"})
+
+	r.Get("/config/quarantine", func(*api.Request) (interface{}, error) {
+		return GetQuarantine(), nil
+	}).Doc("Config", "Get the invalid config entries which were quarantined").Returns(api.ArrayOf(api.Ref("QuarantinedEntry")))
+
+	r.Delete("/config/quarantine", func(*api.Request) (interface{}, error) {
+		return nil, ClearQuarantine()
+	}).Doc("Config", "Discard the quarantined config entries")
+}
@@ -0,0 +1,31 @@
+package config
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/LedFx/ledfx/pkg/api"
+	"github.com/LedFx/ledfx/pkg/api/apitest"
+)
+
+func TestV1API(t *testing.T) {
+	_, restore := loadTestConfig(t, `{"version": 1, "settings": {"port": 8080}}`)
+	defer restore()
+	r := api.NewRouter("/api/v1")
+	NewV1API(r)
+
+	apitest.Run(t, r, []apitest.Case{
+		{Method: http.MethodGet, Path: "/api/v1/settings", Code: http.StatusOK},
+		{Method: http.MethodPut, Path: "/api/v1/settings", Body: `{"port": 8081}`, Code: http.StatusOK},
+		{Method: http.MethodPut, Path: "/api/v1/settings", Body: `{"port": 100000}`, Code: http.StatusBadRequest},
+		{Method: http.MethodPut, Path: "/api/v1/settings", Body: `[`, Code: http.StatusBadRequest},
+		{Method: http.MethodGet, Path: "/api/v1/settings/schema", Code: http.StatusOK},
+		{Method: http.MethodGet, Path: "/api/v1/config/quarantine", Code: http.StatusOK},
+		{Method: http.MethodDelete, Path: "/api/v1/config/quarantine", Code: http.StatusNoContent},
+		{Method: http.MethodPost, Path: "/api/v1/settings", Code: http.StatusMethodNotAllowed},
+	})
+	if port := GetSettings().Port; port != 8081 {
+		t.Errorf("Expected the port to be updated, got %d", port)
+	}
+	apitest.Documents(t, r, "/api/v1", []string{"/settings", "/config/quarantine"}, []string{"Settings", "QuarantinedEntry"})
+}
@@ -1,5 +1,16 @@
 package config
 
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/LedFx/ledfx/pkg/event"
+	"github.com/LedFx/ledfx/pkg/logger"
+	"github.com/LedFx/ledfx/pkg/util"
+
+	"github.com/mitchellh/mapstructure"
+)
+
 type AudioDevice struct {
 	Id          string  `mapstructure:"id" json:"id"`
 	HostApi     string  `mapstructure:"hostapi" json:"hostapi"`
@@ -11,10 +22,93 @@ type AudioDevice struct {
 	Source      string  `mapstructure:"source" json:"source"`
 }
 
+// Parameters of the audio analysis. The EQ is a biquad filter applied before the fft.
 type AudioConfig struct {
-	Device    AudioDevice `mapstructure:"device" json:"device"`
-	FftSize   int         `mapstructure:"fft_size" json:"fft_size"`
-	FrameRate int         `mapstructure:"frame_rate" json:"frame_rate"`
+	FftSize    int     `mapstructure:"fft_size" json:"fft_size" description:"Size of the FFT window used for frequency analysis" default:"4096" validate:"oneof=512 1024 2048 4096 8192"`
+	BufferSize int     `mapstructure:"buffer_size" json:"buffer_size" description:"Number of samples requested from the audio device per buffer" default:"1024" validate:"gte=128,lte=4096"`
+	SampleRate int     `mapstructure:"sample_rate" json:"sample_rate" description:"Sample rate requested from the audio device. Use 0 for the device's own rate" default:"0" validate:"gte=0,lte=192000"`
+	Stereo     bool    `mapstructure:"stereo" json:"stereo" description:"Capture two channels and analyse left and right separately" default:"false" validate:""`
+	EqB0       float64 `mapstructure:"eq_b0" json:"eq_b0" description:"EQ biquad feedforward coefficient b0" default:"1" validate:"gte=-10,lte=10"`
+	EqB1       float64 `mapstructure:"eq_b1" json:"eq_b1" description:"EQ biquad feedforward coefficient b1" default:"-2" validate:"gte=-10,lte=10"`
+	EqB2       float64 `mapstructure:"eq_b2" json:"eq_b2" description:"EQ biquad feedforward coefficient b2" default:"1" validate:"gte=-10,lte=10"`
+	EqA1       float64 `mapstructure:"eq_a1" json:"eq_a1" description:"EQ biquad feedback coefficient a1" default:"-2" validate:"gte=-10,lte=10"`
+	EqA2       float64 `mapstructure:"eq_a2" json:"eq_a2" description:"EQ biquad feedback coefficient a2" default:"1" validate:"gte=-10,lte=10"`
+}
+
+// Generate audio config schema
+func AudioSchema() (schema map[string]interface{}, err error) {
+	return util.CreateSchema(reflect.TypeOf((*AudioConfig)(nil)).Elem())
+}
+
+// Generate audio config schema as json
+func AudioJsonSchema() (jsonSchema []byte, err error) {
+	schema, err := AudioSchema()
+	if err != nil {
+		return jsonSchema, err
+	}
+	jsonSchema, err = util.CreateJsonSchema(schema)
+	return jsonSchema, err
+}
+
+func GetAudio() AudioConfig {
+	return store.Audio
+}
+
+// Incrementally updates the audio config. Listeners of the audio update event
+// are responsible for applying the new values to the analyzer and audio sources.
+// They run asynchronously, each on its own goroutine. The analyzers' subscriber must be safe to run
+// alongside the audio API, which configures the analyzers directly so it can respond with their new state.
+// The audio bridge's subscriber is the only one which restarts the local capture.
+func SetAudio(c map[string]interface{}) error {
+	mu.Lock()
+	defer mu.Unlock()
+	prevAudio := store.Audio
+	err := mapstructure.Decode(c, &store.Audio)
+	if err != nil {
+		store.Audio = prevAudio
+		logger.Logger.WithField("context", "Config").Warn(err)
+		return err
+	}
+	err = validate.Struct(&store.Audio)
+	if err == nil && store.Audio.FftSize < store.Audio.BufferSize {
+		err = fmt.Errorf("fft size %d must not be smaller than the buffer size %d", store.Audio.FftSize, store.Audio.BufferSize)
+	}
+	if err != nil {
+		store.Audio = prevAudio
+		logger.Logger.WithField("context", "Config").Warn(err)
+		return err
+	}
+	err = saveConfig()
+	event.Invoke(event.AudioUpdateData{
+		Config: store.Audio,
+	})
+	return err
+}
+
+// Named audio sources, which run alongside the audio bridge. Maps source ids to local audio device ids.
+func GetAudioSources() map[string]string {
+	return store.AudioSources
+}
+
+func SetAudioSource(id, deviceID string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if store.AudioSources == nil {
+		store.AudioSources = map[string]string{}
+	}
+	store.AudioSources[id] = deviceID
+	return saveConfig()
+}
+
+func DeleteAudioSource(id string) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, exists := store.AudioSources[id]; !exists {
+		return
+	}
+	delete(store.AudioSources, id)
+	logger.Logger.WithField("context", "Config").Debugf("Deleted audio source %s from config", id)
+	saveConfig()
 }
 
 func GetLocalInput() string {
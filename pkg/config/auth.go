@@ -0,0 +1,243 @@
+package config
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/LedFx/ledfx/pkg/logger"
+
+	"github.com/mitchellh/mapstructure"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Roles given to tokens and users
+const (
+	RoleRead  = "read"  // can view everything, but not change anything
+	RoleAdmin = "admin" // can do anything
+)
+
+// Access control for the api and websocket
+type AuthConfig struct {
+	Enabled        bool        `mapstructure:"enabled" json:"enabled" description:"Require a token or password to use the api and websocket" default:"false" validate:""`
+	AllowedOrigins []string    `mapstructure:"allowed_origins" json:"allowed_origins" description:"Web pages allowed to use the api from a browser, eg. http://localhost:3000. LedFx's own web interface is always allowed. Leave empty to allow any" validate:"dive,url"`
+	Tokens         []AuthToken `mapstructure:"tokens" json:"tokens" validate:"dive"`
+	Users          []AuthUser  `mapstructure:"users" json:"users" validate:"dive"`
+}
+
+// An api token. Only a hash of the token is saved, so it's shown once when it's created
+type AuthToken struct {
+	Name    string    `mapstructure:"name" json:"name" validate:"required"`
+	Role    string    `mapstructure:"role" json:"role" validate:"oneof=read admin"`
+	Hash    string    `mapstructure:"hash" json:"hash" validate:"required"`
+	Created time.Time `mapstructure:"created" json:"created"`
+}
+
+type AuthUser struct {
+	Username     string `mapstructure:"username" json:"username" validate:"required"`
+	Role         string `mapstructure:"role" json:"role" validate:"oneof=read admin"`
+	PasswordHash string `mapstructure:"password_hash" json:"password_hash" validate:"required"`
+}
+
+func GetAuth() AuthConfig {
+	mu.Lock()
+	defer mu.Unlock()
+	return store.Auth
+}
+
+/*
+Incrementally updates whether auth is enabled and the allowed origins.
+Auth can't be enabled without an admin token or user, so nobody is locked out.
+*/
+func SetAuth(c map[string]interface{}) error {
+	mu.Lock()
+	defer mu.Unlock()
+	a := store.Auth
+	if v, ok := c["enabled"]; ok {
+		if err := mapstructure.Decode(v, &a.Enabled); err != nil {
+			return err
+		}
+	}
+	if v, ok := c["allowed_origins"]; ok {
+		a.AllowedOrigins = nil
+		if err := mapstructure.Decode(v, &a.AllowedOrigins); err != nil {
+			return err
+		}
+	}
+	if err := validate.Struct(&a); err != nil {
+		return err
+	}
+	if a.Enabled && !a.hasAdmin() {
+		return errors.New("create an admin token or user before enabling auth")
+	}
+	store.Auth = a
+	return saveConfig()
+}
+
+// Creates a token with a role, returning the token. It can't be retrieved again
+func CreateToken(name, role string) (string, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, t := range store.Auth.Tokens {
+		if t.Name == name {
+			return "", fmt.Errorf("token %s already exists", name)
+		}
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := "lfx_" + hex.EncodeToString(b)
+	t := AuthToken{Name: name, Role: role, Hash: hashToken(token), Created: time.Now().UTC()}
+	if err := validate.Struct(&t); err != nil {
+		return "", err
+	}
+	store.Auth.Tokens = append(store.Auth.Tokens, t)
+	return token, saveConfig()
+}
+
+// Deletes a token by name
+func RevokeToken(name string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	a := store.Auth
+	a.Tokens = []AuthToken{}
+	for _, t := range store.Auth.Tokens {
+		if t.Name != name {
+			a.Tokens = append(a.Tokens, t)
+		}
+	}
+	if len(a.Tokens) == len(store.Auth.Tokens) {
+		return fmt.Errorf("token %s does not exist", name)
+	}
+	if a.Enabled && !a.hasAdmin() {
+		return errors.New("cannot revoke the last admin token while auth is enabled")
+	}
+	store.Auth = a
+	return saveConfig()
+}
+
+// Creates a user, or changes the password and role of an existing user
+func SetUser(username, password, role string) error {
+	if len(password) < 8 {
+		return errors.New("password must be at least 8 characters")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	u := AuthUser{Username: username, Role: role, PasswordHash: string(hash)}
+	if err = validate.Struct(&u); err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	a := store.Auth
+	a.Users = []AuthUser{u}
+	for _, prev := range store.Auth.Users {
+		if prev.Username != username {
+			a.Users = append(a.Users, prev)
+		}
+	}
+	if a.Enabled && !a.hasAdmin() {
+		return errors.New("cannot remove the last admin while auth is enabled")
+	}
+	store.Auth = a
+	return saveConfig()
+}
+
+// Deletes a user
+func DeleteUser(username string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	a := store.Auth
+	a.Users = []AuthUser{}
+	for _, u := range store.Auth.Users {
+		if u.Username != username {
+			a.Users = append(a.Users, u)
+		}
+	}
+	if len(a.Users) == len(store.Auth.Users) {
+		return fmt.Errorf("user %s does not exist", username)
+	}
+	if a.Enabled && !a.hasAdmin() {
+		return errors.New("cannot delete the last admin while auth is enabled")
+	}
+	store.Auth = a
+	return saveConfig()
+}
+
+// Checks a token, returning its name and role
+func CheckToken(token string) (name, role string, ok bool) {
+	hash := hashToken(token)
+	mu.Lock()
+	defer mu.Unlock()
+	for _, t := range store.Auth.Tokens {
+		if t.Hash == hash {
+			return t.Name, t.Role, true
+		}
+	}
+	return "", "", false
+}
+
+// Checks a username and password, returning the user's role
+func CheckPassword(username, password string) (role string, ok bool) {
+	mu.Lock()
+	var user *AuthUser
+	for _, u := range store.Auth.Users {
+		if u.Username == username {
+			u := u
+			user = &u
+			break
+		}
+	}
+	mu.Unlock()
+	if user == nil {
+		return "", false
+	}
+	// hashing is slow on purpose, so don't hold the lock
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		return "", false
+	}
+	return user.Role, true
+}
+
+// creates a token for the --create_token arg
+func createTokenAndExit(name, role string) {
+	token, err := CreateToken(name, role)
+	if err == nil {
+		err = Flush()
+	}
+	if err != nil {
+		logger.Logger.WithField("context", "Command Line Arguments").Fatal(err)
+	}
+	fmt.Printf("Created %s token %s. Keep it safe, it won't be shown again:\n%s\n", role, name, token)
+	if !GetAuth().Enabled {
+		fmt.Println("Auth is not enabled yet. Enable it with PUT /api/auth {\"enabled\": true}, or in the auth section of the config file.")
+	}
+	os.Exit(0)
+}
+
+func (a *AuthConfig) hasAdmin() bool {
+	for _, t := range a.Tokens {
+		if t.Role == RoleAdmin {
+			return true
+		}
+	}
+	for _, u := range a.Users {
+		if u.Role == RoleAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// tokens are long and random, so a fast hash is enough
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
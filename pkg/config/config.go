@@ -2,10 +2,13 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"sync"
 
 	"github.com/LedFx/ledfx/pkg/constants"
@@ -23,6 +26,10 @@ var (
 	configPath  string
 	hostArg     string
 	portArg     int
+	tlsArg      bool
+	tlsCertArg  string
+	tlsKeyArg   string
+	redirectArg int
 	noLogoArg   bool
 	noTrayArg   bool
 	noUpdateArg bool
@@ -31,14 +38,22 @@ var (
 	logLevelArg int
 )
 
-var AllowSaving bool = true
+var readOnly bool // the config file is from a newer LedFx, so it isn't saved. guarded by mu
 var mu sync.Mutex = sync.Mutex{}
 var validate *validator.Validate = validator.New()
 var store *config = &config{
-	Settings:    SettingsConfig{},
-	Effects:     map[string]EffectEntry{},
-	Devices:     map[string]DeviceEntry{},
-	Controllers: map[string]ControllerEntry{},
+	Settings:     SettingsConfig{},
+	Effects:      map[string]EffectEntry{},
+	Devices:      map[string]DeviceEntry{},
+	Controllers:  map[string]ControllerEntry{},
+	AudioSources: map[string]string{},
+}
+
+// the config entry sections, which are loaded entry by entry
+var entrySections = map[string]EntryType{
+	"effects":     Effect,
+	"devices":     Device,
+	"controllers": Controller,
 }
 
 type BaseDeviceConfig struct {
@@ -47,15 +62,16 @@ type BaseDeviceConfig struct {
 }
 
 type ControllerConfig struct {
-	Name      string `mapstructure:"name" json:"name" description:"Display name for the controller" validate:"required"`
-	IconName  string `mapstructure:"icon_name" json:"icon_name" description:"Icon name to identify this controller" default:"alert-circle-outline" validate:""`
-	FrameRate int    `mapstructure:"framerate" json:"framerate" description:"Target framerate" default:"60" validate:"gte=5,lte=120"`
+	Name       string  `mapstructure:"name" json:"name" description:"Display name for the controller" validate:"required"`
+	IconName   string  `mapstructure:"icon_name" json:"icon_name" description:"Icon name to identify this controller" default:"alert-circle-outline" validate:""`
+	FrameRate  int     `mapstructure:"framerate" json:"framerate" description:"Target framerate" default:"60" validate:"gte=5,lte=120"`
+	Brightness float64 `mapstructure:"brightness" json:"brightness" description:"Brightness of the controller, applied on top of its effect" default:"1" validate:"gte=0,lte=1"`
 	// Span      bool            `mapstructure:"span" json:"span"`
 	// Outputs   []ControllerOutput `mapstructure:"outputs" json:"outputs"`
 }
 
 type config struct {
-	//Version  string                  `mapstructure:"version" json:"version"`
+	Version       int                        `mapstructure:"version" json:"version"`
 	Settings      SettingsConfig             `mapstructure:"core" json:"core"`
 	Frontend      FrontendConfig             `mapstructure:"frontend" json:"frontend"`
 	Effects       map[string]EffectEntry     `mapstructure:"effects" json:"effects"`
@@ -66,31 +82,62 @@ type config struct {
 	ConnDevice    map[string]string          `mapstructure:"connections_device" json:"connections_device"`
 	VirtStates    map[string]bool            `mapstructure:"controller_states" json:"controller_states"`
 	LocalInput    string                     `mapstructure:"local_input" json:"local_input"`
-	// Audio    AudioEntry              `mapstructure:"audio" json:"audio"`
-	// Audio    AudioConfig             `mapstructure:"audio" json:"audio"`
+	Audio         AudioConfig                `mapstructure:"audio" json:"audio"`
+	AudioSources  map[string]string          `mapstructure:"audio_sources" json:"audio_sources"`
+	Silence       SilenceConfig              `mapstructure:"silence" json:"silence"`
+	Mqtt          MqttConfig                 `mapstructure:"mqtt" json:"mqtt"`
+	Osc           OscConfig                  `mapstructure:"osc" json:"osc"`
+	DmxInput      DmxInputConfig             `mapstructure:"dmx_input" json:"dmx_input"`
+	Auth          AuthConfig                 `mapstructure:"auth" json:"auth"`
+	Quarantine    []QuarantinedEntry         `mapstructure:"quarantine" json:"quarantine,omitempty"`
+	Profile       string                     `mapstructure:"profile" json:"profile" default:"default"`
+	Profiles      map[string]Profile         `mapstructure:"profiles" json:"profiles"`
+}
+
+// Creates a config with default values, at the current version
+func newConfig() (*config, error) {
+	c := &config{
+		Version:      CurrentVersion,
+		Settings:     SettingsConfig{},
+		Effects:      map[string]EffectEntry{},
+		Devices:      map[string]DeviceEntry{},
+		Controllers:  map[string]ControllerEntry{},
+		AudioSources: map[string]string{},
+		Profiles:     map[string]Profile{},
+	}
+	err := defaults.Set(c)
+	return c, err
 }
 
 /* Populates the config store (live config in memory).
 1. set config store to defaults
 2. update with any values from the config file
-3. update with any command line args
+3. update with any environment variables
+4. update with any command line args
 */
 func init() {
 	// special args
 	var version bool
+	var createToken, tokenRole string
 
 	pflag.CommandLine.SortFlags = false
 
 	pflag.BoolVarP(&version, "version", "v", false, "Print the version of LedFx and exit")
-	pflag.StringVarP(&configPath, "config", "c", "", "Path to json configuration file")
+	pflag.StringVarP(&configPath, "config", "c", "", "Path to json or yaml configuration file")
 	pflag.StringVarP(&hostArg, "host", "h", "0.0.0.0", "Web interface hostname")
 	pflag.IntVarP(&portArg, "port", "p", 8080, "Web interface port")
+	pflag.BoolVar(&tlsArg, "tls", false, "Serve the web interface over HTTPS")
+	pflag.StringVar(&tlsCertArg, "tls_cert", "", "Path to the TLS certificate. A self-signed certificate is made in the config dir if not given")
+	pflag.StringVar(&tlsKeyArg, "tls_key", "", "Path to the TLS certificate's private key")
+	pflag.IntVar(&redirectArg, "redirect_port", 0, "Port to redirect HTTP to HTTPS from. 0 disables the redirect")
 	pflag.BoolVarP(&noLogoArg, "no_logo", "n", false, "Hide the command line logo at startup")
 	pflag.BoolVarP(&noTrayArg, "no_tray", "t", false, "Disable system tray icon to access LedFx")
 	pflag.BoolVarP(&noUpdateArg, "no_update", "u", false, "Disable automatic updates at startup")
 	pflag.BoolVarP(&noScanArg, "no_scan", "s", false, "Disable automatic WLED scanning and configuration in LedFx")
 	pflag.BoolVarP(&openUiArg, "open_ui", "o", false, "Automatically open the web interface at startup")
 	pflag.IntVarP(&logLevelArg, "log_level", "l", 2, "Set log level [0: debug, 1: info, 2: warnings]")
+	pflag.StringVar(&createToken, "create_token", "", "Create an api token with this name, print it and exit")
+	pflag.StringVar(&tokenRole, "token_role", RoleAdmin, "Role of the token made by --create_token [admin, read]")
 	// pflag.BoolP("offline", "o", false, "Disable automated updates and sentry crash logger")
 
 	pflag.Parse()
@@ -105,6 +152,10 @@ func init() {
 	SettingsConfigArgs := SettingsConfig{
 		Host:     hostArg,
 		Port:     portArg,
+		TLS:      tlsArg,
+		TLSCert:  tlsCertArg,
+		TLSKey:   tlsKeyArg,
+		Redirect: redirectArg,
 		NoLogo:   noLogoArg,
 		OpenUi:   openUiArg,
 		NoUpdate: noUpdateArg,
@@ -115,20 +166,36 @@ func init() {
 		logger.Logger.WithField("context", "Command Line Arguments").Fatal(err)
 	}
 
+	// read and validate settings from the environment
+	if err = loadEnvSettings(); err != nil {
+		logger.Logger.WithField("context", "Environment Variables").Fatal(err)
+	}
+	if configPath == "" {
+		configPath = os.Getenv(envName("config"))
+	}
+
 	// apply defaults to the config
-	err = defaults.Set(store)
+	store, err = newConfig()
 	if err != nil {
 		logger.Logger.WithField("context", "Config").Fatal(err)
 	}
 
 	// load any config saved on file
 	loadConfig()
-	// TODO validate config loaded from json
+
+	// just create a token and exit if asked to
+	if createToken != "" {
+		createTokenAndExit(createToken, tokenRole)
+	}
 
 	logger.Logger.WithField("context", "Config").Infof("Initialised config")
 }
 
-// LoadConfig reads in config file and populates the config instance.
+/*
+LoadConfig reads in config file and populates the config instance.
+Older config files are backed up and migrated to the current version.
+Entries and sections which are invalid are quarantined, and the rest of the config loads.
+*/
 func loadConfig() {
 
 	// make sure config file can be opened
@@ -143,12 +210,124 @@ func loadConfig() {
 		logger.Logger.WithField("context", "Config").Fatal("Error reading config file: ", err)
 	}
 
-	// parse as json
-	// unknown keys will be ignored
-	err = json.Unmarshal(content, &store)
+	// parse as json or yaml
+	raw, err := parseConfig(content, isYaml(configPath))
 	if err != nil {
 		logger.Logger.WithField("context", "Config").Fatal("Error parsing config file: ", err)
 	}
+	if version := configVersion(raw); version < CurrentVersion {
+		if err = backupForMigration(version, content); err != nil {
+			logger.Logger.WithField("context", "Config").Fatal(err)
+		}
+	}
+	migrated, err := migrateConfig(raw)
+	newer := errors.Is(err, ErrNewerVersion)
+	if err != nil && !newer {
+		logger.Logger.WithField("context", "Config").Fatal(err)
+	}
+
+	loaded, err := newConfig()
+	if err != nil {
+		logger.Logger.WithField("context", "Config").Fatal(err)
+	}
+	quarantined := decodeConfig(loaded, raw)
+
+	mu.Lock()
+	defer mu.Unlock()
+	store = loaded
+	resetHistory()
+	// a newer config is used as it is, but never saved, so it still works with the newer LedFx
+	readOnly = newer
+	if newer {
+		logger.Logger.WithField("context", "Config").Warnf("%v. Loaded it read-only: changes will not be saved", err)
+	}
+	if quarantined > 0 {
+		logger.Logger.WithField("context", "Config").Warnf("Quarantined %d invalid parts of the config file. See /api/config/quarantine", quarantined)
+	}
+	if migrated || quarantined > 0 {
+		writeConfig()
+	}
+}
+
+/*
+Decodes a raw config section by section, and entries entry by entry.
+Unknown keys are ignored. Anything which can't be decoded or is invalid
+is quarantined, leaving defaults in its place. Returns how many were quarantined.
+*/
+func decodeConfig(c *config, raw map[string]interface{}) int {
+	// quarantine from previous loads is kept
+	if q, ok := raw["quarantine"]; ok {
+		if err := decodeJson(q, &c.Quarantine); err != nil {
+			logger.Logger.WithField("context", "Config").Warnf("Discarding unreadable quarantine: %v", err)
+			c.Quarantine = nil
+		}
+	}
+	count := len(c.Quarantine)
+	fields := map[string]reflect.Value{}
+	v := reflect.ValueOf(c).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		fields[strings.Split(v.Type().Field(i).Tag.Get("json"), ",")[0]] = v.Field(i)
+	}
+
+	for key, value := range raw {
+		field, known := fields[key]
+		if !known || key == "quarantine" {
+			continue
+		}
+		if t, isEntries := entrySections[key]; isEntries {
+			if value == nil {
+				continue
+			}
+			entries, ok := value.(map[string]interface{})
+			if !ok {
+				c.quarantine("section", key, value, fmt.Errorf("%s should be an object of entries", key))
+				continue
+			}
+			for id, entry := range entries {
+				e := reflect.New(field.Type().Elem())
+				if err := decodeJson(entry, e.Interface()); err != nil {
+					c.quarantine(t.String(), id, entry, err)
+					continue
+				}
+				field.SetMapIndex(reflect.ValueOf(id), e.Elem())
+			}
+			continue
+		}
+		// decode on top of the defaults, resetting to them if invalid
+		decoded := reflect.New(field.Type())
+		decoded.Elem().Set(field)
+		err := decodeJson(value, decoded.Interface())
+		if err == nil && field.Kind() == reflect.Struct {
+			err = validate.Struct(decoded.Interface())
+		}
+		if err != nil {
+			c.quarantine("section", key, value, err)
+			if key == "auth" {
+				// don't open up the api because of a typo. create a token from the command line to get in
+				c.Auth.Enabled = true
+			}
+			continue
+		}
+		field.Set(decoded.Elem())
+	}
+
+	// sections saved as null
+	if c.AudioSources == nil {
+		c.AudioSources = map[string]string{}
+	}
+	if c.Profiles == nil {
+		c.Profiles = map[string]Profile{}
+	}
+	return len(c.Quarantine) - count
+}
+
+// decodes a value parsed from json into a typed value, as json.Unmarshal would
+func decodeJson(value interface{}, target interface{}) error {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, target)
 }
 
 // Makes sure that a config can be opened at the configPath
@@ -160,6 +339,13 @@ func ensureConfigFile() error {
 	}
 	// if not supplied, make sure we have a config file in the default location
 	configDir := constants.GetOsConfigDir()
+	// prefer a yaml config, if there is one
+	for _, ext := range yamlExtensions {
+		configPath = filepath.Join(configDir, configName+ext)
+		if _, err := os.Stat(configPath); err == nil {
+			return nil
+		}
+	}
 	configPath = filepath.Join(configDir, configName+".json")
 	// first, ensure config directory exists
 	err := os.MkdirAll(configDir, 0744)
@@ -181,19 +367,6 @@ func ensureConfigFile() error {
 	// finally, test we can open the new blank config and write empty config to it
 	f, err := os.Open(configPath)
 	f.Close()
-	saveConfig()
-	return err
-}
-
-func saveConfig() error {
-	if !AllowSaving {
-		return nil
-	}
-	file, _ := json.MarshalIndent(store, "", "  ")
-	err := ioutil.WriteFile(configPath, file, 0644)
-	if err != nil {
-		logger.Logger.WithField("context", "Config").Warnf("Failed to save config to file at %s", configPath)
-	}
-	logger.Logger.WithField("context", "Config").Debugf("Saved config")
+	writeConfig()
 	return err
 }
@@ -0,0 +1,588 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// loads a config file from a temporary directory, restoring the real config afterwards
+func loadTestConfig(t *testing.T, content string) (path string, restore func()) {
+	return loadTestConfigFile(t, "config.json", content)
+}
+
+func loadTestConfigFile(t *testing.T, name, content string) (path string, restore func()) {
+	prevPath, prevStore := configPath, store
+	path = filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	configPath = path
+	loadConfig()
+	return path, func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if saveTimer != nil {
+			saveTimer.Stop()
+		}
+		savePending = time.Time{}
+		configPath, store = prevPath, prevStore
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	v0 := `{
+		"core": {"port": 8080},
+		"effects": {"fx": {"id": "wrong", "type": "energy", "base_config": {}}},
+		"devices": null,
+		"osc": {"enabled": true, "port": 99999}
+	}`
+	path, restore := loadTestConfig(t, v0)
+	defer restore()
+
+	backup, err := ioutil.ReadFile(path + ".v0.bak")
+	if err != nil || string(backup) != v0 {
+		t.Fatalf("Expected the v0 config to be backed up, got %v", err)
+	}
+	if store.Version != CurrentVersion {
+		t.Errorf("Expected version %d, got %d", CurrentVersion, store.Version)
+	}
+	if store.Effects["fx"].ID != "fx" {
+		t.Errorf("Expected the effect id to match its key, got %s", store.Effects["fx"].ID)
+	}
+	if store.Devices == nil {
+		t.Error("Expected a null section to be migrated to an empty one")
+	}
+	// invalid sections fall back to defaults
+	if store.Osc.Port != 9000 || store.Osc.Enabled {
+		t.Errorf("Expected the invalid osc section to be reset, got %+v", store.Osc)
+	}
+	q := GetQuarantine()
+	if len(q) != 1 || q[0].Type != "section" || q[0].ID != "osc" {
+		t.Fatalf("Expected the osc section to be quarantined, got %+v", q)
+	}
+
+	// the migrated config was saved, and loads again without migrating
+	saved, _ := ioutil.ReadFile(path)
+	var raw map[string]interface{}
+	if err = json.Unmarshal(saved, &raw); err != nil {
+		t.Fatal(err)
+	}
+	if raw["version"] != float64(CurrentVersion) || raw["quarantine"] == nil {
+		t.Errorf("Expected the migrated config to be saved with its quarantine, got %v", raw)
+	}
+	_, restore2 := loadTestConfig(t, string(saved))
+	defer restore2()
+	if len(GetQuarantine()) != 1 {
+		t.Errorf("Expected the quarantine to be kept, got %+v", GetQuarantine())
+	}
+}
+
+func TestMigrateDmxMapping(t *testing.T) {
+	v1 := `{
+		"version": 1,
+		"dmx_input": {"enabled": true, "universe": 3, "mode": "mapped", "brightness_channel": 5, "palette_channel": 0, "saturation_channel": 7},
+		"effects": {
+			"fx": {"id": "fx", "type": "dmx", "base_config": {}},
+			"set": {"id": "set", "type": "dmx", "base_config": {}, "extra_config": {"universe": 9}},
+			"other": {"id": "other", "type": "energy", "base_config": {}}
+		},
+		"profiles": {"show": {"effects": {"kept": {"id": "kept", "type": "dmx", "base_config": {}}}}}
+	}`
+	_, restore := loadTestConfig(t, v1)
+	defer restore()
+
+	want := map[string]interface{}{
+		"universe": float64(3),
+		"mode":     "mapped",
+		"channels": map[string]interface{}{"5": "brightness", "7": "saturation"},
+	}
+	if got := store.Effects["fx"].ExtraConfig; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected the dmx mapping to move to the effect, got %v", got)
+	}
+	if got := store.Profiles["show"].Effects["kept"].ExtraConfig; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected the dmx mapping to move to effects in profiles, got %v", got)
+	}
+	if got := store.Effects["set"].ExtraConfig; !reflect.DeepEqual(got, map[string]interface{}{"universe": float64(9)}) {
+		t.Errorf("Expected an effect's own dmx config to be kept, got %v", got)
+	}
+	if store.Effects["other"].ExtraConfig != nil {
+		t.Errorf("Expected other effects to be left alone, got %v", store.Effects["other"].ExtraConfig)
+	}
+	if !store.DmxInput.Enabled {
+		t.Error("Expected the rest of the dmx input config to be kept")
+	}
+}
+
+func TestMigrateAudioDevice(t *testing.T) {
+	v2 := `{
+		"version": 2,
+		"audio": {"device": {"id": "mic", "name": "Mic"}, "fft_size": 2048, "frame_rate": 30},
+		"controllers": {
+			"a": {"id": "a", "base_config": {"name": "A"}},
+			"b": {"id": "b", "base_config": {"name": "B", "framerate": 90}}
+		}
+	}`
+	path, restore := loadTestConfig(t, v2)
+	defer restore()
+
+	if store.LocalInput != "mic" {
+		t.Errorf("Expected the audio device to become the local input, got %q", store.LocalInput)
+	}
+	if store.Audio.FftSize != 2048 {
+		t.Errorf("Expected the rest of the audio config to be kept, got %+v", store.Audio)
+	}
+	if got := store.Controllers["a"].Config["framerate"]; got != float64(30) {
+		t.Errorf("Expected the audio frame rate to move to controllers, got %v", got)
+	}
+	if got := store.Controllers["b"].Config["framerate"]; got != float64(90) {
+		t.Errorf("Expected a controller's own frame rate to be kept, got %v", got)
+	}
+	if q := GetQuarantine(); len(q) != 0 {
+		t.Errorf("Expected nothing to be quarantined, got %+v", q)
+	}
+	saved, _ := ioutil.ReadFile(path)
+	if strings.Contains(string(saved), "frame_rate") || strings.Contains(string(saved), `"device"`) {
+		t.Errorf("Expected the old audio fields to be dropped, got %s", saved)
+	}
+}
+
+func TestQuarantine(t *testing.T) {
+	_, restore := loadTestConfig(t, `{
+		"version": 1,
+		"effects": {
+			"good": {"id": "good", "type": "energy"},
+			"unreadable": {"id": "unreadable", "type": "energy", "base_config": "bright"},
+			"invalid": {"id": "invalid", "type": "sparkles"}
+		},
+		"controllers": {"c": {"id": "c", "base_config": {"name": "Controller"}}},
+		"connections_effect": {"good": "c", "invalid": "c", "missing": "c"}
+	}`)
+	defer restore()
+	RegisterValidator(Effect, func(entry interface{}) error {
+		if entry.(EffectEntry).Type != "energy" {
+			return errors.New("unknown type")
+		}
+		return nil
+	})
+	defer delete(validators, Effect)
+
+	if _, exists := store.Effects["unreadable"]; exists {
+		t.Error("Expected an entry which can't be decoded to be quarantined while loading")
+	}
+	if n := ValidateEntries(); n != 3 {
+		t.Errorf("Expected the invalid effect and two broken connections to be quarantined, got %d", n)
+	}
+	if _, exists := store.Effects["good"]; !exists || len(store.Effects) != 1 {
+		t.Errorf("Expected only the valid effect to remain, got %v", store.Effects)
+	}
+	if len(store.ConnEffect) != 1 || store.ConnEffect["good"] != "c" {
+		t.Errorf("Expected only the valid connection to remain, got %v", store.ConnEffect)
+	}
+	types := map[string]int{}
+	for _, q := range GetQuarantine() {
+		types[q.Type]++
+		if q.Reason == "" {
+			t.Errorf("Expected a reason for quarantining %s %s", q.Type, q.ID)
+		}
+	}
+	if types["effect"] != 2 || types["effect_connection"] != 2 {
+		t.Errorf("Unexpected quarantine %v", types)
+	}
+
+	if err := ClearQuarantine(); err != nil || len(GetQuarantine()) != 0 {
+		t.Errorf("Expected the quarantine to be cleared, got %v", err)
+	}
+}
+
+func TestSave(t *testing.T) {
+	path, restore := loadTestConfig(t, `{"version": 1}`)
+	defer restore()
+	prevDelay, prevMax, prevInterval := saveDelay, saveMaxDelay, backupInterval
+	defer func() { saveDelay, saveMaxDelay, backupInterval = prevDelay, prevMax, prevInterval }()
+	saveDelay, saveMaxDelay, backupInterval = 50*time.Millisecond, 200*time.Millisecond, 0
+
+	saved := func(id string) bool {
+		content, _ := ioutil.ReadFile(path)
+		return strings.Contains(string(content), `"`+id+`"`)
+	}
+
+	// a burst of changes is saved once it settles
+	for _, id := range []string{"a", "b", "c"} {
+		AddEntry(id, ControllerEntry{ID: id})
+	}
+	if saved("c") {
+		t.Error("Expected the save to wait for changes to settle")
+	}
+	time.Sleep(150 * time.Millisecond)
+	if !saved("c") {
+		t.Fatal("Expected the changes to be saved")
+	}
+
+	// changes which never settle are still saved
+	AddEntry("d", ControllerEntry{ID: "d"})
+	for start := time.Now(); time.Since(start) < 400*time.Millisecond; time.Sleep(20 * time.Millisecond) {
+		SetStates(map[string]bool{})
+	}
+	if !saved("d") {
+		t.Error("Expected changes to be saved after the max delay")
+	}
+
+	AddEntry("e", ControllerEntry{ID: "e"})
+	if err := Flush(); err != nil || !saved("e") {
+		t.Errorf("Expected flush to save immediately, got %v", err)
+	}
+
+	for i := 0; i < backupCount+2; i++ {
+		AddEntry("f", ControllerEntry{ID: "f"})
+		Flush()
+	}
+	files, _ := ioutil.ReadDir(filepath.Dir(path))
+	for _, f := range files {
+		if strings.Contains(f.Name(), ".tmp") {
+			t.Errorf("Temporary file %s was left behind", f.Name())
+		}
+	}
+	if _, err := os.Stat(backupPath(backupCount)); err != nil {
+		t.Errorf("Expected %d backups, got %v", backupCount, err)
+	}
+	if _, err := os.Stat(backupPath(backupCount + 1)); err == nil {
+		t.Errorf("Expected no more than %d backups", backupCount)
+	}
+}
+
+func TestSaveRetry(t *testing.T) {
+	path, restore := loadTestConfig(t, `{"version": 1}`)
+	defer restore()
+	prevDelay, prevRetry := saveDelay, saveRetryDelay
+	defer func() { saveDelay, saveRetryDelay = prevDelay, prevRetry }()
+	saveDelay, saveRetryDelay = 10*time.Millisecond, 50*time.Millisecond
+
+	// the config can't be written while its directory is missing
+	dir := filepath.Dir(path)
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	AddEntry("a", ControllerEntry{ID: "a"})
+	if err := Flush(); err == nil {
+		t.Fatal("Expected the save to fail")
+	}
+	mu.Lock()
+	pending := !savePending.IsZero()
+	mu.Unlock()
+	if !pending {
+		t.Error("Expected the changes to stay unsaved after a failed write")
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(150 * time.Millisecond)
+	content, _ := ioutil.ReadFile(path)
+	if !strings.Contains(string(content), `"a"`) {
+		t.Error("Expected the save to be retried")
+	}
+}
+
+func TestImport(t *testing.T) {
+	_, restore := loadTestConfig(t, `{
+		"version": 1,
+		"controllers": {"a": {"id": "a", "base_config": {"name": "A"}}, "b": {"id": "b", "base_config": {"name": "B"}}},
+		"osc": {"port": 9000}
+	}`)
+	defer restore()
+	exported, err := Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := []byte(`{
+		"controllers": {"b": {"id": "b", "base_config": {"name": "Bee"}}, "c": {"id": "c", "base_config": {"name": "C"}}},
+		"connections_effect": {"missing": "c"},
+		"osc": {"port": 9100}
+	}`)
+	imp, err := PrepareImport(content, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := imp.Diff
+	if len(d.Added["controllers"]) != 1 || d.Added["controllers"][0] != "c" || len(d.Changed["controllers"]) != 1 || len(d.Removed["controllers"]) != 0 {
+		t.Errorf("Unexpected merge diff %+v", d)
+	}
+	if len(d.Sections) != 1 || d.Sections[0] != "osc" {
+		t.Errorf("Expected the osc section to change, got %v", d.Sections)
+	}
+	if len(imp.Invalid) != 1 || imp.Invalid[0].Type != "effect_connection" {
+		t.Errorf("Expected the broken connection to be quarantined, got %+v", imp.Invalid)
+	}
+	// preparing an import doesn't change anything
+	if after, _ := Export(); string(after) != string(exported) {
+		t.Error("Expected preparing an import to leave the config alone")
+	}
+
+	imp, err = PrepareImport(content, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(imp.Diff.Removed["controllers"]) != 1 || imp.Diff.Removed["controllers"][0] != "a" {
+		t.Errorf("Expected replacing to remove controller a, got %+v", imp.Diff)
+	}
+	if err = imp.Apply(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = GetController("a"); err == nil || GetControllers()["b"].Config["name"] != "Bee" || GetOsc().Port != 9100 {
+		t.Errorf("Expected the import to replace the config, got %+v", GetControllers())
+	}
+
+	if _, err = PrepareImport([]byte("not json"), true); err == nil {
+		t.Error("Expected an error importing invalid json")
+	}
+}
+
+func TestYaml(t *testing.T) {
+	path, restore := loadTestConfigFile(t, "config.yaml", `
+version: 1
+core:
+  port: 9090
+controllers:
+  c:
+    id: c
+    base_config:
+      name: Yaml
+`)
+	defer restore()
+	if store.Settings.Port != 9090 || store.Controllers["c"].Config["name"] != "Yaml" {
+		t.Fatalf("Expected the yaml config to load, got %+v %+v", store.Settings, store.Controllers)
+	}
+
+	AddEntry("d", ControllerEntry{ID: "d", Config: map[string]interface{}{"name": "D"}})
+	if err := Flush(); err != nil {
+		t.Fatal(err)
+	}
+	content, _ := ioutil.ReadFile(path)
+	raw := map[string]interface{}{}
+	if err := yaml.Unmarshal(content, &raw); err != nil {
+		t.Fatalf("Expected the config to be saved as yaml, got %v", err)
+	}
+	if _, ok := raw["controllers"].(map[string]interface{})["d"]; !ok {
+		t.Errorf("Expected the saved yaml to use the config keys, got %v", raw)
+	}
+
+	// yaml config files can be imported too
+	imp, err := PrepareImport([]byte("controllers:\n  e:\n    base_config:\n      name: E\n"), true)
+	if err != nil || len(imp.Diff.Added["controllers"]) != 1 {
+		t.Errorf("Expected a yaml import to add a controller, got %v", err)
+	}
+}
+
+func TestEnv(t *testing.T) {
+	defer func() { envSettings = map[string]interface{}{} }()
+	t.Setenv("LEDFX_PORT", "7000")
+	t.Setenv("LEDFX_NO_TRAY", "true")
+	if err := loadEnvSettings(); err != nil {
+		t.Fatal(err)
+	}
+	if s := GetSettings(); s.Port != 7000 || !s.NoTray {
+		t.Errorf("Expected environment variables to override settings, got %+v", s)
+	}
+	if store.Settings.Port == 7000 {
+		t.Error("Environment variables should not be saved to config")
+	}
+
+	for value, reason := range map[string]string{"abc": "not a number", "70000": "out of range"} {
+		t.Setenv("LEDFX_PORT", value)
+		if err := loadEnvSettings(); err == nil {
+			t.Errorf("Expected an error for a port which is %s", reason)
+		}
+	}
+	if GetSettings().Port != 7000 {
+		t.Error("Invalid environment variables should not replace valid ones")
+	}
+}
+
+func TestHistory(t *testing.T) {
+	_, restore := loadTestConfig(t, `{"version": 1}`)
+	defer restore()
+	prevGroup, prevCoalesce := historyGroupWindow, historyCoalesceWindow
+	defer func() { historyGroupWindow, historyCoalesceWindow = prevGroup, prevCoalesce }()
+	historyGroupWindow, historyCoalesceWindow = 0, time.Hour
+
+	// updates to the same entry are coalesced
+	AddEntry("fx", EffectEntry{ID: "fx", Type: "energy"})
+	AddEntry("fx", EffectEntry{ID: "fx", Type: "energy", BaseConfig: map[string]interface{}{"brightness": 0.5}})
+	AddEntry("fx", EffectEntry{ID: "fx", Type: "energy", BaseConfig: map[string]interface{}{"brightness": 0.7}})
+	SetConnections(map[string]string{"fx": "c"}, map[string]string{})
+	// and changes which don't change anything aren't recorded
+	SetConnections(map[string]string{"fx": "c"}, map[string]string{})
+	history, position := GetHistory()
+	if len(history) != 3 || position != 3 {
+		t.Fatalf("Expected an add, a coalesced update and a connection, got %+v", history)
+	}
+	if after := history[1].After.(EffectEntry); after.BaseConfig["brightness"] != 0.7 {
+		t.Errorf("Expected the coalesced update to end at the last config, got %v", after.BaseConfig)
+	}
+
+	var applied []interface{}
+	apply := func(c Change, state interface{}) error {
+		applied = append(applied, state)
+		return nil
+	}
+	undone, err := Undo(apply)
+	if err != nil || len(undone) != 1 || undone[0].Kind != ChangeConnections {
+		t.Fatalf("Expected the connection to be undone, got %+v %v", undone, err)
+	}
+	if conn := applied[0].(Connections); len(conn.Effects) != 0 {
+		t.Errorf("Expected to restore no connections, got %v", conn)
+	}
+	Undo(apply)
+	if _, position = GetHistory(); position != 1 {
+		t.Errorf("Expected position 1 after two undos, got %d", position)
+	}
+	if _, err = Redo(apply); err != nil {
+		t.Fatal(err)
+	}
+
+	// a new change discards what could be redone
+	DeleteEntry(Effect, "fx")
+	history, position = GetHistory()
+	if len(history) != 3 || position != 3 || history[2].After != nil {
+		t.Fatalf("Expected the delete to replace the undone connection, got %+v", history)
+	}
+	if _, err = Redo(apply); err == nil {
+		t.Error("Expected nothing to redo")
+	}
+
+	// changes close together are undone together
+	historyGroupWindow = time.Hour
+	AddEntry("c", ControllerEntry{ID: "c"})
+	AddEntry("d", DeviceEntry{ID: "d", Type: "udp_stream"})
+	if undone, _ = Undo(apply); len(undone) != 3 || undone[0].ID != "d" || undone[1].ID != "c" || undone[2].ID != "fx" {
+		t.Errorf("Expected the grouped changes to be undone latest first, got %+v", undone)
+	}
+
+	historyGroupWindow = 0
+
+	// changes made while an undo applies its changes aren't recorded, wherever they're made
+	Redo(apply)
+	Undo(func(c Change, state interface{}) error {
+		AddEntry("undo_own", DeviceEntry{ID: "undo_own", Type: "udp_stream"})
+		done := make(chan struct{})
+		go func() {
+			AddEntry("undo_other", DeviceEntry{ID: "undo_other", Type: "udp_stream"})
+			close(done)
+		}()
+		<-done
+		return nil
+	})
+	history, _ = GetHistory()
+	for _, c := range history {
+		if c.ID == "undo_own" || c.ID == "undo_other" {
+			t.Errorf("Expected changes made during the undo not to be recorded, got %+v", c)
+		}
+	}
+	if _, err = GetDevice("undo_other"); err != nil {
+		t.Error("Expected changes made during the undo to still be made")
+	}
+
+	// and recording carries on once it's done
+	AddEntry("after_undo", DeviceEntry{ID: "after_undo", Type: "udp_stream"})
+	history, position = GetHistory()
+	if last := history[len(history)-1]; last.ID != "after_undo" || position != len(history) {
+		t.Errorf("Expected changes after the undo to be recorded, got %+v at %d", history, position)
+	}
+}
+
+func TestAuthConfig(t *testing.T) {
+	// an invalid auth section locks the api, rather than opening it
+	_, restore := loadTestConfig(t, `{"version": 1, "auth": {"enabled": false, "tokens": [{"name": "x", "role": "root", "hash": "h"}]}}`)
+	defer restore()
+	if !GetAuth().Enabled {
+		t.Error("Expected an invalid auth section to leave auth enabled")
+	}
+	if q := GetQuarantine(); len(q) != 1 || q[0].ID != "auth" {
+		t.Errorf("Expected the auth section to be quarantined, got %+v", q)
+	}
+
+	// imports keep the live auth settings
+	if _, err := CreateToken("keep", RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	imp, err := PrepareImport([]byte(`{"auth": {"enabled": false}}`), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = imp.Apply(); err != nil {
+		t.Fatal(err)
+	}
+	if a := GetAuth(); !a.Enabled || len(a.Tokens) != 1 {
+		t.Errorf("Expected the import to keep the auth settings, got %+v", a)
+	}
+}
+
+func TestTLSFiles(t *testing.T) {
+	path, restore := loadTestConfig(t, `{"core": {"tls": true}}`)
+	defer restore()
+	if !GetSettings().TLS {
+		t.Error("Expected tls to be enabled from the config file")
+	}
+
+	// a self-signed certificate is made next to the config
+	cert, key, err := TLSFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert != filepath.Join(filepath.Dir(path), certName) || key != filepath.Join(filepath.Dir(path), keyName) {
+		t.Errorf("Expected the certificate in the config dir, got %s %s", cert, key)
+	}
+	if _, err = os.Stat(cert); err != nil {
+		t.Error(err)
+	}
+
+	// given files are used as they are
+	defer func() { envSettings = map[string]interface{}{} }()
+	t.Setenv("LEDFX_TLS_CERT", "/certs/ledfx.pem")
+	if err = loadEnvSettings(); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = TLSFiles(); err == nil {
+		t.Error("Expected a certificate without a key to be refused")
+	}
+	t.Setenv("LEDFX_TLS_KEY", "/certs/ledfx-key.pem")
+	if err = loadEnvSettings(); err != nil {
+		t.Fatal(err)
+	}
+	if cert, key, err = TLSFiles(); err != nil || cert != "/certs/ledfx.pem" || key != "/certs/ledfx-key.pem" {
+		t.Errorf("Expected the given certificate and key, got %s %s %v", cert, key, err)
+	}
+}
+
+func TestNewerVersion(t *testing.T) {
+	newer := `{"version": 99, "core": {"port": 7001}, "sparkles": {"enabled": true}}`
+	path, restore := loadTestConfig(t, newer)
+	defer restore()
+	defer func() { readOnly = false }()
+
+	if !readOnly || GetSettings().Port != 7001 {
+		t.Errorf("Expected a newer config to load read-only, got read only %v, port %d", readOnly, GetSettings().Port)
+	}
+	if err := SetSettings(map[string]interface{}{"port": 7002}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if saved, _ := ioutil.ReadFile(path); string(saved) != newer {
+		t.Errorf("Expected a newer config to be left as it is, got %s", saved)
+	}
+	if _, err := PrepareImport([]byte(newer), false); !errors.Is(err, ErrNewerVersion) {
+		t.Errorf("Expected importing a newer config to be refused, got %v", err)
+	}
+}
@@ -1,13 +1,19 @@
 package config
 
+// Copies of the saved connections
 func GetConnections() (effects, devices map[string]string) {
-	return store.ConnEffect, store.ConnDevice
+	mu.Lock()
+	defer mu.Unlock()
+	return copyConnections(store.ConnEffect), copyConnections(store.ConnDevice)
 }
 
+// Saves the connections. The maps are copied, so the caller can keep changing them
 func SetConnections(effects, devices map[string]string) {
 	mu.Lock()
 	defer mu.Unlock()
-	store.ConnEffect = effects
-	store.ConnDevice = devices
+	before := Connections{Effects: copyConnections(store.ConnEffect), Devices: copyConnections(store.ConnDevice)}
+	store.ConnEffect = copyConnections(effects)
+	store.ConnDevice = copyConnections(devices)
+	recordChange(ChangeConnections, "", before, Connections{Effects: store.ConnEffect, Devices: store.ConnDevice})
 	saveConfig()
 }
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"reflect"
+
+	"github.com/LedFx/ledfx/pkg/logger"
+	"github.com/LedFx/ledfx/pkg/util"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// DMX received from a lighting console. Each dmx effect sets the universe it reads, and how its channels are mapped
+type DmxInputConfig struct {
+	Enabled  bool   `mapstructure:"enabled" json:"enabled" description:"Listen for DMX from a lighting console" default:"false" validate:""`
+	Protocol string `mapstructure:"protocol" json:"protocol" description:"Protocol the console sends DMX with" default:"e131" validate:"oneof=e131 artnet"`
+	Port     int    `mapstructure:"port" json:"port" description:"UDP port to listen on. 0 uses the protocol's port, 5568 for E1.31 or 6454 for Art-Net" default:"0" validate:"gte=0,lte=65535"`
+}
+
+// Generate dmx input config schema
+func DmxInputSchema() (schema map[string]interface{}, err error) {
+	return util.CreateSchema(reflect.TypeOf((*DmxInputConfig)(nil)).Elem())
+}
+
+// Generate dmx input config schema as json
+func DmxInputJsonSchema() (jsonSchema []byte, err error) {
+	schema, err := DmxInputSchema()
+	if err != nil {
+		return jsonSchema, err
+	}
+	jsonSchema, err = util.CreateJsonSchema(schema)
+	return jsonSchema, err
+}
+
+func GetDmxInput() DmxInputConfig {
+	return store.DmxInput
+}
+
+// Incrementally updates the dmx input config. The receiver must be restarted for protocol and port changes.
+func SetDmxInput(c map[string]interface{}) error {
+	mu.Lock()
+	defer mu.Unlock()
+	prevDmxInput := store.DmxInput
+	err := mapstructure.Decode(c, &store.DmxInput)
+	if err == nil {
+		err = validate.Struct(&store.DmxInput)
+	}
+	if err != nil {
+		store.DmxInput = prevDmxInput
+		logger.Logger.WithField("context", "Config").Warn(err)
+		return err
+	}
+	return saveConfig()
+}
@@ -1,12 +1,28 @@
 package config
 
 func GetEffectsGlobal() map[string]interface{} {
+	mu.Lock()
+	defer mu.Unlock()
 	return store.EffectsGlobal
 }
 
 func SetGlobalEffects(g map[string]interface{}) {
 	mu.Lock()
 	defer mu.Unlock()
+	var before interface{}
+	if store.EffectsGlobal != nil {
+		before = store.EffectsGlobal
+	}
 	store.EffectsGlobal = g
+	recordChange(ChangeGlobalEffects, "", before, g)
 	saveConfig()
 }
+
+// Moves invalid global effect settings into quarantine, so effects use their defaults
+func QuarantineGlobalEffects(reason error) error {
+	mu.Lock()
+	defer mu.Unlock()
+	store.quarantine("section", "global_effects", store.EffectsGlobal, reason)
+	store.EffectsGlobal = nil
+	return saveConfig()
+}
@@ -29,10 +29,11 @@ func (e EntryType) String() string {
 
 // the saved config entry for an effect
 type EffectEntry struct {
-	ID          string                 `mapstructure:"id" json:"id"`
-	Type        string                 `mapstructure:"type" json:"type"`
-	BaseConfig  map[string]interface{} `mapstructure:"base_config" json:"base_config"`
-	ExtraConfig map[string]interface{} `mapstructure:"extra_config" json:"extra_config"`
+	ID          string                   `mapstructure:"id" json:"id"`
+	Type        string                   `mapstructure:"type" json:"type"`
+	BaseConfig  map[string]interface{}   `mapstructure:"base_config" json:"base_config"`
+	ExtraConfig map[string]interface{}   `mapstructure:"extra_config" json:"extra_config"`
+	Modulators  []map[string]interface{} `mapstructure:"modulators" json:"modulators,omitempty"`
 }
 
 type DeviceEntry struct {
@@ -50,12 +51,26 @@ type ControllerEntry struct {
 func AddEntry(id string, entry interface{}) (err error) {
 	mu.Lock()
 	defer mu.Unlock()
+	var kind string
+	var before interface{}
 	switch t := entry.(type) {
 	case EffectEntry:
+		if prev, exists := store.Effects[id]; exists {
+			before = prev
+		}
+		kind = ChangeEffect
 		store.Effects[id] = entry.(EffectEntry)
 	case DeviceEntry:
+		if prev, exists := store.Devices[id]; exists {
+			before = prev
+		}
+		kind = ChangeDevice
 		store.Devices[id] = entry.(DeviceEntry)
 	case ControllerEntry:
+		if prev, exists := store.Controllers[id]; exists {
+			before = prev
+		}
+		kind = ChangeController
 		store.Controllers[id] = entry.(ControllerEntry)
 	default:
 		err = fmt.Errorf("unknown config entry type: %v", t)
@@ -63,6 +78,7 @@ func AddEntry(id string, entry interface{}) (err error) {
 	if err != nil {
 		return err
 	}
+	recordChange(kind, id, before, entry)
 	return saveConfig()
 }
 
@@ -71,30 +87,45 @@ func DeleteEntry(t EntryType, id string) {
 	defer mu.Unlock()
 	switch t {
 	case Effect:
-		if _, exists := store.Effects[id]; !exists {
+		prev, exists := store.Effects[id]
+		if !exists {
 			return
 		}
 		delete(store.Effects, id)
+		recordChange(ChangeEffect, id, prev, nil)
 	case Device:
-		if _, exists := store.Devices[id]; !exists {
+		prev, exists := store.Devices[id]
+		if !exists {
 			return
 		}
 		delete(store.Devices, id)
+		recordChange(ChangeDevice, id, prev, nil)
 	case Controller:
-		if _, exists := store.Controllers[id]; !exists {
+		prev, exists := store.Controllers[id]
+		if !exists {
 			return
 		}
 		delete(store.Controllers, id)
+		recordChange(ChangeController, id, prev, nil)
 	}
 	logger.Logger.WithField("context", "Config").Debugf("Deleted %s %s from config", t.String(), id)
 	saveConfig()
 }
 
+// A copy of the saved effect entries, safe to range over while they change
 func GetEffects() map[string]EffectEntry {
-	return store.Effects
+	mu.Lock()
+	defer mu.Unlock()
+	entries := make(map[string]EffectEntry, len(store.Effects))
+	for id, entry := range store.Effects {
+		entries[id] = entry
+	}
+	return entries
 }
 
 func GetEffect(id string) (EffectEntry, error) {
+	mu.Lock()
+	defer mu.Unlock()
 	if entry, ok := store.Effects[id]; ok {
 		return entry, nil
 	} else {
@@ -102,11 +133,20 @@ func GetEffect(id string) (EffectEntry, error) {
 	}
 }
 
+// A copy of the saved device entries, safe to range over while they change
 func GetDevices() map[string]DeviceEntry {
-	return store.Devices
+	mu.Lock()
+	defer mu.Unlock()
+	entries := make(map[string]DeviceEntry, len(store.Devices))
+	for id, entry := range store.Devices {
+		entries[id] = entry
+	}
+	return entries
 }
 
 func GetDevice(id string) (DeviceEntry, error) {
+	mu.Lock()
+	defer mu.Unlock()
 	if entry, ok := store.Devices[id]; ok {
 		return entry, nil
 	} else {
@@ -114,11 +154,20 @@ func GetDevice(id string) (DeviceEntry, error) {
 	}
 }
 
+// A copy of the saved controller entries, safe to range over while they change
 func GetControllers() map[string]ControllerEntry {
-	return store.Controllers
+	mu.Lock()
+	defer mu.Unlock()
+	entries := make(map[string]ControllerEntry, len(store.Controllers))
+	for id, entry := range store.Controllers {
+		entries[id] = entry
+	}
+	return entries
 }
 
 func GetController(id string) (ControllerEntry, error) {
+	mu.Lock()
+	defer mu.Unlock()
 	if entry, ok := store.Controllers[id]; ok {
 		return entry, nil
 	} else {
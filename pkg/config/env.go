@@ -0,0 +1,68 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+/*
+Settings can be overridden by environment variables, for running in containers.
+Each SettingsConfig field has a variable named after its config key, eg. LEDFX_PORT or LEDFX_NO_TRAY.
+They take priority over the config file, and command line args take priority over them.
+LEDFX_CONFIG sets the config file path, if it isn't given as an arg.
+*/
+const envPrefix = "LEDFX_"
+
+// settings given by environment variables, by config key
+var envSettings = map[string]interface{}{}
+
+// the environment variable for a config key
+func envName(key string) string {
+	return envPrefix + strings.ToUpper(key)
+}
+
+// reads settings from the environment, returning an error for values which don't parse or validate
+func loadEnvSettings() error {
+	settings := map[string]interface{}{}
+	t := reflect.TypeOf(SettingsConfig{})
+	for i := 0; i < t.NumField(); i++ {
+		key := t.Field(i).Tag.Get("mapstructure")
+		value, ok := os.LookupEnv(envName(key))
+		if !ok {
+			continue
+		}
+		var err error
+		switch t.Field(i).Type.Kind() {
+		case reflect.String:
+			settings[key] = value
+		case reflect.Int:
+			settings[key], err = strconv.Atoi(value)
+		case reflect.Bool:
+			settings[key], err = strconv.ParseBool(value)
+		default:
+			err = fmt.Errorf("unsupported type %s", t.Field(i).Type)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", envName(key), err)
+		}
+	}
+
+	// validate them on top of the defaults, as command line args are
+	s, err := newConfig()
+	if err != nil {
+		return err
+	}
+	if err = mapstructure.Decode(settings, &s.Settings); err != nil {
+		return err
+	}
+	if err = validate.Struct(&s.Settings); err != nil {
+		return err
+	}
+	envSettings = settings
+	return nil
+}
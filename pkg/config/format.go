@@ -0,0 +1,50 @@
+package config
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config files can be json or yaml, told apart by their extension
+var yamlExtensions = []string{".yaml", ".yml"}
+
+func isYaml(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, e := range yamlExtensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// parses a config file into its raw form
+func parseConfig(content []byte, yamlFormat bool) (raw map[string]interface{}, err error) {
+	raw = map[string]interface{}{}
+	if yamlFormat {
+		err = yaml.Unmarshal(content, &raw)
+	} else {
+		err = json.Unmarshal(content, &raw)
+	}
+	if raw == nil {
+		// an empty yaml file
+		raw = map[string]interface{}{}
+	}
+	return raw, err
+}
+
+// encodes a config for its file, using the same keys for yaml as for json
+func encodeConfig(c *config, yamlFormat bool) ([]byte, error) {
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil || !yamlFormat {
+		return b, err
+	}
+	var raw interface{}
+	if err = json.Unmarshal(b, &raw); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(raw)
+}
@@ -0,0 +1,233 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"time"
+)
+
+/*
+Changes to effects, devices, controllers, connections and global effect settings are kept
+in a history, so they can be undone and redone. Changes made together, eg. a controller being
+deleted along with its connections, are grouped and undone together. Repeated updates to the
+same entry, eg. dragging a slider, are coalesced into one change.
+Only changes which are saved are recorded, so loading the config isn't undoable.
+*/
+
+// Kinds of change
+const (
+	ChangeEffect        = "effect"
+	ChangeDevice        = "device"
+	ChangeController    = "controller"
+	ChangeConnections   = "connections"
+	ChangeGlobalEffects = "global_effects"
+)
+
+type Change struct {
+	Seq    uint64      `json:"seq"`
+	Group  uint64      `json:"group"`
+	Time   time.Time   `json:"time"`
+	Kind   string      `json:"kind"`
+	ID     string      `json:"id,omitempty"` // id of the entry, for entry changes
+	Before interface{} `json:"before"`       // nil if the entry didn't exist
+	After  interface{} `json:"after"`        // nil if the entry was deleted
+}
+
+// Effect and device connections to controllers, as saved in config
+type Connections struct {
+	Effects map[string]string `json:"effects"`
+	Devices map[string]string `json:"devices"`
+}
+
+// Max changes kept in the history
+var historySize = 200
+
+// Changes closer together than this are undone together
+var historyGroupWindow = 100 * time.Millisecond
+
+// Updates to the same entry closer together than this are coalesced
+var historyCoalesceWindow = time.Second
+
+var (
+	histMu    sync.Mutex // held for the whole of an undo or redo, so changes can't move the cursor meanwhile
+	changes   []Change
+	cursor    int // changes before the cursor can be undone, changes after it redone
+	changeSeq uint64
+)
+
+/*
+Changes are recorded while the config lock is held, so they can't wait for an undo, which needs the config lock
+to apply its changes. Changes made while the history is busy are queued, and added once it's free.
+The changes an undo or redo makes aren't recorded, like those made while saving is suspended.
+Nothing is recorded while one applies its changes, so a change made meanwhile by anything else
+is saved, but can't be undone.
+*/
+var (
+	pendingMu    sync.Mutex
+	pending      []Change
+	pendingReset bool // the history was reset while it was busy
+	replaying    int  // undos and redos applying their changes. only changed while histMu is held
+)
+
+// Get the history, and the position in it. Changes before the position can be undone
+func GetHistory() (history []Change, position int) {
+	histMu.Lock()
+	defer histMu.Unlock()
+	addPending()
+	history = make([]Change, len(changes))
+	copy(history, changes)
+	return history, cursor
+}
+
+/*
+Undoes the latest group of changes. Apply is called for each change, latest first, with
+the state to restore. It should update the live objects, which save the state to config.
+Returns the changes undone.
+*/
+func Undo(apply func(c Change, state interface{}) error) ([]Change, error) {
+	histMu.Lock()
+	defer histMu.Unlock()
+	addPending()
+	if cursor == 0 {
+		return nil, errors.New("nothing to undo")
+	}
+	group := []Change{}
+	for i := cursor - 1; i >= 0 && changes[i].Group == changes[cursor-1].Group; i-- {
+		group = append(group, changes[i])
+	}
+	n, err := replay(group, func(c Change) error { return apply(c, c.Before) })
+	cursor -= n
+	// anything changed meanwhile comes after the undo
+	addPending()
+	return group[:n], err
+}
+
+// Redoes the latest group of undone changes. Apply is called for each change, earliest first
+func Redo(apply func(c Change, state interface{}) error) ([]Change, error) {
+	histMu.Lock()
+	defer histMu.Unlock()
+	addPending()
+	if cursor == len(changes) {
+		return nil, errors.New("nothing to redo")
+	}
+	group := []Change{}
+	for i := cursor; i < len(changes) && changes[i].Group == changes[cursor].Group; i++ {
+		group = append(group, changes[i])
+	}
+	n, err := replay(group, func(c Change) error { return apply(c, c.After) })
+	cursor += n
+	addPending()
+	return group[:n], err
+}
+
+// applies changes until one fails, returning how many were applied
+func replay(group []Change, apply func(Change) error) (int, error) {
+	pendingMu.Lock()
+	replaying++
+	pendingMu.Unlock()
+	defer func() {
+		pendingMu.Lock()
+		replaying--
+		pendingMu.Unlock()
+	}()
+	for i, c := range group {
+		if err := apply(c); err != nil {
+			return i, err
+		}
+	}
+	return len(group), nil
+}
+
+// records a change. caller must hold the lock
+func recordChange(kind, id string, before, after interface{}) {
+	if suspended > 0 {
+		return
+	}
+	before, after = deepCopy(before), deepCopy(after)
+	if reflect.DeepEqual(before, after) {
+		return
+	}
+	pendingMu.Lock()
+	if replaying > 0 {
+		pendingMu.Unlock()
+		return
+	}
+	pending = append(pending, Change{Time: time.Now(), Kind: kind, ID: id, Before: before, After: after})
+	pendingMu.Unlock()
+	// if an undo has the history, it adds the change when it's done
+	if histMu.TryLock() {
+		addPending()
+		histMu.Unlock()
+	}
+}
+
+// adds the queued changes to the history. caller must hold histMu
+func addPending() {
+	pendingMu.Lock()
+	queued, reset := pending, pendingReset
+	pending, pendingReset = nil, false
+	pendingMu.Unlock()
+	if reset {
+		changes = nil
+		cursor = 0
+	}
+	for _, c := range queued {
+		addChange(c)
+	}
+}
+
+// caller must hold histMu
+func addChange(c Change) {
+	// recording a change discards anything which could be redone
+	changes = changes[:cursor]
+	if len(changes) > 0 {
+		last := &changes[len(changes)-1]
+		if last.Kind == c.Kind && last.ID == c.ID && last.Before != nil && last.After != nil && c.Before != nil && c.After != nil && c.Time.Sub(last.Time) < historyCoalesceWindow {
+			last.After = c.After
+			last.Time = c.Time
+			return
+		}
+	}
+	changeSeq++
+	c.Seq, c.Group = changeSeq, changeSeq
+	if len(changes) > 0 && c.Time.Sub(changes[len(changes)-1].Time) < historyGroupWindow {
+		c.Group = changes[len(changes)-1].Group
+	}
+	changes = append(changes, c)
+	if len(changes) > historySize {
+		changes = changes[len(changes)-historySize:]
+	}
+	cursor = len(changes)
+}
+
+// forgets the history, eg. when the whole config is replaced. caller must hold the lock
+func resetHistory() {
+	pendingMu.Lock()
+	pending, pendingReset = nil, true
+	pendingMu.Unlock()
+	if histMu.TryLock() {
+		addPending()
+		histMu.Unlock()
+	}
+}
+
+func copyConnections(conn map[string]string) map[string]string {
+	c := make(map[string]string, len(conn))
+	for k, v := range conn {
+		c[k] = v
+	}
+	return c
+}
+
+// copies a value, so later changes to maps inside it don't change the history
+func deepCopy(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	p := reflect.New(reflect.TypeOf(v))
+	if err := decodeJson(v, p.Interface()); err != nil {
+		return v
+	}
+	return p.Elem().Interface()
+}
@@ -0,0 +1,203 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/LedFx/ledfx/pkg/logger"
+)
+
+// Version of the config file layout. When the layout changes, bump this and add a migration.
+const CurrentVersion = 3
+
+// The config was saved by a newer LedFx, so saving it would drop what this version doesn't understand
+var ErrNewerVersion = errors.New("config is from a newer version of LedFx")
+
+/*
+Migrations upgrade a raw config file, as decoded json, by one version.
+migrations[n] upgrades version n to n+1. Files saved before versioning are version 0.
+*/
+var migrations = []func(raw map[string]interface{}) error{
+	migrateV0,
+	migrateV1,
+	migrateV2,
+}
+
+// v0 files could save sections as null, and entries with an id which disagrees with their key
+func migrateV0(raw map[string]interface{}) error {
+	for _, section := range []string{"effects", "devices", "controllers"} {
+		if raw[section] == nil {
+			raw[section] = map[string]interface{}{}
+			continue
+		}
+		entries, ok := raw[section].(map[string]interface{})
+		if !ok {
+			// left for decoding to quarantine
+			continue
+		}
+		for id, entry := range entries {
+			if m, ok := entry.(map[string]interface{}); ok {
+				m["id"] = id
+			}
+		}
+	}
+	return nil
+}
+
+/*
+v1 files set the dmx universe and channel mapping for every dmx effect in dmx_input.
+Each dmx effect now has its own, so they're copied into the extra config of dmx effects which have none,
+including those kept in profiles, and channels map to settings by name.
+*/
+func migrateV1(raw map[string]interface{}) error {
+	dmxInput, _ := raw["dmx_input"].(map[string]interface{})
+	if dmxInput == nil {
+		dmxInput = map[string]interface{}{}
+	}
+	setting := func(key string, fallback float64) float64 {
+		if v, ok := dmxInput[key].(float64); ok {
+			return v
+		}
+		return fallback
+	}
+	universe := setting("universe", 1)
+	mode, ok := dmxInput["mode"].(string)
+	if !ok {
+		mode = "passthrough"
+	}
+	// v1 defaults mapped brightness to channel 1 and the palette position to channel 2. 0 left a channel unmapped
+	channels := map[string]interface{}{}
+	for _, m := range []struct {
+		key, target string
+		channel     float64
+	}{
+		{"brightness_channel", "brightness", 1},
+		{"palette_channel", "position", 2},
+		{"saturation_channel", "saturation", 0},
+	} {
+		if ch := int(setting(m.key, m.channel)); ch > 0 {
+			channels[fmt.Sprint(ch)] = m.target
+		}
+	}
+
+	eachEntry(raw, "effects", func(m map[string]interface{}) {
+		if m["type"] != "dmx" || m["extra_config"] != nil {
+			return
+		}
+		effectChannels := map[string]interface{}{}
+		for ch, target := range channels {
+			effectChannels[ch] = target
+		}
+		m["extra_config"] = map[string]interface{}{
+			"universe": universe,
+			"mode":     mode,
+			"channels": effectChannels,
+		}
+	})
+
+	for _, key := range []string{"universe", "mode", "brightness_channel", "palette_channel", "saturation_channel"} {
+		delete(dmxInput, key)
+	}
+	return nil
+}
+
+/*
+The audio section of older configs held the capture device and a frame rate.
+The device is now the local input, and frame rates are set per controller,
+so they're moved there unless already set.
+*/
+func migrateV2(raw map[string]interface{}) error {
+	audio, ok := raw["audio"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	if device, ok := audio["device"].(map[string]interface{}); ok {
+		localInput, _ := raw["local_input"].(string)
+		if id, ok := device["id"].(string); ok && id != "" && localInput == "" {
+			raw["local_input"] = id
+		}
+	}
+	if frameRate, ok := audio["frame_rate"].(float64); ok && frameRate >= 5 && frameRate <= 120 {
+		eachEntry(raw, "controllers", func(m map[string]interface{}) {
+			c, ok := m["base_config"].(map[string]interface{})
+			if !ok {
+				c = map[string]interface{}{}
+				m["base_config"] = c
+			}
+			if _, set := c["framerate"]; !set {
+				c["framerate"] = frameRate
+			}
+		})
+	}
+	delete(audio, "device")
+	delete(audio, "frame_rate")
+	return nil
+}
+
+// calls fn with each entry of a raw config section, including those kept in profiles
+func eachEntry(raw map[string]interface{}, section string, fn func(entry map[string]interface{})) {
+	sections := []interface{}{raw[section]}
+	if profiles, ok := raw["profiles"].(map[string]interface{}); ok {
+		for _, profile := range profiles {
+			if m, ok := profile.(map[string]interface{}); ok {
+				sections = append(sections, m[section])
+			}
+		}
+	}
+	for _, entries := range sections {
+		entries, ok := entries.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, entry := range entries {
+			if m, ok := entry.(map[string]interface{}); ok {
+				fn(m)
+			}
+		}
+	}
+}
+
+// the version of a raw config. files saved before versioning are version 0
+func configVersion(raw map[string]interface{}) int {
+	if v, ok := raw["version"].(float64); ok {
+		return int(v)
+	}
+	if v, ok := raw["version"].(int); ok {
+		return v
+	}
+	return 0
+}
+
+// copies the config file before it's migrated, so it can be restored with an older LedFx
+func backupForMigration(version int, content []byte) error {
+	backupPath := fmt.Sprintf("%s.v%d.bak", configPath, version)
+	if err := ioutil.WriteFile(backupPath, content, 0644); err != nil {
+		return fmt.Errorf("error backing up config before migrating: %w", err)
+	}
+	logger.Logger.WithField("context", "Config").Infof("Backed up config version %d to %s", version, backupPath)
+	return nil
+}
+
+/*
+Upgrades a raw config to the current version.
+Returns whether the config was migrated, in which case it should be saved.
+Configs from a newer version of LedFx return ErrNewerVersion, and are left as they are.
+*/
+func migrateConfig(raw map[string]interface{}) (bool, error) {
+	version := configVersion(raw)
+	if version > CurrentVersion {
+		return false, fmt.Errorf("%w: version %d, this LedFx understands up to %d", ErrNewerVersion, version, CurrentVersion)
+	}
+	if version == CurrentVersion {
+		return false, nil
+	}
+	for ; version < CurrentVersion; version++ {
+		if err := migrations[version](raw); err != nil {
+			return false, fmt.Errorf("error migrating config from version %d: %w", version, err)
+		}
+		logger.Logger.WithField("context", "Config").Infof("Migrated config from version %d to %d", version, version+1)
+	}
+	raw["version"] = CurrentVersion
+	return true, nil
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"reflect"
+
+	"github.com/LedFx/ledfx/pkg/logger"
+	"github.com/LedFx/ledfx/pkg/util"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// Connection to an MQTT broker, eg. for Home Assistant
+type MqttConfig struct {
+	Enabled         bool   `mapstructure:"enabled" json:"enabled" description:"Connect to the MQTT broker" default:"false" validate:""`
+	Host            string `mapstructure:"host" json:"host" description:"Hostname or IP address of the MQTT broker" default:"localhost" validate:"min=1"`
+	Port            int    `mapstructure:"port" json:"port" description:"Port of the MQTT broker" default:"1883" validate:"gte=1,lte=65535"`
+	Username        string `mapstructure:"username" json:"username" description:"Username for the MQTT broker, if it needs one" default:"" validate:""`
+	Password        string `mapstructure:"password" json:"password" description:"Password for the MQTT broker, if it needs one" default:"" validate:""`
+	ClientID        string `mapstructure:"client_id" json:"client_id" description:"Client id given to the MQTT broker. Must be unique on the broker" default:"ledfx" validate:"min=1"`
+	BaseTopic       string `mapstructure:"base_topic" json:"base_topic" description:"Topic under which LedFx publishes state and takes commands" default:"ledfx" validate:"min=1"`
+	DiscoveryPrefix string `mapstructure:"discovery_prefix" json:"discovery_prefix" description:"Home Assistant MQTT discovery prefix" default:"homeassistant" validate:"min=1"`
+}
+
+// Generate mqtt config schema
+func MqttSchema() (schema map[string]interface{}, err error) {
+	return util.CreateSchema(reflect.TypeOf((*MqttConfig)(nil)).Elem())
+}
+
+// Generate mqtt config schema as json
+func MqttJsonSchema() (jsonSchema []byte, err error) {
+	schema, err := MqttSchema()
+	if err != nil {
+		return jsonSchema, err
+	}
+	jsonSchema, err = util.CreateJsonSchema(schema)
+	return jsonSchema, err
+}
+
+func GetMqtt() MqttConfig {
+	return store.Mqtt
+}
+
+// Incrementally updates the mqtt config. The client must be restarted for it to take effect.
+func SetMqtt(c map[string]interface{}) error {
+	mu.Lock()
+	defer mu.Unlock()
+	prevMqtt := store.Mqtt
+	err := mapstructure.Decode(c, &store.Mqtt)
+	if err == nil {
+		err = validate.Struct(&store.Mqtt)
+	}
+	if err != nil {
+		store.Mqtt = prevMqtt
+		logger.Logger.WithField("context", "Config").Warn(err)
+		return err
+	}
+	return saveConfig()
+}
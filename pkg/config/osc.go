@@ -0,0 +1,56 @@
+package config
+
+import (
+	"reflect"
+
+	"github.com/LedFx/ledfx/pkg/logger"
+	"github.com/LedFx/ledfx/pkg/util"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// OSC server for control from live performance tools
+type OscConfig struct {
+	Enabled        bool     `mapstructure:"enabled" json:"enabled" description:"Listen for OSC messages" default:"false" validate:""`
+	Host           string   `mapstructure:"host" json:"host" description:"Address to listen for OSC messages on. 127.0.0.1 only accepts messages from this machine, 0.0.0.0 from any" default:"127.0.0.1" validate:"ip"`
+	Port           int      `mapstructure:"port" json:"port" description:"UDP port to listen for OSC messages on" default:"9000" validate:"gte=1,lte=65535"`
+	AllowedSources []string `mapstructure:"allowed_sources" json:"allowed_sources" description:"Addresses or networks allowed to send OSC messages, eg. 192.168.1.20 or 192.168.1.0/24. Leave empty to allow any" default:"[]" validate:"dive,ip|cidr"`
+	FeedbackHost   string   `mapstructure:"feedback_host" json:"feedback_host" description:"Host to send OSC feedback to. Feedback is always sent to clients which have sent messages" default:"" validate:""`
+	FeedbackPort   int      `mapstructure:"feedback_port" json:"feedback_port" description:"Port to send OSC feedback to, if a feedback host is set" default:"9001" validate:"gte=1,lte=65535"`
+}
+
+// Generate osc config schema
+func OscSchema() (schema map[string]interface{}, err error) {
+	return util.CreateSchema(reflect.TypeOf((*OscConfig)(nil)).Elem())
+}
+
+// Generate osc config schema as json
+func OscJsonSchema() (jsonSchema []byte, err error) {
+	schema, err := OscSchema()
+	if err != nil {
+		return jsonSchema, err
+	}
+	jsonSchema, err = util.CreateJsonSchema(schema)
+	return jsonSchema, err
+}
+
+func GetOsc() OscConfig {
+	return store.Osc
+}
+
+// Incrementally updates the osc config. The server must be restarted for it to take effect.
+func SetOsc(c map[string]interface{}) error {
+	mu.Lock()
+	defer mu.Unlock()
+	prevOsc := store.Osc
+	err := mapstructure.Decode(c, &store.Osc)
+	if err == nil {
+		err = validate.Struct(&store.Osc)
+	}
+	if err != nil {
+		store.Osc = prevOsc
+		logger.Logger.WithField("context", "Config").Warn(err)
+		return err
+	}
+	return saveConfig()
+}
@@ -0,0 +1,236 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/LedFx/ledfx/pkg/event"
+	"github.com/LedFx/ledfx/pkg/logger"
+)
+
+/*
+Changes to the config are saved once they settle, so a burst of changes, eg. dragging a
+slider, is written to file once. Files are replaced atomically: the config is written to a
+temporary file, synced to disk, then renamed over the old file, so a crash can't leave a
+half written config. The previous versions are kept as rotated backups, config.json.1 being
+the newest.
+*/
+
+// How long the config must be quiet before it's saved
+var saveDelay = 500 * time.Millisecond
+
+// The longest a change waits to be saved, even if the config keeps changing
+var saveMaxDelay = 5 * time.Second
+
+// How long to wait before trying again when saving fails
+var saveRetryDelay = 5 * time.Second
+
+// Number of rotated backups to keep
+var backupCount = 5
+
+// Backups are rotated at most this often, so they cover more than the last few seconds of changes
+var backupInterval = 10 * time.Minute
+
+var (
+	saveTimer   *time.Timer
+	savePending time.Time // when the oldest unsaved change was made. zero if there are none
+	lastBackup  time.Time
+	disabled    int // DisableSaving calls which haven't been undone
+	suspended   int // SuspendSaving calls which haven't been resumed
+)
+
+func init() {
+	event.Subscribe(event.Shutdown, func(e *event.Event) { Flush() })
+}
+
+/*
+Stops the config being written to file, eg. in tests. Changes are still made and
+recorded in the history, but those made before saving is enabled again are never saved.
+Calls can nest.
+*/
+func DisableSaving() (enable func()) {
+	mu.Lock()
+	defer mu.Unlock()
+	disabled++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			mu.Lock()
+			defer mu.Unlock()
+			disabled--
+		})
+	}
+}
+
+/*
+Holds off saving and recording history while the live setup is rebuilt from the config,
+eg. by the loader, whose changes only restate the config. Changes made meanwhile are
+saved once saving resumes, but aren't recorded in the history. Calls can nest.
+Explicit writes, such as applying an import, still happen.
+*/
+func SuspendSaving() (resume func()) {
+	mu.Lock()
+	defer mu.Unlock()
+	suspended++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			mu.Lock()
+			defer mu.Unlock()
+			suspended--
+			if suspended == 0 && !savePending.IsZero() {
+				saveConfig()
+			}
+		})
+	}
+}
+
+// Schedules the config to be saved. Caller must hold the lock.
+func saveConfig() error {
+	if disabled > 0 || readOnly {
+		return nil
+	}
+	now := time.Now()
+	if savePending.IsZero() {
+		savePending = now
+	}
+	if suspended > 0 {
+		// saved on resume
+		return nil
+	}
+	delay := saveDelay
+	if untilMax := saveMaxDelay - now.Sub(savePending); untilMax < delay {
+		delay = untilMax
+	}
+	if saveTimer == nil {
+		saveTimer = time.AfterFunc(delay, func() { Flush() })
+	} else {
+		saveTimer.Reset(delay)
+	}
+	return nil
+}
+
+// Saves any unsaved changes to the config now, unless saving is suspended
+func Flush() error {
+	mu.Lock()
+	defer mu.Unlock()
+	if savePending.IsZero() || suspended > 0 {
+		return nil
+	}
+	return writeConfig()
+}
+
+/*
+Writes the config to file. Caller must hold the lock.
+If writing fails, the changes stay unsaved and it's tried again after saveRetryDelay.
+*/
+func writeConfig() (err error) {
+	if saveTimer != nil {
+		saveTimer.Stop()
+	}
+	if disabled > 0 || readOnly {
+		savePending = time.Time{}
+		return nil
+	}
+	defer func() {
+		if err != nil {
+			retrySave()
+			return
+		}
+		savePending = time.Time{}
+	}()
+	file, err := encodeConfig(store, isYaml(configPath))
+	if err != nil {
+		return err
+	}
+	if time.Since(lastBackup) > backupInterval {
+		if err = rotateBackups(); err != nil {
+			logger.Logger.WithField("context", "Config").Warnf("Failed to back up config: %v", err)
+		}
+		lastBackup = time.Now()
+	}
+	if err = writeFileAtomic(configPath, file); err != nil {
+		logger.Logger.WithField("context", "Config").Warnf("Failed to save config to file at %s: %v", configPath, err)
+		return err
+	}
+	logger.Logger.WithField("context", "Config").Debugf("Saved config")
+	return nil
+}
+
+// re-arms the save timer after a failed write. Caller must hold the lock.
+func retrySave() {
+	if savePending.IsZero() {
+		savePending = time.Now()
+	}
+	if saveTimer == nil {
+		saveTimer = time.AfterFunc(saveRetryDelay, func() { Flush() })
+	} else {
+		saveTimer.Reset(saveRetryDelay)
+	}
+}
+
+// the path of a rotated backup. 1 is the newest
+func backupPath(n int) string {
+	return fmt.Sprintf("%s.%d", configPath, n)
+}
+
+// shifts the backups along, dropping the oldest, and copies the config file in as the newest
+func rotateBackups() error {
+	if backupCount < 1 {
+		return nil
+	}
+	content, err := ioutil.ReadFile(configPath)
+	if os.IsNotExist(err) || len(content) == 0 {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	os.Remove(backupPath(backupCount))
+	for n := backupCount - 1; n >= 1; n-- {
+		if err = os.Rename(backupPath(n), backupPath(n+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return writeFileAtomic(backupPath(1), content)
+}
+
+// writes to a temporary file, syncs it, and renames it over the path
+func writeFileAtomic(path string, data []byte) (err error) {
+	dir := filepath.Dir(path)
+	tmp, err := ioutil.TempFile(dir, filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+	if _, err = tmp.Write(data); err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	// sync the directory so the rename survives a crash. not possible on every os
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/LedFx/ledfx/pkg/event"
+)
+
+/*
+Profiles are separate setups of effects, devices, controllers and connections, eg. one for
+home and one for gigs. The active profile is the live config; the others are kept under
+"profiles" until they're switched to. Settings such as audio or mqtt are shared by all profiles.
+*/
+type Profile struct {
+	Effects     map[string]EffectEntry     `mapstructure:"effects" json:"effects"`
+	Devices     map[string]DeviceEntry     `mapstructure:"devices" json:"devices"`
+	Controllers map[string]ControllerEntry `mapstructure:"controllers" json:"controllers"`
+	ConnEffect  map[string]string          `mapstructure:"connections_effect" json:"connections_effect"`
+	ConnDevice  map[string]string          `mapstructure:"connections_device" json:"connections_device"`
+	VirtStates  map[string]bool            `mapstructure:"controller_states" json:"controller_states"`
+}
+
+var profileName = regexp.MustCompile(`^[\w\- ]{1,64}$`)
+
+// A profile switch, holding the active profile as it was before anything was unloaded
+type ProfileSwitch struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	active Profile
+}
+
+// Get the active profile, and the names of all profiles
+func GetProfiles() (active string, names []string) {
+	mu.Lock()
+	defer mu.Unlock()
+	return store.Profile, store.profileNames()
+}
+
+// Creates an empty profile
+func CreateProfile(name string) error {
+	return addProfile(name, func() (Profile, error) { return Profile{}, nil })
+}
+
+// Creates a profile as a copy of another
+func CloneProfile(from, name string) error {
+	return addProfile(name, func() (p Profile, err error) {
+		source, err := store.profile(from)
+		if err != nil {
+			return p, err
+		}
+		// a deep copy, so the profiles don't share entries
+		err = decodeJson(source, &p)
+		return p, err
+	})
+}
+
+// Deletes a profile. The active profile can't be deleted
+func DeleteProfile(name string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if name == store.Profile {
+		return fmt.Errorf("cannot delete the active profile %s", name)
+	}
+	if _, exists := store.Profiles[name]; !exists {
+		return fmt.Errorf("profile %s does not exist", name)
+	}
+	delete(store.Profiles, name)
+	profilesUpdated()
+	return saveConfig()
+}
+
+/*
+Prepares to switch to another profile, keeping a copy of the active profile.
+Unload the running setup, apply the switch, then load from the new config.
+*/
+func PrepareProfileSwitch(name string) (*ProfileSwitch, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if name == store.Profile {
+		return nil, fmt.Errorf("profile %s is already active", name)
+	}
+	if _, exists := store.Profiles[name]; !exists {
+		return nil, fmt.Errorf("profile %s does not exist", name)
+	}
+	s := &ProfileSwitch{From: store.Profile, To: name}
+	active, _ := store.profile(store.Profile)
+	if err := decodeJson(active, &s.active); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Stores the previously active profile and makes the new one live
+func (s *ProfileSwitch) Apply() error {
+	mu.Lock()
+	defer mu.Unlock()
+	next, exists := store.Profiles[s.To]
+	if !exists {
+		return fmt.Errorf("profile %s does not exist", s.To)
+	}
+	store.Profiles[s.From] = s.active
+	delete(store.Profiles, s.To)
+	store.Profile = s.To
+	store.Effects = next.Effects
+	store.Devices = next.Devices
+	store.Controllers = next.Controllers
+	store.ConnEffect = next.ConnEffect
+	store.ConnDevice = next.ConnDevice
+	store.VirtStates = next.VirtStates
+	if store.Effects == nil {
+		store.Effects = map[string]EffectEntry{}
+	}
+	if store.Devices == nil {
+		store.Devices = map[string]DeviceEntry{}
+	}
+	if store.Controllers == nil {
+		store.Controllers = map[string]ControllerEntry{}
+	}
+	resetHistory()
+	profilesUpdated()
+	return writeConfig()
+}
+
+func addProfile(name string, create func() (Profile, error)) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if !profileName.MatchString(name) {
+		return fmt.Errorf("invalid profile name '%s'. Use up to 64 letters, numbers, spaces, dashes and underscores", name)
+	}
+	if _, err := store.profile(name); err == nil {
+		return fmt.Errorf("profile %s already exists", name)
+	}
+	p, err := create()
+	if err != nil {
+		return err
+	}
+	store.Profiles[name] = p
+	profilesUpdated()
+	return saveConfig()
+}
+
+// announces the profiles have changed. caller must hold the lock
+func profilesUpdated() {
+	event.Invoke(event.ProfilesUpdateData{Active: store.Profile, Profiles: store.profileNames()})
+}
+
+// names of all profiles, including the active profile, sorted. caller must hold the lock
+func (c *config) profileNames() []string {
+	names := []string{c.Profile}
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// gets a profile by name, including the active profile. caller must hold the lock
+func (c *config) profile(name string) (Profile, error) {
+	if name == c.Profile {
+		return Profile{
+			Effects:     c.Effects,
+			Devices:     c.Devices,
+			Controllers: c.Controllers,
+			ConnEffect:  c.ConnEffect,
+			ConnDevice:  c.ConnDevice,
+			VirtStates:  c.VirtStates,
+		}, nil
+	}
+	if p, exists := c.Profiles[name]; exists {
+		return p, nil
+	}
+	return Profile{}, fmt.Errorf("profile %s does not exist", name)
+}
@@ -0,0 +1,222 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/LedFx/ledfx/pkg/logger"
+)
+
+/*
+Invalid parts of the config are moved into quarantine rather than stopping LedFx.
+They are kept in the config file under "quarantine", so nothing is lost, and can
+be reviewed or discarded over the API.
+*/
+type QuarantinedEntry struct {
+	Type   string      `json:"type"` // effect, device, controller, effect_connection, device_connection or section
+	ID     string      `json:"id"`   // id of the entry, or key of the section
+	Entry  interface{} `json:"entry"`
+	Reason string      `json:"reason"`
+	Time   time.Time   `json:"time"`
+}
+
+// Checks a config entry is valid, as the package which loads it would
+type EntryValidator func(entry interface{}) error
+
+var validators = map[EntryType]EntryValidator{}
+
+// Registers the validator for a type of entry, used by ValidateEntries
+func RegisterValidator(t EntryType, fn EntryValidator) {
+	mu.Lock()
+	defer mu.Unlock()
+	validators[t] = fn
+}
+
+// Get everything in quarantine
+func GetQuarantine() []QuarantinedEntry {
+	mu.Lock()
+	defer mu.Unlock()
+	q := make([]QuarantinedEntry, len(store.Quarantine))
+	copy(q, store.Quarantine)
+	return q
+}
+
+// Discard everything in quarantine
+func ClearQuarantine() error {
+	mu.Lock()
+	defer mu.Unlock()
+	store.Quarantine = nil
+	return saveConfig()
+}
+
+/*
+Moves an entry which can't be loaded out of the config and into quarantine.
+The entry is given, as it may have already been removed from the config.
+*/
+func QuarantineEntry(t EntryType, id string, entry interface{}, reason error) error {
+	mu.Lock()
+	defer mu.Unlock()
+	switch t {
+	case Effect:
+		delete(store.Effects, id)
+	case Device:
+		delete(store.Devices, id)
+	case Controller:
+		delete(store.Controllers, id)
+	}
+	store.quarantine(t.String(), id, entry, reason)
+	return saveConfig()
+}
+
+// Moves a connection which can't be made out of the config and into quarantine
+func QuarantineConnection(t EntryType, id string, reason error) error {
+	mu.Lock()
+	defer mu.Unlock()
+	var conn map[string]string
+	switch t {
+	case Effect:
+		conn = store.ConnEffect
+	case Device:
+		conn = store.ConnDevice
+	default:
+		return fmt.Errorf("%s entries have no connections", t.String())
+	}
+	controllerID, exists := conn[id]
+	if !exists {
+		return nil
+	}
+	delete(conn, id)
+	store.quarantine(t.String()+"_connection", id, controllerID, reason)
+	return saveConfig()
+}
+
+/*
+Runs the registered validators over every effect, device and controller in the
+config, and quarantines the invalid ones along with connections to anything missing.
+Returns how many were quarantined.
+*/
+func ValidateEntries() int {
+	mu.Lock()
+	entries := store.entries()
+	mu.Unlock()
+
+	// validators may read the config, so they are run without holding the lock
+	invalid := validateEntries(entries)
+
+	mu.Lock()
+	defer mu.Unlock()
+	count := store.quarantineInvalid(invalid)
+	if count > 0 {
+		logger.Logger.WithField("context", "Config").Warnf("Quarantined %d invalid config entries. See /api/config/quarantine", count)
+		saveConfig()
+	}
+	return count
+}
+
+type invalidEntry struct {
+	t     EntryType
+	id    string
+	entry interface{}
+	err   error
+}
+
+// the effect, device and controller entries of a config
+func (c *config) entries() map[EntryType]map[string]interface{} {
+	entries := map[EntryType]map[string]interface{}{Effect: {}, Device: {}, Controller: {}}
+	for id, entry := range c.Effects {
+		entries[Effect][id] = entry
+	}
+	for id, entry := range c.Devices {
+		entries[Device][id] = entry
+	}
+	for id, entry := range c.Controllers {
+		entries[Controller][id] = entry
+	}
+	return entries
+}
+
+// runs the registered validators over entries. must not be called holding the lock
+func validateEntries(entries map[EntryType]map[string]interface{}) (invalid []invalidEntry) {
+	mu.Lock()
+	fns := map[EntryType]EntryValidator{}
+	for t, fn := range validators {
+		fns[t] = fn
+	}
+	mu.Unlock()
+	for _, t := range []EntryType{Effect, Device, Controller} {
+		fn, ok := fns[t]
+		if !ok {
+			continue
+		}
+		for id, entry := range entries[t] {
+			if err := fn(entry); err != nil {
+				invalid = append(invalid, invalidEntry{t, id, entry, err})
+			}
+		}
+	}
+	return invalid
+}
+
+/*
+Quarantines invalid entries, then connections to anything missing.
+Returns how many were quarantined. Caller must hold the lock when c is the store.
+*/
+func (c *config) quarantineInvalid(invalid []invalidEntry) int {
+	for _, i := range invalid {
+		switch i.t {
+		case Effect:
+			delete(c.Effects, i.id)
+		case Device:
+			delete(c.Devices, i.id)
+		case Controller:
+			delete(c.Controllers, i.id)
+		}
+		c.quarantine(i.t.String(), i.id, i.entry, i.err)
+	}
+	count := len(invalid)
+	for effectID, controllerID := range c.ConnEffect {
+		if err := c.connectionError(Effect, effectID, controllerID); err != nil {
+			delete(c.ConnEffect, effectID)
+			c.quarantine("effect_connection", effectID, controllerID, err)
+			count++
+		}
+	}
+	for deviceID, controllerID := range c.ConnDevice {
+		if err := c.connectionError(Device, deviceID, controllerID); err != nil {
+			delete(c.ConnDevice, deviceID)
+			c.quarantine("device_connection", deviceID, controllerID, err)
+			count++
+		}
+	}
+	return count
+}
+
+// a connection is broken if either end is missing from the config
+func (c *config) connectionError(t EntryType, id, controllerID string) error {
+	exists := false
+	switch t {
+	case Effect:
+		_, exists = c.Effects[id]
+	case Device:
+		_, exists = c.Devices[id]
+	}
+	if !exists {
+		return fmt.Errorf("%s %s does not exist", t.String(), id)
+	}
+	if _, exists = c.Controllers[controllerID]; !exists {
+		return fmt.Errorf("controller %s does not exist", controllerID)
+	}
+	return nil
+}
+
+// caller must hold the lock when c is the store
+func (c *config) quarantine(t, id string, entry interface{}, reason error) {
+	logger.Logger.WithField("context", "Config").Warnf("Quarantined %s %s: %v", t, id, reason)
+	c.Quarantine = append(c.Quarantine, QuarantinedEntry{
+		Type:   t,
+		ID:     id,
+		Entry:  entry,
+		Reason: reason.Error(),
+		Time:   time.Now(),
+	})
+}
@@ -20,6 +20,10 @@ type SettingsConfig struct {
 	NoScan   bool   `mapstructure:"no_scan" json:"no_scan" default:"false" validate:"" description:"Disable automatic WLED scanning and configuration in LedFx"`
 	OpenUi   bool   `mapstructure:"open_ui" json:"open_ui" default:"false" validate:"" description:"Automatically open the web interface at startup"`
 	LogLevel int    `mapstructure:"log_level" json:"log_level" default:"2" validate:"gte=0,lte=2" description:"Set log level [0: debug, 1: info, 2: warnings]"`
+	TLS      bool   `mapstructure:"tls" json:"tls" default:"false" validate:"" description:"Serve the web interface over HTTPS"`
+	TLSCert  string `mapstructure:"tls_cert" json:"tls_cert" default:"" validate:"" description:"Path to the TLS certificate. A self-signed certificate is made in the config dir if not given"`
+	TLSKey   string `mapstructure:"tls_key" json:"tls_key" default:"" validate:"" description:"Path to the TLS certificate's private key"`
+	Redirect int    `mapstructure:"redirect_port" json:"redirect_port" default:"0" validate:"gte=0,lte=65535" description:"Port to redirect HTTP to HTTPS from. 0 disables the redirect"`
 }
 
 // Generate settings config schema
@@ -37,9 +41,11 @@ func CoreJsonSchema() (jsonSchema []byte, err error) {
 	return jsonSchema, err
 }
 
-// returns settings including those set by command line args
+// returns settings including those set by environment variables and command line args
 func GetSettings() SettingsConfig {
 	settings := store.Settings
+	// apply environment variables, which have been validated
+	mapstructure.Decode(envSettings, &settings)
 	// apply command line args which the user specified
 	host := pflag.Lookup("host")
 	port := pflag.Lookup("port")
@@ -49,6 +55,10 @@ func GetSettings() SettingsConfig {
 	noTray := pflag.Lookup("no_tray")
 	openUi := pflag.Lookup("open_ui")
 	logLevel := pflag.Lookup("log_level")
+	tls := pflag.Lookup("tls")
+	tlsCert := pflag.Lookup("tls_cert")
+	tlsKey := pflag.Lookup("tls_key")
+	redirect := pflag.Lookup("redirect_port")
 
 	if host.Changed {
 		settings.Host = hostArg
@@ -74,6 +84,18 @@ func GetSettings() SettingsConfig {
 	if logLevel.Changed {
 		settings.LogLevel = logLevelArg
 	}
+	if tls.Changed {
+		settings.TLS = tlsArg
+	}
+	if tlsCert.Changed {
+		settings.TLSCert = tlsCertArg
+	}
+	if tlsKey.Changed {
+		settings.TLSKey = tlsKeyArg
+	}
+	if redirect.Changed {
+		settings.Redirect = redirectArg
+	}
 	return settings
 }
 
@@ -93,9 +115,8 @@ func SetSettings(c map[string]interface{}) error {
 		return err
 	}
 	err = saveConfig()
-	event.Invoke(event.SettingsUpdate,
-		map[string]interface{}{
-			"settings": store.Settings,
-		})
+	event.Invoke(event.SettingsUpdateData{
+		Settings: store.Settings,
+	})
 	return err
 }
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"reflect"
+
+	"github.com/LedFx/ledfx/pkg/logger"
+	"github.com/LedFx/ledfx/pkg/util"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// What to do when the audio goes silent, and how silence is detected
+type SilenceConfig struct {
+	Threshold     int     `mapstructure:"threshold" json:"threshold" description:"Audio level in dB below which audio is considered silent" default:"-60" validate:"gte=-120,lte=0"`
+	Window        int     `mapstructure:"window" json:"window" description:"Seconds of continuous quiet before audio is considered silent" default:"10" validate:"gte=1,lte=3600"`
+	Action        string  `mapstructure:"action" json:"action" description:"What active controllers do during silence" default:"none" validate:"oneof=none effect dim off"`
+	IdleEffect    string  `mapstructure:"idle_effect" json:"idle_effect" description:"Effect type shown during silence, if the action is effect" default:"fade" validate:""`
+	DimBrightness float64 `mapstructure:"dim_brightness" json:"dim_brightness" description:"Brightness of controllers during silence, if the action is dim" default:"0.2" validate:"gte=0,lte=1"`
+}
+
+// Generate silence config schema
+func SilenceSchema() (schema map[string]interface{}, err error) {
+	return util.CreateSchema(reflect.TypeOf((*SilenceConfig)(nil)).Elem())
+}
+
+// Generate silence config schema as json
+func SilenceJsonSchema() (jsonSchema []byte, err error) {
+	schema, err := SilenceSchema()
+	if err != nil {
+		return jsonSchema, err
+	}
+	jsonSchema, err = util.CreateJsonSchema(schema)
+	return jsonSchema, err
+}
+
+func GetSilence() SilenceConfig {
+	return store.Silence
+}
+
+// Incrementally updates the silence config. Takes effect from the next audio buffer.
+func SetSilence(c map[string]interface{}) error {
+	mu.Lock()
+	defer mu.Unlock()
+	prevSilence := store.Silence
+	err := mapstructure.Decode(c, &store.Silence)
+	if err == nil {
+		err = validate.Struct(&store.Silence)
+	}
+	if err != nil {
+		store.Silence = prevSilence
+		logger.Logger.WithField("context", "Config").Warn(err)
+		return err
+	}
+	return saveConfig()
+}
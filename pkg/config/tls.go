@@ -0,0 +1,59 @@
+package config
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/LedFx/ledfx/pkg/logger"
+	"github.com/LedFx/ledfx/pkg/util"
+)
+
+// names of the self-signed certificate and key made in the config dir
+const (
+	certName = "ledfx.crt"
+	keyName  = "ledfx.key"
+)
+
+/*
+Gets the paths of the certificate and key to serve HTTPS with.
+If none were given, a self-signed certificate is made in the config dir on first start,
+for localhost, this machine's name and its addresses. It's remade if they change.
+Browsers will warn about it until it's trusted.
+*/
+func TLSFiles() (cert, key string, err error) {
+	settings := GetSettings()
+	if settings.TLSCert != "" || settings.TLSKey != "" {
+		if settings.TLSCert == "" || settings.TLSKey == "" {
+			return "", "", errors.New("tls_cert and tls_key must be given together")
+		}
+		return settings.TLSCert, settings.TLSKey, nil
+	}
+	dir := filepath.Dir(configPath)
+	cert, key = filepath.Join(dir, certName), filepath.Join(dir, keyName)
+	if err = util.EnsureSelfSignedCert(cert, key, certHosts(settings.Host)); err != nil {
+		return "", "", err
+	}
+	logger.Logger.WithField("context", "Config").Infof("Using self-signed certificate %s", cert)
+	return cert, key, nil
+}
+
+// the names and addresses the web interface can be reached at
+func certHosts(host string) []string {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	if name, err := os.Hostname(); err == nil {
+		hosts = append(hosts, name, name+".local")
+	}
+	if ip := net.ParseIP(host); ip != nil && !ip.IsUnspecified() {
+		return append(hosts, host)
+	}
+	// listening on all interfaces, so include all their addresses
+	addrs, _ := net.InterfaceAddrs()
+	for _, a := range addrs {
+		if n, ok := a.(*net.IPNet); ok && !n.IP.IsLoopback() {
+			hosts = append(hosts, n.IP.String())
+		}
+	}
+	return hosts
+}
@@ -0,0 +1,148 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// Sections keyed by id, which are compared and merged key by key
+var keyedSections = []string{"effects", "devices", "controllers", "connections_effect", "connections_device", "controller_states", "audio_sources"}
+
+// Changes an import would make to the config, by section
+type ImportDiff struct {
+	Added    map[string][]string `json:"added"`    // ids added to each keyed section
+	Removed  map[string][]string `json:"removed"`  // ids removed from each keyed section
+	Changed  map[string][]string `json:"changed"`  // ids changed in each keyed section
+	Sections []string            `json:"sections"` // other sections which change, eg. core or audio
+}
+
+// A config staged for import, ready to replace the live config
+type Import struct {
+	Merge   bool               `json:"merge"`
+	Diff    ImportDiff         `json:"diff"`
+	Invalid []QuarantinedEntry `json:"invalid"` // parts of the import which were quarantined
+	config  *config
+}
+
+// The live config as json, for backups and moving a setup to another machine
+func Export() ([]byte, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	return json.MarshalIndent(store, "", "  ")
+}
+
+/*
+Stages an exported config, or a yaml config file, for import, migrating it if it's from an older version.
+Merging adds and overwrites entries and settings given in the import, keeping the rest.
+Otherwise the import replaces the whole config, apart from the auth settings which are always kept.
+Invalid parts of the import are quarantined, as when the config file is loaded.
+*/
+func PrepareImport(content []byte, merge bool) (*Import, error) {
+	raw, err := parseConfig(content, false)
+	if err != nil {
+		// exports are json, but a yaml config file can be imported too
+		if raw, err = parseConfig(content, true); err != nil {
+			return nil, fmt.Errorf("error parsing config: %w", err)
+		}
+	}
+	if _, err := migrateConfig(raw); err != nil {
+		return nil, err
+	}
+
+	c, err := newConfig()
+	if err != nil {
+		return nil, err
+	}
+	if merge {
+		// decode on top of a copy of the live config, keeping its quarantine
+		delete(raw, "quarantine")
+		mu.Lock()
+		err = decodeJson(store, c)
+		mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+	}
+	invalid := decodeConfig(c, raw)
+	c.Version = CurrentVersion
+	// an import mustn't turn off auth or change who can get in
+	mu.Lock()
+	c.Auth = store.Auth
+	mu.Unlock()
+	invalid += c.quarantineInvalid(validateEntries(c.entries()))
+
+	i := &Import{Merge: merge, config: c}
+	i.Invalid = c.Quarantine[len(c.Quarantine)-invalid:]
+	mu.Lock()
+	defer mu.Unlock()
+	i.Diff, err = diffConfigs(store, c)
+	return i, err
+}
+
+/*
+Replaces the live config with the import and saves it, backing up the config it replaces.
+Doesn't touch running effects, devices or controllers: unload them first, then load them
+from the new config.
+*/
+func (i *Import) Apply() error {
+	mu.Lock()
+	defer mu.Unlock()
+	store = i.config
+	resetHistory()
+	// always keep a backup of the config before an import
+	lastBackup = time.Time{}
+	return writeConfig()
+}
+
+// compares two configs section by section, as they would be saved
+func diffConfigs(from, to *config) (diff ImportDiff, err error) {
+	diff = ImportDiff{
+		Added:    map[string][]string{},
+		Removed:  map[string][]string{},
+		Changed:  map[string][]string{},
+		Sections: []string{},
+	}
+	a, b := map[string]interface{}{}, map[string]interface{}{}
+	if err = decodeJson(from, &a); err != nil {
+		return diff, err
+	}
+	if err = decodeJson(to, &b); err != nil {
+		return diff, err
+	}
+
+	keyed := map[string]bool{}
+	for _, section := range keyedSections {
+		keyed[section] = true
+		before, _ := a[section].(map[string]interface{})
+		after, _ := b[section].(map[string]interface{})
+		for id, value := range after {
+			if prev, exists := before[id]; !exists {
+				diff.Added[section] = append(diff.Added[section], id)
+			} else if !reflect.DeepEqual(prev, value) {
+				diff.Changed[section] = append(diff.Changed[section], id)
+			}
+		}
+		for id := range before {
+			if _, exists := after[id]; !exists {
+				diff.Removed[section] = append(diff.Removed[section], id)
+			}
+		}
+		sort.Strings(diff.Added[section])
+		sort.Strings(diff.Removed[section])
+		sort.Strings(diff.Changed[section])
+	}
+
+	for key := range b {
+		if keyed[key] || key == "version" || key == "quarantine" {
+			continue
+		}
+		if !reflect.DeepEqual(a[key], b[key]) {
+			diff.Sections = append(diff.Sections, key)
+		}
+	}
+	sort.Strings(diff.Sections)
+	return diff, nil
+}
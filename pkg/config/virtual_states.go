@@ -1,7 +1,14 @@
 package config
 
+// A copy of the saved controller states
 func GetStates() map[string]bool {
-	return store.VirtStates
+	mu.Lock()
+	defer mu.Unlock()
+	states := make(map[string]bool, len(store.VirtStates))
+	for id, state := range store.VirtStates {
+		states[id] = state
+	}
+	return states
 }
 
 func SetStates(states map[string]bool) {
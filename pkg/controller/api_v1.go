@@ -0,0 +1,178 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/LedFx/ledfx/pkg/api"
+	"github.com/LedFx/ledfx/pkg/config"
+	"github.com/LedFx/ledfx/pkg/device"
+	"github.com/LedFx/ledfx/pkg/effect"
+	"github.com/LedFx/ledfx/pkg/logger"
+)
+
+type effectJSON struct {
+	EffectID string `json:"effect_id"`
+}
+
+type connectionsJSON struct {
+	Effects map[string]string `json:"effects" description:"Controller ids, by effect id"`
+	Devices map[string]string `json:"devices" description:"Controller ids, by device id"`
+}
+
+func NewV1API(r *api.Router) {
+	schema, err := Schema()
+	if err != nil {
+		logger.Logger.WithField("context", "Controllers API").Error(err)
+	}
+	r.Schema("ControllerConfig", api.ConfigSchema(schema))
+	controllerSchema := api.TypeSchema(config.ControllerEntry{})
+	controllerSchema["properties"].(map[string]interface{})["base_config"] = api.Ref("ControllerConfig")
+	r.Schema("Controller", controllerSchema)
+
+	r.Get("/controllers", func(*api.Request) (interface{}, error) {
+		return config.GetControllers(), nil
+	}).Doc("Controllers", "Get all controllers, by id").Returns(api.MapOf(api.Ref("Controller")))
+
+	r.Post("/controllers", func(req *api.Request) (interface{}, error) {
+		data := config.ControllerEntry{}
+		if err := req.Decode(&data); err != nil {
+			return nil, err
+		}
+		_, id, err := New(data.ID, data.Config)
+		if err != nil {
+			return nil, api.BadRequest(err)
+		}
+		return config.GetController(id)
+	}).Doc("Controllers", "Create a controller. An id is made if none is given").
+		Accepts(api.Ref("Controller")).Returns(api.Ref("Controller")).Status(http.StatusCreated)
+
+	r.Get("/controllers/schema", func(*api.Request) (interface{}, error) {
+		return Schema()
+	}).Doc("Controllers", "Get the schema of controller configs, for building forms").Returns(map[string]interface{}{"type": "object"})
+
+	r.Get("/controllers/state", func(*api.Request) (interface{}, error) {
+		return GetStates(), nil
+	}).Doc("Controllers", "Get whether each controller is active, by id").Returns(api.MapOf(map[string]interface{}{"type": "boolean"}))
+
+	r.Put("/controllers/state", func(req *api.Request) (interface{}, error) {
+		states := map[string]bool{}
+		if err := req.Decode(&states); err != nil {
+			return nil, err
+		}
+		for id := range states {
+			if _, err := Get(id); err != nil {
+				return nil, api.NotFound(err)
+			}
+		}
+		if err := SetStates(states); err != nil {
+			return nil, err
+		}
+		return GetStates(), nil
+	}).Doc("Controllers", "Start or stop controllers, by id").
+		Accepts(api.MapOf(map[string]interface{}{"type": "boolean"})).Returns(api.MapOf(map[string]interface{}{"type": "boolean"}))
+
+	r.Get("/controllers/connections", func(*api.Request) (interface{}, error) {
+		effects, devices := GetConnections()
+		return connectionsJSON{Effects: effects, Devices: devices}, nil
+	}).Doc("Controllers", "Get the effects and devices connected to each controller").Returns(api.TypeSchema(connectionsJSON{}))
+
+	r.Get("/controllers/{id}", func(req *api.Request) (interface{}, error) {
+		if _, err := Get(req.Param("id")); err != nil {
+			return nil, api.NotFound(err)
+		}
+		return config.GetController(req.Param("id"))
+	}).Doc("Controllers", "Get a controller").Returns(api.Ref("Controller"))
+
+	r.Put("/controllers/{id}", func(req *api.Request) (interface{}, error) {
+		v, err := Get(req.Param("id"))
+		if err != nil {
+			return nil, api.NotFound(err)
+		}
+		saved, err := config.GetController(v.ID)
+		if err != nil {
+			return nil, err
+		}
+		data := config.ControllerEntry{}
+		if err = req.Decode(&data); err != nil {
+			return nil, err
+		}
+		// only the settings given are changed
+		c := make(map[string]interface{}, len(saved.Config)+len(data.Config))
+		for k, val := range saved.Config {
+			c[k] = val
+		}
+		for k, val := range data.Config {
+			c[k] = val
+		}
+		if err = v.UpdateConfig(c); err != nil {
+			return nil, api.BadRequest(err)
+		}
+		return config.GetController(v.ID)
+	}).Doc("Controllers", "Update a controller's config. Only the settings given are changed").
+		Accepts(api.Ref("Controller")).Returns(api.Ref("Controller"))
+
+	r.Delete("/controllers/{id}", func(req *api.Request) (interface{}, error) {
+		if _, err := Get(req.Param("id")); err != nil {
+			return nil, api.NotFound(err)
+		}
+		Destroy(req.Param("id"))
+		return nil, nil
+	}).Doc("Controllers", "Delete a controller, disconnecting its effect and devices")
+
+	r.Put("/controllers/{id}/effect", func(req *api.Request) (interface{}, error) {
+		if _, err := Get(req.Param("id")); err != nil {
+			return nil, api.NotFound(err)
+		}
+		data := effectJSON{}
+		if err := req.Decode(&data); err != nil {
+			return nil, err
+		}
+		if _, err := effect.Get(data.EffectID); err != nil {
+			return nil, api.BadRequest(err)
+		}
+		if err := ConnectEffect(data.EffectID, req.Param("id")); err != nil {
+			return nil, api.BadRequest(err)
+		}
+		return data, nil
+	}).Doc("Controllers", "Connect an effect to a controller, replacing its effect").
+		Accepts(api.TypeSchema(effectJSON{})).Returns(api.TypeSchema(effectJSON{}))
+
+	r.Delete("/controllers/{id}/effect", func(req *api.Request) (interface{}, error) {
+		v, err := Get(req.Param("id"))
+		if err != nil {
+			return nil, api.NotFound(err)
+		}
+		effectID := v.EffectID()
+		if effectID == "" {
+			return nil, api.NotFound(errors.New("controller has no effect"))
+		}
+		if err = DisconnectEffect(effectID, v.ID); err != nil {
+			return nil, api.BadRequest(err)
+		}
+		return nil, nil
+	}).Doc("Controllers", "Disconnect a controller's effect")
+
+	r.Put("/controllers/{id}/devices/{device_id}", func(req *api.Request) (interface{}, error) {
+		if _, err := Get(req.Param("id")); err != nil {
+			return nil, api.NotFound(err)
+		}
+		if _, err := device.Get(req.Param("device_id")); err != nil {
+			return nil, api.NotFound(err)
+		}
+		if err := ConnectDevice(req.Param("device_id"), req.Param("id")); err != nil {
+			return nil, api.BadRequest(err)
+		}
+		return nil, nil
+	}).Doc("Controllers", "Connect a device to a controller").Status(http.StatusNoContent)
+
+	r.Delete("/controllers/{id}/devices/{device_id}", func(req *api.Request) (interface{}, error) {
+		if _, err := Get(req.Param("id")); err != nil {
+			return nil, api.NotFound(err)
+		}
+		if err := DisconnectDevice(req.Param("device_id"), req.Param("id")); err != nil {
+			return nil, api.NotFound(err)
+		}
+		return nil, nil
+	}).Doc("Controllers", "Disconnect a device from a controller")
+}
@@ -0,0 +1,36 @@
+package controller
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/LedFx/ledfx/pkg/api"
+	"github.com/LedFx/ledfx/pkg/api/apitest"
+	"github.com/LedFx/ledfx/pkg/config"
+	"github.com/LedFx/ledfx/pkg/effect"
+)
+
+func TestV1API(t *testing.T) {
+	defer config.DisableSaving()()
+	r := api.NewRouter("/api/v1")
+	NewV1API(r)
+	if _, _, err := effect.New("v1_fx", "energy", 10, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer effect.Destroy("v1_fx")
+
+	apitest.Run(t, r, []apitest.Case{
+		{Method: http.MethodPost, Path: "/api/v1/controllers", Body: `{"id": "v1_c", "base_config": {"name": "V1"}}`, Code: http.StatusCreated},
+		{Method: http.MethodGet, Path: "/api/v1/controllers/v1_c", Code: http.StatusOK},
+		{Method: http.MethodGet, Path: "/api/v1/controllers/nope", Code: http.StatusNotFound},
+		{Method: http.MethodPut, Path: "/api/v1/controllers/v1_c/effect", Body: `{"effect_id": "v1_fx"}`, Code: http.StatusOK},
+		{Method: http.MethodPut, Path: "/api/v1/controllers/v1_c/effect", Body: `{"effect_id": "nope"}`, Code: http.StatusBadRequest},
+		{Method: http.MethodPut, Path: "/api/v1/controllers/nope/effect", Body: `{"effect_id": "v1_fx"}`, Code: http.StatusNotFound},
+		{Method: http.MethodPut, Path: "/api/v1/controllers/v1_c/devices/nope", Code: http.StatusNotFound},
+		{Method: http.MethodDelete, Path: "/api/v1/controllers/v1_c/effect", Code: http.StatusNoContent},
+		{Method: http.MethodDelete, Path: "/api/v1/controllers/v1_c/effect", Code: http.StatusNotFound},
+		{Method: http.MethodDelete, Path: "/api/v1/controllers/v1_c", Code: http.StatusNoContent},
+		{Method: http.MethodDelete, Path: "/api/v1/controllers/v1_c", Code: http.StatusNotFound},
+	})
+	apitest.Documents(t, r, "/api/v1", []string{"/controllers/{id}", "/controllers/{id}/effect"}, []string{"Controller", "ControllerConfig"})
+}
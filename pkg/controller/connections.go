@@ -2,6 +2,7 @@ package controller
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/LedFx/ledfx/pkg/config"
 	"github.com/LedFx/ledfx/pkg/device"
@@ -10,6 +11,9 @@ import (
 	"github.com/LedFx/ledfx/pkg/logger"
 )
 
+// guards the connections. held for the whole of a connection change, so changes don't interleave
+var connMu sync.Mutex
+
 // links effect IDs to controller IDs
 var connectionsEffect = map[string]string{}
 
@@ -21,21 +25,19 @@ func LoadConnectionsFromConfig() {
 	for eID, vID := range effects {
 		err := ConnectEffect(eID, vID)
 		if err != nil {
-			logger.Logger.WithField("context", "Controller Connections").Fatal(err)
+			config.QuarantineConnection(config.Effect, eID, err)
 		}
 	}
 	for dID, vID := range devices {
 		err := ConnectDevice(dID, vID)
 		if err != nil {
-			logger.Logger.WithField("context", "Controller Connections").Fatal(err)
+			config.QuarantineConnection(config.Device, dID, err)
 		}
 	}
 	// invoke event
-	event.Invoke(event.ConnectionsUpdate,
-		map[string]interface{}{
-			"effects": connectionsEffect,
-			"devices": connectionsDevice,
-		})
+	connMu.Lock()
+	invokeConnectionsUpdate()
+	connMu.Unlock()
 }
 
 func ConnectEffect(effectID, controllerID string) error {
@@ -49,10 +51,17 @@ func ConnectEffect(effectID, controllerID string) error {
 	if err != nil {
 		return err
 	}
+	connMu.Lock()
+	defer connMu.Unlock()
+	// an idle effect would replace the new effect when audio returns
+	v.wake()
+	v.mu.Lock()
 	// if already connected, don't continue
 	if v.Effect != nil && v.Effect.ID == effectID {
+		v.mu.Unlock()
 		return nil
 	}
+	v.mu.Unlock()
 
 	for eID, vID := range connectionsEffect {
 		// -> controller can only have one effect.
@@ -61,23 +70,25 @@ func ConnectEffect(effectID, controllerID string) error {
 		// if it's already assigned to a controller, disconnect it first
 		if eID == effectID || vID == controllerID {
 			delete(connectionsEffect, eID)
-			otherv, _ := Get(vID)
-			otherv.Effect = nil
+			if otherv, err := Get(vID); err == nil {
+				otherv.wake()
+				otherv.mu.Lock()
+				otherv.Effect = nil
+				otherv.mu.Unlock()
+			}
 		}
 	}
 	connectionsEffect[effectID] = controllerID
+	v.mu.Lock()
 	v.Effect = e
 	// if the controller has a device, initialise the effect with the pixel count
 	if len(v.Devices) != 0 {
-		v.Effect.UpdatePixelCount(v.PixelCount())
+		v.Effect.UpdatePixelCount(v.pixelCount())
 	}
+	v.mu.Unlock()
 	config.SetConnections(connectionsEffect, connectionsDevice)
 	// invoke event
-	event.Invoke(event.ConnectionsUpdate,
-		map[string]interface{}{
-			"effects": connectionsEffect,
-			"devices": connectionsDevice,
-		})
+	invokeConnectionsUpdate()
 	logger.Logger.WithField("context", "Controllers").Infof("Connected %s to %s", effectID, controllerID)
 	return nil
 }
@@ -93,28 +104,24 @@ func ConnectDevice(deviceID, controllerID string) error {
 	if err != nil {
 		return err
 	}
+	connMu.Lock()
+	defer connMu.Unlock()
+	v.mu.Lock()
 	// if already connected, don't continue
-	for _, d := range v.Devices {
-		if d.ID == deviceID {
-			return nil
-		}
+	if _, connected := v.Devices[deviceID]; connected {
+		v.mu.Unlock()
+		return nil
 	}
 	connectionsDevice[deviceID] = controllerID
 	v.Devices[dev.ID] = dev
 	if dev.State != device.Connected {
 		err = dev.Connect()
 	}
-	// if the controller has an effect, initialise it with the pixel count
-	if v.Effect != nil {
-		v.Effect.UpdatePixelCount(v.PixelCount())
-	}
+	v.devicesChanged()
+	v.mu.Unlock()
 	config.SetConnections(connectionsEffect, connectionsDevice)
 	// invoke event
-	event.Invoke(event.ConnectionsUpdate,
-		map[string]interface{}{
-			"effects": connectionsEffect,
-			"devices": connectionsDevice,
-		})
+	invokeConnectionsUpdate()
 	logger.Logger.WithField("context", "Controllers").Infof("Connected %s to %s", deviceID, controllerID)
 	return err
 }
@@ -125,25 +132,29 @@ func DisconnectEffect(effectID, controllerID string) error {
 	if err != nil {
 		return err
 	}
+	connMu.Lock()
+	defer connMu.Unlock()
 	vID, connected := connectionsEffect[effectID]
 	if !connected || controllerID != vID {
 		err = fmt.Errorf("effect %s and controller %s are not connected", effectID, controllerID)
 		return err
 	}
 	v, _ := Get(vID)
-	if v.Effect == nil {
+	v.wake()
+	v.mu.Lock()
+	hasEffect := v.Effect != nil
+	v.mu.Unlock()
+	if !hasEffect {
 		return nil
 	}
 	delete(connectionsEffect, effectID)
 	v.Stop()
+	v.mu.Lock()
 	v.Effect = nil
+	v.mu.Unlock()
 	config.SetConnections(connectionsEffect, connectionsDevice)
 	// invoke event
-	event.Invoke(event.ConnectionsUpdate,
-		map[string]interface{}{
-			"effects": connectionsEffect,
-			"devices": connectionsDevice,
-		})
+	invokeConnectionsUpdate()
 	logger.Logger.WithField("context", "Controllers").Infof("Disconnected %s from %s", effectID, controllerID)
 	return err
 }
@@ -154,6 +165,8 @@ func DisconnectDevice(deviceID, controllerID string) error {
 	if err != nil {
 		return err
 	}
+	connMu.Lock()
+	defer connMu.Unlock()
 	// delete it from connections
 	vID, connected := connectionsDevice[deviceID]
 	if !connected || controllerID != vID {
@@ -163,24 +176,88 @@ func DisconnectDevice(deviceID, controllerID string) error {
 	delete(connectionsDevice, deviceID)
 	// delete it from controller
 	v, _ := Get(vID)
+	v.mu.Lock()
 	d, exists := v.Devices[deviceID]
 	if !exists {
+		v.mu.Unlock()
 		return nil
 	}
 	if d.State == device.Connected {
 		err = d.Disconnect()
 	}
 	delete(v.Devices, deviceID)
-	if len(v.Devices) == 0 {
+	v.devicesChanged()
+	empty := len(v.Devices) == 0
+	v.mu.Unlock()
+	if empty {
 		v.Stop()
 	}
 	config.SetConnections(connectionsEffect, connectionsDevice)
 	// invoke event
-	event.Invoke(event.ConnectionsUpdate,
-		map[string]interface{}{
-			"effects": connectionsEffect,
-			"devices": connectionsDevice,
-		})
+	invokeConnectionsUpdate()
 	logger.Logger.WithField("context", "Controllers").Infof("Disconnected %s from %s", deviceID, controllerID)
 	return err
 }
+
+/*
+Replaces a device with a new one made from the given config, keeping its connection.
+The controller holds the old device, so it's disconnected, and the new one connected in its place.
+A controller left with no devices stops, so it's started again if it was running.
+*/
+func ReplaceDevice(id, deviceType string, baseConfig, implConfig map[string]interface{}) error {
+	_, devices := GetConnections()
+	controllerID, connected := devices[id]
+	var v *Controller
+	wasActive := false
+	if connected {
+		var err error
+		if v, err = Get(controllerID); err != nil {
+			connected = false
+		} else {
+			wasActive = v.active()
+			DisconnectDevice(id, controllerID)
+		}
+	}
+	if _, _, err := device.New(id, deviceType, baseConfig, implConfig); err != nil {
+		return err
+	}
+	if !connected {
+		return nil
+	}
+	if err := ConnectDevice(id, controllerID); err != nil {
+		return err
+	}
+	if wasActive {
+		return v.Start()
+	}
+	return nil
+}
+
+// Get copies of the effect and device connections to controllers
+func GetConnections() (effects, devices map[string]string) {
+	connMu.Lock()
+	defer connMu.Unlock()
+	return copyConnections()
+}
+
+// caller must hold the lock
+func copyConnections() (effects, devices map[string]string) {
+	effects = make(map[string]string, len(connectionsEffect))
+	for eID, vID := range connectionsEffect {
+		effects[eID] = vID
+	}
+	devices = make(map[string]string, len(connectionsDevice))
+	for dID, vID := range connectionsDevice {
+		devices[dID] = vID
+	}
+	return effects, devices
+}
+
+// events are delivered asynchronously, so subscribers get a copy of the connections. caller must hold the lock
+func invokeConnectionsUpdate() {
+	effects, devices := copyConnections()
+	event.Invoke(event.ConnectionsUpdateData{
+		Effects: effects,
+		Devices: devices,
+	})
+}
@@ -1,6 +1,8 @@
 package controller
 
 import (
+	"fmt"
+	"sync"
 	"time"
 
 	"github.com/LedFx/ledfx/pkg/config"
@@ -9,12 +11,10 @@ import (
 	"github.com/LedFx/ledfx/pkg/event"
 	"github.com/LedFx/ledfx/pkg/logger"
 	"github.com/LedFx/ledfx/pkg/render"
-
-	"github.com/creasty/defaults"
-	"github.com/mitchellh/mapstructure"
 )
 
 type Controller struct {
+	mu      sync.Mutex // guards the effect, devices, state and pixels, which the render loop uses every frame
 	ID      string
 	Effect  *effect.Effect
 	Devices map[string]*device.Device
@@ -23,17 +23,16 @@ type Controller struct {
 	ticker  *time.Ticker
 	done    chan bool
 	pixels  *render.PixelGroup
+	// brightness multiplier applied on top of the effect, eg. to dim during silence
+	brightness float64
+	idle       *idleState
+	previews   map[*Preview]struct{}
 }
 
 func (v *Controller) Initialize(id string, c map[string]interface{}) (err error) {
 	v.ID = id
-	v.State = false
-	defaults.Set(&v.Config)
-	err = mapstructure.Decode(c, &v.Config)
-	if err != nil {
-		return err
-	}
-	err = validate.Struct(&v.Config)
+	v.brightness = 1
+	v.Config, err = decodeConfig(c)
 	if err != nil {
 		return err
 	}
@@ -50,17 +49,30 @@ func (v *Controller) Initialize(id string, c map[string]interface{}) (err error)
 		return err
 	}
 	// invoke event
-	event.Invoke(event.ControllerUpdate,
-		map[string]interface{}{
-			"id":          v.ID,
-			"base_config": c,
-			"active":      v.State,
-		})
+	event.Invoke(event.ControllerUpdateData{
+		ID:         v.ID,
+		BaseConfig: c,
+		Active:     v.State,
+	})
 	return err
 }
 
+// whether the controller is running
+func (v *Controller) active() bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.State
+}
+
 // gets the sum of device pixel counts
 func (v *Controller) PixelCount() int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.pixelCount()
+}
+
+// caller must hold the lock
+func (v *Controller) pixelCount() int {
 	pc := 0
 	for _, d := range v.Devices {
 		pc += d.Config.PixelCount
@@ -68,32 +80,150 @@ func (v *Controller) PixelCount() int {
 	return pc
 }
 
-func (v *Controller) renderLoop() {
+func (v *Controller) renderLoop(ticker *time.Ticker, done chan bool) {
 	for {
 		select {
-		case <-v.ticker.C:
-			if v.Effect == nil {
-				return
-			}
-			v.Effect.Render(v.pixels) // todo catch errors in send?
-			for _, d := range v.Devices {
-				d.Send(v.pixels.Group[d.ID])
-			}
-			// if err != nil {
-			// 	logger.Logger.WithField("context", "Controller").Error(err)
-			// }
-		case <-v.done:
+		case <-ticker.C:
+			v.renderFrame(done)
+		case <-done:
 			return
 		}
 	}
 }
 
+func (v *Controller) renderFrame(done chan bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	select {
+	case <-done:
+		// stopped while waiting for the lock
+		return
+	default:
+	}
+	// the effect can be disconnected while running, eg. when it's connected to another controller
+	if v.Effect == nil {
+		return
+	}
+	v.Effect.Render(v.pixels) // todo catch errors in send?
+	v.applyBrightness()
+	v.sendPreviews()
+	for _, d := range v.Devices {
+		d.Send(v.pixels.Group[d.ID])
+	}
+	// if err != nil {
+	// 	logger.Logger.WithField("context", "Controller").Error(err)
+	// }
+}
+
+// rebuilds the pixel group after devices are connected or disconnected. caller must hold the lock
+func (v *Controller) devicesChanged() {
+	pixels, err := render.NewPixelGroup(v.Devices, []string{})
+	if err != nil {
+		logger.Logger.WithField("context", "Controller").Errorf("failed to update the pixels of %s: %s", v.ID, err)
+		return
+	}
+	v.pixels = pixels
+	// if the controller has an effect, initialise it with the pixel count
+	if v.Effect != nil && len(v.Devices) != 0 {
+		v.Effect.UpdatePixelCount(v.pixelCount())
+	}
+}
+
+func (v *Controller) applyBrightness() {
+	brightness := v.Config.Brightness * v.brightness
+	if brightness == 1 {
+		return
+	}
+	for _, p := range v.pixels.Group {
+		for i := range p {
+			p[i][0] *= brightness
+			p[i][1] *= brightness
+			p[i][2] *= brightness
+		}
+	}
+}
+
+// Sets the brightness of the controller from 0 to 1, and saves it to the controller's config
+func (v *Controller) SetBrightness(brightness float64) error {
+	if brightness < 0 || brightness > 1 {
+		return fmt.Errorf("brightness %v is not between 0 and 1", brightness)
+	}
+	entry, err := config.GetController(v.ID)
+	if err != nil {
+		return err
+	}
+	c := map[string]interface{}{}
+	for key, val := range entry.Config {
+		c[key] = val
+	}
+	c["brightness"] = brightness
+	v.mu.Lock()
+	v.Config.Brightness = brightness
+	v.mu.Unlock()
+	err = config.AddEntry(
+		v.ID,
+		config.ControllerEntry{
+			ID:     v.ID,
+			Config: c,
+		},
+	)
+	if err != nil {
+		return err
+	}
+	// invoke event
+	event.Invoke(event.ControllerUpdateData{
+		ID:         v.ID,
+		BaseConfig: c,
+		Active:     v.State,
+	})
+	return nil
+}
+
+// Replaces the config of the controller and saves it, keeping its connections
+func (v *Controller) UpdateConfig(c map[string]interface{}) error {
+	newConfig, err := decodeConfig(c)
+	if err != nil {
+		return err
+	}
+	v.mu.Lock()
+	framerateChanged := newConfig.FrameRate != v.Config.FrameRate
+	v.Config = newConfig
+	if v.State && framerateChanged {
+		v.ticker.Reset(time.Duration(1000/v.Config.FrameRate) * time.Millisecond)
+	}
+	v.mu.Unlock()
+	err = config.AddEntry(
+		v.ID,
+		config.ControllerEntry{
+			ID:     v.ID,
+			Config: c,
+		},
+	)
+	if err != nil {
+		return err
+	}
+	// invoke event
+	event.Invoke(event.ControllerUpdateData{
+		ID:         v.ID,
+		BaseConfig: c,
+		Active:     v.State,
+	})
+	return nil
+}
+
 func (v *Controller) Start() error {
+	v.mu.Lock()
+	if v.State {
+		v.mu.Unlock()
+		return nil
+	}
 	if v.Effect == nil {
+		v.mu.Unlock()
 		logger.Logger.WithField("context", "Controller").Warnf("cannot start %s, it does not have an effect", v.ID)
 		return nil
 	}
 	if len(v.Devices) == 0 {
+		v.mu.Unlock()
 		logger.Logger.WithField("context", "Controller").Warnf("cannot start %s, it does not have any devices", v.ID)
 		return nil
 	}
@@ -109,38 +239,41 @@ func (v *Controller) Start() error {
 	}
 	v.ticker = time.NewTicker(time.Duration(1000/v.Config.FrameRate) * time.Millisecond)
 	v.done = make(chan bool)
-	go v.renderLoop()
+	go v.renderLoop(v.ticker, v.done)
 	v.State = true
+	v.mu.Unlock()
 	logger.Logger.WithField("context", "Controllers").Infof("Activated %s", v.ID)
 	// invoke event
 	entry, _ := config.GetController(v.ID)
-	event.Invoke(event.ControllerUpdate,
-		map[string]interface{}{
-			"id":          v.ID,
-			"base_config": entry.Config,
-			"active":      v.State,
-		})
+	event.Invoke(event.ControllerUpdateData{
+		ID:         v.ID,
+		BaseConfig: entry.Config,
+		Active:     v.State,
+	})
 	return nil
 }
 
 func (v *Controller) Stop() {
+	v.mu.Lock()
 	if v.ticker != nil {
 		v.ticker.Stop()
 	}
+	// the render loop may be waiting for the lock, so it's told to stop rather than waited for
 	if v.done != nil {
-		v.done <- true
+		close(v.done)
+		v.done = nil
 	}
 	v.State = false
 	for _, d := range v.Devices {
 		d.Disconnect()
 	}
+	v.mu.Unlock()
 	logger.Logger.WithField("context", "Controllers").Infof("Deactivated %s", v.ID)
 	// invoke event
 	entry, _ := config.GetController(v.ID)
-	event.Invoke(event.ControllerUpdate,
-		map[string]interface{}{
-			"id":          v.ID,
-			"base_config": entry.Config,
-			"active":      v.State,
-		})
+	event.Invoke(event.ControllerUpdateData{
+		ID:         v.ID,
+		BaseConfig: entry.Config,
+		Active:     v.State,
+	})
 }
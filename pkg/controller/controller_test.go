@@ -2,15 +2,14 @@ package controller
 
 import (
 	"fmt"
-	"log"
 	"testing"
 	"time"
 
 	"github.com/LedFx/ledfx/pkg/audio"
 	"github.com/LedFx/ledfx/pkg/audio/audiobridge"
-	"github.com/LedFx/ledfx/pkg/color"
 	"github.com/LedFx/ledfx/pkg/device"
 	"github.com/LedFx/ledfx/pkg/effect"
+	"github.com/LedFx/ledfx/pkg/render"
 )
 
 func TestController(t *testing.T) {
@@ -25,9 +24,9 @@ func TestController(t *testing.T) {
 		"protocol": "DRGB",
 		"timeout":  60,
 	}
-	d, _, err := device.New("", "udp", bdc, udpc)
+	d, _, err := device.New("", "udp_stream", bdc, udpc)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 	err = d.Connect()
 	if err != nil {
@@ -51,16 +50,19 @@ func TestController(t *testing.T) {
 		t.Error(err)
 	}
 
-	p := make(color.Pixels, bdc["pixel_count"].(int))
+	p, err := render.NewPixelGroup(map[string]*device.Device{d.ID: d}, []string{d.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	br, err := audiobridge.NewBridge(audio.Analyzer.BufferCallback)
 	if err != nil {
-		log.Fatalf("Error initializing new bridge: %v\n", err)
+		t.Skipf("Error initializing new bridge: %v", err)
 	}
 	defer br.Stop()
 
 	if err := br.StartLocalInput("9f012a5ef29af5e7b226bae734a8cb2ad229f063"); err != nil { // get from config
-		log.Fatalf("Error starting local input: %v\n", err)
+		t.Skipf("Error starting local input: %v", err)
 	}
 
 	ticker := time.NewTicker(16 * time.Millisecond)
@@ -76,7 +78,7 @@ func TestController(t *testing.T) {
 		select {
 		case <-ticker.C:
 			e.Render(p)
-			err = d.Send(p)
+			err = d.Send(p.Group[d.ID])
 			if err != nil {
 				t.Error(err)
 			}
@@ -98,9 +100,9 @@ func BenchmarkController(t *testing.B) {
 		"protocol": "DRGB",
 		"timeout":  60,
 	}
-	d, _, err := device.New("", "udp", bdc, udpc)
+	d, _, err := device.New("", "udp_stream", bdc, udpc)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 	err = d.Connect()
 	if err != nil {
@@ -124,12 +126,15 @@ func BenchmarkController(t *testing.B) {
 		t.Error(err)
 	}
 
-	p := make(color.Pixels, bdc["pixel_count"].(int))
+	p, err := render.NewPixelGroup(map[string]*device.Device{d.ID: d}, []string{d.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	t.Run(fmt.Sprintf("%d pixels", bdc["pixel_count"].(int)), func(t *testing.B) {
 		for i := 0; i < t.N; i++ {
 			e.Render(p)
-			err = d.Send(p)
+			err = d.Send(p.Group[d.ID])
 			if err != nil {
 				t.Error(err)
 			}
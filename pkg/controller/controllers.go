@@ -4,14 +4,15 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
-	"strconv"
 
 	"github.com/LedFx/ledfx/pkg/config"
 	"github.com/LedFx/ledfx/pkg/event"
 	"github.com/LedFx/ledfx/pkg/logger"
 	"github.com/LedFx/ledfx/pkg/util"
 
+	"github.com/creasty/defaults"
 	"github.com/go-playground/validator/v10"
+	"github.com/mitchellh/mapstructure"
 )
 
 // Creates a new controller and returns its unique id
@@ -21,19 +22,12 @@ func New(new_id string, config map[string]interface{}) (controller *Controller,
 	// if the id exists and has already been registered, overwrite the existing controller with that id
 	if new_id != "" {
 		id = new_id
-		if _, exists := controllerInstances[new_id]; exists {
+		if controllerInstances.Has(new_id) {
 			Destroy(id)
 		}
-		controllerInstances[id] = controller
+		controllerInstances.Set(id, controller)
 	} else { // otherwise, generate a new id
-		for i := 0; ; i++ {
-			id = "controller" + strconv.Itoa(i)
-			_, exists := controllerInstances[id]
-			if !exists {
-				controllerInstances[id] = controller
-				break
-			}
-		}
+		id = controllerInstances.Add("controller", controller)
 	}
 	logger.Logger.WithField("context", "Controllers").Debugf("Creating controller with id %s", id)
 
@@ -45,13 +39,17 @@ func New(new_id string, config map[string]interface{}) (controller *Controller,
 	return controller, id, err
 }
 
-var controllerInstances = make(map[string]*Controller)
+var controllerInstances = util.NewRegistry[*Controller]()
 
 var validate *validator.Validate = validator.New()
 
+func init() {
+	config.RegisterValidator(config.Controller, validateEntry)
+}
+
 // Get an existing controller instance by its unique id
 func Get(id string) (*Controller, error) {
-	if inst, exists := controllerInstances[id]; exists {
+	if inst, exists := controllerInstances.Get(id); exists {
 		return inst, nil
 	} else {
 		return inst, fmt.Errorf("cannot retrieve controller of id: %s", id)
@@ -60,38 +58,43 @@ func Get(id string) (*Controller, error) {
 
 // Kill a controller instance
 func Destroy(id string) {
-	v, ok := controllerInstances[id]
+	v, ok := controllerInstances.Get(id)
 	if !ok {
 		logger.Logger.WithField("context", "Controllers").Warnf("Cannot delete %s, it doesn't exist", id)
 		return
 	}
-	if v.State {
+	if v.active() {
 		v.Stop()
 	}
 	// remove it from saved states
 	config.SetStates(GetStates())
 	// disconnect any effects and devices
-	if v.Effect != nil {
-		DisconnectEffect(v.Effect.ID, v.ID)
+	if effectID := v.EffectID(); effectID != "" {
+		DisconnectEffect(effectID, v.ID)
 	}
+	v.mu.Lock()
+	deviceIDs := make([]string, 0, len(v.Devices))
 	for id := range v.Devices {
-		DisconnectDevice(id, v.ID)
+		deviceIDs = append(deviceIDs, id)
+	}
+	v.mu.Unlock()
+	for _, deviceID := range deviceIDs {
+		DisconnectDevice(deviceID, v.ID)
 	}
 	// remove it from config
 	config.DeleteEntry(config.Controller, id)
-	delete(controllerInstances, id)
+	controllerInstances.Delete(id)
 	logger.Logger.WithField("context", "Controllers").Infof("Deleted %s", id)
-	event.Invoke(event.ControllerDelete,
-		map[string]interface{}{
-			"id": id,
-		})
+	event.Invoke(event.ControllerDeleteData{
+		ID: id,
+	})
 }
 
 // get activity status of all controllers
 func GetStates() map[string]bool {
 	states := make(map[string]bool)
-	for _, v := range controllerInstances {
-		states[v.ID] = v.State
+	for id, v := range controllerInstances.Snapshot() {
+		states[id] = v.active()
 	}
 	return states
 }
@@ -99,12 +102,13 @@ func GetStates() map[string]bool {
 // set activity status of all controllers
 func SetStates(states map[string]bool) (err error) {
 	msg := ""
-	for _, v := range controllerInstances {
+	for _, v := range controllerInstances.Values() {
 		state, ok := states[v.ID]
 		if !ok {
 			continue
 		}
-		if v.State == state {
+		v.forgetIdleStop()
+		if v.active() == state {
 			continue
 		}
 		if state {
@@ -124,24 +128,44 @@ func SetStates(states map[string]bool) (err error) {
 }
 
 func GetIDs() []string {
-	ids := []string{}
-	for id := range controllerInstances {
-		ids = append(ids, id)
-	}
-	return ids
+	return controllerInstances.IDs()
 }
 
+/*
+Loads the controllers saved in config. Controllers which fail to load
+are quarantined, and the rest carry on loading.
+*/
 func LoadFromConfig() error {
 	storedControllers := config.GetControllers()
 	for id, entry := range storedControllers {
 		_, _, err := New(id, entry.Config)
 		if err != nil {
-			return err
+			config.QuarantineEntry(config.Controller, id, entry, err)
 		}
 	}
 	return nil
 }
 
+// Checks a saved controller entry would load
+func validateEntry(entry interface{}) error {
+	c, ok := entry.(config.ControllerEntry)
+	if !ok {
+		return fmt.Errorf("invalid controller entry type: %T", entry)
+	}
+	_, err := decodeConfig(c.Config)
+	return err
+}
+
+// decodes and validates a controller config on top of the defaults
+func decodeConfig(c map[string]interface{}) (vc config.ControllerConfig, err error) {
+	defaults.Set(&vc)
+	if err = mapstructure.Decode(c, &vc); err != nil {
+		return vc, err
+	}
+	err = validate.Struct(&vc)
+	return vc, err
+}
+
 func LoadStatesFromConfig() {
 	SetStates(config.GetStates())
 }
@@ -0,0 +1,137 @@
+package controller
+
+import (
+	"github.com/LedFx/ledfx/pkg/audio"
+	"github.com/LedFx/ledfx/pkg/config"
+	"github.com/LedFx/ledfx/pkg/effect"
+	"github.com/LedFx/ledfx/pkg/event"
+	"github.com/LedFx/ledfx/pkg/logger"
+)
+
+// What a controller did when its audio went silent, so it can be undone when audio returns
+type idleState struct {
+	source string         // audio source which went silent
+	action string         // silence config action which was applied
+	effect *effect.Effect // the controller's own effect, while the idle effect is shown
+}
+
+func init() {
+	// one subscriber for both, so the end of a silence is never handled before its start
+	event.SubscribeTypes([]event.EventType{event.SilenceStart, event.SilenceEnd}, func(e *event.Event) {
+		switch data := e.Data.(type) {
+		case event.SilenceStartData:
+			enterIdle(data.Source)
+		case event.SilenceEndData:
+			exitIdle(data.Source)
+		}
+	}, event.Options{})
+}
+
+// Applies the silence action to active controllers whose effect reacts to the silent source
+func enterIdle(source string) {
+	c := config.GetSilence()
+	if c.Action == "none" {
+		return
+	}
+	for _, v := range controllerInstances.Values() {
+		v.mu.Lock()
+		if !v.State || v.Effect == nil || v.idle != nil || audio.GetAnalyzer(v.Effect.GetConfig().Source).ID != source {
+			v.mu.Unlock()
+			continue
+		}
+		idle := &idleState{source: source, action: c.Action}
+		switch c.Action {
+		case "dim":
+			v.brightness = c.DimBrightness
+		case "effect":
+			e, err := effect.NewTransient("idle_"+v.ID, c.IdleEffect, v.pixelCount(), nil)
+			if err != nil {
+				v.mu.Unlock()
+				logger.Logger.WithField("context", "Controller Idle").Errorf("Cannot show idle effect on %s: %v", v.ID, err)
+				continue
+			}
+			idle.effect = v.Effect
+			v.Effect = e
+		}
+		v.idle = idle
+		v.mu.Unlock()
+		// stopping takes the lock itself
+		if c.Action == "off" {
+			v.Stop()
+		}
+		logger.Logger.WithField("context", "Controller Idle").Infof("Silence on %s: %s applied to %s", source, c.Action, v.ID)
+	}
+}
+
+// Restores controllers which went idle on the source
+func exitIdle(source string) {
+	for _, v := range controllerInstances.Values() {
+		v.mu.Lock()
+		idle := v.idle
+		v.mu.Unlock()
+		if idle != nil && idle.source == source {
+			v.wake()
+		}
+	}
+}
+
+// Undoes the silence action on the controller, if any
+func (v *Controller) wake() {
+	v.mu.Lock()
+	idle := v.idle
+	if idle == nil {
+		v.mu.Unlock()
+		return
+	}
+	v.idle = nil
+	switch idle.action {
+	case "dim":
+		v.brightness = 1
+	case "effect":
+		if v.Effect != nil {
+			v.Effect.Release()
+		}
+		v.Effect = idle.effect
+	}
+	v.mu.Unlock()
+	// starting takes the lock itself
+	if idle.action == "off" && !v.active() {
+		if err := v.Start(); err != nil {
+			logger.Logger.WithField("context", "Controller Idle").Errorf("Cannot restart %s: %v", v.ID, err)
+		}
+	}
+	logger.Logger.WithField("context", "Controller Idle").Infof("Audio returned: restored %s", v.ID)
+}
+
+/*
+Forgets that the silence action stopped the controller, so it isn't restarted when audio returns.
+For when the user chooses whether it runs, which overrides the silence action.
+*/
+func (v *Controller) forgetIdleStop() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.idle != nil && v.idle.action == "off" {
+		v.idle = nil
+	}
+}
+
+// Whether the controller is in its silence state
+func (v *Controller) Idle() bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.idle != nil
+}
+
+// ID of the effect connected to the controller, ignoring any idle effect shown during silence. Empty if none.
+func (v *Controller) EffectID() string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	e := v.Effect
+	if v.idle != nil && v.idle.action == "effect" {
+		e = v.idle.effect
+	}
+	if e == nil {
+		return ""
+	}
+	return e.ID
+}
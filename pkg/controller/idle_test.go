@@ -0,0 +1,61 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/LedFx/ledfx/pkg/audio"
+	"github.com/LedFx/ledfx/pkg/config"
+	"github.com/LedFx/ledfx/pkg/device"
+	"github.com/LedFx/ledfx/pkg/effect"
+)
+
+func TestIdleOff(t *testing.T) {
+	defer config.DisableSaving()()
+	if _, _, err := device.New("idle_d", "udp_stream", map[string]interface{}{"name": "idle_d", "pixel_count": 10}, map[string]interface{}{"ip": "127.0.0.1"}); err != nil {
+		t.Fatal(err)
+	}
+	defer device.Destroy("idle_d")
+	if _, _, err := effect.New("idle_fx", "energy", 10, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer effect.Destroy("idle_fx")
+	v, _, err := New("idle_c", map[string]interface{}{"name": "Idle"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Destroy("idle_c")
+	if err = ConnectEffect("idle_fx", "idle_c"); err != nil {
+		t.Fatal(err)
+	}
+	if err = ConnectDevice("idle_d", "idle_c"); err != nil {
+		t.Fatal(err)
+	}
+	if err = config.SetSilence(map[string]interface{}{"action": "off"}); err != nil {
+		t.Fatal(err)
+	}
+	defer config.SetSilence(map[string]interface{}{"action": "none"})
+	source := audio.GetAnalyzer(audio.DefaultSource).ID
+
+	// stopped by silence, so started again when audio returns
+	if err = SetStates(map[string]bool{"idle_c": true}); err != nil {
+		t.Fatal(err)
+	}
+	enterIdle(source)
+	if v.active() || !v.Idle() {
+		t.Fatal("Expected silence to stop the controller")
+	}
+	exitIdle(source)
+	if !v.active() || v.Idle() {
+		t.Error("Expected audio returning to restart the controller")
+	}
+
+	// stopped by the user during the silence, so left stopped
+	enterIdle(source)
+	if err = SetStates(map[string]bool{"idle_c": false}); err != nil {
+		t.Fatal(err)
+	}
+	exitIdle(source)
+	if v.active() || v.Idle() {
+		t.Error("Expected the user's stop to outlast the silence")
+	}
+}
@@ -0,0 +1,119 @@
+package controller
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/LedFx/ledfx/pkg/render"
+)
+
+/*
+Previews stream a controller's rendered frames to clients, eg. the frontend.
+Frames are encoded as uint8 RGB triplets, one per preview pixel, in the pixel group order.
+The render loop never waits on a preview: a frame the client hasn't taken yet is replaced by the next.
+*/
+
+// Limits for what clients can ask of a preview
+const (
+	PreviewMaxFps    = 60
+	PreviewMaxPixels = 1024
+)
+
+type Preview struct {
+	Frames   chan []byte // encoded frames. holds only the latest frame
+	pixels   int
+	interval time.Duration
+	lastSent time.Time
+}
+
+var previewMu sync.Mutex
+
+/*
+Adds a preview of the controller's frames.
+Frames are downsampled to at most the given number of pixels, and sent at most fps times per second.
+Call the returned func to stop the preview.
+*/
+func (v *Controller) AddPreview(pixels, fps int) (*Preview, func()) {
+	if pixels <= 0 || pixels > PreviewMaxPixels {
+		pixels = PreviewMaxPixels
+	}
+	if fps <= 0 || fps > PreviewMaxFps {
+		fps = PreviewMaxFps
+	}
+	p := &Preview{
+		Frames:   make(chan []byte, 1),
+		pixels:   pixels,
+		interval: time.Second / time.Duration(fps),
+	}
+	previewMu.Lock()
+	if v.previews == nil {
+		v.previews = map[*Preview]struct{}{}
+	}
+	v.previews[p] = struct{}{}
+	previewMu.Unlock()
+	return p, func() {
+		previewMu.Lock()
+		defer previewMu.Unlock()
+		delete(v.previews, p)
+	}
+}
+
+// Offers the latest frame to each preview that is due one
+func (v *Controller) sendPreviews() {
+	previewMu.Lock()
+	defer previewMu.Unlock()
+	if len(v.previews) == 0 {
+		return
+	}
+	now := time.Now()
+	for p := range v.previews {
+		if now.Sub(p.lastSent) < p.interval {
+			continue
+		}
+		// client is still busy with the last frame, so drop it for this one.
+		// frames are only sent here under the lock, so there's room after draining
+		select {
+		case <-p.Frames:
+		default:
+		}
+		p.Frames <- EncodePreview(v.pixels, p.pixels)
+		p.lastSent = now
+	}
+}
+
+// Downsamples a pixel group to at most the given number of pixels, as uint8 RGB
+func EncodePreview(pg *render.PixelGroup, pixels int) []byte {
+	total := 0
+	for _, id := range pg.Order {
+		total += len(pg.Group[id])
+	}
+	if pixels > total {
+		pixels = total
+	}
+	frame := make([]byte, pixels*3)
+	if pixels == 0 {
+		return frame
+	}
+	// average the pixels falling in each preview pixel
+	sums := make([][3]float64, pixels)
+	counts := make([]int, pixels)
+	i := 0
+	for _, id := range pg.Order {
+		for _, c := range pg.Group[id] {
+			j := i * pixels / total
+			sums[j][0] += c[0]
+			sums[j][1] += c[1]
+			sums[j][2] += c[2]
+			counts[j]++
+			i++
+		}
+	}
+	for j := range sums {
+		for k := 0; k < 3; k++ {
+			val := sums[j][k] / float64(counts[j])
+			frame[j*3+k] = uint8(math.Round(math.Max(0, math.Min(val, 1)) * 255))
+		}
+	}
+	return frame
+}
@@ -0,0 +1,32 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/LedFx/ledfx/pkg/color"
+	"github.com/LedFx/ledfx/pkg/render"
+)
+
+func TestPreviewLatestFrame(t *testing.T) {
+	v := &Controller{pixels: &render.PixelGroup{
+		Group: map[string]color.Pixels{"a": {{1, 0, 0}}},
+		Order: []string{"a"},
+	}}
+	p, remove := v.AddPreview(1, PreviewMaxFps)
+	defer remove()
+
+	// the client doesn't take the first frame before the second is rendered
+	v.sendPreviews()
+	v.pixels.Group["a"][0] = color.Color{0, 0, 1}
+	p.lastSent = time.Time{}
+	v.sendPreviews()
+	select {
+	case frame := <-p.Frames:
+		if expected := []byte{0, 0, 255}; string(frame) != string(expected) {
+			t.Errorf("Expected the latest frame %v but got %v", expected, frame)
+		}
+	default:
+		t.Fatal("Expected a frame")
+	}
+}
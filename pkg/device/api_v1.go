@@ -0,0 +1,116 @@
+package device
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/LedFx/ledfx/pkg/api"
+	"github.com/LedFx/ledfx/pkg/config"
+	"github.com/LedFx/ledfx/pkg/logger"
+)
+
+func NewV1API(r *api.Router) {
+	schema, err := Schema()
+	if err != nil {
+		logger.Logger.WithField("context", "Device API").Error(err)
+	}
+	deviceSchema := api.TypeSchema(config.DeviceEntry{})
+	if base, ok := schema["base"].(map[string]interface{}); ok {
+		r.Schema("DeviceConfig", api.ConfigSchema(base))
+		deviceSchema["properties"].(map[string]interface{})["base_config"] = api.Ref("DeviceConfig")
+	}
+	if impl, ok := schema["impl"].(map[string]interface{}); ok {
+		// the impl config depends on the device type
+		implSchemas := []interface{}{}
+		for _, t := range sortedTypes(impl) {
+			if s, ok := impl[t].(map[string]interface{}); ok {
+				implSchemas = append(implSchemas, api.ConfigSchema(s))
+			}
+		}
+		deviceSchema["properties"].(map[string]interface{})["impl_config"] = map[string]interface{}{"oneOf": implSchemas}
+	}
+	r.Schema("Device", deviceSchema)
+
+	r.Get("/devices", func(*api.Request) (interface{}, error) {
+		return config.GetDevices(), nil
+	}).Doc("Devices", "Get all devices, by id").Returns(api.MapOf(api.Ref("Device")))
+
+	r.Post("/devices", func(req *api.Request) (interface{}, error) {
+		data := config.DeviceEntry{}
+		if err := req.Decode(&data); err != nil {
+			return nil, err
+		}
+		_, id, err := New(data.ID, data.Type, data.BaseConfig, data.ImplConfig)
+		if err != nil {
+			return nil, api.BadRequest(err)
+		}
+		return config.GetDevice(id)
+	}).Doc("Devices", "Create a device. An id is made from the type if none is given").
+		Accepts(api.Ref("Device")).Returns(api.Ref("Device")).Status(http.StatusCreated)
+
+	r.Get("/devices/schema", func(*api.Request) (interface{}, error) {
+		return Schema()
+	}).Doc("Devices", "Get the schema of device configs, for building forms").Returns(map[string]interface{}{"type": "object"})
+
+	r.Get("/devices/state", func(*api.Request) (interface{}, error) {
+		return GetStates(), nil
+	}).Doc("Devices", "Get the state of all devices, by id").Returns(api.MapOf(map[string]interface{}{"type": "integer"}))
+
+	r.Get("/devices/{id}", func(req *api.Request) (interface{}, error) {
+		if _, err := Get(req.Param("id")); err != nil {
+			return nil, api.NotFound(err)
+		}
+		return config.GetDevice(req.Param("id"))
+	}).Doc("Devices", "Get a device").Returns(api.Ref("Device"))
+
+	r.Put("/devices/{id}", func(req *api.Request) (interface{}, error) {
+		if _, err := Get(req.Param("id")); err != nil {
+			return nil, api.NotFound(err)
+		}
+		c, err := config.GetDevice(req.Param("id"))
+		if err != nil {
+			return nil, err
+		}
+		data := config.DeviceEntry{}
+		if err = req.Decode(&data); err != nil {
+			return nil, err
+		}
+		// only the settings given are changed
+		if _, _, err = New(c.ID, c.Type, merge(c.BaseConfig, data.BaseConfig), merge(c.ImplConfig, data.ImplConfig)); err != nil {
+			return nil, api.BadRequest(err)
+		}
+		return config.GetDevice(c.ID)
+	}).Doc("Devices", "Update a device's config. Only the settings given are changed").
+		Accepts(api.Ref("Device")).Returns(api.Ref("Device"))
+
+	r.Delete("/devices/{id}", func(req *api.Request) (interface{}, error) {
+		if _, err := Get(req.Param("id")); err != nil {
+			return nil, api.NotFound(err)
+		}
+		Destroy(req.Param("id"))
+		return nil, nil
+	}).Doc("Devices", "Delete a device")
+}
+
+// a copy of the saved config with the given settings applied
+func merge(saved, given map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(saved)+len(given))
+	for k, v := range saved {
+		c[k] = v
+	}
+	for k, v := range given {
+		c[k] = v
+	}
+	return c
+}
+
+func sortedTypes(impl map[string]interface{}) []string {
+	types := make([]string, 0, len(impl))
+	for t := range deviceTypes {
+		if _, ok := impl[t]; ok {
+			types = append(types, t)
+		}
+	}
+	sort.Strings(types)
+	return types
+}
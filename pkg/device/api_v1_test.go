@@ -0,0 +1,28 @@
+package device
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/LedFx/ledfx/pkg/api"
+	"github.com/LedFx/ledfx/pkg/api/apitest"
+	"github.com/LedFx/ledfx/pkg/config"
+)
+
+func TestV1API(t *testing.T) {
+	defer config.DisableSaving()()
+	r := api.NewRouter("/api/v1")
+	NewV1API(r)
+
+	apitest.Run(t, r, []apitest.Case{
+		{Method: http.MethodPost, Path: "/api/v1/devices", Body: `{"id": "v1_d", "type": "udp_stream", "base_config": {"name": "V1", "pixel_count": 10}, "impl_config": {"ip": "127.0.0.1"}}`, Code: http.StatusCreated},
+		{Method: http.MethodPost, Path: "/api/v1/devices", Body: `{"type": "nope"}`, Code: http.StatusBadRequest},
+		{Method: http.MethodGet, Path: "/api/v1/devices/v1_d", Code: http.StatusOK},
+		{Method: http.MethodGet, Path: "/api/v1/devices/nope", Code: http.StatusNotFound},
+		{Method: http.MethodPut, Path: "/api/v1/devices/v1_d", Body: `{"base_config": {"pixel_count": 20}}`, Code: http.StatusOK},
+		{Method: http.MethodPut, Path: "/api/v1/devices/nope", Body: `{}`, Code: http.StatusNotFound},
+		{Method: http.MethodDelete, Path: "/api/v1/devices/v1_d", Code: http.StatusNoContent},
+		{Method: http.MethodDelete, Path: "/api/v1/devices/v1_d", Code: http.StatusNotFound},
+	})
+	apitest.Documents(t, r, "/api/v1", []string{"/devices/{id}"}, []string{"Device", "DeviceConfig"})
+}
@@ -31,17 +31,7 @@ type Device struct {
 
 func (d *Device) Initialize(id string, baseConfig map[string]interface{}, implConfig map[string]interface{}) (err error) {
 	d.ID = id
-	// set and validate base config
-	defaults.Set(&d.Config)
-	err = mapstructure.Decode(baseConfig, &d.Config)
-	if err != nil {
-		return err
-	}
-	err = validate.Struct(&d.Config)
-	if err != nil {
-		return err
-	}
-	err = d.pixelPusher.initialize(d, implConfig)
+	err = d.configure(baseConfig, implConfig)
 	if err != nil {
 		return err
 	}
@@ -60,16 +50,29 @@ func (d *Device) Initialize(id string, baseConfig map[string]interface{}, implCo
 		return err
 	}
 	// invoke event
-	event.Invoke(event.DeviceUpdate,
-		map[string]interface{}{
-			"id":          d.ID,
-			"base_config": base,
-			"impl_config": impl,
-			"state":       d.State,
-		})
+	event.Invoke(event.DeviceUpdateData{
+		ID:         d.ID,
+		BaseConfig: base,
+		ImplConfig: impl,
+		State:      int(d.State),
+	})
 	return err
 }
 
+// sets and validates the base and implementation config, without saving it
+func (d *Device) configure(baseConfig map[string]interface{}, implConfig map[string]interface{}) (err error) {
+	defaults.Set(&d.Config)
+	err = mapstructure.Decode(baseConfig, &d.Config)
+	if err != nil {
+		return err
+	}
+	err = validate.Struct(&d.Config)
+	if err != nil {
+		return err
+	}
+	return d.pixelPusher.initialize(d, implConfig)
+}
+
 func (d *Device) Connect() (err error) {
 	d.State = Connecting
 	err = d.pixelPusher.connect()
@@ -77,13 +80,12 @@ func (d *Device) Connect() (err error) {
 		d.State = Connected
 		// invoke event
 		base, impl := d.FullConfig()
-		event.Invoke(event.DeviceUpdate,
-			map[string]interface{}{
-				"id":          d.ID,
-				"base_config": base,
-				"impl_config": impl,
-				"state":       d.State,
-			})
+		event.Invoke(event.DeviceUpdateData{
+			ID:         d.ID,
+			BaseConfig: base,
+			ImplConfig: impl,
+			State:      int(d.State),
+		})
 	} else {
 		logger.Logger.WithField("context", "Device").Errorf("Device %s failed to connect: %s", d.ID, err.Error())
 	}
@@ -97,13 +99,12 @@ func (d *Device) Disconnect() (err error) {
 		d.State = Disconnected
 		// invoke event
 		base, impl := d.FullConfig()
-		event.Invoke(event.DeviceUpdate,
-			map[string]interface{}{
-				"id":          d.ID,
-				"base_config": base,
-				"impl_config": impl,
-				"state":       d.State,
-			})
+		event.Invoke(event.DeviceUpdateData{
+			ID:         d.ID,
+			BaseConfig: base,
+			ImplConfig: impl,
+			State:      int(d.State),
+		})
 	} else {
 		logger.Logger.WithField("context", "Device").Errorf("Device %s failed to disconnect: %s", d.ID, err.Error())
 	}
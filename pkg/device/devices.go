@@ -3,7 +3,6 @@ package device
 import (
 	"fmt"
 	"reflect"
-	"strconv"
 
 	"github.com/LedFx/ledfx/pkg/config"
 	"github.com/LedFx/ledfx/pkg/event"
@@ -44,6 +43,40 @@ var deviceTypes = map[string]deviceInfo{
 
 // Creates a new device and returns its unique id
 func New(new_id, device_type string, baseConfig map[string]interface{}, implConfig map[string]interface{}) (device *Device, id string, err error) {
+	device, err = newDevice(device_type)
+	if err != nil {
+		return device, id, err
+	}
+
+	// if an id is given, use it. if a device exists with that id, overwrite it
+	var prev_state State = Disconnected
+	if new_id != "" {
+		id = new_id
+		if old_d, exists := deviceInstances.Get(id); exists {
+			// save the state so we can restore it
+			prev_state = old_d.State
+			Destroy(id)
+		}
+		deviceInstances.Set(id, device)
+	} else { // otherwise, generate a new id
+		id = deviceInstances.Add(device_type, device)
+	}
+	logger.Logger.WithField("context", "Devices").Debugf("Creating %s device with id %s", device_type, id)
+
+	// initialise the new device with its id and config
+	if err = device.Initialize(id, baseConfig, implConfig); err != nil {
+		Destroy(id)
+	}
+	// restore its state
+	if prev_state == Connected || prev_state == Connecting {
+		go device.Connect()
+	}
+	logger.Logger.WithField("context", "Devices").Infof("Created device with id %s", id)
+	return device, id, err
+}
+
+// Creates a device of the given type, without an id or config
+func newDevice(device_type string) (device *Device, err error) {
 	switch device_type {
 	case "udp_stream":
 		device = &Device{
@@ -62,49 +95,23 @@ func New(new_id, device_type string, baseConfig map[string]interface{}, implConf
 			pixelPusher: &E131{},
 		}
 	default:
-		return device, id, fmt.Errorf("%s is not a known device type", device_type)
+		return device, fmt.Errorf("%s is not a known device type", device_type)
 	}
 	device.Type = device_type
-
-	// if the id exists and has already been registered, overwrite the existing device with that id
-	var prev_state State = Disconnected
-	if old_d, exists := deviceInstances[new_id]; exists && new_id != "" {
-		// save the state so we can restore it
-		prev_state = old_d.State
-		id = new_id
-		Destroy(id)
-		deviceInstances[id] = device
-	} else { // otherwise, generate a new id
-		for i := 0; ; i++ {
-			id = device_type + strconv.Itoa(i)
-			_, exists := deviceInstances[id]
-			if !exists {
-				deviceInstances[id] = device
-				break
-			}
-		}
-	}
-	logger.Logger.WithField("context", "Devices").Debugf("Creating %s device with id %s", device_type, id)
-
-	// initialise the new device with its id and config
-	if err = device.Initialize(id, baseConfig, implConfig); err != nil {
-		Destroy(id)
-	}
-	// restore its state
-	if prev_state == Connected || prev_state == Connecting {
-		go device.Connect()
-	}
-	logger.Logger.WithField("context", "Devices").Infof("Created device with id %s", id)
-	return device, id, err
+	return device, nil
 }
 
-var deviceInstances = make(map[string]*Device)
+var deviceInstances = util.NewRegistry[*Device]()
 
 var validate *validator.Validate = validator.New()
 
+func init() {
+	config.RegisterValidator(config.Device, validateEntry)
+}
+
 // Get an existing device instance by its unique id
 func Get(id string) (*Device, error) {
-	if inst, exists := deviceInstances[id]; exists {
+	if inst, exists := deviceInstances.Get(id); exists {
 		return inst, nil
 	} else {
 		return inst, fmt.Errorf("cannot retrieve device of id: %s", id)
@@ -113,46 +120,58 @@ func Get(id string) (*Device, error) {
 
 // Kill a device instance
 func Destroy(id string) {
-	if deviceInstances[id].State == Connected {
-		deviceInstances[id].Disconnect()
+	if d, exists := deviceInstances.Get(id); exists && d.State == Connected {
+		d.Disconnect()
 	}
 	config.DeleteEntry(config.Device, id)
-	delete(deviceInstances, id)
+	deviceInstances.Delete(id)
 	logger.Logger.WithField("context", "Devices").Infof("Deleted device with id %s", id)
 	// invoke event
-	event.Invoke(event.DeviceDelete,
-		map[string]interface{}{
-			"id": id,
-		})
+	event.Invoke(event.DeviceDeleteData{
+		ID: id,
+	})
 }
 
 func GetIDs() []string {
-	ids := []string{}
-	for id := range deviceInstances {
-		ids = append(ids, id)
-	}
-	return ids
+	return deviceInstances.IDs()
 }
 
 func GetStates() map[string]State {
 	states := map[string]State{}
-	for _, d := range deviceInstances {
-		states[d.ID] = d.State
+	for id, d := range deviceInstances.Snapshot() {
+		states[id] = d.State
 	}
 	return states
 }
 
+/*
+Loads the devices saved in config. Devices which fail to load
+are quarantined, and the rest carry on loading.
+*/
 func LoadFromConfig() error {
 	storedDevices := config.GetDevices()
 	for id, entry := range storedDevices {
 		_, _, err := New(id, entry.Type, entry.BaseConfig, entry.ImplConfig)
 		if err != nil {
-			return err
+			config.QuarantineEntry(config.Device, id, entry, err)
 		}
 	}
 	return nil
 }
 
+// Checks a saved device entry would load
+func validateEntry(entry interface{}) error {
+	c, ok := entry.(config.DeviceEntry)
+	if !ok {
+		return fmt.Errorf("invalid device entry type: %T", entry)
+	}
+	d, err := newDevice(c.Type)
+	if err != nil {
+		return err
+	}
+	return d.configure(c.BaseConfig, c.ImplConfig)
+}
+
 // Generate a map schema for all devices
 func Schema() (schema map[string]interface{}, err error) {
 	schema = make(map[string]interface{})
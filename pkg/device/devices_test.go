@@ -0,0 +1,51 @@
+package device
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/LedFx/ledfx/pkg/config"
+)
+
+// run with -race. the scanner, api and websocket use the devices from their own goroutines
+func TestDevicesConcurrency(t *testing.T) {
+	defer config.DisableSaving()()
+
+	impl := map[string]interface{}{"ip": "127.0.0.1"}
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(2)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				id := fmt.Sprintf("race_%d_%d", w, i%5)
+				if _, _, err := New(id, "udp_stream", map[string]interface{}{"name": id, "pixel_count": 10}, impl); err != nil {
+					t.Error(err)
+					return
+				}
+				if i%3 == 0 {
+					Destroy(id)
+				}
+			}
+		}(w)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				for _, id := range GetIDs() {
+					Get(id)
+				}
+				GetStates()
+				for range config.GetDevices() {
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	for _, id := range GetIDs() {
+		Destroy(id)
+	}
+	if ids := GetIDs(); len(ids) != 0 {
+		t.Errorf("Expected all devices to be destroyed, got %v", ids)
+	}
+}
@@ -152,7 +152,7 @@ func handleEntry(entry *zeroconf.ServiceEntry) error {
 		return err
 	}
 	// Try to avoid duplication matching IP to other devices
-	for id, d := range deviceInstances {
+	for id, d := range deviceInstances.Snapshot() {
 		switch pusher := d.pixelPusher.(type) {
 		case *UDP:
 			if pusher.config.IP == info.IP {
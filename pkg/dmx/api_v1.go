@@ -0,0 +1,51 @@
+package dmx
+
+import (
+	"github.com/LedFx/ledfx/pkg/api"
+	"github.com/LedFx/ledfx/pkg/config"
+	"github.com/LedFx/ledfx/pkg/logger"
+)
+
+type dmxInfo struct {
+	Config    config.DmxInputConfig `json:"config"`
+	Running   bool                  `json:"running"`
+	Universes []int                 `json:"universes"` // universes received recently
+}
+
+func info() dmxInfo {
+	return dmxInfo{Config: config.GetDmxInput(), Running: Running(), Universes: GetUniverses()}
+}
+
+func NewV1API(r *api.Router) {
+	schema, err := config.DmxInputSchema()
+	if err != nil {
+		logger.Logger.WithField("context", "DMX API").Error(err)
+	}
+	r.Schema("DmxConfig", api.ConfigSchema(schema))
+	infoSchema := api.TypeSchema(dmxInfo{})
+	infoSchema["properties"].(map[string]interface{})["config"] = api.Ref("DmxConfig")
+	r.Schema("Dmx", infoSchema)
+
+	r.Get("/dmx", func(*api.Request) (interface{}, error) {
+		return info(), nil
+	}).Doc("DMX", "Get the dmx input config and the universes being received").Returns(api.Ref("Dmx"))
+
+	r.Put("/dmx", func(req *api.Request) (interface{}, error) {
+		c := map[string]interface{}{}
+		if err := req.Decode(&c); err != nil {
+			return nil, err
+		}
+		if err := config.SetDmxInput(c); err != nil {
+			return nil, api.BadRequest(err)
+		}
+		if err := Start(); err != nil {
+			return nil, err
+		}
+		return info(), nil
+	}).Doc("DMX", "Update the dmx input config and restart the receiver with it").
+		Accepts(api.Ref("DmxConfig")).Returns(api.Ref("Dmx"))
+
+	r.Get("/dmx/schema", func(*api.Request) (interface{}, error) {
+		return config.DmxInputSchema()
+	}).Doc("DMX", "Get the schema of the dmx input config, for building forms").Returns(map[string]interface{}{"type": "object"})
+}
@@ -0,0 +1,132 @@
+package dmx
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/LedFx/ledfx/pkg/config"
+)
+
+func e131Packet(universe int, options byte, data []byte) []byte {
+	b := make([]byte, e131DataOffset+len(data))
+	binary.BigEndian.PutUint16(b[0:], 0x0010)
+	copy(b[4:], e131Identifier)
+	binary.BigEndian.PutUint32(b[18:], e131RootVector)
+	binary.BigEndian.PutUint32(b[40:], e131FramingVector)
+	copy(b[44:], "test console")
+	b[108] = 100 // priority
+	b[112] = options
+	binary.BigEndian.PutUint16(b[113:], uint16(universe))
+	b[117] = e131DmpVector
+	b[118] = 0xa1
+	binary.BigEndian.PutUint16(b[121:], 1)
+	binary.BigEndian.PutUint16(b[123:], uint16(len(data)+1))
+	copy(b[e131DataOffset:], data)
+	return b
+}
+
+func artNetPacket(universe int, data []byte) []byte {
+	b := make([]byte, artNetDataOffset+len(data))
+	copy(b, artNetIdentifier)
+	binary.LittleEndian.PutUint16(b[8:], artNetOpDmx)
+	b[11] = 14 // protocol version
+	b[14] = byte(universe)
+	b[15] = byte(universe >> 8)
+	binary.BigEndian.PutUint16(b[16:], uint16(len(data)))
+	copy(b[artNetDataOffset:], data)
+	return b
+}
+
+func TestDecode(t *testing.T) {
+	p, err := decodeE131(e131Packet(300, 0, []byte{1, 2, 3}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.universe != 300 || string(p.data) != string([]byte{1, 2, 3}) || p.terminated {
+		t.Errorf("Unexpected E1.31 packet %+v", p)
+	}
+	if _, err = decodeE131(e131Packet(1, e131OptPreview, []byte{1})); err != errNotDmx {
+		t.Errorf("Preview data should be ignored, got %v", err)
+	}
+	if p, _ = decodeE131(e131Packet(1, e131OptTerminated, nil)); !p.terminated {
+		t.Error("Expected a terminated stream")
+	}
+
+	p, err = decodeArtNet(artNetPacket(0x123, []byte{4, 5}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.universe != 0x123 || string(p.data) != string([]byte{4, 5}) {
+		t.Errorf("Unexpected Art-Net packet %+v", p)
+	}
+	poll := artNetPacket(0, nil)
+	binary.LittleEndian.PutUint16(poll[8:], 0x2000)
+	if _, err = decodeArtNet(poll); err != errNotDmx {
+		t.Errorf("Art-Net polls should be ignored, got %v", err)
+	}
+	if _, err = decodeArtNet([]byte("not art-net at all")); err == nil || err == errNotDmx {
+		t.Errorf("Expected an error for a bad packet, got %v", err)
+	}
+}
+
+func freePort(t *testing.T) int {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+// sends packets until the universe is received
+func waitForUniverse(t *testing.T, port int, packet []byte, u int) [512]byte {
+	conn, err := net.DialUDP("udp4", nil, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		conn.Write(packet)
+		time.Sleep(10 * time.Millisecond)
+		if data, ok := Universe(u); ok {
+			return data
+		}
+	}
+	t.Fatalf("Universe %d was not received", u)
+	return [512]byte{}
+}
+
+func TestReceiver(t *testing.T) {
+	defer config.DisableSaving()()
+	for _, protocol := range []string{"e131", "artnet"} {
+		port := freePort(t)
+		if err := config.SetDmxInput(map[string]interface{}{"enabled": true, "protocol": protocol, "port": port}); err != nil {
+			t.Fatal(err)
+		}
+		if err := Start(); err != nil {
+			t.Fatal(err)
+		}
+		var packet []byte
+		u := 7
+		if protocol == "e131" {
+			packet = e131Packet(u, 0, []byte{255, 128, 0})
+		} else {
+			u = 8
+			packet = artNetPacket(u, []byte{255, 128, 0, 0})
+		}
+		data := waitForUniverse(t, port, packet, u)
+		if data[0] != 255 || data[1] != 128 || data[2] != 0 || data[3] != 0 {
+			t.Errorf("%s: unexpected channels %v", protocol, data[:4])
+		}
+		Stop()
+		if Running() {
+			t.Errorf("%s: receiver still running after stop", protocol)
+		}
+	}
+	if _, ok := Universe(9); ok {
+		t.Error("A universe which was never sent should not be received")
+	}
+}
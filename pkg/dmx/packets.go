@@ -0,0 +1,79 @@
+package dmx
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+/*
+Decoders for the DMX data packets of E1.31 (sACN) and Art-Net (ArtDmx).
+Other packets, eg. E1.31 sync or Art-Net polls, are reported with errNotDmx and can be ignored.
+*/
+
+var errNotDmx = errors.New("not a dmx data packet")
+
+var e131Identifier = []byte("ASC-E1.17\x00\x00\x00")
+var artNetIdentifier = []byte("Art-Net\x00")
+
+const (
+	e131RootVector    = 0x00000004
+	e131FramingVector = 0x00000002
+	e131DmpVector     = 0x02
+	e131DataOffset    = 126
+	e131OptPreview    = 0x80 // data is for visualisers only, not for live output
+	e131OptTerminated = 0x40 // the source has stopped sending this universe
+	artNetOpDmx       = 0x5000
+	artNetDataOffset  = 18
+)
+
+// A universe of dmx data from a packet
+type packet struct {
+	universe   int
+	data       []byte // channel values, up to 512
+	terminated bool   // the source has stopped sending the universe
+}
+
+func decodeE131(b []byte) (packet, error) {
+	p := packet{}
+	if len(b) < e131DataOffset || !bytes.Equal(b[4:16], e131Identifier) {
+		return p, errors.New("not an E1.31 packet")
+	}
+	if binary.BigEndian.Uint32(b[18:22]) != e131RootVector || binary.BigEndian.Uint32(b[40:44]) != e131FramingVector {
+		return p, errNotDmx
+	}
+	if b[117] != e131DmpVector || b[125] != 0 { // start code 0 is dimmer data
+		return p, errNotDmx
+	}
+	options := b[112]
+	if options&e131OptPreview != 0 {
+		return p, errNotDmx
+	}
+	p.universe = int(binary.BigEndian.Uint16(b[113:115]))
+	p.terminated = options&e131OptTerminated != 0
+	count := int(binary.BigEndian.Uint16(b[123:125])) - 1 // includes the start code
+	if count < 0 || count > 512 || e131DataOffset+count > len(b) {
+		return p, fmt.Errorf("invalid E1.31 property count %d", count+1)
+	}
+	p.data = b[e131DataOffset : e131DataOffset+count]
+	return p, nil
+}
+
+func decodeArtNet(b []byte) (packet, error) {
+	p := packet{}
+	if len(b) < 10 || !bytes.Equal(b[:8], artNetIdentifier) {
+		return p, errors.New("not an Art-Net packet")
+	}
+	if binary.LittleEndian.Uint16(b[8:10]) != artNetOpDmx || len(b) < artNetDataOffset {
+		return p, errNotDmx
+	}
+	// port address is 15 bits: net (7), sub net (4), universe (4)
+	p.universe = int(b[15]&0x7f)<<8 | int(b[14])
+	count := int(binary.BigEndian.Uint16(b[16:18]))
+	if count > 512 || artNetDataOffset+count > len(b) {
+		return p, fmt.Errorf("invalid Art-Net length %d", count)
+	}
+	p.data = b[artNetDataOffset : artNetDataOffset+count]
+	return p, nil
+}
@@ -0,0 +1,194 @@
+package dmx
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/LedFx/ledfx/pkg/config"
+	log "github.com/LedFx/ledfx/pkg/logger"
+
+	"golang.org/x/net/ipv4"
+)
+
+/*
+Receives DMX universes from a lighting console, over E1.31 (sACN) or Art-Net.
+E1.31 universes are joined by multicast as they are asked for, and unicast is accepted too.
+Art-Net is accepted by unicast or broadcast.
+*/
+
+const (
+	E131Port   = 5568
+	ArtNetPort = 6454
+	// universes are stale if not received for this long, as in E1.31's network data loss timeout
+	Timeout = 2500 * time.Millisecond
+)
+
+type universe struct {
+	data    [512]byte
+	updated time.Time
+}
+
+type receiver struct {
+	conn      *net.UDPConn
+	pc        *ipv4.PacketConn // for joining multicast groups. nil for Art-Net
+	protocol  string
+	mu        sync.Mutex
+	joined    map[int]bool // universes joined by multicast
+	closeOnce sync.Once
+}
+
+var rcv *receiver
+var rcvMu sync.Mutex
+
+var universes = map[int]*universe{}
+var universesMu sync.RWMutex
+
+// Starts receiving with the dmx input config, if it's enabled. Replaces any running receiver.
+func Start() error {
+	Stop()
+	c := config.GetDmxInput()
+	if !c.Enabled {
+		return nil
+	}
+	port := c.Port
+	if port == 0 {
+		port = E131Port
+		if c.Protocol == "artnet" {
+			port = ArtNetPort
+		}
+	}
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{Port: port})
+	if err != nil {
+		return fmt.Errorf("error starting DMX input: %w", err)
+	}
+	r := &receiver{
+		conn:     conn,
+		protocol: c.Protocol,
+		joined:   map[int]bool{},
+	}
+	if c.Protocol == "e131" {
+		r.pc = ipv4.NewPacketConn(conn)
+	}
+	rcvMu.Lock()
+	rcv = r
+	rcvMu.Unlock()
+	go r.serve()
+	log.Logger.WithField("context", "DMX Input").Infof("Listening for %s on port %d", c.Protocol, port)
+	return nil
+}
+
+func Stop() {
+	rcvMu.Lock()
+	r := rcv
+	rcv = nil
+	rcvMu.Unlock()
+	if r != nil {
+		r.closeOnce.Do(func() { r.conn.Close() })
+	}
+}
+
+// Whether the receiver is running
+func Running() bool {
+	rcvMu.Lock()
+	defer rcvMu.Unlock()
+	return rcv != nil
+}
+
+func (r *receiver) serve() {
+	buf := make([]byte, 1500)
+	for {
+		n, _, err := r.conn.ReadFromUDP(buf)
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			log.Logger.WithField("context", "DMX Input").Warn(err)
+			continue
+		}
+		var p packet
+		if r.protocol == "artnet" {
+			p, err = decodeArtNet(buf[:n])
+		} else {
+			p, err = decodeE131(buf[:n])
+		}
+		if errors.Is(err, errNotDmx) {
+			continue
+		}
+		if err != nil {
+			log.Logger.WithField("context", "DMX Input").Debug(err)
+			continue
+		}
+		store(p)
+	}
+}
+
+func store(p packet) {
+	universesMu.Lock()
+	defer universesMu.Unlock()
+	if p.terminated {
+		delete(universes, p.universe)
+		return
+	}
+	u, exists := universes[p.universe]
+	if !exists {
+		u = &universe{}
+		universes[p.universe] = u
+	}
+	// channels left out of a short packet are zero
+	u.data = [512]byte{}
+	copy(u.data[:], p.data)
+	u.updated = time.Now()
+}
+
+// joins the multicast group of an E1.31 universe, 239.255.<high byte>.<low byte>
+func (r *receiver) join(u int) {
+	if r.pc == nil || u < 1 || u > 63999 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.joined[u] {
+		return
+	}
+	// only try once, unicast still works if joining fails
+	r.joined[u] = true
+	group := &net.UDPAddr{IP: net.IPv4(239, 255, byte(u>>8), byte(u))}
+	if err := r.pc.JoinGroup(nil, group); err != nil {
+		log.Logger.WithField("context", "DMX Input").Debugf("Cannot join multicast for universe %d: %v", u, err)
+	}
+}
+
+// Get the latest data of a universe. ok is false if the universe has not been received recently.
+func Universe(u int) (data [512]byte, ok bool) {
+	rcvMu.Lock()
+	r := rcv
+	rcvMu.Unlock()
+	if r != nil {
+		r.join(u)
+	}
+	universesMu.RLock()
+	defer universesMu.RUnlock()
+	un, exists := universes[u]
+	if !exists || time.Since(un.updated) > Timeout {
+		return data, false
+	}
+	return un.data, true
+}
+
+// Get the universes received recently
+func GetUniverses() []int {
+	universesMu.RLock()
+	defer universesMu.RUnlock()
+	ids := []int{}
+	for id, un := range universes {
+		if time.Since(un.updated) <= Timeout {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	return ids
+}
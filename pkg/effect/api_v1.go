@@ -0,0 +1,208 @@
+package effect
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/LedFx/ledfx/pkg/api"
+	"github.com/LedFx/ledfx/pkg/config"
+	"github.com/LedFx/ledfx/pkg/logger"
+)
+
+type scriptJSON struct {
+	Script string `json:"script"`
+}
+
+type scriptStatus struct {
+	Script string `json:"script"`
+	Error  string `json:"error,omitempty"`
+}
+
+func getScript(id string) (*Script, error) {
+	effect, err := Get(id)
+	if err != nil {
+		return nil, err
+	}
+	script, ok := effect.pixelGenerator.(*Script)
+	if !ok {
+		return nil, fmt.Errorf("effect %s is not a script effect", id)
+	}
+	return script, nil
+}
+
+func NewV1API(r *api.Router) {
+	schema, err := Schema()
+	if err != nil {
+		logger.Logger.WithField("context", "Effects API").Error(err)
+	}
+	effectSchema := api.TypeSchema(config.EffectEntry{})
+	if base, ok := schema["base"].(map[string]interface{}); ok {
+		r.Schema("EffectConfig", api.ConfigSchema(base))
+		effectSchema["properties"].(map[string]interface{})["base_config"] = api.Ref("EffectConfig")
+	}
+	if modulator, ok := schema["modulator"].(map[string]interface{}); ok {
+		r.Schema("Modulator", api.ConfigSchema(modulator))
+		effectSchema["properties"].(map[string]interface{})["modulators"] = api.ArrayOf(api.Ref("Modulator"))
+	}
+	r.Schema("Effect", effectSchema)
+
+	r.Get("/effects", func(*api.Request) (interface{}, error) {
+		return config.GetEffects(), nil
+	}).Doc("Effects", "Get all effects, by id").Returns(api.MapOf(api.Ref("Effect")))
+
+	r.Post("/effects", func(req *api.Request) (interface{}, error) {
+		data := config.EffectEntry{}
+		if err := req.Decode(&data); err != nil {
+			return nil, err
+		}
+		e, id, err := New(data.ID, data.Type, 100, data.BaseConfig)
+		if err != nil {
+			return nil, api.BadRequest(err)
+		}
+		if err = updateEffect(e, data); err != nil {
+			Destroy(id)
+			return nil, err
+		}
+		return config.GetEffect(id)
+	}).Doc("Effects", "Create an effect. An id is made from the type if none is given").
+		Accepts(api.Ref("Effect")).Returns(api.Ref("Effect")).Status(http.StatusCreated)
+
+	r.Get("/effects/schema", func(*api.Request) (interface{}, error) {
+		return Schema()
+	}).Doc("Effects", "Get the schema of effect configs, for building forms").Returns(map[string]interface{}{"type": "object"})
+
+	r.Get("/effects/global", func(*api.Request) (interface{}, error) {
+		return GetGlobalSettings(), nil
+	}).Doc("Effects", "Get the settings applied to all effects").Returns(api.Ref("EffectConfig"))
+
+	r.Put("/effects/global", func(req *api.Request) (interface{}, error) {
+		data := map[string]interface{}{}
+		if err := req.Decode(&data); err != nil {
+			return nil, err
+		}
+		if err := SetGlobalSettings(data); err != nil {
+			return nil, api.BadRequest(err)
+		}
+		return GetGlobalSettings(), nil
+	}).Doc("Effects", "Update settings of all effects").Accepts(api.Ref("EffectConfig")).Returns(api.Ref("EffectConfig"))
+
+	r.Get("/effects/{id}", func(req *api.Request) (interface{}, error) {
+		if _, err := Get(req.Param("id")); err != nil {
+			return nil, api.NotFound(err)
+		}
+		return config.GetEffect(req.Param("id"))
+	}).Doc("Effects", "Get an effect").Returns(api.Ref("Effect"))
+
+	r.Put("/effects/{id}", func(req *api.Request) (interface{}, error) {
+		e, err := Get(req.Param("id"))
+		if err != nil {
+			return nil, api.NotFound(err)
+		}
+		data := config.EffectEntry{}
+		if err = req.Decode(&data); err != nil {
+			return nil, err
+		}
+		if data.BaseConfig != nil {
+			if err = e.UpdateBaseConfig(data.BaseConfig); err != nil {
+				return nil, api.BadRequest(err)
+			}
+		}
+		if err = updateEffect(e, data); err != nil {
+			return nil, err
+		}
+		return config.GetEffect(e.ID)
+	}).Doc("Effects", "Update an effect's config. Only the settings given are changed").
+		Accepts(api.Ref("Effect")).Returns(api.Ref("Effect"))
+
+	r.Delete("/effects/{id}", func(req *api.Request) (interface{}, error) {
+		if _, err := Get(req.Param("id")); err != nil {
+			return nil, api.NotFound(err)
+		}
+		Destroy(req.Param("id"))
+		return nil, nil
+	}).Doc("Effects", "Delete an effect")
+
+	r.Get("/effects/{id}/modulators", func(req *api.Request) (interface{}, error) {
+		e, err := Get(req.Param("id"))
+		if err != nil {
+			return nil, api.NotFound(err)
+		}
+		return e.GetModulators(), nil
+	}).Doc("Effects", "Get an effect's modulators").Returns(api.ArrayOf(api.Ref("Modulator")))
+
+	r.Put("/effects/{id}/modulators", func(req *api.Request) (interface{}, error) {
+		e, err := Get(req.Param("id"))
+		if err != nil {
+			return nil, api.NotFound(err)
+		}
+		modulators := []map[string]interface{}{}
+		if err = req.Decode(&modulators); err != nil {
+			return nil, err
+		}
+		if err = e.SetModulators(modulators); err != nil {
+			return nil, api.BadRequest(err)
+		}
+		return e.GetModulators(), nil
+	}).Doc("Effects", "Replace an effect's modulators").
+		Accepts(api.ArrayOf(api.Ref("Modulator"))).Returns(api.ArrayOf(api.Ref("Modulator")))
+
+	r.Get("/effects/{id}/script", func(req *api.Request) (interface{}, error) {
+		script, err := getV1Script(req.Param("id"))
+		if err != nil {
+			return nil, err
+		}
+		return newScriptStatus(script), nil
+	}).Doc("Effects", "Get a script effect's script, and the error which stopped it").Returns(api.TypeSchema(scriptStatus{}))
+
+	r.Put("/effects/{id}/script", func(req *api.Request) (interface{}, error) {
+		script, err := getV1Script(req.Param("id"))
+		if err != nil {
+			return nil, err
+		}
+		data := scriptJSON{}
+		if err = req.Decode(&data); err != nil {
+			return nil, err
+		}
+		e, _ := Get(req.Param("id"))
+		if err = e.UpdateExtraConfig(map[string]interface{}{"script": data.Script}); err != nil {
+			return nil, api.BadRequest(err)
+		}
+		return newScriptStatus(script), nil
+	}).Doc("Effects", "Update a script effect's script. Compile errors are a bad request").
+		Accepts(api.TypeSchema(scriptJSON{})).Returns(api.TypeSchema(scriptStatus{}))
+}
+
+// applies the extra config and modulators of an entry, if given
+func updateEffect(e *Effect, data config.EffectEntry) error {
+	if data.ExtraConfig != nil {
+		if err := e.UpdateExtraConfig(data.ExtraConfig); err != nil {
+			return api.BadRequest(err)
+		}
+	}
+	if data.Modulators != nil {
+		if err := e.SetModulators(data.Modulators); err != nil {
+			return api.BadRequest(err)
+		}
+	}
+	return nil
+}
+
+func getV1Script(id string) (*Script, error) {
+	if _, err := Get(id); err != nil {
+		return nil, api.NotFound(err)
+	}
+	script, err := getScript(id)
+	if err != nil {
+		return nil, api.BadRequest(err)
+	}
+	return script, nil
+}
+
+func newScriptStatus(script *Script) scriptStatus {
+	status := scriptStatus{}
+	var err error
+	if status.Script, err = script.status(); err != nil {
+		status.Error = err.Error()
+	}
+	return status
+}
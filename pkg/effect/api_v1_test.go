@@ -0,0 +1,38 @@
+package effect
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/LedFx/ledfx/pkg/api"
+	"github.com/LedFx/ledfx/pkg/api/apitest"
+	"github.com/LedFx/ledfx/pkg/config"
+)
+
+func TestV1API(t *testing.T) {
+	defer config.DisableSaving()()
+	r := api.NewRouter("/api/v1")
+	NewV1API(r)
+
+	apitest.Run(t, r, []apitest.Case{
+		{Method: http.MethodPost, Path: "/api/v1/effects", Body: `{"id": "v1_fx", "type": "energy"}`, Code: http.StatusCreated},
+		{Method: http.MethodPost, Path: "/api/v1/effects", Body: `{"type": "nope"}`, Code: http.StatusBadRequest},
+		{Method: http.MethodGet, Path: "/api/v1/effects/v1_fx", Code: http.StatusOK},
+		{Method: http.MethodGet, Path: "/api/v1/effects/nope", Code: http.StatusNotFound},
+		{Method: http.MethodPut, Path: "/api/v1/effects/v1_fx", Body: `{"base_config": {"brightness": 0.5}}`, Code: http.StatusOK},
+		{Method: http.MethodPut, Path: "/api/v1/effects/v1_fx", Body: `{"base_config": {"brightness": 5}}`, Code: http.StatusBadRequest},
+		{Method: http.MethodPut, Path: "/api/v1/effects/nope", Body: `{}`, Code: http.StatusNotFound},
+		{Method: http.MethodGet, Path: "/api/v1/effects/v1_fx/script", Code: http.StatusBadRequest},
+		{Method: http.MethodDelete, Path: "/api/v1/effects/v1_fx", Code: http.StatusNoContent},
+		{Method: http.MethodDelete, Path: "/api/v1/effects/v1_fx", Code: http.StatusNotFound},
+	})
+
+	// the document includes the routes and the config schemas
+	apitest.Documents(t, r, "/api/v1", []string{"/effects/{id}"}, []string{"Effect", "EffectConfig"})
+	_, doc := apitest.Do(r, http.MethodGet, "/api/v1/openapi.json", "")
+	schemas := doc["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	brightness := schemas["EffectConfig"].(map[string]interface{})["properties"].(map[string]interface{})["brightness"]
+	if brightness == nil {
+		t.Error("Expected the effect config schema to come from the effect config")
+	}
+}
@@ -17,11 +17,11 @@ https://electromage.com/patterns
 type BlockReflections struct{}
 
 // Apply new pixels to an existing pixel array.
-func (e *BlockReflections) assembleFrame(base *Effect, pg *render.PixelGroup) {
+func (e *BlockReflections) assembleFrame(base *Effect, c BaseEffectConfig, pg *render.PixelGroup) {
 	// operate on the largest pixel output in group, then clone to others
 	p := pg.Group[pg.Largest]
 
-	mel, err := audio.Analyzer.GetMelbank(base.ID)
+	mel, err := audio.GetAnalyzer(c.Source).GetMelbank(base.ID)
 	if err != nil {
 		logger.Logger.WithField("context", "Effect").Error(err)
 		return
@@ -38,14 +38,14 @@ func (e *BlockReflections) assembleFrame(base *Effect, pg *render.PixelGroup) {
 
 	for i := 0; i < len(p); i++ {
 		fi := float64(i)
-		m := (0.3 + base.triangle(t2)*0.2 + (lows * base.Config.Intensity))
-		h := math.Sin(t2) + math.Mod(((fi-base.pixelScaler/2)/base.pixelScaler)*(base.triangle(t3)*10+4*math.Sin(t4)+(lows*base.Config.Intensity)), m)
+		m := (0.3 + base.triangle(t2)*0.2 + (lows * c.Intensity))
+		h := math.Sin(t2) + math.Mod(((fi-base.pixelScaler/2)/base.pixelScaler)*(base.triangle(t3)*10+4*math.Sin(t4)+(lows*c.Intensity)), m)
 		v := math.Mod(math.Abs(h)+math.Abs(m), 1)
 		v = math.Pow(v, 2)
 
 		p[i][0] = h + high
-		p[i][1] = 1 - (lows * base.Config.Intensity)
-		p[i][2] = v + (mids * base.Config.Intensity)
+		p[i][1] = 1 - (lows * c.Intensity)
+		p[i][2] = v + (mids * c.Intensity)
 	}
 
 	pg.CloneToAll(pg.Largest)
@@ -0,0 +1,197 @@
+package effect
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+
+	"github.com/LedFx/ledfx/pkg/color"
+	"github.com/LedFx/ledfx/pkg/dmx"
+	"github.com/LedFx/ledfx/pkg/logger"
+	"github.com/LedFx/ledfx/pkg/render"
+
+	"github.com/creasty/defaults"
+	"github.com/mitchellh/mapstructure"
+)
+
+/*
+Shows DMX from a lighting console, received as set in the dmx input config.
+Each dmx effect reads its own universe. In passthrough mode the frame is RGB, straight from the console.
+In mapped mode, channels set the effect's settings through its config, as if they were updated over the API,
+so they're validated, saved and can be undone. A setting is only set when its channel changes,
+so a setting changed elsewhere holds until the console moves the channel.
+*/
+type Dmx struct {
+	mu       sync.Mutex
+	config   DmxConfig
+	channels map[int]string // settings by channel, from the config
+	last     map[int]byte   // channel values last applied
+}
+
+type DmxConfig struct {
+	Universe int               `mapstructure:"universe" json:"universe" description:"First universe to read. Passthrough continues into the following universes, 170 pixels per universe" default:"1" validate:"gte=0,lte=63999"`
+	Mode     string            `mapstructure:"mode" json:"mode" description:"Show the channels as RGB pixels, or map channels to the effect's settings" default:"passthrough" validate:"oneof=passthrough mapped"`
+	Channels map[string]string `mapstructure:"channels" json:"channels" description:"Effect settings set by channels 1-512 in mapped mode, by channel. position sets the position in the palette shown"`
+}
+
+func newDmx() *Dmx {
+	e := &Dmx{channels: map[int]string{}, last: map[int]byte{}}
+	defaults.Set(&e.config)
+	return e
+}
+
+// the channel target which sets the position in the palette, rather than a setting
+const dmxPosition = "position"
+
+func (e *Dmx) extraConfig() map[string]interface{} {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	m := map[string]interface{}{}
+	mapstructure.Decode(e.config, &m)
+	return m
+}
+
+func (e *Dmx) setExtraConfig(base *Effect, c map[string]interface{}) error {
+	dc, channels, err := decodeDmxConfig(c)
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.config = dc
+	e.channels = channels
+	e.last = map[int]byte{}
+	return nil
+}
+
+func (e *Dmx) checkExtraConfig(c map[string]interface{}) error {
+	_, _, err := decodeDmxConfig(c)
+	return err
+}
+
+// keeps the config as it was given, so it's saved unchanged. Nothing is mapped until it's fixed
+func (e *Dmx) holdExtraConfig(c map[string]interface{}, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	defaults.Set(&e.config)
+	mapstructure.Decode(c, &e.config)
+	e.channels = map[int]string{}
+}
+
+// decodes dmx config on top of the defaults, and checks its channels map to settings which can be set
+func decodeDmxConfig(c map[string]interface{}) (dc DmxConfig, channels map[int]string, err error) {
+	if err = defaults.Set(&dc); err != nil {
+		return dc, nil, err
+	}
+	if err = mapstructure.Decode(c, &dc); err != nil {
+		return dc, nil, err
+	}
+	if err = validate.Struct(&dc); err != nil {
+		return dc, nil, err
+	}
+	channels = map[int]string{}
+	for k, setting := range dc.Channels {
+		ch, err := strconv.Atoi(k)
+		if err != nil || ch < 1 || ch > 512 {
+			return dc, nil, fmt.Errorf("dmx channel '%s' must be 1-512", k)
+		}
+		if setting != dmxPosition {
+			i, ok := configFields[setting]
+			if !ok {
+				return dc, nil, fmt.Errorf("dmx channel %d maps to unknown setting '%s'", ch, setting)
+			}
+			if kind := reflect.TypeOf(BaseEffectConfig{}).Field(i).Type.Kind(); kind != reflect.Float64 && kind != reflect.Bool {
+				return dc, nil, fmt.Errorf("dmx channel %d maps to '%s', which isn't a 0-1 or on/off setting", ch, setting)
+			}
+		}
+		channels[ch] = setting
+	}
+	return dc, channels, nil
+}
+
+// In passthrough mode the frame is RGB, straight from the console
+func (e *Dmx) rgb() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.config.Mode == "passthrough"
+}
+
+// Apply new pixels to an existing pixel array.
+// Passthrough reads 3 channels per pixel, 170 pixels per universe, continuing into the following universes.
+// Mapped fills the pixels with the palette color at the mapped position, and sets the mapped settings.
+// Pixels go dark if the console stops sending.
+func (e *Dmx) assembleFrame(base *Effect, c BaseEffectConfig, pg *render.PixelGroup) {
+	e.mu.Lock()
+	if e.config.Mode == "passthrough" {
+		defer e.mu.Unlock()
+		e.passthrough(e.config.Universe, pg)
+		return
+	}
+	data, ok := dmx.Universe(e.config.Universe)
+	position := 0.0
+	changed := map[string]interface{}{}
+	if ok {
+		for ch, setting := range e.channels {
+			x := data[ch-1]
+			if setting == dmxPosition {
+				position = float64(x) / 255
+				continue
+			}
+			if last, applied := e.last[ch]; applied && last == x {
+				continue
+			}
+			e.last[ch] = x
+			if reflect.TypeOf(BaseEffectConfig{}).Field(configFields[setting]).Type.Kind() == reflect.Bool {
+				changed[setting] = x >= 128
+			} else {
+				changed[setting] = float64(x) / 255
+			}
+		}
+	}
+	e.mu.Unlock()
+
+	// saving the config reads the extra config, so it's applied without the lock
+	if len(changed) > 0 {
+		if err := base.updateBaseConfig(changed); err != nil {
+			logger.Logger.WithField("context", "DMX Effect").Warnf("Cannot apply DMX to effect %s: %v", base.ID, err)
+		}
+	}
+
+	// operate on the largest pixel output in group, then clone to others
+	p := pg.Group[pg.Largest]
+	brightness := 1.0
+	if !ok {
+		brightness = 0
+	}
+	for i := range p {
+		p[i] = color.Color{position, 1, brightness}
+	}
+	pg.CloneToAll(pg.Largest)
+}
+
+func (e *Dmx) passthrough(first int, pg *render.PixelGroup) {
+	u := -1
+	var data [512]byte
+	var ok bool
+	i := 0
+	for _, id := range pg.Order {
+		p := pg.Group[id]
+		for j := range p {
+			if i/170 != u {
+				u = i / 170
+				data, ok = dmx.Universe(first + u)
+			}
+			if !ok {
+				p[j] = color.Color{}
+				i++
+				continue
+			}
+			k := (i % 170) * 3
+			p[j][0] = float64(data[k]) / 255
+			p[j][1] = float64(data[k+1]) / 255
+			p[j][2] = float64(data[k+2]) / 255
+			i++
+		}
+	}
+}
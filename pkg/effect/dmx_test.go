@@ -0,0 +1,108 @@
+package effect
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/LedFx/ledfx/pkg/color"
+	"github.com/LedFx/ledfx/pkg/config"
+	"github.com/LedFx/ledfx/pkg/dmx"
+)
+
+// sends an Art-Net universe to a local receiver until it's received
+func sendArtNet(t *testing.T, port, universe int, data []byte) {
+	b := make([]byte, 18+len(data))
+	copy(b, "Art-Net\x00")
+	binary.LittleEndian.PutUint16(b[8:], 0x5000)
+	b[11] = 14
+	b[14] = byte(universe)
+	b[15] = byte(universe >> 8)
+	binary.BigEndian.PutUint16(b[16:], uint16(len(data)))
+	copy(b[18:], data)
+
+	conn, err := net.DialUDP("udp4", nil, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		conn.Write(b)
+		time.Sleep(10 * time.Millisecond)
+		if received, ok := dmx.Universe(universe); ok && received[0] == data[0] {
+			return
+		}
+	}
+	t.Fatalf("Universe %d was not received", universe)
+}
+
+func TestDmxMapped(t *testing.T) {
+	defer config.DisableSaving()()
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+	conn.Close()
+	if err = config.SetDmxInput(map[string]interface{}{"enabled": true, "protocol": "artnet", "port": port}); err != nil {
+		t.Fatal(err)
+	}
+	if err = dmx.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer dmx.Stop()
+
+	e, _, err := New("dmx_test", "dmx", 4, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Destroy("dmx_test")
+	for _, channels := range []map[string]interface{}{
+		{"0": "brightness"},
+		{"513": "brightness"},
+		{"1": "nope"},
+		{"1": "palette"},
+	} {
+		if err = e.UpdateExtraConfig(map[string]interface{}{"mode": "mapped", "channels": channels}); err == nil {
+			t.Errorf("Expected an error mapping %v", channels)
+		}
+	}
+	err = e.UpdateExtraConfig(map[string]interface{}{
+		"universe": 12,
+		"mode":     "mapped",
+		"channels": map[string]interface{}{"1": "brightness", "2": "position", "3": "flip"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sendArtNet(t, port, 12, []byte{51, 255, 200})
+	pg := testPixelGroup(4)
+	e.Render(pg)
+	entry, _ := config.GetEffect("dmx_test")
+	if entry.BaseConfig["brightness"] != 0.2 || entry.BaseConfig["flip"] != true {
+		t.Errorf("Expected the mapped settings to be saved, got %v", entry.BaseConfig)
+	}
+	if e.Config.Brightness != 0.2 || !e.Config.Flip {
+		t.Errorf("Expected the mapped settings to be applied, got %+v", e.Config)
+	}
+
+	// settings changed elsewhere hold until the channel moves
+	if err = e.UpdateBaseConfig(map[string]interface{}{"brightness": 0.9}); err != nil {
+		t.Fatal(err)
+	}
+	e.Render(pg)
+	if e.Config.Brightness != 0.9 {
+		t.Errorf("Expected an unchanged channel to leave brightness alone, got %v", e.Config.Brightness)
+	}
+	sendArtNet(t, port, 12, []byte{102, 255, 0})
+	e.Render(pg)
+	if e.Config.Brightness != 0.4 || e.Config.Flip {
+		t.Errorf("Expected the moved channels to be applied, got %+v", e.Config)
+	}
+	if pg.Group["test"][0] == (color.Color{}) {
+		t.Error("Expected the palette to be shown")
+	}
+}
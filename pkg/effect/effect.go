@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"sync"
 	"time"
 
 	"github.com/LedFx/ledfx/pkg/audio"
@@ -24,10 +25,25 @@ Effects must be computed in HSV space. The color is abstracted and handled outsi
 using the effect's palette. Post processing will handle conversion to RGB but requires HSV space
 */
 type PixelGenerator interface {
-	assembleFrame(base *Effect, pixelGroup *render.PixelGroup)
+	assembleFrame(base *Effect, c BaseEffectConfig, pixelGroup *render.PixelGroup)
+}
+
+// Generators which can assemble RGB frames, eg. from an external source, rather than HSV.
+// RGB frames skip the palette and hue shift.
+type rgbGenerator interface {
+	rgb() bool
+}
+
+// Generators with settings of their own, beyond the base config. These are saved as the effect's extra config.
+type extraConfigGenerator interface {
+	extraConfig() map[string]interface{}
+	setExtraConfig(base *Effect, c map[string]interface{}) error
+	checkExtraConfig(c map[string]interface{}) error     // checks extra config is well formed, without applying it
+	holdExtraConfig(c map[string]interface{}, err error) // keeps extra config which failed to apply, and stops the generator
 }
 
 type Effect struct {
+	mu             sync.Mutex // guards the config, the properties made from it, and the modulators, which are read while rendering
 	ID             string
 	Type           string
 	pixelCount     int
@@ -40,9 +56,12 @@ type Effect struct {
 	deltaPrevFrame time.Duration    // time delta since prev frame, so effects can run at constant speed
 	palette        *color.Palette   // color palette for the effect. derive single colors from the palette
 	blurrer        *color.Blurrer   // blurs the effect
+	blurFor        float64          // blur value the blurrer was made for
 	prevFrame      color.Pixels     // the previous frame, in hsv
 	bkgColor       color.Color      // parsed background color
 	mirror         color.Pixels     // scratch array used by mirror function
+	transient      bool             // not registered or saved to config. see NewTransient
+	modulators     []*modulator     // drive settings from LFOs and audio features. see SetModulators
 	Ready          bool
 }
 
@@ -60,12 +79,25 @@ type BaseEffectConfig struct {
 	BackgroundColor      string  `mapstructure:"background_color" json:"background_color" description:"Apply a background color" default:"#000000" validate:"color"`
 	FreqMin              int     `mapstructure:"freq_min" json:"freq_min" description:"Lowest audio frequency to react to" default:"20" validate:"gte=20,lte=20000"`
 	FreqMax              int     `mapstructure:"freq_max" json:"freq_max" description:"Highest audio frequency to react to" default:"20000" validate:"gte=20,lte=20000"`
+	Source               string  `mapstructure:"source" json:"source" description:"Audio source to react to" default:"default" validate:"audio_source"`
 }
 
 func (e *Effect) GetID() string {
 	return e.ID
 }
 
+// Get the base config of the effect, without modulation
+func (e *Effect) GetConfig() BaseEffectConfig {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.Config
+}
+
+// Frees the audio resources of a transient effect
+func (e *Effect) Release() {
+	audio.DeleteMelbanks(e.ID)
+}
+
 func (e *Effect) initialize(id string, pixelCount int) {
 	e.Ready = false
 	e.ID = id
@@ -80,8 +112,12 @@ func (e *Effect) initialize(id string, pixelCount int) {
 }
 
 func (e *Effect) UpdatePixelCount(pixelCount int) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	// initializing resets the config, so keep it
+	c := e.Config
 	e.initialize(e.ID, pixelCount)
-	return e.UpdateBaseConfig(e.Config)
+	return e.updateBaseConfig(c)
 }
 
 /*
@@ -90,9 +126,109 @@ as EnergyConfig, map[string]interface{}, or raw json.
 You can also use a nil to set config to defaults
 */
 func (e *Effect) UpdateBaseConfig(c interface{}) (err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.updateBaseConfig(c)
+}
+
+// caller must hold the lock
+func (e *Effect) updateBaseConfig(c interface{}) (err error) {
 	e.Ready = false
 	defer func() { e.Ready = true }()
-	newConfig := e.Config
+	newConfig, err := e.decodeConfig(c)
+	if err != nil {
+		return err
+	}
+
+	// create stored properties from new config
+	e.updateStoredProperties(newConfig)
+
+	// apply config to effect
+	e.Config = newConfig
+	if e.transient {
+		return nil
+	}
+
+	// save to config store
+	mapConfig, err := e.saveEntry()
+	if mapConfig == nil {
+		return err
+	}
+
+	// invoke event
+	event.Invoke(event.EffectUpdateData{
+		ID:         e.ID,
+		Type:       e.Type,
+		BaseConfig: mapConfig,
+	})
+	return err
+}
+
+/*
+Applies config to the effect without saving it or invoking an update.
+Use this for values which change every frame, eg. sequencer ramps, then
+UpdateBaseConfig once the value settles.
+*/
+func (e *Effect) ApplyBaseConfig(c interface{}) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	newConfig, err := e.decodeConfig(c)
+	if err != nil {
+		return err
+	}
+	e.updateStoredProperties(newConfig)
+	e.Config = newConfig
+	return nil
+}
+
+// Updates the settings specific to the effect's type, eg. the code of a script effect
+func (e *Effect) UpdateExtraConfig(c map[string]interface{}) error {
+	g, ok := e.pixelGenerator.(extraConfigGenerator)
+	if !ok {
+		return fmt.Errorf("%s effects have no extra config", e.Type)
+	}
+	if err := g.setExtraConfig(e, c); err != nil {
+		return err
+	}
+	if e.transient {
+		return nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, err := e.saveEntry()
+	return err
+}
+
+// Get the settings specific to the effect's type, or nil if it has none
+func (e *Effect) ExtraConfig() map[string]interface{} {
+	if g, ok := e.pixelGenerator.(extraConfigGenerator); ok {
+		return g.extraConfig()
+	}
+	return nil
+}
+
+// saves the config, extra config and modulators of the effect to the config store. caller must hold the lock
+func (e *Effect) saveEntry() (mapConfig map[string]interface{}, err error) {
+	mapConfig = map[string]interface{}{}
+	if err = mapstructure.Decode(e.Config, &mapConfig); err != nil {
+		return nil, err
+	}
+	err = config.AddEntry(
+		e.ID,
+		config.EffectEntry{
+			ID:          e.ID,
+			Type:        e.Type,
+			BaseConfig:  mapConfig,
+			ExtraConfig: e.ExtraConfig(),
+			Modulators:  e.modulatorMaps(),
+		},
+	)
+	return mapConfig, err
+}
+
+// decodes and validates config on top of the current config
+func (e *Effect) decodeConfig(c interface{}) (newConfig BaseEffectConfig, err error) {
+	newConfig = e.Config
 	switch t := c.(type) {
 	case BaseEffectConfig: // No conversion necessary
 		newConfig = c.(BaseEffectConfig)
@@ -106,50 +242,20 @@ func (e *Effect) UpdateBaseConfig(c interface{}) (err error) {
 		err = fmt.Errorf("invalid config type: %T %s", t, t)
 	}
 	if err != nil {
-		return err
+		return newConfig, err
 	}
 
 	// validate all values
-	err = validate.Struct(&newConfig)
 	if errs, ok := validate.Struct(&newConfig).(validator.ValidationErrors); ok {
 		if errs != nil {
 			errString := "Validation Errors: "
 			for _, err := range errs {
 				errString += fmt.Sprintf("Field %s with value %v; ", err.Field(), err.Value())
 			}
-			return errors.New(errString)
+			return newConfig, errors.New(errString)
 		}
 	}
-
-	// create stored properties from new config
-	e.updateStoredProperties(newConfig)
-
-	// apply config to effect
-	e.Config = newConfig
-
-	// save to config store
-	mapConfig := map[string]interface{}{}
-	err = mapstructure.Decode(newConfig, &mapConfig)
-	if err != nil {
-		return err
-	}
-	err = config.AddEntry(
-		e.ID,
-		config.EffectEntry{
-			ID:         e.ID,
-			Type:       e.Type,
-			BaseConfig: mapConfig,
-		},
-	)
-
-	// invoke event
-	event.Invoke(event.EffectUpdate,
-		map[string]interface{}{
-			"id":          e.ID,
-			"type":        e.Type,
-			"base_config": mapConfig,
-		})
-	return err
+	return newConfig, nil
 }
 
 // updates properties and objects which are generated from the config
@@ -165,6 +271,7 @@ func (e *Effect) updateStoredProperties(newConfig BaseEffectConfig) {
 	// blur needs new blurrer if changed
 	if e.blurrer == nil || e.Config.Blur != newConfig.Blur {
 		e.blurrer = color.NewBlurrer(e.pixelCount, newConfig.Blur)
+		e.blurFor = newConfig.Blur
 	}
 	// MELBANK
 	// make sure min and max are ordered properly. doesn't matter in config, but does for audio processing.
@@ -178,20 +285,38 @@ func (e *Effect) updateStoredProperties(newConfig BaseEffectConfig) {
 		// we'll just add 50 to the max since there's always room there
 		newConfig.FreqMax += 50
 	}
-	// need to register a new melbank if our freqs or audio stream has changed
+	// the melbank lives on the analyzer of the audio source, so drop it everywhere if the source changed
+	if e.Config.Source != newConfig.Source {
+		audio.DeleteMelbanks(e.ID)
+	}
+	// drop the melbank if our freqs have changed, a new one is registered below
 	if e.Config.FreqMin != newConfig.FreqMin || e.Config.FreqMax != newConfig.FreqMax || e.Config.Intensity != newConfig.Intensity {
-		audio.Analyzer.DeleteMelbank(e.ID)
-		audio.Analyzer.NewMelbank(e.ID, uint(newConfig.FreqMin), uint(newConfig.FreqMax), newConfig.Intensity)
+		audio.GetAnalyzer(newConfig.Source).DeleteMelbank(e.ID)
 	}
 	// need to register a melbank if the effect doesn't have one yet
-	if _, err := audio.Analyzer.GetMelbank(e.ID); err != nil {
-		audio.Analyzer.NewMelbank(e.ID, uint(newConfig.FreqMin), uint(newConfig.FreqMax), newConfig.Intensity)
+	e.ensureMelbank(newConfig)
+}
+
+// audio sources can come and go while the effect is running, so the melbank is checked every frame
+func (e *Effect) ensureMelbank(c BaseEffectConfig) {
+	analyzer := audio.GetAnalyzer(c.Source)
+	if _, err := analyzer.GetMelbank(e.ID); err != nil {
+		freqMin, freqMax := c.FreqMin, c.FreqMax
+		if freqMin > freqMax {
+			freqMin, freqMax = freqMax, freqMin
+		}
+		if freqMax-freqMin < 50 {
+			freqMax += 50
+		}
+		analyzer.NewMelbank(e.ID, uint(freqMin), uint(freqMax), c.Intensity)
 	}
 }
 
 // Render a new frame of pixels. Give the previous frame as argument.
 // This handles assembling a new frame, then applying mirrors, blur, filters, etc
 func (e *Effect) Render(pg *render.PixelGroup) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
 	if !e.Ready {
 		return
 	}
@@ -203,9 +328,20 @@ func (e *Effect) Render(pg *render.PixelGroup) {
 	e.deltaStart = now.Sub(e.startTime)
 	e.prevFrameTime = now
 
+	// modulators override settings for this frame only, so the frame is rendered from its own copy of the config
+	c := e.Config
+	if len(e.modulators) > 0 {
+		c = e.modulate(c)
+	}
+	// a modulated blur changes every frame, so only make a new blurrer when it changes noticeably
+	if math.Abs(c.Blur-e.blurFor) > 0.01 {
+		e.blurrer = color.NewBlurrer(e.pixelCount, c.Blur)
+		e.blurFor = c.Blur
+	}
+
 	// Overwrite the incoming frame (RGB) with the last frame (HSV) while applying temporal decay
 	// for formula explanation, see: https://www.desmos.com/calculator/5qk6xql8bn
-	decay := math.Pow(-math.Log(((1-e.Config.Decay)*math.E-(1-e.Config.Decay)+1)/math.E), 10*e.deltaPrevFrame.Seconds())
+	decay := math.Pow(-math.Log(((1-c.Decay)*math.E-(1-c.Decay)+1)/math.E), 10*e.deltaPrevFrame.Seconds())
 	// Previous frame is a color.Pixels of the entire pixel group for efficiency.
 	i := 0
 	for _, id := range pg.Order {
@@ -216,7 +352,8 @@ func (e *Effect) Render(pg *render.PixelGroup) {
 		}
 	}
 	// Assemble new pixels onto the frame
-	e.pixelGenerator.assembleFrame(e, pg)
+	e.ensureMelbank(c)
+	e.pixelGenerator.assembleFrame(e, c, pg)
 	// Sanitise frame
 	for _, p := range pg.Group {
 		e.sanitise(p)
@@ -231,48 +368,55 @@ func (e *Effect) Render(pg *render.PixelGroup) {
 		}
 	}
 
+	rgb := false
+	if g, ok := e.pixelGenerator.(rgbGenerator); ok {
+		rgb = g.rgb()
+	}
 	for _, p := range pg.Group {
-		// HSV processes
-		e.applyFlip(p)
-		e.applyMirror(p)
-		color.HueShiftPixels(p, e.Config.HueShift*e.deltaStart.Seconds())
-
-		// convert p from HSV to RGB using the palette
-		for i := 0; i < len(p); i++ {
-			s := p[i][1]
-			v := p[i][2]
-			p[i] = e.palette.Get(p[i][0])
-			p[i] = color.Saturation(p[i], s)
-			p[i] = color.Value(p[i], v)
+		e.applyFlip(c, p)
+		e.applyMirror(c, p)
+		if !rgb {
+			// HSV processes
+			color.HueShiftPixels(p, c.HueShift*e.deltaStart.Seconds())
+
+			// convert p from HSV to RGB using the palette
+			for i := 0; i < len(p); i++ {
+				s := p[i][1]
+				v := p[i][2]
+				p[i] = e.palette.Get(p[i][0])
+				p[i] = color.Saturation(p[i], s)
+				p[i] = color.Value(p[i], v)
+			}
 		}
 
 		// RGB processes
-		e.applyBkg(p)
+		e.applyBkg(c, p)
 		for i := range p {
-			p[i] = color.Saturation(p[i], e.Config.Saturation)
-			p[i] = color.Value(p[i], e.Config.Brightness)
+			p[i] = color.Saturation(p[i], c.Saturation)
+			p[i] = color.Value(p[i], c.Brightness)
 		}
-		e.applyBlur(p)
+		e.applyBlur(c, p)
 	}
 
-	event.Invoke(
-		event.EffectRender,
-		map[string]interface{}{
-			"pixels": pg,
-		},
-	)
+	// subscribers get the frame asynchronously, so they need their own copy
+	if event.Subscribers(event.EffectRender) > 0 {
+		event.Invoke(event.EffectRenderData{
+			ID:     e.ID,
+			Pixels: pg.Copy(),
+		})
+	}
 }
 
-func (e *Effect) applyBlur(p color.Pixels) {
-	if e.Config.Blur == 0 {
+func (e *Effect) applyBlur(c BaseEffectConfig, p color.Pixels) {
+	if c.Blur == 0 {
 		return
 	}
 	e.blurrer.BoxBlur(p)
 }
 
 // Reverses the pixels
-func (e *Effect) applyFlip(p color.Pixels) {
-	if !e.Config.Mirror {
+func (e *Effect) applyFlip(c BaseEffectConfig, p color.Pixels) {
+	if !c.Mirror {
 		return
 	}
 	// in place slice reversal
@@ -282,8 +426,8 @@ func (e *Effect) applyFlip(p color.Pixels) {
 }
 
 // Mirrors pixels down the centre
-func (e *Effect) applyMirror(p color.Pixels) {
-	if !e.Config.Mirror {
+func (e *Effect) applyMirror(c BaseEffectConfig, p color.Pixels) {
+	if !c.Mirror {
 		return
 	}
 	// assign indices from end in reverse direction
@@ -305,11 +449,11 @@ func (e *Effect) applyMirror(p color.Pixels) {
 }
 
 // mixes a background colour
-func (e *Effect) applyBkg(p color.Pixels) {
-	for i := 0; i < e.pixelCount; i++ {
-		p[i][0] += e.bkgColor[0] * e.Config.BackgroundBrightness
-		p[i][1] += e.bkgColor[1] * e.Config.BackgroundBrightness
-		p[i][2] += e.bkgColor[2] * e.Config.BackgroundBrightness
+func (e *Effect) applyBkg(c BaseEffectConfig, p color.Pixels) {
+	for i := range p {
+		p[i][0] += e.bkgColor[0] * c.BackgroundBrightness
+		p[i][1] += e.bkgColor[1] * c.BackgroundBrightness
+		p[i][2] += e.bkgColor[2] * c.BackgroundBrightness
 	}
 
 }
@@ -4,8 +4,6 @@ import (
 	"encoding/json"
 	"strings"
 	"testing"
-
-	"github.com/LedFx/ledfx/pkg/color"
 )
 
 func TestSchema(t *testing.T) {
@@ -79,8 +77,7 @@ func TestEffectBaseFunctions(t *testing.T) {
 	_ = GetIDs()
 
 	// Run the effect on some pixels
-	p := make(color.Pixels, 100)
-	effect.Render(p)
+	effect.Render(testPixelGroup(100))
 
 	// Try to update with an invalid json
 	c["nonsense"] = "data" // unknown keys are discarded
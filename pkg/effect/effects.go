@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"log"
 	"reflect"
-	"strconv"
 
 	"github.com/LedFx/ledfx/pkg/audio"
 	"github.com/LedFx/ledfx/pkg/color"
@@ -110,11 +109,106 @@ var effectTypes = map[string]EffectInfo{
 		Category:    "Audio Reactive",
 		Preview:     []byte{},
 	},
+	"stereo": {
+		Description: "A band of color following the stereo balance and width, lit by the left and right channels",
+		GoodFor:     []string{"Stereo sources", "Wide mixes", "Live music"},
+		Category:    "Audio Reactive",
+		Preview:     []byte{},
+	},
+	"dmx": {
+		Description: "Pixels driven by a lighting console over E1.31 or Art-Net",
+		GoodFor:     []string{"Live shows", "Lighting consoles", "Protocol conversion"},
+		Category:    "Non Reactive",
+		Preview:     []byte{},
+	},
+	"script": {
+		Description: "Your own effect, written in JavaScript",
+		GoodFor:     []string{"Tinkering", "Custom patterns", "Porting PixelBlaze patterns"},
+		Category:    "Audio Reactive",
+		Preview:     []byte{},
+	},
 }
 
 // Creates a new effect and returns its unique id.
 // You can supply an ID. If an effect exists with this id, it will be destroyed and overwriten with this new effect
 func New(new_id, effect_type string, pixelCount int, new_config interface{}) (effect *Effect, id string, err error) {
+	return create(new_id, effect_type, pixelCount, new_config, nil)
+}
+
+/*
+Creates a new effect as New does, with the settings specific to its type applied before it's first saved.
+If they can't be applied, eg. a script which errors, they're kept as they are and the effect is stopped,
+so a stored script is never replaced by the default.
+*/
+func create(new_id, effect_type string, pixelCount int, new_config interface{}, extra map[string]interface{}) (effect *Effect, id string, err error) {
+	effect, err = newEffect(effect_type)
+	if err != nil {
+		return effect, id, err
+	}
+	if extra != nil {
+		if g, ok := effect.pixelGenerator.(extraConfigGenerator); !ok {
+			logger.Logger.WithField("context", "Effects").Errorf("Ignoring extra config of effect %s: %s effects have none", new_id, effect_type)
+		} else if err = g.setExtraConfig(effect, extra); err != nil {
+			logger.Logger.WithField("context", "Effects").Errorf("Effect %s is stopped, its extra config failed to load: %v", new_id, err)
+			g.holdExtraConfig(extra, err)
+			err = nil
+		}
+	}
+
+	if new_id != "" { // if an id is given, use it
+		// if effect already exists with that id, destroy it
+		id = new_id
+		if effectInstances.Has(id) {
+			Destroy(id)
+		}
+		effectInstances.Set(id, effect)
+	} else { // otherwise, generate a new id
+		id = effectInstances.Add(effect_type, effect)
+	}
+	logger.Logger.WithField("context", "Effects").Debugf("Creating %s effect with id %s", effect_type, id)
+
+	// initialise the new effect with its id and config
+	effect.initialize(id, pixelCount)
+	// Set effect's config to defaults
+	if err = defaults.Set(&effect.Config); err != nil {
+		Destroy(id)
+		return effect, id, err
+	}
+	// update with any given config
+	if err = effect.UpdateBaseConfig(new_config); err != nil {
+		logger.Logger.WithField("context", "Effects").Warnf("Effect %s created with invalid config - aborting", id)
+		Destroy(id)
+		return effect, id, err
+	}
+	logger.Logger.WithField("context", "Effects").Infof("Created effect with id %s", id)
+	return effect, id, err
+}
+
+/*
+Creates an effect which is not registered, saved to config, or announced by events.
+Useful for effects shown temporarily, eg. idle scenes during silence.
+Call Release on the effect when done with it.
+*/
+func NewTransient(id, effect_type string, pixelCount int, new_config interface{}) (effect *Effect, err error) {
+	effect, err = newEffect(effect_type)
+	if err != nil {
+		return effect, err
+	}
+	effect.transient = true
+	effect.initialize(id, pixelCount)
+	if err = defaults.Set(&effect.Config); err != nil {
+		return effect, err
+	}
+	if err = effect.UpdateBaseConfig(new_config); err != nil {
+		effect.Release()
+		return effect, err
+	}
+	logger.Logger.WithField("context", "Effects").Debugf("Created transient %s effect with id %s", effect_type, id)
+	return effect, err
+}
+
+// Creates an effect of the given type, without an id or config
+func newEffect(effect_type string) (effect *Effect, err error) {
 	switch effect_type {
 	case "energy":
 		effect = &Effect{
@@ -168,52 +262,34 @@ func New(new_id, effect_type string, pixelCount int, new_config interface{}) (ef
 		effect = &Effect{
 			pixelGenerator: &Scroll{},
 		}
-	default:
-		return effect, id, fmt.Errorf("'%s' is not a known effect type. Has it been registered in effects.go?", effect_type)
-	}
-	effect.Type = effect_type
-
-	if new_id != "" { // if an id is given, use it
-		// if effect already exists with that id, destroy it
-		id = new_id
-		if _, exists := effectInstances[id]; exists {
-			Destroy(id)
+	case "stereo":
+		effect = &Effect{
+			pixelGenerator: &Stereo{},
 		}
-		effectInstances[id] = effect
-	} else { // otherwise, generate a new id
-		for i := 0; ; i++ {
-			id = effect_type + strconv.Itoa(i)
-			_, exists := effectInstances[id]
-			if !exists {
-				effectInstances[id] = effect
-				break
-			}
+	case "dmx":
+		effect = &Effect{
+			pixelGenerator: newDmx(),
 		}
+	case "script":
+		script := &Script{}
+		if err = script.load(defaultScript); err != nil {
+			return effect, err
+		}
+		effect = &Effect{
+			pixelGenerator: script,
+		}
+	default:
+		return effect, fmt.Errorf("'%s' is not a known effect type. Has it been registered in effects.go?", effect_type)
 	}
-	logger.Logger.WithField("context", "Effects").Debugf("Creating %s effect with id %s", effect_type, id)
-
-	// initialise the new effect with its id and config
-	effect.initialize(id, pixelCount)
-	// Set effect's config to defaults
-	if err = defaults.Set(&effect.Config); err != nil {
-		Destroy(id)
-		return effect, id, err
-	}
-	// update with any given config
-	if err = effect.UpdateBaseConfig(new_config); err != nil {
-		logger.Logger.WithField("context", "Effects").Warnf("Effect %s created with invalid config - aborting", id)
-		Destroy(id)
-		return effect, id, err
-	}
-	logger.Logger.WithField("context", "Effects").Infof("Created effect with id %s", id)
-	return effect, id, err
+	effect.Type = effect_type
+	return effect, nil
 }
 
 /*
 Nothing to modify below here =====================
 */
 
-var effectInstances = make(map[string]*Effect)
+var effectInstances = util.NewRegistry[*Effect]()
 var globalConfig = BaseEffectConfig{}
 var validate *validator.Validate = validator.New()
 
@@ -226,16 +302,25 @@ func init() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	err = validate.RegisterValidation("audio_source", validateAudioSource)
+	if err != nil {
+		log.Fatal(err)
+	}
 	// set global effect settings to default values
 	if err = defaults.Set(&globalConfig); err != nil {
 		log.Fatal(err)
 	}
 	// apply stored global config
 	mapstructure.Decode(config.GetEffectsGlobal(), &globalConfig)
-	// validate global effect settings
+	// validate global effect settings, falling back to defaults if they're invalid
 	if err = validate.Struct(&globalConfig); err != nil {
-		log.Fatal(err)
+		config.QuarantineGlobalEffects(err)
+		globalConfig = BaseEffectConfig{}
+		if err = defaults.Set(&globalConfig); err != nil {
+			log.Fatal(err)
+		}
 	}
+	config.RegisterValidator(config.Effect, validateEntry)
 }
 
 type TransitionConfig struct {
@@ -253,6 +338,23 @@ func validateColor(fl validator.FieldLevel) bool {
 	return err == nil
 }
 
+func validateAudioSource(fl validator.FieldLevel) bool {
+	id := fl.Field().String()
+	if id == audio.DefaultSource {
+		return true
+	}
+	if _, exists := config.GetAudioSources()[id]; exists {
+		return true
+	}
+	// sources which aren't saved to config, eg. replays
+	for _, analyzerID := range audio.GetAnalyzerIDs() {
+		if id == analyzerID {
+			return true
+		}
+	}
+	return false
+}
+
 /*
 Updates the global effect settings. Config can be given
 as BaseEffectConfig, map[string]interface{}, or raw json
@@ -276,23 +378,25 @@ func SetGlobalSettings(c map[string]interface{}) (err error) {
 		}
 	}
 	// knowing that it's valid, pass it on to all the effects
-	for _, e := range effectInstances {
+	for _, e := range effectInstances.Values() {
 		// we'll do this manually rather than calling updateBaseConfig to avoid unnecessary config saves and validation
 		// update effect configs incrementally with global config settings
+		e.mu.Lock()
 		eConfig := e.Config
 		err = mapstructure.Decode(&c, &eConfig)
 		if err != nil {
+			e.mu.Unlock()
 			return err
 		}
 		e.updateStoredProperties(eConfig)
 		e.Config = eConfig
+		e.mu.Unlock()
 		// manually invoke event
-		event.Invoke(event.EffectUpdate,
-			map[string]interface{}{
-				"id":          e.ID,
-				"type":        e.Type,
-				"base_config": eConfig,
-			})
+		event.Invoke(event.EffectUpdateData{
+			ID:         e.ID,
+			Type:       e.Type,
+			BaseConfig: eConfig,
+		})
 	}
 
 	// save to config
@@ -302,16 +406,20 @@ func SetGlobalSettings(c map[string]interface{}) (err error) {
 	}
 	globalConfig = newConfig
 	// invoke event
-	event.Invoke(event.GlobalEffectUpdate,
-		map[string]interface{}{
-			"config": c,
-		})
+	event.Invoke(event.GlobalEffectUpdateData{
+		Config: c,
+	})
 	return err
 }
 
+// Get the global effect settings
+func GetGlobalSettings() BaseEffectConfig {
+	return globalConfig
+}
+
 // Get an existing pixel generator instance by its unique id
 func Get(id string) (*Effect, error) {
-	if inst, exists := effectInstances[id]; exists {
+	if inst, exists := effectInstances.Get(id); exists {
 		return inst, nil
 	} else {
 		return inst, fmt.Errorf("cannot retrieve effect of id: %s", id)
@@ -320,23 +428,18 @@ func Get(id string) (*Effect, error) {
 
 // Kill an effect instance
 func Destroy(id string) {
-	audio.Analyzer.DeleteMelbank(id)
+	audio.DeleteMelbanks(id)
 	config.DeleteEntry(config.Effect, id)
-	delete(effectInstances, id)
+	effectInstances.Delete(id)
 	logger.Logger.WithField("context", "Effects").Infof("Deleted effect with id %s", id)
 	// invoke event
-	event.Invoke(event.EffectDelete,
-		map[string]interface{}{
-			"id": id,
-		})
+	event.Invoke(event.EffectDeleteData{
+		ID: id,
+	})
 }
 
 func GetIDs() []string {
-	ids := []string{}
-	for id := range effectInstances {
-		ids = append(ids, id)
-	}
-	return ids
+	return effectInstances.IDs()
 }
 
 // Generate a map schema for all effects
@@ -346,6 +449,10 @@ func Schema() (schema map[string]interface{}, err error) {
 	if err != nil {
 		return schema, err
 	}
+	schema["modulator"], err = util.CreateSchema(reflect.TypeOf((*ModulatorConfig)(nil)).Elem())
+	if err != nil {
+		return schema, err
+	}
 	types := make(map[string]interface{})
 	mapstructure.Decode(&effectTypes, &types)
 	schema["types"] = types
@@ -361,13 +468,55 @@ func JsonSchema() (jsonSchema []byte, err error) {
 	return jsonSchema, err
 }
 
+/*
+Loads the effects saved in config. Effects which fail to load
+are quarantined, and the rest carry on loading.
+*/
 func LoadFromConfig() error {
 	storedEffects := config.GetEffects()
 	for id, entry := range storedEffects {
-		_, _, err := New(id, entry.Type, 100, entry.BaseConfig)
+		// a broken script shouldn't stop the rest of the effects loading, so it's loaded stopped
+		e, _, err := create(id, entry.Type, 100, entry.BaseConfig, entry.ExtraConfig)
 		if err != nil {
-			return err
+			config.QuarantineEntry(config.Effect, id, entry, err)
+			continue
+		}
+		if len(entry.Modulators) > 0 {
+			if err = e.SetModulators(entry.Modulators); err != nil {
+				Destroy(id)
+				config.QuarantineEntry(config.Effect, id, entry, err)
+			}
 		}
 	}
 	return nil
 }
+
+// Checks a saved effect entry would load
+func validateEntry(entry interface{}) error {
+	c, ok := entry.(config.EffectEntry)
+	if !ok {
+		return fmt.Errorf("invalid effect entry type: %T", entry)
+	}
+	e, err := newEffect(c.Type)
+	if err != nil {
+		return err
+	}
+	e.Config = globalConfig
+	if err = defaults.Set(&e.Config); err != nil {
+		return err
+	}
+	if _, err = e.decodeConfig(c.BaseConfig); err != nil {
+		return err
+	}
+	if c.ExtraConfig != nil {
+		g, ok := e.pixelGenerator.(extraConfigGenerator)
+		if !ok {
+			return fmt.Errorf("%s effects have no extra config", c.Type)
+		}
+		if err = g.checkExtraConfig(c.ExtraConfig); err != nil {
+			return err
+		}
+	}
+	_, err = newModulators(c.Modulators)
+	return err
+}
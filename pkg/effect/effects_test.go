@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/LedFx/ledfx/pkg/color"
+	"github.com/LedFx/ledfx/pkg/config"
 )
 
 func BenchmarkEffects(t *testing.B) {
@@ -20,7 +21,7 @@ func BenchmarkEffects(t *testing.B) {
 			// Run the effect on some pixels
 			t.Run(fmt.Sprintf("%s %d pixels", eType, len(p)), func(t *testing.B) {
 				for i := 0; i < t.N; i++ {
-					effect.Render(p)
+					effect.Render(testPixelGroup(len(p)))
 				}
 			})
 			Destroy(effect.GetID())
@@ -63,8 +64,8 @@ func TestEffects(t *testing.T) {
 				t.Error(err)
 			}
 			for _, c := range testConfigs {
-				err = effect.UpdateBaseConfig(c) // Assign the config
-				effect.Render(p)                 // Run it on some pixels
+				err = effect.UpdateBaseConfig(c)      // Assign the config
+				effect.Render(testPixelGroup(len(p))) // Run it on some pixels
 				if err != nil {
 					t.Errorf("Failed on test config: %v", c)
 				}
@@ -74,6 +75,23 @@ func TestEffects(t *testing.T) {
 	}
 }
 
+func TestValidateEntry(t *testing.T) {
+	valid := config.EffectEntry{Type: "palette", BaseConfig: map[string]interface{}{"brightness": 0.5}}
+	if err := validateEntry(valid); err != nil {
+		t.Errorf("Expected a valid entry, got %v", err)
+	}
+	invalid := map[string]config.EffectEntry{
+		"unknown type":      {Type: "sparkles"},
+		"invalid config":    {Type: "palette", BaseConfig: map[string]interface{}{"brightness": 2.0}},
+		"invalid modulator": {Type: "palette", Modulators: []map[string]interface{}{{"field": "sparkle"}}},
+	}
+	for name, entry := range invalid {
+		if err := validateEntry(entry); err == nil {
+			t.Errorf("Expected an error for an entry with an %s", name)
+		}
+	}
+}
+
 // cases := []struct {
 // 	q string
 // 	a Color
@@ -10,11 +10,11 @@ import (
 type Energy struct{}
 
 // Apply new pixels to an existing pixel array.
-func (e *Energy) assembleFrame(base *Effect, pg *render.PixelGroup) {
+func (e *Energy) assembleFrame(base *Effect, c BaseEffectConfig, pg *render.PixelGroup) {
 	// operate on the largest pixel output in group, then clone to others
 	p := pg.Group[pg.Largest]
 
-	mel, err := audio.Analyzer.GetMelbank(base.ID)
+	mel, err := audio.GetAnalyzer(c.Source).GetMelbank(base.ID)
 	if err != nil {
 		logger.Logger.WithField("context", "Effect Energy").Error(err)
 		return
@@ -8,7 +8,7 @@ import (
 type Fade struct{}
 
 // Apply new pixels to an existing pixel array.
-func (e *Fade) assembleFrame(base *Effect, pg *render.PixelGroup) {
+func (e *Fade) assembleFrame(base *Effect, c BaseEffectConfig, pg *render.PixelGroup) {
 	// operate on the largest pixel output in group, then clone to others
 	p := pg.Group[pg.Largest]
 	for i := 0; i < len(p); i++ {
@@ -10,12 +10,12 @@ import (
 type Maelstrom struct{}
 
 // Apply new pixels to an existing pixel array.
-func (e *Maelstrom) assembleFrame(base *Effect, pg *render.PixelGroup) {
+func (e *Maelstrom) assembleFrame(base *Effect, c BaseEffectConfig, pg *render.PixelGroup) {
 	// operate on the largest pixel output in group, then clone to others
 	p := pg.Group[pg.Largest]
 
-	volume := audio.Analyzer.Vol.Volume
-	timestep := audio.Analyzer.Vol.Timestep
+	volume := audio.GetAnalyzer(c.Source).Vol.Volume
+	timestep := audio.GetAnalyzer(c.Source).Vol.Timestep
 
 	for i := 0; i < len(p); i++ {
 		fi := float64(i)
@@ -0,0 +1,216 @@
+package effect
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"time"
+
+	"github.com/LedFx/ledfx/pkg/audio"
+
+	"github.com/creasty/defaults"
+	"github.com/go-playground/validator/v10"
+	"github.com/mitchellh/mapstructure"
+)
+
+/*
+Modulators drive an effect setting from an LFO or an audio feature. They are evaluated every frame
+while rendering, overriding the setting for that frame without changing or saving the effect's config.
+The source gives a value 0-1, which is shaped by the curve and scaled to the range min-max.
+Min can be above max to invert the source. When several modulators drive the same setting, the last wins.
+*/
+type ModulatorConfig struct {
+	Field     string  `mapstructure:"field" json:"field" description:"Effect setting to modulate" default:"brightness" validate:"oneof=intensity brightness saturation blur decay hue_shift background_brightness"`
+	Source    string  `mapstructure:"source" json:"source" description:"LFO waveform or audio feature driving the setting" default:"sine" validate:"oneof=sine saw square random volume lows mids highs onset"`
+	Rate      float64 `mapstructure:"rate" json:"rate" description:"LFO cycles per second" default:"0.5" validate:"gte=0,lte=20"`
+	Phase     float64 `mapstructure:"phase" json:"phase" description:"Offset into the LFO cycle" default:"0" validate:"gte=0,lte=1"`
+	Min       float64 `mapstructure:"min" json:"min" description:"Setting value when the source is lowest" default:"0" validate:"gte=0,lte=1"`
+	Max       float64 `mapstructure:"max" json:"max" description:"Setting value when the source is highest" default:"1" validate:"gte=0,lte=1"`
+	Curve     string  `mapstructure:"curve" json:"curve" description:"Response curve from the source to the range" default:"linear" validate:"oneof=linear ease_in ease_out smooth"`
+	Smoothing float64 `mapstructure:"smoothing" json:"smoothing" description:"Smooths sudden changes in the source" default:"0" validate:"gte=0,lte=1"`
+}
+
+type modulator struct {
+	config ModulatorConfig
+	field  int     // index of the field in BaseEffectConfig
+	value  float64 // smoothed source value
+	cycle  int64   // cycle the random LFO is holding a value for
+	held   float64 // value held by the random LFO
+}
+
+// onsets light up the onset source, which then falls away over this time
+const onsetDecay = 200 * time.Millisecond
+
+// indexes of the BaseEffectConfig fields by their mapstructure key
+var configFields = map[string]int{}
+
+func init() {
+	t := reflect.TypeOf(BaseEffectConfig{})
+	for i := 0; i < t.NumField(); i++ {
+		configFields[t.Field(i).Tag.Get("mapstructure")] = i
+	}
+}
+
+/*
+Replaces the modulators of the effect. Modulators can be given
+as []ModulatorConfig, []map[string]interface{}, or raw json.
+Values missing from a modulator are set to defaults.
+*/
+func (e *Effect) SetModulators(c interface{}) error {
+	modulators, err := newModulators(c)
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.modulators = modulators
+	if e.transient {
+		return nil
+	}
+	_, err = e.saveEntry()
+	return err
+}
+
+// decodes and validates modulators
+func newModulators(c interface{}) (modulators []*modulator, err error) {
+	var configs []ModulatorConfig
+	switch t := c.(type) {
+	case []ModulatorConfig:
+		configs = t
+	case []map[string]interface{}:
+		configs, err = decodeModulators(t)
+	case []byte:
+		maps := []map[string]interface{}{}
+		if err = json.Unmarshal(t, &maps); err == nil {
+			configs, err = decodeModulators(maps)
+		}
+	case nil:
+	default:
+		err = fmt.Errorf("invalid modulators type: %T", t)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	modulators = make([]*modulator, len(configs))
+	for i, mc := range configs {
+		if errs, ok := validate.Struct(&mc).(validator.ValidationErrors); ok && errs != nil {
+			errString := fmt.Sprintf("Modulator %d Validation Errors: ", i)
+			for _, err := range errs {
+				errString += fmt.Sprintf("Field %s with value %v; ", err.Field(), err.Value())
+			}
+			return nil, errors.New(errString)
+		}
+		modulators[i] = &modulator{config: mc, field: configFields[mc.Field]}
+	}
+	return modulators, nil
+}
+
+// Get the configs of the effect's modulators
+func (e *Effect) GetModulators() []ModulatorConfig {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	configs := make([]ModulatorConfig, len(e.modulators))
+	for i, m := range e.modulators {
+		configs[i] = m.config
+	}
+	return configs
+}
+
+func decodeModulators(maps []map[string]interface{}) ([]ModulatorConfig, error) {
+	configs := make([]ModulatorConfig, len(maps))
+	for i, m := range maps {
+		if err := defaults.Set(&configs[i]); err != nil {
+			return nil, err
+		}
+		if err := mapstructure.Decode(m, &configs[i]); err != nil {
+			return nil, fmt.Errorf("modulator %d: %w", i, err)
+		}
+	}
+	return configs, nil
+}
+
+// the modulators as saved in the config store
+func (e *Effect) modulatorMaps() []map[string]interface{} {
+	if len(e.modulators) == 0 {
+		return nil
+	}
+	maps := make([]map[string]interface{}, len(e.modulators))
+	for i, m := range e.modulators {
+		maps[i] = map[string]interface{}{}
+		mapstructure.Decode(m.config, &maps[i])
+	}
+	return maps
+}
+
+// returns the config with modulated settings for this frame
+func (e *Effect) modulate(c BaseEffectConfig) BaseEffectConfig {
+	v := reflect.ValueOf(&c).Elem()
+	for _, m := range e.modulators {
+		x := m.source(e, c.Source)
+		// smoothing is the fraction kept every 1/30s, so it feels the same at any framerate
+		if m.config.Smoothing > 0 {
+			keep := math.Pow(m.config.Smoothing, 30*e.deltaPrevFrame.Seconds())
+			x = m.value*keep + x*(1-keep)
+		}
+		m.value = x
+		v.Field(m.field).SetFloat(m.config.Min + (m.config.Max-m.config.Min)*m.curve(x))
+	}
+	return c
+}
+
+// the source value, 0-1
+func (m *modulator) source(e *Effect, audioSource string) float64 {
+	cycle := e.deltaStart.Seconds()*m.config.Rate + m.config.Phase
+	var x float64
+	switch m.config.Source {
+	case "sine":
+		x = e.sin(cycle)
+	case "saw":
+		_, x = math.Modf(cycle)
+	case "square":
+		if _, frac := math.Modf(cycle); frac < 0.5 {
+			x = 1
+		}
+	case "random":
+		// sample and hold a new value each cycle
+		if n := int64(math.Floor(cycle)); n != m.cycle || m.held == 0 {
+			m.cycle = n
+			m.held = rand.Float64()
+		}
+		x = m.held
+	case "volume":
+		x = audio.GetAnalyzer(audioSource).Vol.Volume
+	case "lows", "mids", "highs":
+		mel, err := audio.GetAnalyzer(audioSource).GetMelbank(e.ID)
+		if err != nil {
+			return 0
+		}
+		switch m.config.Source {
+		case "lows":
+			x = mel.LowsAmplitude()
+		case "mids":
+			x = mel.MidsAmplitude()
+		default:
+			x = mel.HighAmplitude()
+		}
+	case "onset":
+		x = 1 - float64(time.Since(audio.GetAnalyzer(audioSource).RecentOnset))/float64(onsetDecay)
+	}
+	return math.Max(0, math.Min(1, x))
+}
+
+func (m *modulator) curve(x float64) float64 {
+	switch m.config.Curve {
+	case "ease_in":
+		return x * x
+	case "ease_out":
+		return 1 - (1-x)*(1-x)
+	case "smooth":
+		return x * x * (3 - 2*x)
+	}
+	return x
+}
@@ -0,0 +1,117 @@
+package effect
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/LedFx/ledfx/pkg/config"
+)
+
+func TestModulatorSources(t *testing.T) {
+	e := &Effect{}
+	e.deltaStart = 250 * time.Millisecond
+	lfo := func(source string) float64 {
+		m := &modulator{config: ModulatorConfig{Source: source, Rate: 1}}
+		return m.source(e, "default")
+	}
+	if v := lfo("sine"); math.Abs(v-1) > 1e-9 {
+		t.Errorf("Expected a sine at a quarter cycle to peak, got %v", v)
+	}
+	if v := lfo("saw"); v != 0.25 {
+		t.Errorf("Expected a saw at a quarter cycle to be 0.25, got %v", v)
+	}
+	if v := lfo("square"); v != 1 {
+		t.Errorf("Expected a square in the first half of its cycle to be high, got %v", v)
+	}
+	m := &modulator{config: ModulatorConfig{Source: "random", Rate: 1}}
+	held := m.source(e, "default")
+	e.deltaStart = 750 * time.Millisecond
+	if v := m.source(e, "default"); v != held {
+		t.Errorf("Expected random to hold its value for the cycle, got %v then %v", held, v)
+	}
+
+	for curve, want := range map[string]float64{"linear": 0.5, "ease_in": 0.25, "ease_out": 0.75, "smooth": 0.5} {
+		m := &modulator{config: ModulatorConfig{Curve: curve}}
+		if v := m.curve(0.5); v != want {
+			t.Errorf("Expected %s curve at 0.5 to be %v, got %v", curve, want, v)
+		}
+	}
+}
+
+func TestModulators(t *testing.T) {
+	defer config.DisableSaving()()
+	e, _, err := New("modulator_test", "palette", 10, map[string]interface{}{"brightness": 0.8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Destroy("modulator_test")
+
+	if err = e.SetModulators([]map[string]interface{}{{"field": "sparkle"}}); err == nil {
+		t.Error("Expected an error modulating an unknown field")
+	}
+	if err = e.SetModulators([]map[string]interface{}{{"field": "brightness", "source": "vibes"}}); err == nil {
+		t.Error("Expected an error for an unknown source")
+	}
+	// a square held high sets the field to max, a saw at the start of its cycle sets it to min
+	err = e.SetModulators([]byte(`[
+		{"field": "hue_shift", "source": "square", "rate": 0, "min": 0.1, "max": 0.6},
+		{"field": "saturation", "source": "saw", "rate": 0, "min": 0.3, "max": 0.9}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mods := e.GetModulators(); len(mods) != 2 || mods[0].Curve != "linear" || mods[1].Phase != 0 {
+		t.Errorf("Expected modulators with defaults, got %+v", mods)
+	}
+	entry, _ := config.GetEffect("modulator_test")
+	if len(entry.Modulators) != 2 || entry.Modulators[0]["field"] != "hue_shift" {
+		t.Errorf("Expected the modulators to be saved, got %v", entry.Modulators)
+	}
+
+	modulated := e.modulate(e.Config)
+	if modulated.HueShift != 0.6 || modulated.Saturation != 0.3 || modulated.Brightness != 0.8 {
+		t.Errorf("Unexpected modulated config %+v", modulated)
+	}
+
+	// rendering applies modulation for the frame, but doesn't keep or save it
+	e.Render(testPixelGroup(10))
+	if e.Config.HueShift != 0 || e.Config.Saturation != 1 {
+		t.Errorf("Modulation leaked into the config: %+v", e.Config)
+	}
+	entry, _ = config.GetEffect("modulator_test")
+	if entry.BaseConfig["hue_shift"] != 0.0 {
+		t.Errorf("Modulation was saved: %v", entry.BaseConfig)
+	}
+
+	if err = e.SetModulators(nil); err != nil || len(e.GetModulators()) != 0 {
+		t.Errorf("Expected modulators to be cleared, got %v", err)
+	}
+}
+
+// run with -race: config is applied from other goroutines, eg. the sequencer, while the effect renders
+func TestApplyWhileRendering(t *testing.T) {
+	defer config.DisableSaving()()
+	e, _, err := New("apply_test", "palette", 10, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Destroy("apply_test")
+	if err = e.SetModulators([]map[string]interface{}{{"field": "blur", "source": "saw", "rate": 20}}); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			e.ApplyBaseConfig(map[string]interface{}{"brightness": float64(i) / 100, "blur": float64(i%2) / 2})
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		e.Render(testPixelGroup(10))
+	}
+	<-done
+	if c := e.GetConfig(); c.Brightness != 0.99 {
+		t.Errorf("Expected the last applied config, got brightness %v", c.Brightness)
+	}
+}
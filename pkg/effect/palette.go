@@ -8,7 +8,7 @@ import (
 type Palette struct{}
 
 // Apply new pixels to an existing pixel array.
-func (e *Palette) assembleFrame(base *Effect, pg *render.PixelGroup) {
+func (e *Palette) assembleFrame(base *Effect, c BaseEffectConfig, pg *render.PixelGroup) {
 	// operate on the largest pixel output in group, then clone to others
 	p := pg.Group[pg.Largest]
 
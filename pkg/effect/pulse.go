@@ -10,11 +10,11 @@ import (
 type Pulse struct{}
 
 // Apply new pixels to an existing pixel array.
-func (e *Pulse) assembleFrame(base *Effect, pg *render.PixelGroup) {
+func (e *Pulse) assembleFrame(base *Effect, c BaseEffectConfig, pg *render.PixelGroup) {
 	// operate on the largest pixel output in group, then clone to others
 	p := pg.Group[pg.Largest]
 
-	if 1-math.Mod(base.deltaStart.Seconds(), 5.1-base.Config.Intensity*5) < 0.1 {
+	if 1-math.Mod(base.deltaStart.Seconds(), 5.1-c.Intensity*5) < 0.1 {
 		for i := range p {
 			p[i] = color.Full
 			p[i][0] = float64(i) / base.pixelScaler
@@ -0,0 +1,221 @@
+package effect
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/LedFx/ledfx/pkg/audio"
+	"github.com/LedFx/ledfx/pkg/color"
+	"github.com/LedFx/ledfx/pkg/logger"
+	"github.com/LedFx/ledfx/pkg/render"
+
+	"github.com/dop251/goja"
+)
+
+/*
+Script effects run user JavaScript, much like PixelBlaze patterns. The script must define
+render(index, x), which is called for every pixel each frame and sets its color with hsv(h, s, v).
+x is the position of the pixel, 0-1. It may also define beforeRender(delta), called once per frame.
+
+Each frame the script can read these globals:
+
+	time        seconds since the effect started
+	delta       seconds since the previous frame
+	pixelCount  number of pixels
+	intensity   the effect's intensity setting, 0-1
+	volume      audio volume, 0-1
+	lows, mids, highs   melbank amplitudes, 0-1
+	melbank     the melbank, an array of 0-1 values
+	onset       true if there was an onset since the previous frame
+
+Scripts are sandboxed: there is no file, network or module access, the call stack is limited,
+and a frame which takes longer than scriptFrameBudget is interrupted. A script which errors while
+running is stopped until it is updated, and the error is reported over the API.
+*/
+type Script struct {
+	mu           sync.Mutex
+	source       string
+	vm           *goja.Runtime
+	render       goja.Callable
+	beforeRender goja.Callable
+	err          error        // the latest error, which stops the script
+	pixels       color.Pixels // pixels being rendered, set by hsv()
+	index        int          // pixel being rendered
+	melbank      []float64
+}
+
+const scriptFrameBudget = 20 * time.Millisecond
+const scriptMaxCallStack = 256
+
+const defaultScript = `// a scrolling rainbow, brighter with the bass
+function render(index, x) {
+	hsv(x + time * 0.1, 1, 0.3 + 0.7 * lows)
+}
+`
+
+func (e *Script) extraConfig() map[string]interface{} {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return map[string]interface{}{"script": e.source}
+}
+
+func (e *Script) setExtraConfig(base *Effect, c map[string]interface{}) error {
+	source, ok := c["script"].(string)
+	if !ok {
+		return errors.New("script effects need a script")
+	}
+	return e.load(source)
+}
+
+// compiles the script without running it
+func (e *Script) checkExtraConfig(c map[string]interface{}) error {
+	source, ok := c["script"].(string)
+	if !ok {
+		return errors.New("script effects need a script")
+	}
+	if _, err := goja.Compile("script", source, true); err != nil {
+		return fmt.Errorf("script compile error: %w", err)
+	}
+	return nil
+}
+
+// keeps a script which failed to load, stopped, so it's saved unchanged and can be fixed
+func (e *Script) holdExtraConfig(c map[string]interface{}, err error) {
+	source, _ := c["script"].(string)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.source = source
+	e.vm = nil
+	e.render = nil
+	e.beforeRender = nil
+	e.err = err
+}
+
+// the script and the error which stopped it, if any
+func (e *Script) status() (source string, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.source, e.err
+}
+
+// compiles and runs a script, replacing the running script if it succeeds
+func (e *Script) load(source string) error {
+	program, err := goja.Compile("script", source, true)
+	if err != nil {
+		return fmt.Errorf("script compile error: %w", err)
+	}
+	vm := goja.New()
+	vm.SetMaxCallStackSize(scriptMaxCallStack)
+	// the top level of the script has no pixel to set, and no frame yet
+	vm.Set("hsv", func(h, s, v float64) {})
+	for _, name := range []string{"time", "delta", "pixelCount", "intensity", "volume", "lows", "mids", "highs"} {
+		vm.Set(name, 0)
+	}
+	vm.Set("melbank", []float64{})
+	vm.Set("onset", false)
+	if err = runWithBudget(vm, func() error {
+		_, err := vm.RunProgram(program)
+		return err
+	}); err != nil {
+		return fmt.Errorf("script error: %w", err)
+	}
+	renderFn, ok := goja.AssertFunction(vm.Get("render"))
+	if !ok {
+		return errors.New("script must define a render(index, x) function")
+	}
+	beforeRenderFn, _ := goja.AssertFunction(vm.Get("beforeRender"))
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	vm.Set("hsv", e.hsv)
+	e.source = source
+	e.vm = vm
+	e.render = renderFn
+	e.beforeRender = beforeRenderFn
+	e.err = nil
+	return nil
+}
+
+// sets the color of the pixel being rendered
+func (e *Script) hsv(h, s, v float64) {
+	if e.index < 0 || e.index >= len(e.pixels) {
+		return
+	}
+	e.pixels[e.index] = color.Color{h - math.Floor(h), clamp01(s), clamp01(v)}
+}
+
+func clamp01(x float64) float64 {
+	return math.Max(0, math.Min(1, x))
+}
+
+// interrupts the vm if fn takes longer than the frame budget
+func runWithBudget(vm *goja.Runtime, fn func() error) error {
+	interrupted := make(chan struct{})
+	timer := time.AfterFunc(scriptFrameBudget, func() {
+		vm.Interrupt(fmt.Sprintf("script took longer than %v", scriptFrameBudget))
+		close(interrupted)
+	})
+	err := fn()
+	// if the timer fired, its interrupt must land before it's cleared, or it'd stop the next frame
+	if !timer.Stop() {
+		<-interrupted
+	}
+	vm.ClearInterrupt()
+	return err
+}
+
+func (e *Script) assembleFrame(base *Effect, c BaseEffectConfig, pg *render.PixelGroup) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.vm == nil || e.err != nil {
+		return
+	}
+
+	// operate on the largest pixel output in group, then clone to others
+	p := pg.Group[pg.Largest]
+	e.setGlobals(base, c, len(p))
+	e.pixels = p
+	e.err = runWithBudget(e.vm, func() error {
+		if e.beforeRender != nil {
+			if _, err := e.beforeRender(goja.Undefined(), e.vm.ToValue(base.deltaPrevFrame.Seconds())); err != nil {
+				return err
+			}
+		}
+		for i := range p {
+			e.index = i
+			if _, err := e.render(goja.Undefined(), e.vm.ToValue(i), e.vm.ToValue(float64(i)/math.Max(1, base.pixelScaler))); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	e.pixels = nil
+	if e.err != nil {
+		logger.Logger.WithField("context", "Effect Script").Warnf("Stopped script of effect %s: %v", base.ID, e.err)
+		return
+	}
+	pg.CloneToAll(pg.Largest)
+}
+
+func (e *Script) setGlobals(base *Effect, c BaseEffectConfig, pixelCount int) {
+	analyzer := audio.GetAnalyzer(c.Source)
+	var lows, mids, highs float64
+	e.melbank = e.melbank[:0]
+	if mel, err := analyzer.GetMelbank(base.ID); err == nil {
+		lows, mids, highs = mel.LowsAmplitude(), mel.MidsAmplitude(), mel.HighAmplitude()
+		e.melbank = append(e.melbank, mel.Data...)
+	}
+	e.vm.Set("time", base.deltaStart.Seconds())
+	e.vm.Set("delta", base.deltaPrevFrame.Seconds())
+	e.vm.Set("pixelCount", pixelCount)
+	e.vm.Set("intensity", c.Intensity)
+	e.vm.Set("volume", analyzer.Vol.Volume)
+	e.vm.Set("lows", lows)
+	e.vm.Set("mids", mids)
+	e.vm.Set("highs", highs)
+	e.vm.Set("melbank", e.melbank)
+	e.vm.Set("onset", analyzer.RecentOnset.After(base.prevFrameTime.Add(-base.deltaPrevFrame)))
+}
@@ -0,0 +1,143 @@
+package effect
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LedFx/ledfx/pkg/color"
+	"github.com/LedFx/ledfx/pkg/config"
+	"github.com/LedFx/ledfx/pkg/render"
+
+	"github.com/dop251/goja"
+)
+
+func testPixelGroup(n int) *render.PixelGroup {
+	return &render.PixelGroup{
+		Group:      map[string]color.Pixels{"test": make(color.Pixels, n)},
+		Order:      []string{"test"},
+		Largest:    "test",
+		Smallest:   "test",
+		LargestLen: n,
+		TotalLen:   n,
+	}
+}
+
+func TestScript(t *testing.T) {
+	defer config.DisableSaving()()
+	e, _, err := New("script_test", "script", 4, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Destroy("script_test")
+	script := e.pixelGenerator.(*Script)
+	if source, err := script.status(); source != defaultScript || err != nil {
+		t.Errorf("Expected the default script to be running, got %v", err)
+	}
+
+	if err = e.UpdateExtraConfig(map[string]interface{}{"script": "function render(index, x) {"}); err == nil || !strings.Contains(err.Error(), "compile") {
+		t.Errorf("Expected a compile error, got %v", err)
+	}
+	if err = e.UpdateExtraConfig(map[string]interface{}{"script": "var x = 1"}); err == nil {
+		t.Error("Expected an error for a script without a render function")
+	}
+	if err = e.UpdateExtraConfig(map[string]interface{}{"script": "while (true) {}"}); err == nil {
+		t.Error("Expected a script which never finishes loading to be interrupted")
+	}
+	if source, _ := script.status(); source != defaultScript {
+		t.Error("A broken script should not replace the running script")
+	}
+
+	source := `
+		var frames = 0
+		function beforeRender(delta) { frames++ }
+		function render(index, x) {
+			hsv(x + 1.25, index % 2, frames / 10 + pixelCount)
+		}`
+	if err = e.UpdateExtraConfig(map[string]interface{}{"script": source}); err != nil {
+		t.Fatal(err)
+	}
+	entry, _ := config.GetEffect("script_test")
+	if entry.ExtraConfig["script"] != source {
+		t.Errorf("Expected the script to be saved, got %v", entry.ExtraConfig)
+	}
+
+	pg := testPixelGroup(4)
+	script.assembleFrame(e, e.Config, pg)
+	p := pg.Group["test"]
+	if p[0] != (color.Color{0.25, 0, 1}) || p[3] != (color.Color{0.25, 1, 1}) {
+		t.Errorf("Unexpected pixels %v", p)
+	}
+	if _, err = script.status(); err != nil {
+		t.Fatal(err)
+	}
+
+	// runtime errors stop the script
+	if err = e.UpdateExtraConfig(map[string]interface{}{"script": "function render(index, x) { if (index == 2) { nope() } }"}); err != nil {
+		t.Fatal(err)
+	}
+	script.assembleFrame(e, e.Config, testPixelGroup(4))
+	if _, err = script.status(); err == nil || !strings.Contains(err.Error(), "nope") {
+		t.Errorf("Expected a runtime error, got %v", err)
+	}
+	if err = e.UpdateExtraConfig(map[string]interface{}{"script": "function render(index, x) { while (true) {} }"}); err != nil {
+		t.Fatal(err)
+	}
+	script.assembleFrame(e, e.Config, testPixelGroup(4))
+	if _, err = script.status(); err == nil || !strings.Contains(err.Error(), "longer than") {
+		t.Errorf("Expected an endless frame to be interrupted, got %v", err)
+	}
+
+	// the sandbox has no way out
+	if err = e.UpdateExtraConfig(map[string]interface{}{"script": "require('fs'); function render() {}"}); err == nil {
+		t.Error("Expected require to be unavailable")
+	}
+
+	if err = (&Effect{Type: "energy", pixelGenerator: &Energy{}}).UpdateExtraConfig(map[string]interface{}{"script": source}); err == nil {
+		t.Error("Expected an error setting extra config on an effect without any")
+	}
+}
+
+func TestLoadBrokenScript(t *testing.T) {
+	defer config.DisableSaving()()
+	// compiles, but never finishes loading
+	source := "while (true) {}\nfunction render(index, x) {}"
+	entry := config.EffectEntry{ID: "broken_script", Type: "script", ExtraConfig: map[string]interface{}{"script": source}}
+	if err := validateEntry(entry); err != nil {
+		t.Errorf("Expected a script which compiles to be valid, got %v", err)
+	}
+	e, _, err := create(entry.ID, entry.Type, 4, nil, entry.ExtraConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Destroy(entry.ID)
+	if s, err := e.pixelGenerator.(*Script).status(); s != source || err == nil {
+		t.Errorf("Expected the stored script to be kept and stopped, got %v", err)
+	}
+	saved, _ := config.GetEffect(entry.ID)
+	if saved.ExtraConfig["script"] != source {
+		t.Errorf("Expected the stored script to be saved unchanged, got %v", saved.ExtraConfig)
+	}
+
+	entry.ExtraConfig = map[string]interface{}{"script": "function render(index, x) {"}
+	if err := validateEntry(entry); err == nil || !strings.Contains(err.Error(), "compile") {
+		t.Errorf("Expected a compile error, got %v", err)
+	}
+}
+
+func TestBudgetInterruptCleared(t *testing.T) {
+	vm := goja.New()
+	// frames which end as the budget runs out must not leave an interrupt for the next frame
+	for i := 0; i < 20; i++ {
+		runWithBudget(vm, func() error {
+			time.Sleep(scriptFrameBudget)
+			return nil
+		})
+		if err := runWithBudget(vm, func() error {
+			_, err := vm.RunString("1 + 1")
+			return err
+		}); err != nil {
+			t.Fatalf("Expected the next frame to run, got %v", err)
+		}
+	}
+}
@@ -13,7 +13,7 @@ type Scroll struct {
 }
 
 // Apply new pixels to an existing pixel array.
-func (e *Scroll) assembleFrame(base *Effect, pg *render.PixelGroup) {
+func (e *Scroll) assembleFrame(base *Effect, c BaseEffectConfig, pg *render.PixelGroup) {
 	if !e.initialised {
 		e.scroller = make(color.Pixels, 1000)
 		e.initialised = true
@@ -22,19 +22,19 @@ func (e *Scroll) assembleFrame(base *Effect, pg *render.PixelGroup) {
 	// operate on the largest pixel output in group, then clone to others
 	p := pg.Group[pg.Largest]
 
-	mel, err := audio.Analyzer.GetMelbank(base.ID)
+	mel, err := audio.GetAnalyzer(c.Source).GetMelbank(base.ID)
 	if err != nil {
 		logger.Logger.WithField("context", "Effect Scroll").Error(err)
 		return
 	}
 
 	// make a new color based on the volume and frequency composition
-	value := audio.Analyzer.Vol.Timestep
+	value := audio.GetAnalyzer(c.Source).Vol.Timestep
 	hue := mel.LowsAmplitude() + mel.MidsAmplitude() + mel.HighAmplitude()
 	newCol := color.Color{hue, 1, value}
 
 	// rotate scroller array
-	shift := int(10*base.Config.Intensity + 1)
+	shift := int(10*c.Intensity + 1)
 	e.scroller = append(e.scroller[1000-shift:], e.scroller[:1000-shift]...)
 
 	for i := 0; i < shift; i++ {
@@ -0,0 +1,43 @@
+package effect
+
+import (
+	"math"
+
+	"github.com/LedFx/ledfx/pkg/audio"
+	"github.com/LedFx/ledfx/pkg/render"
+)
+
+type Stereo struct{}
+
+// Apply new pixels to an existing pixel array.
+// A band of color sits at the stereo balance and spreads with the stereo width.
+// Each side of the band is lit by its own audio channel.
+func (e *Stereo) assembleFrame(base *Effect, c BaseEffectConfig, pg *render.PixelGroup) {
+	// operate on the largest pixel output in group, then clone to others
+	p := pg.Group[pg.Largest]
+
+	analyzer := audio.GetAnalyzer(c.Source)
+	center := 0.5 + analyzer.Balance()/2
+	spread := 0.05 + analyzer.Width()*(0.45+c.Intensity/2)
+	leftVol := analyzer.ChannelVolume(audio.Left)
+	rightVol := analyzer.ChannelVolume(audio.Right)
+
+	for i := 0; i < len(p); i++ {
+		pos := float64(i) / base.pixelScaler
+		dist := pos - center
+		// fade out towards the edges of the band
+		v := 1 - math.Abs(dist)/spread
+		if v <= 0 {
+			continue
+		}
+		if dist < 0 {
+			v *= leftVol
+		} else {
+			v *= rightVol
+		}
+		p[i][0] = pos
+		p[i][1] = 1
+		p[i][2] = math.Max(p[i][2], v)
+	}
+	pg.CloneToAll(pg.Largest)
+}
@@ -11,11 +11,11 @@ import (
 type Strobe struct{}
 
 // Apply new pixels to an existing pixel array.
-func (e *Strobe) assembleFrame(base *Effect, pg *render.PixelGroup) {
+func (e *Strobe) assembleFrame(base *Effect, c BaseEffectConfig, pg *render.PixelGroup) {
 	// operate on the largest pixel output in group, then clone to others
 	p := pg.Group[pg.Largest]
 
-	mel, err := audio.Analyzer.GetMelbank(base.ID)
+	mel, err := audio.GetAnalyzer(c.Source).GetMelbank(base.ID)
 	if err != nil {
 		return
 	}
@@ -27,12 +27,12 @@ func (e *Strobe) assembleFrame(base *Effect, pg *render.PixelGroup) {
 	}
 
 	// if an onset has not happened since the last frame
-	if !audio.Analyzer.RecentOnset.After(base.prevFrameTime) {
+	if !audio.GetAnalyzer(c.Source).RecentOnset.After(base.prevFrameTime) {
 		return
 	}
 
 	// choose a random place to put the strobe on the strip
-	strobe_width := int(base.Config.Intensity * base.pixelScaler)
+	strobe_width := int(c.Intensity * base.pixelScaler)
 	for i := rand.Intn(len(p) - strobe_width); i < strobe_width; i++ {
 		p[i][1] = 0 // desaturate the colour to white
 		p[i][2] = 1 // set full brightness
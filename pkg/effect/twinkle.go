@@ -15,7 +15,7 @@ type Twinkle struct {
 }
 
 // Apply new pixels to an existing pixel array.
-func (e *Twinkle) assembleFrame(base *Effect, pg *render.PixelGroup) {
+func (e *Twinkle) assembleFrame(base *Effect, c BaseEffectConfig, pg *render.PixelGroup) {
 	// operate on the largest pixel output in group, then clone to others
 	p := pg.Group[pg.Largest]
 
@@ -29,7 +29,7 @@ func (e *Twinkle) assembleFrame(base *Effect, pg *render.PixelGroup) {
 		e.initialised = true
 	}
 
-	mel, err := audio.Analyzer.GetMelbank(base.ID)
+	mel, err := audio.GetAnalyzer(c.Source).GetMelbank(base.ID)
 	if err != nil {
 		logger.Logger.WithField("context", "Effect Energy").Error(err)
 		return
@@ -16,18 +16,18 @@ type Weave struct {
 }
 
 // Apply new pixels to an existing pixel array.
-func (e *Weave) assembleFrame(base *Effect, pg *render.PixelGroup) {
+func (e *Weave) assembleFrame(base *Effect, c BaseEffectConfig, pg *render.PixelGroup) {
 	// operate on the largest pixel output in group, then clone to others
 	p := pg.Group[pg.Largest]
 
-	mel, err := audio.Analyzer.GetMelbank(base.ID)
+	mel, err := audio.GetAnalyzer(c.Source).GetMelbank(base.ID)
 	if err != nil {
 		logger.Logger.WithField("context", "Effect Weave").Error(err)
 		return
 	}
-	lowsStep := base.deltaStart.Seconds()*base.Config.Intensity*3 + 0
-	midsStep := base.deltaStart.Seconds()*base.Config.Intensity*3 + 0.5
-	highStep := base.deltaStart.Seconds()*base.Config.Intensity*3 + 1
+	lowsStep := base.deltaStart.Seconds()*c.Intensity*3 + 0
+	midsStep := base.deltaStart.Seconds()*c.Intensity*3 + 0.5
+	highStep := base.deltaStart.Seconds()*c.Intensity*3 + 1
 
 	lowsNew := int(weavePosition(lowsStep, 1) * base.pixelScaler)
 	midsNew := int(weavePosition(midsStep, 1) * base.pixelScaler)
@@ -1,9 +1,6 @@
 package event
 
 import (
-	"crypto/rand"
-	"encoding/hex"
-	"errors"
 	"sync"
 	"time"
 )
@@ -24,6 +21,10 @@ const (
 	DeviceDelete
 	ConnectionsUpdate
 	SettingsUpdate
+	AudioUpdate
+	SilenceStart
+	SilenceEnd
+	ProfilesUpdate
 )
 
 func (et EventType) String() string {
@@ -52,101 +53,224 @@ func (et EventType) String() string {
 		return "Connections Update"
 	case SettingsUpdate:
 		return "Settings Update"
+	case AudioUpdate:
+		return "Audio Update"
+	case SilenceStart:
+		return "Silence Start"
+	case SilenceEnd:
+		return "Silence End"
+	case ProfilesUpdate:
+		return "Profiles Update"
 	default:
 		return "Unknown"
 	}
 }
 
+// All event types, in order
+func Types() []EventType {
+	types := []EventType{}
+	for et := Log; et.String() != "Unknown"; et++ {
+		types = append(types, et)
+	}
+	return types
+}
+
 type Event struct {
+	Seq       uint64 // increases by one with every kept event, of any type, so a gap means events were missed. Zero for events which aren't kept.
 	Timestamp time.Time
 	Type      EventType
 	Title     string
-	Data      map[string]interface{}
+	Data      Payload
 }
 
-type callbacks map[string]func(*Event)
+/*
+Events are delivered asynchronously. Each subscriber has its own goroutine and a bounded queue,
+so a slow subscriber never blocks Invoke or the other subscribers.
+When a subscriber's queue is full, events are dropped according to its policy.
+*/
+
+// What to do with an event when a subscriber's queue is full
+type Policy int
+
+const (
+	// Drop the oldest queued event to make room for the new one
+	DropOldest Policy = iota
+	// Drop the new event, keeping the queue as it is
+	DropNewest
+	// Replace a queued event about the same thing (eg. the same effect id) with the new one.
+	// Events without an id replace any queued event of their type. Falls back to DropOldest.
+	Coalesce
+)
 
-var listeners map[EventType]callbacks = make(map[EventType]callbacks)
-var mu sync.Mutex = sync.Mutex{}
-var ErrInvalidEvent error = errors.New("invalid event data")
+const DefaultQueueSize = 64
 
+type Options struct {
+	QueueSize int    // max queued events. defaults to DefaultQueueSize
+	Policy    Policy // what to do when the queue is full
+}
+
+type subscriber struct {
+	cb      func(*Event)
+	opts    Options
+	mu      sync.Mutex
+	queue   []*Event
+	dropped int           // events dropped since the subscriber was created
+	signal  chan struct{} // wakes the subscriber goroutine. buffered, holds at most one wake up
+	done    chan struct{}
+	once    sync.Once
+}
+
+var listeners = make(map[EventType]map[*subscriber]struct{})
+var mu sync.RWMutex
+
+// Subscribe to an event type with the default options.
+// The callback runs on the subscriber's own goroutine. Call the returned func to unsubscribe.
 func Subscribe(et EventType, cb func(*Event)) (unsub func()) {
-	// create callbacks if it doesn't exist
-	if _, exists := listeners[et]; !exists {
-		listeners[et] = make(callbacks)
-	}
+	return SubscribeWithOptions(et, cb, Options{})
+}
 
-	// generate and store the callback with a unique id
-	id := randID()
-	listeners[et][id] = cb
+// Subscribe to an event type with a given queue size and drop policy
+func SubscribeWithOptions(et EventType, cb func(*Event), opts Options) (unsub func()) {
+	return SubscribeTypes([]EventType{et}, cb, opts)
+}
 
-	// return a func to unsubscribe
-	unsub = func() {
-		mu.Lock()
-		defer mu.Unlock()
-		delete(listeners[et], id)
-		if len(listeners[et]) == 0 {
-			delete(listeners, et)
+// Subscribe to several event types with one callback. Their events share a queue and goroutine,
+// so the callback gets them in the order they were invoked.
+func SubscribeTypes(ets []EventType, cb func(*Event), opts Options) (unsub func()) {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = DefaultQueueSize
+	}
+	s := &subscriber{
+		cb:     cb,
+		opts:   opts,
+		signal: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	mu.Lock()
+	for _, et := range ets {
+		if _, exists := listeners[et]; !exists {
+			listeners[et] = make(map[*subscriber]struct{})
 		}
+		listeners[et][s] = struct{}{}
 	}
+	mu.Unlock()
+	go s.run()
 
-	return unsub
-}
-
-func Invoke(et EventType, data map[string]interface{}) {
-	// make sure event has the right keys in data
-	var err error
-	switch et {
-	case Log:
-		err = checkKeys(data, []string{"level", "msg"})
-	case EffectRender:
-		err = checkKeys(data, []string{"id", "pixels"})
-	case EffectUpdate:
-		err = checkKeys(data, []string{"id", "type", "base_config"})
-	case GlobalEffectUpdate:
-		err = checkKeys(data, []string{"config"})
-	case ControllerUpdate:
-		err = checkKeys(data, []string{"id", "base_config", "active"})
-	case DeviceUpdate:
-		err = checkKeys(data, []string{"id", "base_config", "impl_config", "state"})
-	case EffectDelete | DeviceDelete | ControllerDelete:
-		err = checkKeys(data, []string{"id"})
-	case ConnectionsUpdate:
-		err = checkKeys(data, []string{"effects", "devices"})
-	case SettingsUpdate:
-		err = checkKeys(data, []string{"settings"})
+	return func() {
+		mu.Lock()
+		for _, et := range ets {
+			delete(listeners[et], s)
+			if len(listeners[et]) == 0 {
+				delete(listeners, et)
+			}
+		}
+		mu.Unlock()
+		s.once.Do(func() { close(s.done) })
 	}
+}
 
-	// Do not invoke the event if it's missing keys
-	if err != nil {
+// Invokes an event with the given payload. Never blocks on subscribers.
+func Invoke(data Payload) {
+	if data == nil {
 		return
 	}
-
-	// make event
-	event := Event{
+	et := data.EventType()
+	// sequence, record and queue together, so subscribers get events in sequence order
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	event := &Event{
 		Timestamp: time.Now(),
 		Type:      et,
 		Title:     et.String(),
 		Data:      data,
 	}
+	record(event)
+	mu.RLock()
+	defer mu.RUnlock()
+	for s := range listeners[et] {
+		s.push(event)
+	}
+}
+
+// Number of subscribers to an event type
+func Subscribers(et EventType) int {
+	mu.RLock()
+	defer mu.RUnlock()
+	return len(listeners[et])
+}
+
+func (s *subscriber) push(e *Event) {
+	s.mu.Lock()
+	if s.opts.Policy == Coalesce {
+		key := coalesceKey(e)
+		for i, queued := range s.queue {
+			if coalesceKey(queued) == key {
+				s.queue[i] = e
+				s.dropped++
+				s.mu.Unlock()
+				s.wake()
+				return
+			}
+		}
+	}
+	if len(s.queue) >= s.opts.QueueSize {
+		s.dropped++
+		if s.opts.Policy == DropNewest {
+			s.mu.Unlock()
+			return
+		}
+		s.queue = s.queue[1:]
+	}
+	s.queue = append(s.queue, e)
+	s.mu.Unlock()
+	s.wake()
+}
+
+func (s *subscriber) wake() {
+	select {
+	case s.signal <- struct{}{}:
+	default:
+	}
+}
 
-	// invoke callbacks
-	for _, cb := range listeners[et] {
-		cb(&event)
+func (s *subscriber) pop() (*Event, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.queue) == 0 {
+		return nil, false
 	}
+	e := s.queue[0]
+	s.queue[0] = nil
+	s.queue = s.queue[1:]
+	return e, true
 }
 
-func checkKeys(m map[string]interface{}, keys []string) error {
-	for _, key := range keys {
-		if _, exists := m[key]; !exists {
-			return ErrInvalidEvent
+func (s *subscriber) run() {
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-s.signal:
+		}
+		for {
+			// stop delivering as soon as the subscriber is gone
+			select {
+			case <-s.done:
+				return
+			default:
+			}
+			e, ok := s.pop()
+			if !ok {
+				break
+			}
+			s.cb(e)
 		}
 	}
-	return nil
 }
 
-func randID() string {
-	b := make([]byte, 32)
-	rand.Read(b)
-	return hex.Dump(b)
+func coalesceKey(e *Event) string {
+	if k, ok := e.Data.(keyedPayload); ok {
+		return k.coalesceKey()
+	}
+	return ""
 }
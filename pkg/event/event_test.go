@@ -1,15 +1,220 @@
 package event
 
 import (
+	"fmt"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestEvents(t *testing.T) {
-	handleLogEvent := func(e *Event) {
-		t.Log(e.Data["level"], e.Data["msg"])
+	received := make(chan LogData, 1)
+	unsub := Subscribe(Log, func(e *Event) {
+		received <- e.Data.(LogData)
+	})
+	Invoke(LogData{Level: "testing level", Msg: "testing message"})
+	select {
+	case data := <-received:
+		if data.Msg != "testing message" {
+			t.Errorf("Expected 'testing message', got '%s'", data.Msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Event was not delivered")
 	}
-	unsub := Subscribe(Log, handleLogEvent)
-	Invoke(Log, map[string]interface{}{"level": "testing level", "msg": "testing message"})
 	unsub()
-	Invoke(Log, map[string]interface{}{"level": "testing level", "msg": "testing message again"})
+	Invoke(LogData{Level: "testing level", Msg: "testing message again"})
+	select {
+	case <-received:
+		t.Error("Event was delivered after unsubscribing")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if n := Subscribers(Log); n != 0 {
+		t.Errorf("Expected no subscribers, got %d", n)
+	}
+}
+
+func TestSlowSubscriber(t *testing.T) {
+	release := make(chan struct{})
+	unsub := SubscribeWithOptions(EffectDelete, func(e *Event) { <-release }, Options{QueueSize: 2})
+	defer unsub()
+	defer close(release)
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 100; i++ {
+			Invoke(EffectDeleteData{ID: fmt.Sprint(i)})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Invoke blocked on a slow subscriber")
+	}
+}
+
+// subscribes with a callback that waits until the first event is released, then records the rest
+func blockedSubscriber(et EventType, opts Options) (release func(), got func() []Event, unsub func()) {
+	var mu sync.Mutex
+	events := []Event{}
+	first := true
+	gate := make(chan struct{})
+	unsub = SubscribeWithOptions(et, func(e *Event) {
+		if first {
+			first = false
+			<-gate
+			return
+		}
+		mu.Lock()
+		events = append(events, *e)
+		mu.Unlock()
+	}, opts)
+	release = func() { close(gate) }
+	got = func() []Event {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]Event{}, events...)
+	}
+	return release, got, unsub
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for events")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestDropPolicies(t *testing.T) {
+	for _, policy := range []Policy{DropOldest, DropNewest} {
+		release, got, unsub := blockedSubscriber(DeviceDelete, Options{QueueSize: 3, Policy: policy})
+		Invoke(DeviceDeleteData{ID: "blocker"})
+		// wait for the subscriber to take the first event, so the queue is empty
+		time.Sleep(20 * time.Millisecond)
+		for i := 0; i < 5; i++ {
+			Invoke(DeviceDeleteData{ID: fmt.Sprint(i)})
+		}
+		release()
+		waitFor(t, func() bool { return len(got()) == 3 })
+		expected := []string{"2", "3", "4"}
+		if policy == DropNewest {
+			expected = []string{"0", "1", "2"}
+		}
+		for i, e := range got() {
+			if id := e.Data.(DeviceDeleteData).ID; id != expected[i] {
+				t.Errorf("Policy %d: expected event %d to be '%s', got '%s'", policy, i, expected[i], id)
+			}
+		}
+		unsub()
+	}
+}
+
+func TestCoalesce(t *testing.T) {
+	release, got, unsub := blockedSubscriber(ControllerUpdate, Options{Policy: Coalesce})
+	defer unsub()
+	Invoke(ControllerUpdateData{ID: "blocker"})
+	time.Sleep(20 * time.Millisecond)
+	for i := 0; i < 10; i++ {
+		Invoke(ControllerUpdateData{ID: "a", Active: i%2 == 0})
+		Invoke(ControllerUpdateData{ID: "b", Active: i%2 == 1})
+	}
+	release()
+	waitFor(t, func() bool { return len(got()) == 2 })
+	time.Sleep(20 * time.Millisecond)
+	events := got()
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 coalesced events, got %d", len(events))
+	}
+	a, b := events[0].Data.(ControllerUpdateData), events[1].Data.(ControllerUpdateData)
+	if a.ID != "a" || a.Active || b.ID != "b" || !b.Active {
+		t.Errorf("Expected the latest update of each controller in order, got %+v and %+v", a, b)
+	}
+}
+
+// run with -race
+func TestConcurrentSubscribers(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				unsub := Subscribe(SettingsUpdate, func(e *Event) {})
+				Invoke(SettingsUpdateData{Settings: j})
+				unsub()
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				Invoke(SettingsUpdateData{Settings: j})
+			}
+		}()
+	}
+	wg.Wait()
+	if n := Subscribers(SettingsUpdate); n != 0 {
+		t.Errorf("Expected no subscribers, got %d", n)
+	}
+}
+
+func TestSubscribeTypes(t *testing.T) {
+	received := make(chan EventType, 20)
+	unsub := SubscribeTypes([]EventType{SilenceStart, SilenceEnd}, func(e *Event) {
+		received <- e.Type
+	}, Options{})
+	for i := 0; i < 10; i++ {
+		Invoke(SilenceStartData{Source: "test"})
+		Invoke(SilenceEndData{Source: "test"})
+	}
+	for i := 0; i < 20; i++ {
+		select {
+		case et := <-received:
+			if expected := []EventType{SilenceStart, SilenceEnd}[i%2]; et != expected {
+				t.Fatalf("Expected %s as event %d, got %s", expected, i, et)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Event was not delivered")
+		}
+	}
+	unsub()
+	if Subscribers(SilenceStart) != 0 || Subscribers(SilenceEnd) != 0 {
+		t.Error("Expected no subscribers after unsubscribing")
+	}
+}
+
+func TestHistory(t *testing.T) {
+	start := LatestSeq()
+	for i := 0; i < HistorySize+10; i++ {
+		Invoke(EffectDeleteData{ID: fmt.Sprint(i)})
+	}
+	Invoke(ControllerDeleteData{ID: "controller"})
+	Invoke(EffectRenderData{ID: "render"})
+
+	events := History(start, time.Time{}, EffectDelete)
+	if len(events) != HistorySize {
+		t.Fatalf("Expected %d kept events, got %d", HistorySize, len(events))
+	}
+	// the oldest events were pushed out, leaving a gap after start
+	if events[0].Seq != start+11 || events[0].Data.(EffectDeleteData).ID != "10" {
+		t.Errorf("Expected the oldest kept event to be 10 with seq %d, got %+v", start+11, events[0])
+	}
+	for i := 1; i < len(events); i++ {
+		if events[i].Seq != events[i-1].Seq+1 {
+			t.Fatalf("Events out of sequence: %d after %d", events[i].Seq, events[i-1].Seq)
+		}
+	}
+
+	events = History(events[len(events)-1].Seq, time.Time{})
+	if len(events) != 1 || events[0].Type != ControllerDelete {
+		t.Errorf("Expected only the controller delete after the last effect delete, got %d events", len(events))
+	}
+	// the render isn't kept, so it isn't numbered either
+	if LatestSeq() != start+HistorySize+11 {
+		t.Errorf("Expected latest seq %d, got %d", start+HistorySize+11, LatestSeq())
+	}
+	if len(History(start, time.Now())) != 0 {
+		t.Error("Expected no events after now")
+	}
 }
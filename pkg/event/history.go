@@ -0,0 +1,88 @@
+package event
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+/*
+Recent events are kept so clients which (re)connect can catch up on what they missed.
+Each event type has its own ring buffer, so frequent events like logs don't push out rarer state changes.
+*/
+
+// Number of recent events kept for each event type
+const HistorySize = 100
+
+// event types that aren't kept. Frames are only useful live.
+var noHistory = map[EventType]bool{
+	EffectRender: true,
+}
+
+type ring struct {
+	events []*Event
+	next   int // index the next event is written to, once the ring is full
+}
+
+func (r *ring) add(e *Event) {
+	if len(r.events) < HistorySize {
+		r.events = append(r.events, e)
+		return
+	}
+	r.events[r.next] = e
+	r.next = (r.next + 1) % HistorySize
+}
+
+var history = map[EventType]*ring{}
+var seq uint64 // sequence of the latest kept event
+var historyMu sync.Mutex
+
+// Numbers and keeps the event, unless its type isn't kept. Caller must hold historyMu
+func record(e *Event) {
+	if noHistory[e.Type] {
+		return
+	}
+	seq++
+	e.Seq = seq
+	r, exists := history[e.Type]
+	if !exists {
+		r = &ring{}
+		history[e.Type] = r
+	}
+	r.add(e)
+}
+
+// Sequence id of the latest kept event
+func LatestSeq() uint64 {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	return seq
+}
+
+/*
+Get the kept events after a sequence id and after a time, in sequence order.
+Zero values match all kept events. If no types are given, events of all types are returned.
+*/
+func History(afterSeq uint64, since time.Time, types ...EventType) []*Event {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	if len(types) == 0 {
+		types = Types()
+	}
+	events := []*Event{}
+	seen := map[EventType]bool{}
+	for _, et := range types {
+		r, exists := history[et]
+		if !exists || seen[et] {
+			continue
+		}
+		seen[et] = true
+		for _, e := range r.events {
+			if e.Seq > afterSeq && e.Timestamp.After(since) {
+				events = append(events, e)
+			}
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Seq < events[j].Seq })
+	return events
+}
@@ -0,0 +1,111 @@
+package event
+
+// Payloads carry the data of an event. Each payload type belongs to one event type.
+// Payloads are shared between subscribers, so callbacks must not modify them.
+type Payload interface {
+	EventType() EventType
+}
+
+// Payloads which have a key are coalesced by it. See Coalesce.
+type keyedPayload interface {
+	coalesceKey() string
+}
+
+type LogData struct {
+	Level string `json:"level"`
+	Msg   string `json:"msg"`
+}
+
+type ShutdownData struct{}
+
+type EffectRenderData struct {
+	ID     string      `json:"id"`
+	Pixels interface{} `json:"pixels"` // *render.PixelGroup
+}
+
+type EffectUpdateData struct {
+	ID         string      `json:"id"`
+	Type       string      `json:"type"`
+	BaseConfig interface{} `json:"base_config"`
+}
+
+type EffectDeleteData struct {
+	ID string `json:"id"`
+}
+
+type GlobalEffectUpdateData struct {
+	Config map[string]interface{} `json:"config"`
+}
+
+type ControllerUpdateData struct {
+	ID         string                 `json:"id"`
+	BaseConfig map[string]interface{} `json:"base_config"`
+	Active     bool                   `json:"active"`
+}
+
+type ControllerDeleteData struct {
+	ID string `json:"id"`
+}
+
+type DeviceUpdateData struct {
+	ID         string                 `json:"id"`
+	BaseConfig map[string]interface{} `json:"base_config"`
+	ImplConfig map[string]interface{} `json:"impl_config"`
+	State      int                    `json:"state"`
+}
+
+type DeviceDeleteData struct {
+	ID string `json:"id"`
+}
+
+type ConnectionsUpdateData struct {
+	Effects map[string]string `json:"effects"`
+	Devices map[string]string `json:"devices"`
+}
+
+type SettingsUpdateData struct {
+	Settings interface{} `json:"settings"` // config.SettingsConfig
+}
+
+type AudioUpdateData struct {
+	Config interface{} `json:"config"` // config.AudioConfig
+}
+
+type SilenceStartData struct {
+	Source string `json:"source"`
+}
+
+type SilenceEndData struct {
+	Source   string  `json:"source"`
+	Duration float64 `json:"duration"` // seconds
+}
+
+type ProfilesUpdateData struct {
+	Active   string   `json:"active"`
+	Profiles []string `json:"profiles"` // names of all profiles, including the active one
+}
+
+func (LogData) EventType() EventType                { return Log }
+func (ShutdownData) EventType() EventType           { return Shutdown }
+func (EffectRenderData) EventType() EventType       { return EffectRender }
+func (EffectUpdateData) EventType() EventType       { return EffectUpdate }
+func (EffectDeleteData) EventType() EventType       { return EffectDelete }
+func (GlobalEffectUpdateData) EventType() EventType { return GlobalEffectUpdate }
+func (ControllerUpdateData) EventType() EventType   { return ControllerUpdate }
+func (ControllerDeleteData) EventType() EventType   { return ControllerDelete }
+func (DeviceUpdateData) EventType() EventType       { return DeviceUpdate }
+func (DeviceDeleteData) EventType() EventType       { return DeviceDelete }
+func (ConnectionsUpdateData) EventType() EventType  { return ConnectionsUpdate }
+func (SettingsUpdateData) EventType() EventType     { return SettingsUpdate }
+func (AudioUpdateData) EventType() EventType        { return AudioUpdate }
+func (SilenceStartData) EventType() EventType       { return SilenceStart }
+func (SilenceEndData) EventType() EventType         { return SilenceEnd }
+func (ProfilesUpdateData) EventType() EventType     { return ProfilesUpdate }
+
+// updates to the same thing can be coalesced, as only the latest matters
+func (d EffectRenderData) coalesceKey() string     { return d.ID }
+func (d EffectUpdateData) coalesceKey() string     { return d.ID }
+func (d ControllerUpdateData) coalesceKey() string { return d.ID }
+func (d DeviceUpdateData) coalesceKey() string     { return d.ID }
+func (d SilenceStartData) coalesceKey() string     { return d.Source }
+func (d SilenceEndData) coalesceKey() string       { return d.Source }
@@ -0,0 +1,167 @@
+package loader
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/LedFx/ledfx/pkg/api"
+	"github.com/LedFx/ledfx/pkg/config"
+)
+
+type profileNameJSON struct {
+	Name string `json:"name"`
+}
+
+type profilesResponse struct {
+	Active   string   `json:"active"`
+	Profiles []string `json:"profiles"`
+}
+
+type profileRequest struct {
+	Name  string `json:"name"`
+	Clone string `json:"clone"` // profile to copy, when creating
+}
+
+type historyResponse struct {
+	Changes  []config.Change `json:"changes"`
+	Position int             `json:"position"` // changes before the position can be undone
+}
+
+type importResponse struct {
+	*config.Import
+	Applied bool `json:"applied"`
+}
+
+func contains(s []string, x string) bool {
+	for _, v := range s {
+		if v == x {
+			return true
+		}
+	}
+	return false
+}
+
+func NewV1API(r *api.Router) {
+	r.Schema("Change", api.TypeSchema(config.Change{}))
+	r.Schema("Profiles", api.TypeSchema(profilesResponse{}))
+
+	r.Get("/config/export", func(*api.Request) (interface{}, error) {
+		b, err := config.Export()
+		if err != nil {
+			return nil, err
+		}
+		return json.RawMessage(b), nil
+	}).Doc("Config", "Get the whole config, for backups and moving a setup to another machine").Returns(map[string]interface{}{"type": "object"})
+
+	r.Post("/config/import", func(req *api.Request) (interface{}, error) {
+		query := req.URL.Query()
+		mode := query.Get("mode")
+		if mode == "" {
+			mode = "merge"
+		}
+		if mode != "merge" && mode != "replace" {
+			return nil, api.BadRequest(errors.New("mode must be merge or replace"))
+		}
+		dryRun := query.Get("dry_run") == "true"
+		content, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil, api.BadRequest(err)
+		}
+		imp, err := Import(content, mode == "merge", dryRun)
+		if imp == nil && err != nil {
+			return nil, api.BadRequest(err)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return importResponse{Import: imp, Applied: !dryRun}, nil
+	}).Doc("Config", "Import a config, merging it into the live config or replacing it").
+		Query("mode", "merge (default) or replace").Query("dry_run", "true to only see the changes").
+		Accepts(map[string]interface{}{"type": "object"}).Returns(api.TypeSchema(importResponse{}))
+
+	r.Get("/history", func(*api.Request) (interface{}, error) {
+		changes, position := config.GetHistory()
+		return historyResponse{Changes: changes, Position: position}, nil
+	}).Doc("History", "Get the history of changes which can be undone and redone").
+		Returns(map[string]interface{}{"type": "object", "properties": map[string]interface{}{
+			"changes":  api.ArrayOf(api.Ref("Change")),
+			"position": map[string]interface{}{"type": "integer", "description": "Changes before the position can be undone"},
+		}})
+
+	r.Post("/history/undo", func(*api.Request) (interface{}, error) {
+		return replayV1(Undo)
+	}).Doc("History", "Undo the latest group of changes, returning the changes undone").Returns(api.ArrayOf(api.Ref("Change")))
+
+	r.Post("/history/redo", func(*api.Request) (interface{}, error) {
+		return replayV1(Redo)
+	}).Doc("History", "Redo the latest group of undone changes, returning the changes redone").Returns(api.ArrayOf(api.Ref("Change")))
+
+	r.Get("/profiles", func(*api.Request) (interface{}, error) {
+		return getProfiles(), nil
+	}).Doc("Profiles", "Get the active profile and the names of all profiles").Returns(api.Ref("Profiles"))
+
+	r.Post("/profiles", func(req *api.Request) (interface{}, error) {
+		var p profileRequest
+		if err := req.Decode(&p); err != nil {
+			return nil, err
+		}
+		var err error
+		if p.Clone != "" {
+			err = config.CloneProfile(p.Clone, p.Name)
+		} else {
+			err = config.CreateProfile(p.Name)
+		}
+		if err != nil {
+			return nil, api.BadRequest(err)
+		}
+		return getProfiles(), nil
+	}).Doc("Profiles", "Create a profile, empty or cloned from another").
+		Accepts(api.TypeSchema(profileRequest{})).Returns(api.Ref("Profiles")).Status(http.StatusCreated)
+
+	r.Delete("/profiles/{name}", func(req *api.Request) (interface{}, error) {
+		if _, names := config.GetProfiles(); !contains(names, req.Param("name")) {
+			return nil, api.NotFound(fmt.Errorf("profile %s does not exist", req.Param("name")))
+		}
+		if err := config.DeleteProfile(req.Param("name")); err != nil {
+			return nil, api.BadRequest(err)
+		}
+		return nil, nil
+	}).Doc("Profiles", "Delete a profile. The active profile can't be deleted")
+
+	r.Put("/profiles/active", func(req *api.Request) (interface{}, error) {
+		var p profileNameJSON
+		if err := req.Decode(&p); err != nil {
+			return nil, err
+		}
+		active, names := config.GetProfiles()
+		if !contains(names, p.Name) {
+			return nil, api.NotFound(fmt.Errorf("profile %s does not exist", p.Name))
+		}
+		if p.Name != active {
+			if err := SwitchProfile(p.Name); err != nil {
+				return nil, err
+			}
+		}
+		return getProfiles(), nil
+	}).Doc("Profiles", "Switch to a profile").Accepts(api.TypeSchema(profileNameJSON{})).Returns(api.Ref("Profiles"))
+}
+
+func getProfiles() profilesResponse {
+	active, names := config.GetProfiles()
+	return profilesResponse{Active: active, Profiles: names}
+}
+
+// undoes or redoes changes. Having nothing to replay is a bad request
+func replayV1(replay func() ([]config.Change, error)) (interface{}, error) {
+	changes, err := replay()
+	if len(changes) == 0 && err != nil {
+		return nil, api.BadRequest(err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
@@ -0,0 +1,41 @@
+package loader
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/LedFx/ledfx/pkg/api"
+	"github.com/LedFx/ledfx/pkg/api/apitest"
+	"github.com/LedFx/ledfx/pkg/config"
+	"github.com/LedFx/ledfx/pkg/effect"
+)
+
+func TestV1API(t *testing.T) {
+	defer config.DisableSaving()()
+	r := api.NewRouter("/api/v1")
+	NewV1API(r)
+	if _, _, err := effect.New("v1_undo", "energy", 10, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer effect.Destroy("v1_undo")
+
+	apitest.Run(t, r, []apitest.Case{
+		{Method: http.MethodGet, Path: "/api/v1/config/export", Code: http.StatusOK},
+		{Method: http.MethodPost, Path: "/api/v1/config/import?mode=nope", Body: `{}`, Code: http.StatusBadRequest},
+		{Method: http.MethodPost, Path: "/api/v1/config/import?dry_run=true", Body: `{"effects": {"v1_fx": {"id": "v1_fx", "type": "energy"}}}`, Code: http.StatusOK},
+		{Method: http.MethodPost, Path: "/api/v1/config/import", Body: `[`, Code: http.StatusBadRequest},
+		{Method: http.MethodGet, Path: "/api/v1/history", Code: http.StatusOK},
+		{Method: http.MethodPost, Path: "/api/v1/history/undo", Code: http.StatusOK},
+		{Method: http.MethodPost, Path: "/api/v1/history/redo", Code: http.StatusOK},
+		{Method: http.MethodPost, Path: "/api/v1/profiles", Body: `{"name": "v1"}`, Code: http.StatusCreated},
+		{Method: http.MethodPost, Path: "/api/v1/profiles", Body: `{"name": "v1"}`, Code: http.StatusBadRequest},
+		{Method: http.MethodGet, Path: "/api/v1/profiles", Code: http.StatusOK},
+		{Method: http.MethodPut, Path: "/api/v1/profiles/active", Body: `{"name": "nope"}`, Code: http.StatusNotFound},
+		{Method: http.MethodDelete, Path: "/api/v1/profiles/v1", Code: http.StatusNoContent},
+		{Method: http.MethodDelete, Path: "/api/v1/profiles/v1", Code: http.StatusNotFound},
+	})
+	if _, err := effect.Get("v1_fx"); err == nil {
+		t.Error("Expected a dry run not to import the effect")
+	}
+	apitest.Documents(t, r, "/api/v1", []string{"/config/import", "/history", "/profiles/{name}"}, []string{"Change", "Profiles"})
+}
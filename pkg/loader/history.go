@@ -0,0 +1,136 @@
+package loader
+
+import (
+	"fmt"
+
+	"github.com/LedFx/ledfx/pkg/config"
+	"github.com/LedFx/ledfx/pkg/controller"
+	"github.com/LedFx/ledfx/pkg/device"
+	"github.com/LedFx/ledfx/pkg/effect"
+
+	"github.com/creasty/defaults"
+	"github.com/mitchellh/mapstructure"
+)
+
+// Undoes the latest group of config changes on the live effects, devices and controllers
+func Undo() ([]config.Change, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	return config.Undo(applyChange)
+}
+
+// Redoes the latest group of undone config changes
+func Redo() ([]config.Change, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	return config.Redo(applyChange)
+}
+
+// brings a live object to the state it had before or after a change. nil state means it didn't exist
+func applyChange(c config.Change, state interface{}) error {
+	switch c.Kind {
+	case config.ChangeEffect:
+		if state == nil {
+			if _, err := effect.Get(c.ID); err == nil {
+				effect.Destroy(c.ID)
+			}
+			return nil
+		}
+		return applyEffect(c.ID, state.(config.EffectEntry))
+	case config.ChangeDevice:
+		if state == nil {
+			if _, err := device.Get(c.ID); err == nil {
+				device.Destroy(c.ID)
+			}
+			return nil
+		}
+		entry := state.(config.DeviceEntry)
+		return controller.ReplaceDevice(c.ID, entry.Type, entry.BaseConfig, entry.ImplConfig)
+	case config.ChangeController:
+		if state == nil {
+			if _, err := controller.Get(c.ID); err == nil {
+				controller.Destroy(c.ID)
+			}
+			return nil
+		}
+		entry := state.(config.ControllerEntry)
+		if v, err := controller.Get(c.ID); err == nil {
+			return v.UpdateConfig(entry.Config)
+		}
+		_, _, err := controller.New(c.ID, entry.Config)
+		return err
+	case config.ChangeConnections:
+		return applyConnections(state.(config.Connections))
+	case config.ChangeGlobalEffects:
+		if state == nil {
+			// the global settings had never been set, so they were the defaults
+			var d effect.BaseEffectConfig
+			if err := defaults.Set(&d); err != nil {
+				return err
+			}
+			m := map[string]interface{}{}
+			mapstructure.Decode(d, &m)
+			return effect.SetGlobalSettings(m)
+		}
+		return effect.SetGlobalSettings(state.(map[string]interface{}))
+	}
+	return fmt.Errorf("unknown change %s", c.Kind)
+}
+
+func applyEffect(id string, entry config.EffectEntry) error {
+	e, err := effect.Get(id)
+	if err != nil || e.Type != entry.Type {
+		// a new effect replaces the old one, so reconnect it
+		effects, _ := controller.GetConnections()
+		controllerID, connected := effects[id]
+		if connected {
+			controller.DisconnectEffect(id, controllerID)
+		}
+		if e, _, err = effect.New(id, entry.Type, 100, entry.BaseConfig); err != nil {
+			return err
+		}
+		if connected {
+			if err = controller.ConnectEffect(id, controllerID); err != nil {
+				return err
+			}
+		}
+	} else if err = e.UpdateBaseConfig(entry.BaseConfig); err != nil {
+		return err
+	}
+	if entry.ExtraConfig != nil {
+		if err = e.UpdateExtraConfig(entry.ExtraConfig); err != nil {
+			return err
+		}
+	}
+	return e.SetModulators(entry.Modulators)
+}
+
+// disconnects and connects effects and devices to match the connections
+func applyConnections(conn config.Connections) error {
+	effects, devices := controller.GetConnections()
+	for eID, vID := range effects {
+		if conn.Effects[eID] != vID {
+			controller.DisconnectEffect(eID, vID)
+		}
+	}
+	for dID, vID := range devices {
+		if conn.Devices[dID] != vID {
+			controller.DisconnectDevice(dID, vID)
+		}
+	}
+	for eID, vID := range conn.Effects {
+		if effects[eID] != vID {
+			if err := controller.ConnectEffect(eID, vID); err != nil {
+				return err
+			}
+		}
+	}
+	for dID, vID := range conn.Devices {
+		if devices[dID] != vID {
+			if err := controller.ConnectDevice(dID, vID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package loader
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/LedFx/ledfx/pkg/config"
+	"github.com/LedFx/ledfx/pkg/controller"
+	"github.com/LedFx/ledfx/pkg/device"
+	"github.com/LedFx/ledfx/pkg/effect"
+	"github.com/LedFx/ledfx/pkg/logger"
+)
+
+/*
+The loader builds the live effects, devices and controllers from the config, and tears them
+down again, so the whole setup can be swapped without restarting LedFx.
+*/
+
+var mu sync.Mutex
+
+/*
+Creates the effects, devices and controllers saved in config, connects them,
+and restores which controllers are running. Invalid entries are quarantined.
+*/
+func Load() error {
+	// invalid entries are quarantined rather than loaded
+	config.ValidateEntries()
+
+	defer config.SuspendSaving()()
+	if err := effect.LoadFromConfig(); err != nil {
+		return fmt.Errorf("error loading effects: %w", err)
+	}
+	if err := device.LoadFromConfig(); err != nil {
+		return fmt.Errorf("error loading devices: %w", err)
+	}
+	if err := controller.LoadFromConfig(); err != nil {
+		return fmt.Errorf("error loading controllers: %w", err)
+	}
+	controller.LoadConnectionsFromConfig()
+	controller.LoadStatesFromConfig()
+	return nil
+}
+
+/*
+Stops the controllers, disconnects the devices, and destroys every controller, device and effect.
+Destroying them removes them from the live config, so replace the config before loading again.
+*/
+func Unload() {
+	defer config.SuspendSaving()()
+	for _, id := range controller.GetIDs() {
+		controller.Destroy(id)
+	}
+	for _, id := range device.GetIDs() {
+		device.Destroy(id)
+	}
+	for _, id := range effect.GetIDs() {
+		effect.Destroy(id)
+	}
+}
+
+/*
+Imports an exported config, merging it into the live config or replacing it.
+A dry run only reports what would change. Otherwise the setup is unloaded and
+rebuilt from the new config. Settings such as audio or mqtt are saved, and take
+effect when their service next starts.
+*/
+func Import(content []byte, merge, dryRun bool) (*config.Import, error) {
+	// a merge is prepared from the live config, so nothing else may change it until it's applied
+	mu.Lock()
+	defer mu.Unlock()
+	imp, err := config.PrepareImport(content, merge)
+	if err != nil || dryRun {
+		return imp, err
+	}
+	// anything waiting to be saved goes into the backup taken before the import
+	config.Flush()
+	// the unloaded setup is never saved
+	defer config.SuspendSaving()()
+	Unload()
+	err = imp.Apply()
+	if err != nil {
+		logger.Logger.WithField("context", "Config Import").Errorf("Error saving imported config: %v", err)
+	}
+	if loadErr := Load(); loadErr != nil {
+		return imp, loadErr
+	}
+	logger.Logger.WithField("context", "Config Import").Infof("Imported config")
+	return imp, err
+}
+
+// Switches to another profile, stopping and unloading the running setup and loading the profile's
+func SwitchProfile(name string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	s, err := config.PrepareProfileSwitch(name)
+	if err != nil {
+		return err
+	}
+	config.Flush()
+	defer config.SuspendSaving()()
+	Unload()
+	err = s.Apply()
+	if err != nil {
+		logger.Logger.WithField("context", "Profiles").Errorf("Error saving profile switch: %v", err)
+	}
+	if loadErr := Load(); loadErr != nil {
+		return loadErr
+	}
+	logger.Logger.WithField("context", "Profiles").Infof("Switched from profile %s to %s", s.From, s.To)
+	return err
+}
@@ -0,0 +1,292 @@
+package loader
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LedFx/ledfx/pkg/audio"
+	"github.com/LedFx/ledfx/pkg/config"
+	"github.com/LedFx/ledfx/pkg/controller"
+	"github.com/LedFx/ledfx/pkg/device"
+	"github.com/LedFx/ledfx/pkg/effect"
+	"github.com/LedFx/ledfx/pkg/event"
+)
+
+func TestImport(t *testing.T) {
+	defer config.DisableSaving()()
+
+	setup := []byte(`{
+		"effects": {"loader_fx": {"id": "loader_fx", "type": "energy", "base_config": {"brightness": 0.5}}},
+		"controllers": {"loader_c": {"id": "loader_c", "base_config": {"name": "Loader"}}},
+		"connections_effect": {"loader_fx": "loader_c"}
+	}`)
+	imp, err := Import(setup, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(imp.Diff.Added["effects"]) != 1 {
+		t.Errorf("Expected the dry run to add an effect, got %+v", imp.Diff)
+	}
+	if _, err = effect.Get("loader_fx"); err == nil {
+		t.Fatal("Expected a dry run not to create anything")
+	}
+
+	if _, err = Import(setup, false, false); err != nil {
+		t.Fatal(err)
+	}
+	c, err := controller.Get("loader_c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Effect == nil || c.Effect.ID != "loader_fx" || c.Effect.Config.Brightness != 0.5 {
+		t.Errorf("Expected the imported effect to be connected to the imported controller, got %+v", c.Effect)
+	}
+
+	// merging keeps what's there
+	if _, err = Import([]byte(`{"effects": {"loader_fx2": {"id": "loader_fx2", "type": "fade"}}}`), true, false); err != nil {
+		t.Fatal(err)
+	}
+	if len(effect.GetIDs()) != 2 || len(controller.GetIDs()) != 1 {
+		t.Errorf("Expected a merge to add an effect, got effects %v controllers %v", effect.GetIDs(), controller.GetIDs())
+	}
+
+	if _, err = Import([]byte(`{}`), false, false); err != nil {
+		t.Fatal(err)
+	}
+	if len(effect.GetIDs()) != 0 || len(controller.GetIDs()) != 0 {
+		t.Errorf("Expected replacing with an empty config to unload everything, got effects %v controllers %v", effect.GetIDs(), controller.GetIDs())
+	}
+}
+
+func TestSwitchProfile(t *testing.T) {
+	defer config.DisableSaving()()
+	if _, err := Import([]byte(`{
+		"effects": {"home_fx": {"id": "home_fx", "type": "energy"}},
+		"controllers": {"home_c": {"id": "home_c", "base_config": {"name": "Home"}}},
+		"connections_effect": {"home_fx": "home_c"}
+	}`), false, false); err != nil {
+		t.Fatal(err)
+	}
+	home, _ := config.GetProfiles()
+
+	if err := config.CreateProfile("gig"); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.CloneProfile(home, "home copy"); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.CreateProfile("gig"); err == nil {
+		t.Error("Expected an error creating a profile which exists")
+	}
+	if err := config.CreateProfile("../etc"); err == nil {
+		t.Error("Expected an error for an invalid profile name")
+	}
+	if active, names := config.GetProfiles(); active != home || len(names) != 3 {
+		t.Errorf("Expected three profiles, got %v", names)
+	}
+
+	if err := SwitchProfile("gig"); err != nil {
+		t.Fatal(err)
+	}
+	if len(effect.GetIDs()) != 0 || len(controller.GetIDs()) != 0 {
+		t.Errorf("Expected the empty profile to unload everything, got effects %v controllers %v", effect.GetIDs(), controller.GetIDs())
+	}
+	if _, _, err := effect.New("gig_fx", "fade", 10, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// the clone has the home setup, connected
+	if err := SwitchProfile("home copy"); err != nil {
+		t.Fatal(err)
+	}
+	c, err := controller.Get("home_c")
+	if err != nil || c.Effect == nil || c.Effect.ID != "home_fx" {
+		t.Fatalf("Expected the cloned setup to load, got %v", err)
+	}
+	if err = config.DeleteProfile("home copy"); err == nil {
+		t.Error("Expected an error deleting the active profile")
+	}
+
+	// the gig profile kept the effect made while it was active
+	if err = SwitchProfile("gig"); err != nil {
+		t.Fatal(err)
+	}
+	if ids := effect.GetIDs(); len(ids) != 1 || ids[0] != "gig_fx" {
+		t.Errorf("Expected the gig profile's effect, got %v", ids)
+	}
+	if err = config.DeleteProfile("home copy"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUndo(t *testing.T) {
+	defer config.DisableSaving()()
+	if _, err := Import([]byte(`{
+		"effects": {"undo_fx": {"id": "undo_fx", "type": "energy", "base_config": {"brightness": 0.5}}},
+		"controllers": {"undo_c": {"id": "undo_c", "base_config": {"name": "Undo"}}},
+		"connections_effect": {"undo_fx": "undo_c"}
+	}`), false, false); err != nil {
+		t.Fatal(err)
+	}
+	defer Unload()
+
+	e, _ := effect.Get("undo_fx")
+	if err := e.UpdateBaseConfig(map[string]interface{}{"brightness": 0.2}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Undo(); err != nil {
+		t.Fatal(err)
+	}
+	if e.Config.Brightness != 0.5 {
+		t.Errorf("Expected undo to restore the brightness, got %v", e.Config.Brightness)
+	}
+	if _, err := Redo(); err != nil {
+		t.Fatal(err)
+	}
+	if e.Config.Brightness != 0.2 {
+		t.Errorf("Expected redo to set the brightness again, got %v", e.Config.Brightness)
+	}
+
+	// undoing a delete brings the effect back, connected.
+	// wait so the delete isn't grouped with the update
+	time.Sleep(200 * time.Millisecond)
+	effect.Destroy("undo_fx")
+	if _, err := Undo(); err != nil {
+		t.Fatal(err)
+	}
+	c, _ := controller.Get("undo_c")
+	if c.Effect == nil || c.Effect.ID != "undo_fx" || c.Effect.Config.Brightness != 0.2 {
+		t.Errorf("Expected the deleted effect to be restored and connected, got %+v", c.Effect)
+	}
+	if _, err := Undo(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Undo(); err == nil {
+		t.Error("Expected nothing more to undo")
+	}
+}
+
+// run with -race. effects and controllers are created and read from many goroutines, eg. api handlers and the websocket
+func TestRegistriesConcurrency(t *testing.T) {
+	defer config.DisableSaving()()
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(2)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				eID, vID := fmt.Sprintf("race_fx_%d_%d", w, i%5), fmt.Sprintf("race_c_%d_%d", w, i%5)
+				if _, _, err := effect.New(eID, "energy", 10, nil); err != nil {
+					t.Error(err)
+					return
+				}
+				if _, _, err := controller.New(vID, map[string]interface{}{"name": vID}); err != nil {
+					t.Error(err)
+					return
+				}
+				if i%3 == 0 {
+					controller.Destroy(vID)
+					effect.Destroy(eID)
+				}
+			}
+		}(w)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				for _, id := range effect.GetIDs() {
+					effect.Get(id)
+				}
+				for _, id := range controller.GetIDs() {
+					controller.Get(id)
+				}
+				controller.GetStates()
+				for range config.GetEffects() {
+				}
+				for range config.GetControllers() {
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	Unload()
+	if len(effect.GetIDs()) != 0 || len(controller.GetIDs()) != 0 {
+		t.Errorf("Expected everything to be unloaded, got effects %v controllers %v", effect.GetIDs(), controller.GetIDs())
+	}
+}
+
+// run with -race. connections change from the api while controllers render on their own goroutines
+func TestConnectWhileRendering(t *testing.T) {
+	defer config.DisableSaving()()
+	defer Unload()
+	impl := map[string]interface{}{"ip": "127.0.0.1"}
+	for _, id := range []string{"render_d1", "render_d2"} {
+		if _, _, err := device.New(id, "udp_stream", map[string]interface{}{"name": id, "pixel_count": 10}, impl); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the blurrer is made for the whole controller rather than each device, so blur is off
+	for _, id := range []string{"render_fx1", "render_fx2"} {
+		if _, _, err := effect.New(id, "energy", 10, map[string]interface{}{"blur": 0.0}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, _, err := controller.New("render_c", map[string]interface{}{"name": "Render", "framerate": 120}); err != nil {
+		t.Fatal(err)
+	}
+	if err := controller.ConnectEffect("render_fx1", "render_c"); err != nil {
+		t.Fatal(err)
+	}
+	if err := controller.ConnectDevice("render_d1", "render_c"); err != nil {
+		t.Fatal(err)
+	}
+	if err := controller.SetStates(map[string]bool{"render_c": true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.SetSilence(map[string]interface{}{"action": "dim"}); err != nil {
+		t.Fatal(err)
+	}
+	defer config.SetSilence(map[string]interface{}{"action": "none"})
+
+	var wg sync.WaitGroup
+	wg.Add(4)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			controller.ConnectDevice("render_d2", "render_c")
+			time.Sleep(time.Millisecond)
+			controller.DisconnectDevice("render_d2", "render_c")
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			controller.ConnectEffect(fmt.Sprintf("render_fx%d", i%2+1), "render_c")
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			controller.GetConnections()
+			controller.GetStates()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			event.Invoke(event.SilenceStartData{Source: audio.DefaultSource})
+			time.Sleep(time.Millisecond)
+			event.Invoke(event.SilenceEndData{Source: audio.DefaultSource})
+		}
+	}()
+	wg.Wait()
+	if !controller.GetStates()["render_c"] {
+		t.Error("Expected the controller to keep running")
+	}
+	if effects, devices := controller.GetConnections(); effects["render_fx2"] != "render_c" || devices["render_d1"] != "render_c" || devices["render_d2"] != "" {
+		t.Errorf("Unexpected connections, effects %v devices %v", effects, devices)
+	}
+}
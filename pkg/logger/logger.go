@@ -41,13 +41,10 @@ func (*LogEventHook) Levels() []logrus.Level {
 }
 
 func (*LogEventHook) Fire(e *logrus.Entry) error {
-	event.Invoke(
-		event.Log,
-		map[string]interface{}{
-			"level": e.Level.String(),
-			"msg":   e.Message,
-		},
-	)
+	event.Invoke(event.LogData{
+		Level: e.Level.String(),
+		Msg:   e.Message,
+	})
 	return nil
 }
 
@@ -0,0 +1,57 @@
+package mqtt
+
+import (
+	"github.com/LedFx/ledfx/pkg/api"
+	"github.com/LedFx/ledfx/pkg/config"
+	"github.com/LedFx/ledfx/pkg/logger"
+)
+
+// The password is never sent back, only whether one is set
+type mqttInfo struct {
+	Config      config.MqttConfig `json:"config"`
+	HasPassword bool              `json:"has_password"`
+	Connected   bool              `json:"connected"`
+}
+
+func info() mqttInfo {
+	c := config.GetMqtt()
+	hasPassword := c.Password != ""
+	c.Password = ""
+	return mqttInfo{Config: c, HasPassword: hasPassword, Connected: Connected()}
+}
+
+func NewV1API(r *api.Router) {
+	schema, err := config.MqttSchema()
+	if err != nil {
+		logger.Logger.WithField("context", "MQTT API").Error(err)
+	}
+	r.Schema("MqttConfig", api.ConfigSchema(schema))
+	infoSchema := api.TypeSchema(mqttInfo{})
+	infoSchema["properties"].(map[string]interface{})["config"] = api.Ref("MqttConfig")
+	r.Schema("Mqtt", infoSchema)
+
+	r.Get("/mqtt", func(*api.Request) (interface{}, error) {
+		return info(), nil
+	}).Doc("MQTT", "Get the mqtt config and connection status. The password is never sent").Returns(api.Ref("Mqtt"))
+
+	r.Put("/mqtt", func(req *api.Request) (interface{}, error) {
+		c := map[string]interface{}{}
+		if err := req.Decode(&c); err != nil {
+			return nil, err
+		}
+		// a blank password keeps the stored one, as it's never sent to be sent back
+		if p, ok := c["password"]; ok && (p == nil || p == "") {
+			delete(c, "password")
+		}
+		if err := config.SetMqtt(c); err != nil {
+			return nil, api.BadRequest(err)
+		}
+		Start()
+		return info(), nil
+	}).Doc("MQTT", "Update the mqtt config and reconnect with it. A blank password keeps the stored one").
+		Accepts(api.Ref("MqttConfig")).Returns(api.Ref("Mqtt"))
+
+	r.Get("/mqtt/schema", func(*api.Request) (interface{}, error) {
+		return config.MqttSchema()
+	}).Doc("MQTT", "Get the schema of the mqtt config, for building forms").Returns(map[string]interface{}{"type": "object"})
+}
@@ -0,0 +1,34 @@
+package mqtt
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/LedFx/ledfx/pkg/api"
+	"github.com/LedFx/ledfx/pkg/api/apitest"
+	"github.com/LedFx/ledfx/pkg/config"
+)
+
+func TestV1API(t *testing.T) {
+	defer config.DisableSaving()()
+	r := api.NewRouter("/api/v1")
+	NewV1API(r)
+	if err := config.SetMqtt(map[string]interface{}{"enabled": false}); err != nil {
+		t.Fatal(err)
+	}
+	defer config.SetMqtt(map[string]interface{}{"password": ""})
+
+	apitest.Run(t, r, []apitest.Case{
+		{Method: http.MethodGet, Path: "/api/v1/mqtt", Code: http.StatusOK},
+		{Method: http.MethodPut, Path: "/api/v1/mqtt", Body: `{"password": "hunter2"}`, Code: http.StatusOK},
+		{Method: http.MethodPut, Path: "/api/v1/mqtt", Body: `{"port": 0}`, Code: http.StatusBadRequest},
+		{Method: http.MethodGet, Path: "/api/v1/mqtt/schema", Code: http.StatusOK},
+	})
+	// the password is redacted, and a blank one keeps it
+	_, res := apitest.Do(r, http.MethodPut, "/api/v1/mqtt", `{"password": ""}`)
+	c, _ := res["config"].(map[string]interface{})
+	if c["password"] != "" || res["has_password"] != true || config.GetMqtt().Password != "hunter2" {
+		t.Errorf("Expected the password to be kept and redacted, got %v", res)
+	}
+	apitest.Documents(t, r, "/api/v1", []string{"/mqtt"}, []string{"Mqtt", "MqttConfig"})
+}
@@ -0,0 +1,254 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+const dialTimeout = 5 * time.Second
+const ackTimeout = 5 * time.Second
+
+type Options struct {
+	ClientID  string
+	Username  string
+	Password  string
+	KeepAlive time.Duration // time between pings. defaults to 30s
+	Will      *Message      // published by the broker if the client drops off without disconnecting
+}
+
+// An MQTT client connection. Messages are published at QoS 0.
+type Client struct {
+	conn      net.Conn
+	keepAlive time.Duration
+	writeMu   sync.Mutex
+	mu        sync.Mutex
+	handlers  map[string]func(Message) // by topic filter
+	acks      map[uint16]chan byte     // pending subscriptions, by packet id
+	nextID    uint16
+	done      chan struct{}
+	err       error
+	closeOnce sync.Once
+}
+
+var ErrClosed = errors.New("mqtt connection closed")
+
+// Connects to a broker at addr (host:port)
+func Dial(addr string, o Options) (*Client, error) {
+	if o.KeepAlive <= 0 {
+		o.KeepAlive = 30 * time.Second
+	}
+	conn, err := net.DialTimeout("tcp", addr, dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{
+		conn:      conn,
+		keepAlive: o.KeepAlive,
+		handlers:  map[string]func(Message){},
+		acks:      map[uint16]chan byte{},
+		done:      make(chan struct{}),
+	}
+	b, err := encodeConnect(connectOptions{
+		clientID:  o.ClientID,
+		username:  o.Username,
+		password:  o.Password,
+		keepAlive: uint16(o.KeepAlive / time.Second),
+		will:      o.Will,
+	})
+	if err == nil {
+		err = c.write(b)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	// the broker must answer the connect before anything else
+	r := bufio.NewReader(conn)
+	conn.SetReadDeadline(time.Now().Add(dialTimeout))
+	p, err := readPacket(r)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error reading connack: %w", err)
+	}
+	if p.kind != packetConnack || len(p.body) != 2 {
+		conn.Close()
+		return nil, fmt.Errorf("expected connack, got packet type %d", p.kind)
+	}
+	if code := p.body[1]; code != 0 {
+		conn.Close()
+		if reason, ok := connackErrors[code]; ok {
+			return nil, fmt.Errorf("broker refused connection: %s", reason)
+		}
+		return nil, fmt.Errorf("broker refused connection with code %d", code)
+	}
+	go c.readLoop(r)
+	go c.pingLoop()
+	return c, nil
+}
+
+func (c *Client) write(b []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(ackTimeout))
+	_, err := c.conn.Write(b)
+	return err
+}
+
+// Publishes a message at QoS 0
+func (c *Client) Publish(topic string, payload []byte, retain bool) error {
+	b, err := encodePublish(Message{Topic: topic, Payload: payload, Retain: retain})
+	if err != nil {
+		return err
+	}
+	if err = c.write(b); err != nil {
+		c.close(err)
+	}
+	return err
+}
+
+/*
+Subscribes to a topic filter, which may have + and # wildcards.
+The handler is called for each message on the connection's read goroutine, so it should not block for long.
+Returns once the broker has acknowledged the subscription.
+*/
+func (c *Client) Subscribe(filter string, handler func(Message)) error {
+	c.mu.Lock()
+	c.nextID++
+	if c.nextID == 0 {
+		c.nextID++
+	}
+	id := c.nextID
+	ack := make(chan byte, 1)
+	c.acks[id] = ack
+	c.handlers[filter] = handler
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.acks, id)
+		c.mu.Unlock()
+	}()
+
+	b, err := encodeSubscribe(id, filter)
+	if err != nil {
+		return err
+	}
+	if err = c.write(b); err != nil {
+		c.close(err)
+		return err
+	}
+	select {
+	case code := <-ack:
+		if code == 0x80 {
+			return fmt.Errorf("broker refused subscription to %s", filter)
+		}
+		return nil
+	case <-c.done:
+		return c.Err()
+	case <-time.After(ackTimeout):
+		return fmt.Errorf("timed out subscribing to %s", filter)
+	}
+}
+
+// Disconnects from the broker. The will message is not published.
+func (c *Client) Close() error {
+	b, _ := encodePacket(packetDisconnect, 0, nil)
+	err := c.write(b)
+	c.close(ErrClosed)
+	return err
+}
+
+// Closed when the connection ends
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Why the connection ended. Nil while it's open.
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func (c *Client) close(err error) {
+	c.closeOnce.Do(func() {
+		c.mu.Lock()
+		c.err = err
+		c.mu.Unlock()
+		c.conn.Close()
+		close(c.done)
+	})
+}
+
+func (c *Client) readLoop(r *bufio.Reader) {
+	for {
+		// the broker answers every ping, so a quiet connection is a dead one
+		c.conn.SetReadDeadline(time.Now().Add(c.keepAlive * 3 / 2))
+		p, err := readPacket(r)
+		if err != nil {
+			c.close(err)
+			return
+		}
+		switch p.kind {
+		case packetPublish:
+			m, qos, id, err := decodePublish(p)
+			if err != nil {
+				c.close(err)
+				return
+			}
+			if qos == 1 {
+				b, _ := encodePacket(packetPuback, 0, appendUint16(nil, id))
+				if err = c.write(b); err != nil {
+					c.close(err)
+					return
+				}
+			}
+			c.dispatch(m)
+		case packetSuback:
+			if len(p.body) < 3 {
+				continue
+			}
+			id := binary.BigEndian.Uint16(p.body)
+			c.mu.Lock()
+			if ack, ok := c.acks[id]; ok {
+				ack <- p.body[2]
+			}
+			c.mu.Unlock()
+		}
+	}
+}
+
+func (c *Client) dispatch(m Message) {
+	c.mu.Lock()
+	handlers := []func(Message){}
+	for filter, handler := range c.handlers {
+		if topicMatches(filter, m.Topic) {
+			handlers = append(handlers, handler)
+		}
+	}
+	c.mu.Unlock()
+	for _, handler := range handlers {
+		handler(m)
+	}
+}
+
+func (c *Client) pingLoop() {
+	ticker := time.NewTicker(c.keepAlive)
+	defer ticker.Stop()
+	ping, _ := encodePacket(packetPingreq, 0, nil)
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			if err := c.write(ping); err != nil {
+				c.close(err)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,441 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LedFx/ledfx/pkg/config"
+	"github.com/LedFx/ledfx/pkg/constants"
+	"github.com/LedFx/ledfx/pkg/controller"
+	"github.com/LedFx/ledfx/pkg/effect"
+	"github.com/LedFx/ledfx/pkg/event"
+	"github.com/LedFx/ledfx/pkg/loader"
+	log "github.com/LedFx/ledfx/pkg/logger"
+)
+
+/*
+Controllers are published to Home Assistant as lights, using its MQTT discovery and json light schema.
+With the default config, controller "desk" uses these topics:
+	ledfx/status                                   "online" or "offline"
+	ledfx/controller/desk/state                    {"state": "ON", "brightness": 255, "effect": "my_effect"}
+	ledfx/controller/desk/set                      commands, in the same format as the state
+	homeassistant/light/ledfx/desk/config          discovery config
+The effect list of each light is the ids of the effects in LedFx. Setting an effect connects it to the controller.
+
+Profiles are LedFx's scenes, so each is published as a Home Assistant scene. Activating it switches to the profile.
+Profile "Gig Night" uses these topics:
+	ledfx/profile/gig_night/set                    "ON" switches to the profile
+	homeassistant/scene/ledfx/profile_gig_night/config   discovery config
+*/
+
+const retryInterval = 5 * time.Second
+
+var client *Client
+var clientMu sync.Mutex
+var mqttConfig config.MqttConfig // config of the running connection
+var configMu sync.RWMutex
+var stop chan struct{}
+var unsubs []func()
+var scenes = map[string]bool{} // topic ids of the profiles published as scenes, so deleted ones can be removed
+var scenesMu sync.Mutex
+
+// Connects to the broker in the mqtt config, if it's enabled. Replaces any running connection.
+// Connection errors are logged, and the connection is retried until Stop is called.
+func Start() {
+	Stop()
+	c := config.GetMqtt()
+	if !c.Enabled {
+		return
+	}
+	configMu.Lock()
+	mqttConfig = c
+	configMu.Unlock()
+	clientMu.Lock()
+	stop = make(chan struct{})
+	unsubs = []func(){
+		event.Subscribe(event.ControllerUpdate, func(e *event.Event) {
+			if data, ok := e.Data.(event.ControllerUpdateData); ok {
+				publishController(data.ID)
+			}
+		}),
+		event.Subscribe(event.ControllerDelete, func(e *event.Event) {
+			if data, ok := e.Data.(event.ControllerDeleteData); ok {
+				removeController(data.ID)
+			}
+		}),
+		// the active effect of each controller
+		event.Subscribe(event.ConnectionsUpdate, func(e *event.Event) { publishStates() }),
+		// the effect list of each light
+		event.Subscribe(event.EffectUpdate, func(e *event.Event) { publishAll() }),
+		event.Subscribe(event.EffectDelete, func(e *event.Event) { publishAll() }),
+		event.Subscribe(event.ProfilesUpdate, func(e *event.Event) { publishScenes() }),
+	}
+	go run(c, stop)
+	clientMu.Unlock()
+}
+
+// Disconnects from the broker, marking LedFx as offline
+func Stop() {
+	clientMu.Lock()
+	defer clientMu.Unlock()
+	for _, unsub := range unsubs {
+		unsub()
+	}
+	unsubs = nil
+	if stop != nil {
+		close(stop)
+		stop = nil
+	}
+	if client != nil {
+		client.Publish(statusTopic(), []byte("offline"), true)
+		client.Close()
+		client = nil
+	}
+}
+
+// Whether LedFx is connected to the broker
+func Connected() bool {
+	clientMu.Lock()
+	defer clientMu.Unlock()
+	return client != nil
+}
+
+// keeps a connection to the broker until stopped
+func run(c config.MqttConfig, stop chan struct{}) {
+	addr := net.JoinHostPort(c.Host, fmt.Sprint(c.Port))
+	for {
+		cl, err := connect(addr, c)
+		if err != nil {
+			log.Logger.WithField("context", "MQTT").Warnf("Cannot connect to MQTT broker at %s: %v", addr, err)
+		} else {
+			clientMu.Lock()
+			select {
+			case <-stop:
+				// stopped while connecting
+				clientMu.Unlock()
+				cl.Close()
+				return
+			default:
+			}
+			client = cl
+			clientMu.Unlock()
+			log.Logger.WithField("context", "MQTT").Infof("Connected to MQTT broker at %s", addr)
+			publishAll()
+			<-cl.Done()
+			clientMu.Lock()
+			if client == cl {
+				client = nil
+			}
+			clientMu.Unlock()
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			log.Logger.WithField("context", "MQTT").Warnf("Lost connection to MQTT broker: %v", cl.Err())
+		}
+		select {
+		case <-stop:
+			return
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+func connect(addr string, c config.MqttConfig) (*Client, error) {
+	cl, err := Dial(addr, Options{
+		ClientID: c.ClientID,
+		Username: c.Username,
+		Password: c.Password,
+		Will:     &Message{Topic: statusTopic(), Payload: []byte("offline"), Retain: true},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err = cl.Subscribe(c.BaseTopic+"/controller/+/set", handleCommand); err != nil {
+		cl.Close()
+		return nil, err
+	}
+	if err = cl.Subscribe(c.BaseTopic+"/profile/+/set", handleSceneCommand); err != nil {
+		cl.Close()
+		return nil, err
+	}
+	if err = cl.Publish(statusTopic(), []byte("online"), true); err != nil {
+		cl.Close()
+		return nil, err
+	}
+	return cl, nil
+}
+
+func getConfig() config.MqttConfig {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return mqttConfig
+}
+
+func statusTopic() string {
+	return getConfig().BaseTopic + "/status"
+}
+
+func controllerTopic(id string) string {
+	return getConfig().BaseTopic + "/controller/" + id
+}
+
+func discoveryTopic(id string) string {
+	c := getConfig()
+	return fmt.Sprintf("%s/light/%s/%s/config", c.DiscoveryPrefix, c.ClientID, id)
+}
+
+func sceneTopic(id string) string {
+	return getConfig().BaseTopic + "/profile/" + id
+}
+
+func sceneDiscoveryTopic(id string) string {
+	c := getConfig()
+	return fmt.Sprintf("%s/scene/%s/profile_%s/config", c.DiscoveryPrefix, c.ClientID, id)
+}
+
+// profile names can have spaces and capitals, which topic ids shouldn't
+func sceneID(profile string) string {
+	return strings.ToLower(strings.ReplaceAll(profile, " ", "_"))
+}
+
+// State and commands share this format
+type lightState struct {
+	State      string  `json:"state,omitempty"` // ON or OFF
+	Brightness *int    `json:"brightness,omitempty"`
+	Effect     *string `json:"effect,omitempty"`
+}
+
+type discoveryDevice struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+	SwVersion    string   `json:"sw_version"`
+}
+
+type discoveryConfig struct {
+	Name              string          `json:"name"`
+	UniqueID          string          `json:"unique_id"`
+	Schema            string          `json:"schema"`
+	StateTopic        string          `json:"state_topic"`
+	CommandTopic      string          `json:"command_topic"`
+	AvailabilityTopic string          `json:"availability_topic"`
+	Brightness        bool            `json:"brightness"`
+	BrightnessScale   int             `json:"brightness_scale"`
+	Effect            bool            `json:"effect"`
+	EffectList        []string        `json:"effect_list"`
+	Device            discoveryDevice `json:"device"`
+}
+
+type sceneDiscoveryConfig struct {
+	Name              string          `json:"name"`
+	UniqueID          string          `json:"unique_id"`
+	CommandTopic      string          `json:"command_topic"`
+	PayloadOn         string          `json:"payload_on"`
+	AvailabilityTopic string          `json:"availability_topic"`
+	Device            discoveryDevice `json:"device"`
+}
+
+func controllerState(v *controller.Controller) lightState {
+	s := lightState{State: "OFF"}
+	if v.State {
+		s.State = "ON"
+	}
+	brightness := int(math.Round(v.Config.Brightness * 255))
+	s.Brightness = &brightness
+	effectID := v.EffectID()
+	s.Effect = &effectID
+	return s
+}
+
+func controllerDiscovery(v *controller.Controller) discoveryConfig {
+	effects := effect.GetIDs()
+	sort.Strings(effects)
+	clientID := getConfig().ClientID
+	return discoveryConfig{
+		Name:              v.Config.Name,
+		UniqueID:          clientID + "_" + v.ID,
+		Schema:            "json",
+		StateTopic:        controllerTopic(v.ID) + "/state",
+		CommandTopic:      controllerTopic(v.ID) + "/set",
+		AvailabilityTopic: statusTopic(),
+		Brightness:        true,
+		BrightnessScale:   255,
+		Effect:            true,
+		EffectList:        effects,
+		Device:            ledfxDevice(),
+	}
+}
+
+func sceneDiscovery(profile string) sceneDiscoveryConfig {
+	id := sceneID(profile)
+	return sceneDiscoveryConfig{
+		Name:              profile,
+		UniqueID:          getConfig().ClientID + "_profile_" + id,
+		CommandTopic:      sceneTopic(id) + "/set",
+		PayloadOn:         "ON",
+		AvailabilityTopic: statusTopic(),
+		Device:            ledfxDevice(),
+	}
+}
+
+// lights and scenes all belong to the one LedFx device
+func ledfxDevice() discoveryDevice {
+	return discoveryDevice{
+		Identifiers:  []string{getConfig().ClientID},
+		Name:         "LedFx",
+		Manufacturer: "LedFx",
+		SwVersion:    constants.VERSION,
+	}
+}
+
+// publishes a json message, if connected
+func publishJSON(topic string, v interface{}) {
+	clientMu.Lock()
+	cl := client
+	clientMu.Unlock()
+	if cl == nil {
+		return
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		log.Logger.WithField("context", "MQTT").Error(err)
+		return
+	}
+	if err = cl.Publish(topic, b, true); err != nil {
+		log.Logger.WithField("context", "MQTT").Warnf("Error publishing to %s: %v", topic, err)
+	}
+}
+
+// publishes the discovery config and state of a controller
+func publishController(id string) {
+	v, err := controller.Get(id)
+	if err != nil {
+		return
+	}
+	publishJSON(discoveryTopic(id), controllerDiscovery(v))
+	publishJSON(controllerTopic(id)+"/state", controllerState(v))
+}
+
+// removes a deleted controller from Home Assistant
+func removeController(id string) {
+	clientMu.Lock()
+	cl := client
+	clientMu.Unlock()
+	if cl == nil {
+		return
+	}
+	// an empty retained message deletes the discovered entity, and clears the retained state
+	cl.Publish(discoveryTopic(id), nil, true)
+	cl.Publish(controllerTopic(id)+"/state", nil, true)
+}
+
+func publishAll() {
+	for _, id := range controller.GetIDs() {
+		publishController(id)
+	}
+	publishScenes()
+}
+
+// publishes the discovery config of each profile, and removes deleted profiles from Home Assistant
+func publishScenes() {
+	clientMu.Lock()
+	cl := client
+	clientMu.Unlock()
+	if cl == nil {
+		return
+	}
+	_, profiles := config.GetProfiles()
+	scenesMu.Lock()
+	defer scenesMu.Unlock()
+	current := map[string]bool{}
+	for _, name := range profiles {
+		current[sceneID(name)] = true
+		publishJSON(sceneDiscoveryTopic(sceneID(name)), sceneDiscovery(name))
+	}
+	for id := range scenes {
+		if !current[id] {
+			cl.Publish(sceneDiscoveryTopic(id), nil, true)
+		}
+	}
+	scenes = current
+}
+
+func publishStates() {
+	for _, id := range controller.GetIDs() {
+		if v, err := controller.Get(id); err == nil {
+			publishJSON(controllerTopic(id)+"/state", controllerState(v))
+		}
+	}
+}
+
+// handles a command on <base>/controller/<id>/set
+func handleCommand(m Message) {
+	parts := strings.Split(m.Topic, "/")
+	if len(parts) < 3 {
+		return
+	}
+	id := parts[len(parts)-2]
+	v, err := controller.Get(id)
+	if err != nil {
+		log.Logger.WithField("context", "MQTT").Warnf("Command for unknown controller %s", id)
+		return
+	}
+	cmd := lightState{}
+	if err = json.Unmarshal(m.Payload, &cmd); err != nil {
+		log.Logger.WithField("context", "MQTT").Warnf("Invalid command for controller %s: %v", id, err)
+		return
+	}
+	// connect the effect before turning on, as a controller cannot start without one
+	if cmd.Effect != nil && *cmd.Effect != v.EffectID() {
+		if err = controller.ConnectEffect(*cmd.Effect, id); err != nil {
+			log.Logger.WithField("context", "MQTT").Warnf("Cannot set effect of controller %s: %v", id, err)
+		}
+	}
+	if cmd.Brightness != nil {
+		brightness := math.Max(0, math.Min(float64(*cmd.Brightness)/255, 1))
+		if err = v.SetBrightness(brightness); err != nil {
+			log.Logger.WithField("context", "MQTT").Warnf("Cannot set brightness of controller %s: %v", id, err)
+		}
+	}
+	if cmd.State == "ON" || cmd.State == "OFF" {
+		if err = controller.SetStates(map[string]bool{id: cmd.State == "ON"}); err != nil {
+			log.Logger.WithField("context", "MQTT").Warnf("Cannot set state of controller %s: %v", id, err)
+		}
+	}
+	// confirm the state, as not every command changes it
+	publishJSON(controllerTopic(id)+"/state", controllerState(v))
+}
+
+// handles a command on <base>/profile/<id>/set, switching to the profile
+func handleSceneCommand(m Message) {
+	parts := strings.Split(m.Topic, "/")
+	if len(parts) < 3 || string(m.Payload) != "ON" {
+		return
+	}
+	id := parts[len(parts)-2]
+	active, profiles := config.GetProfiles()
+	for _, name := range profiles {
+		if sceneID(name) != id {
+			continue
+		}
+		if name == active {
+			return
+		}
+		// switching restarts the controllers, so don't hold up the connection
+		go func() {
+			if err := loader.SwitchProfile(name); err != nil {
+				log.Logger.WithField("context", "MQTT").Warnf("Cannot switch to profile %s: %v", name, err)
+			}
+		}()
+		return
+	}
+	log.Logger.WithField("context", "MQTT").Warnf("Command for unknown profile %s", id)
+}
@@ -0,0 +1,311 @@
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LedFx/ledfx/pkg/api"
+	"github.com/LedFx/ledfx/pkg/config"
+	"github.com/LedFx/ledfx/pkg/controller"
+)
+
+// A stand-in for an MQTT broker. Routes publishes to matching subscribers, and records every publish.
+type broker struct {
+	ln        net.Listener
+	mu        sync.Mutex
+	subs      map[net.Conn][]string
+	published chan Message
+}
+
+func newBroker(t *testing.T) *broker {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &broker{ln: ln, subs: map[net.Conn][]string{}, published: make(chan Message, 100)}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go b.serve(conn)
+		}
+	}()
+	return b
+}
+
+func (b *broker) port() int {
+	return b.ln.Addr().(*net.TCPAddr).Port
+}
+
+func (b *broker) serve(conn net.Conn) {
+	defer func() {
+		b.mu.Lock()
+		delete(b.subs, conn)
+		b.mu.Unlock()
+		conn.Close()
+	}()
+	r := bufio.NewReader(conn)
+	if p, err := readPacket(r); err != nil || p.kind != packetConnect {
+		return
+	}
+	conn.Write([]byte{packetConnack << 4, 2, 0, 0})
+	for {
+		p, err := readPacket(r)
+		if err != nil {
+			return
+		}
+		switch p.kind {
+		case packetPublish:
+			m, _, _, err := decodePublish(p)
+			if err != nil {
+				return
+			}
+			b.published <- m
+			b.route(m)
+		case packetSubscribe:
+			id := binary.BigEndian.Uint16(p.body)
+			filter, _, err := readString(p.body[2:])
+			if err != nil {
+				return
+			}
+			b.mu.Lock()
+			b.subs[conn] = append(b.subs[conn], filter)
+			b.mu.Unlock()
+			suback, _ := encodePacket(packetSuback, 0, append(appendUint16(nil, id), 0))
+			conn.Write(suback)
+		case packetPingreq:
+			conn.Write([]byte{packetPingresp << 4, 0})
+		case packetDisconnect:
+			return
+		}
+	}
+}
+
+// sends a message to the subscribers of its topic
+func (b *broker) route(m Message) {
+	pub, _ := encodePublish(m)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for conn, filters := range b.subs {
+		for _, filter := range filters {
+			if topicMatches(filter, m.Topic) {
+				conn.Write(pub)
+				break
+			}
+		}
+	}
+}
+
+// waits for a publish to a topic, skipping others
+func (b *broker) waitFor(t *testing.T, topic string) Message {
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case m := <-b.published:
+			if m.Topic == topic {
+				return m
+			}
+		case <-timeout:
+			t.Fatalf("Nothing was published to %s", topic)
+		}
+	}
+}
+
+func TestTopicMatches(t *testing.T) {
+	cases := []struct {
+		filter, topic string
+		match         bool
+	}{
+		{"a/b", "a/b", true},
+		{"a/b", "a/c", false},
+		{"a/+/c", "a/b/c", true},
+		{"a/+/c", "a/b/d", false},
+		{"a/+", "a/b/c", false},
+		{"a/#", "a/b/c", true},
+		{"a/#", "a", true},
+		{"#", "a/b", true},
+		{"a/b", "a/b/c", false},
+	}
+	for _, c := range cases {
+		if topicMatches(c.filter, c.topic) != c.match {
+			t.Errorf("Expected filter %s matching topic %s to be %v", c.filter, c.topic, c.match)
+		}
+	}
+}
+
+func TestPacketLength(t *testing.T) {
+	payload := bytes.Repeat([]byte{1}, 20000) // needs a 3 byte remaining length
+	b, err := encodePublish(Message{Topic: "a/b", Payload: payload, Retain: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := readPacket(bufio.NewReader(bytes.NewReader(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, _, _, err := decodePublish(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Topic != "a/b" || !m.Retain || !bytes.Equal(m.Payload, payload) {
+		t.Error("Publish did not survive encoding")
+	}
+}
+
+func TestClient(t *testing.T) {
+	b := newBroker(t)
+	c, err := Dial(b.ln.Addr().String(), Options{ClientID: "test", KeepAlive: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	received := make(chan Message, 1)
+	if err = c.Subscribe("test/+/set", func(m Message) { received <- m }); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Publish("test/a/set", []byte("hello"), false); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case m := <-received:
+		if string(m.Payload) != "hello" {
+			t.Errorf("Expected 'hello', got '%s'", m.Payload)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Subscribed message was not received")
+	}
+	// pings keep the connection alive past the keep alive
+	time.Sleep(200 * time.Millisecond)
+	select {
+	case <-c.Done():
+		t.Fatalf("Connection dropped: %v", c.Err())
+	default:
+	}
+}
+
+func TestHomeAssistant(t *testing.T) {
+	defer config.DisableSaving()()
+	b := newBroker(t)
+	if _, _, err := controller.New("mqtt_test", map[string]interface{}{"name": "MQTT Test"}); err != nil {
+		t.Fatal(err)
+	}
+	defer controller.Destroy("mqtt_test")
+	err := config.SetMqtt(map[string]interface{}{"enabled": true, "host": "127.0.0.1", "port": b.port()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	Start()
+	defer Stop()
+
+	if m := b.waitFor(t, "ledfx/status"); string(m.Payload) != "online" || !m.Retain {
+		t.Errorf("Expected retained 'online' status, got '%s'", m.Payload)
+	}
+	m := b.waitFor(t, "homeassistant/light/ledfx/mqtt_test/config")
+	discovery := discoveryConfig{}
+	if err = json.Unmarshal(m.Payload, &discovery); err != nil {
+		t.Fatal(err)
+	}
+	if discovery.Name != "MQTT Test" || discovery.CommandTopic != "ledfx/controller/mqtt_test/set" || discovery.Schema != "json" {
+		t.Errorf("Unexpected discovery config %+v", discovery)
+	}
+
+	// profiles are discovered as scenes, and removed when deleted
+	active, _ := config.GetProfiles()
+	if m = b.waitFor(t, "homeassistant/scene/ledfx/profile_"+sceneID(active)+"/config"); !strings.Contains(string(m.Payload), `"command_topic":"ledfx/profile/`+sceneID(active)+`/set"`) {
+		t.Errorf("Unexpected scene discovery config %s", m.Payload)
+	}
+	if err = config.CreateProfile("MQTT Scene"); err != nil {
+		t.Fatal(err)
+	}
+	b.waitFor(t, "homeassistant/scene/ledfx/profile_mqtt_scene/config")
+	if err = config.DeleteProfile("MQTT Scene"); err != nil {
+		t.Fatal(err)
+	}
+	// it may be republished before it's removed
+	for len(b.waitFor(t, "homeassistant/scene/ledfx/profile_mqtt_scene/config").Payload) > 0 {
+		continue
+	}
+
+	// Home Assistant sends commands to the command topic
+	b.route(Message{Topic: "ledfx/controller/mqtt_test/set", Payload: []byte(`{"state": "OFF", "brightness": 51}`)})
+	for {
+		m = b.waitFor(t, "ledfx/controller/mqtt_test/state")
+		state := lightState{}
+		if err = json.Unmarshal(m.Payload, &state); err != nil {
+			t.Fatal(err)
+		}
+		if state.Brightness != nil && *state.Brightness == 51 {
+			if state.State != "OFF" {
+				t.Errorf("Expected state OFF, got %s", state.State)
+			}
+			break
+		}
+	}
+	v, _ := controller.Get("mqtt_test")
+	if v.Config.Brightness != 0.2 {
+		t.Errorf("Expected controller brightness 0.2, got %v", v.Config.Brightness)
+	}
+
+	Stop()
+	if m := b.waitFor(t, "ledfx/status"); string(m.Payload) != "offline" {
+		t.Errorf("Expected 'offline' status on stop, got '%s'", m.Payload)
+	}
+	if Connected() {
+		t.Error("Should not be connected after stopping")
+	}
+	if !strings.HasPrefix(discovery.UniqueID, "ledfx_") {
+		t.Errorf("Unexpected unique id %s", discovery.UniqueID)
+	}
+}
+
+func TestAPIPassword(t *testing.T) {
+	defer config.DisableSaving()()
+	mux := http.NewServeMux()
+	r := api.NewRouter("/api/v1")
+	NewV1API(r)
+	r.Serve(mux)
+	put := func(body string) mqttInfo {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/api/v1/mqtt", strings.NewReader(body)))
+		info := mqttInfo{}
+		if err := json.NewDecoder(w.Body).Decode(&info); err != nil {
+			t.Fatal(err)
+		}
+		return info
+	}
+	// saving the config restarts the client, which is kept disabled
+	if err := config.SetMqtt(map[string]interface{}{"enabled": false}); err != nil {
+		t.Fatal(err)
+	}
+	defer config.SetMqtt(map[string]interface{}{"username": "", "password": ""})
+
+	if info := put(`{"password": "hunter2"}`); info.Config.Password != "" || !info.HasPassword {
+		t.Errorf("Expected the password to be redacted, got %+v", info)
+	}
+	// the client sends back the blank password it was given
+	if info := put(`{"password": "", "username": "ledfx"}`); !info.HasPassword || config.GetMqtt().Password != "hunter2" {
+		t.Errorf("Expected a blank password to keep the stored one, got %+v", info)
+	}
+}
+
+func TestSchema(t *testing.T) {
+	defer config.DisableSaving()()
+	if _, err := config.MqttSchema(); err != nil {
+		t.Error(err)
+	}
+	if err := config.SetMqtt(map[string]interface{}{"enabled": false, "host": ""}); err == nil {
+		t.Error("Expected a blank host to be refused")
+	}
+}
@@ -0,0 +1,228 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+/*
+Just enough of MQTT 3.1.1 for LedFx: connect, publish and subscribe at QoS 0, and keep alive.
+Incoming QoS 1 publishes are acknowledged, QoS 2 is not supported.
+See https://docs.oasis-open.org/mqtt/mqtt/v3.1.1/os/mqtt-v3.1.1-os.html
+*/
+
+// Control packet types
+const (
+	packetConnect    byte = 1
+	packetConnack    byte = 2
+	packetPublish    byte = 3
+	packetPuback     byte = 4
+	packetSubscribe  byte = 8
+	packetSuback     byte = 9
+	packetPingreq    byte = 12
+	packetPingresp   byte = 13
+	packetDisconnect byte = 14
+)
+
+const maxRemainingLength = 268435455
+
+// A packet as read off the wire
+type packet struct {
+	kind  byte // control packet type
+	flags byte // low nibble of the fixed header
+	body  []byte
+}
+
+// A message published to a topic
+type Message struct {
+	Topic   string
+	Payload []byte
+	Retain  bool
+}
+
+func readPacket(r *bufio.Reader) (packet, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return packet{}, err
+	}
+	// remaining length is a varint of up to 4 bytes
+	length := 0
+	for shift := 0; ; shift += 7 {
+		if shift > 21 {
+			return packet{}, errors.New("malformed remaining length")
+		}
+		b, err := r.ReadByte()
+		if err != nil {
+			return packet{}, err
+		}
+		length |= int(b&0x7f) << shift
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	body := make([]byte, length)
+	if _, err = io.ReadFull(r, body); err != nil {
+		return packet{}, err
+	}
+	return packet{kind: header >> 4, flags: header & 0x0f, body: body}, nil
+}
+
+func encodePacket(kind, flags byte, body []byte) ([]byte, error) {
+	if len(body) > maxRemainingLength {
+		return nil, fmt.Errorf("packet of %d bytes is too large", len(body))
+	}
+	b := []byte{kind<<4 | flags&0x0f}
+	length := len(body)
+	for {
+		digit := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			digit |= 0x80
+		}
+		b = append(b, digit)
+		if length == 0 {
+			break
+		}
+	}
+	return append(b, body...), nil
+}
+
+func appendUint16(b []byte, n uint16) []byte {
+	return append(b, byte(n>>8), byte(n))
+}
+
+func appendString(b []byte, s string) []byte {
+	return appendBytes(b, []byte(s))
+}
+
+func appendBytes(b []byte, p []byte) []byte {
+	b = appendUint16(b, uint16(len(p)))
+	return append(b, p...)
+}
+
+// reads a length prefixed string from the start of b, returning the rest of b
+func readString(b []byte) (string, []byte, error) {
+	if len(b) < 2 {
+		return "", nil, errors.New("packet too short")
+	}
+	n := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+n {
+		return "", nil, errors.New("packet too short")
+	}
+	return string(b[2 : 2+n]), b[2+n:], nil
+}
+
+type connectOptions struct {
+	clientID  string
+	username  string
+	password  string
+	keepAlive uint16 // seconds
+	will      *Message
+}
+
+func encodeConnect(o connectOptions) ([]byte, error) {
+	var flags byte = 0x02 // clean session
+	body := appendString(nil, "MQTT")
+	body = append(body, 4) // protocol level 3.1.1
+	if o.will != nil {
+		flags |= 0x04
+		if o.will.Retain {
+			flags |= 0x20
+		}
+	}
+	if o.username != "" {
+		flags |= 0x80
+		if o.password != "" {
+			flags |= 0x40
+		}
+	}
+	body = append(body, flags)
+	body = appendUint16(body, o.keepAlive)
+	body = appendString(body, o.clientID)
+	if o.will != nil {
+		body = appendString(body, o.will.Topic)
+		body = appendBytes(body, o.will.Payload)
+	}
+	if o.username != "" {
+		body = appendString(body, o.username)
+		if o.password != "" {
+			body = appendString(body, o.password)
+		}
+	}
+	return encodePacket(packetConnect, 0, body)
+}
+
+// Reasons a broker may refuse a connection, by connack return code
+var connackErrors = map[byte]string{
+	1: "unacceptable protocol version",
+	2: "client id rejected",
+	3: "server unavailable",
+	4: "bad username or password",
+	5: "not authorized",
+}
+
+func encodePublish(m Message) ([]byte, error) {
+	var flags byte
+	if m.Retain {
+		flags |= 0x01
+	}
+	body := appendString(nil, m.Topic)
+	body = append(body, m.Payload...)
+	return encodePacket(packetPublish, flags, body)
+}
+
+// decodes an incoming publish, and its packet id if it needs to be acknowledged
+func decodePublish(p packet) (m Message, qos byte, id uint16, err error) {
+	qos = (p.flags >> 1) & 0x03
+	m.Retain = p.flags&0x01 != 0
+	var rest []byte
+	if m.Topic, rest, err = readString(p.body); err != nil {
+		return m, qos, id, err
+	}
+	if qos > 0 {
+		if len(rest) < 2 {
+			return m, qos, id, errors.New("packet too short")
+		}
+		id = binary.BigEndian.Uint16(rest)
+		rest = rest[2:]
+	}
+	m.Payload = rest
+	return m, qos, id, nil
+}
+
+func encodeSubscribe(id uint16, filter string) ([]byte, error) {
+	body := appendUint16(nil, id)
+	body = appendString(body, filter)
+	body = append(body, 0) // qos 0
+	return encodePacket(packetSubscribe, 0x02, body)
+}
+
+// Whether a topic matches a subscription filter, with + and # wildcards
+func topicMatches(filter, topic string) bool {
+	for {
+		fi, ti := strings.IndexByte(filter, '/'), strings.IndexByte(topic, '/')
+		if fi < 0 {
+			fi = len(filter)
+		}
+		if ti < 0 {
+			ti = len(topic)
+		}
+		f, t := filter[:fi], topic[:ti]
+		if f == "#" {
+			return true
+		}
+		if f != "+" && f != t {
+			return false
+		}
+		fDone, tDone := fi == len(filter), ti == len(topic)
+		if fDone || tDone {
+			// "a/#" also matches "a"
+			return fDone && tDone || (tDone && filter[fi+1:] == "#")
+		}
+		filter, topic = filter[fi+1:], topic[ti+1:]
+	}
+}
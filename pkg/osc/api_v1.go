@@ -0,0 +1,52 @@
+package osc
+
+import (
+	"github.com/LedFx/ledfx/pkg/api"
+	"github.com/LedFx/ledfx/pkg/config"
+	"github.com/LedFx/ledfx/pkg/logger"
+)
+
+type oscInfo struct {
+	Config  config.OscConfig `json:"config"`
+	Running bool             `json:"running"`
+}
+
+func info() oscInfo {
+	srvMu.Lock()
+	defer srvMu.Unlock()
+	return oscInfo{Config: config.GetOsc(), Running: srv != nil}
+}
+
+func NewV1API(r *api.Router) {
+	schema, err := config.OscSchema()
+	if err != nil {
+		logger.Logger.WithField("context", "OSC API").Error(err)
+	}
+	r.Schema("OscConfig", api.ConfigSchema(schema))
+	infoSchema := api.TypeSchema(oscInfo{})
+	infoSchema["properties"].(map[string]interface{})["config"] = api.Ref("OscConfig")
+	r.Schema("Osc", infoSchema)
+
+	r.Get("/osc", func(*api.Request) (interface{}, error) {
+		return info(), nil
+	}).Doc("OSC", "Get the osc config and whether the server is running").Returns(api.Ref("Osc"))
+
+	r.Put("/osc", func(req *api.Request) (interface{}, error) {
+		c := map[string]interface{}{}
+		if err := req.Decode(&c); err != nil {
+			return nil, err
+		}
+		if err := config.SetOsc(c); err != nil {
+			return nil, api.BadRequest(err)
+		}
+		if err := Start(); err != nil {
+			return nil, err
+		}
+		return info(), nil
+	}).Doc("OSC", "Update the osc config and restart the server with it").
+		Accepts(api.Ref("OscConfig")).Returns(api.Ref("Osc"))
+
+	r.Get("/osc/schema", func(*api.Request) (interface{}, error) {
+		return config.OscSchema()
+	}).Doc("OSC", "Get the schema of the osc config, for building forms").Returns(map[string]interface{}{"type": "object"})
+}
@@ -0,0 +1,24 @@
+package osc
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/LedFx/ledfx/pkg/api"
+	"github.com/LedFx/ledfx/pkg/api/apitest"
+	"github.com/LedFx/ledfx/pkg/config"
+)
+
+func TestV1API(t *testing.T) {
+	defer config.DisableSaving()()
+	r := api.NewRouter("/api/v1")
+	NewV1API(r)
+
+	apitest.Run(t, r, []apitest.Case{
+		{Method: http.MethodGet, Path: "/api/v1/osc", Code: http.StatusOK},
+		{Method: http.MethodPut, Path: "/api/v1/osc", Body: `{"enabled": false, "port": 9000}`, Code: http.StatusOK},
+		{Method: http.MethodPut, Path: "/api/v1/osc", Body: `{"host": "nope"}`, Code: http.StatusBadRequest},
+		{Method: http.MethodGet, Path: "/api/v1/osc/schema", Code: http.StatusOK},
+	})
+	apitest.Documents(t, r, "/api/v1", []string{"/osc"}, []string{"Osc", "OscConfig"})
+}
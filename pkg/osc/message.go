@@ -0,0 +1,212 @@
+package osc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+/*
+OSC 1.0 messages and bundles. See https://opensoundcontrol.stanford.edu/spec-1_0.html
+Arguments decode to int32, int64, float32, float64, string, []byte or bool.
+Bundle time tags are ignored, bundled messages are handled as soon as they arrive.
+*/
+
+type Message struct {
+	Address string
+	Args    []interface{}
+}
+
+const bundleTag = "#bundle"
+
+// Decodes a packet, which may be a message or a bundle of them
+func Decode(b []byte) ([]Message, error) {
+	if len(b) == 0 {
+		return nil, errors.New("empty packet")
+	}
+	if b[0] == '#' {
+		return decodeBundle(b)
+	}
+	m, err := decodeMessage(b)
+	if err != nil {
+		return nil, err
+	}
+	return []Message{m}, nil
+}
+
+func decodeBundle(b []byte) ([]Message, error) {
+	tag, rest, err := readString(b)
+	if err != nil {
+		return nil, err
+	}
+	if tag != bundleTag || len(rest) < 8 {
+		return nil, errors.New("malformed bundle")
+	}
+	rest = rest[8:] // time tag
+	messages := []Message{}
+	for len(rest) > 0 {
+		if len(rest) < 4 {
+			return nil, errors.New("malformed bundle element")
+		}
+		// checked before converting, as a large size would be negative as an int on 32 bit systems
+		if binary.BigEndian.Uint32(rest) > uint32(len(rest)-4) {
+			return nil, errors.New("malformed bundle element")
+		}
+		size := int(binary.BigEndian.Uint32(rest))
+		rest = rest[4:]
+		ms, err := Decode(rest[:size])
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, ms...)
+		rest = rest[size:]
+	}
+	return messages, nil
+}
+
+func decodeMessage(b []byte) (Message, error) {
+	m := Message{}
+	address, rest, err := readString(b)
+	if err != nil {
+		return m, err
+	}
+	if len(address) == 0 || address[0] != '/' {
+		return m, fmt.Errorf("invalid address '%s'", address)
+	}
+	m.Address = address
+	if len(rest) == 0 {
+		// type tags are optional in old implementations
+		return m, nil
+	}
+	tags, rest, err := readString(rest)
+	if err != nil {
+		return m, err
+	}
+	if len(tags) == 0 || tags[0] != ',' {
+		return m, errors.New("missing type tags")
+	}
+	for _, tag := range tags[1:] {
+		switch tag {
+		case 'i', 'f':
+			if len(rest) < 4 {
+				return m, errors.New("message too short")
+			}
+			n := binary.BigEndian.Uint32(rest)
+			if tag == 'i' {
+				m.Args = append(m.Args, int32(n))
+			} else {
+				m.Args = append(m.Args, math.Float32frombits(n))
+			}
+			rest = rest[4:]
+		case 'h', 'd':
+			if len(rest) < 8 {
+				return m, errors.New("message too short")
+			}
+			n := binary.BigEndian.Uint64(rest)
+			if tag == 'h' {
+				m.Args = append(m.Args, int64(n))
+			} else {
+				m.Args = append(m.Args, math.Float64frombits(n))
+			}
+			rest = rest[8:]
+		case 's', 'S':
+			var s string
+			if s, rest, err = readString(rest); err != nil {
+				return m, err
+			}
+			m.Args = append(m.Args, s)
+		case 'b':
+			if len(rest) < 4 {
+				return m, errors.New("message too short")
+			}
+			if binary.BigEndian.Uint32(rest) > uint32(len(rest)-4) {
+				return m, errors.New("message too short")
+			}
+			size := int(binary.BigEndian.Uint32(rest))
+			if len(rest) < 4+padded(size) {
+				return m, errors.New("message too short")
+			}
+			m.Args = append(m.Args, rest[4:4+size])
+			rest = rest[4+padded(size):]
+		case 'T':
+			m.Args = append(m.Args, true)
+		case 'F':
+			m.Args = append(m.Args, false)
+		case 'N', 'I':
+			// nil and impulse carry no value
+		default:
+			return m, fmt.Errorf("unsupported type tag '%c'", tag)
+		}
+	}
+	return m, nil
+}
+
+// Encodes a message. Ints are sent as int32, floats as float32.
+func (m Message) Encode() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	writeString(buf, m.Address)
+	tags := []byte{','}
+	args := &bytes.Buffer{}
+	for _, arg := range m.Args {
+		switch v := arg.(type) {
+		case int:
+			tags = append(tags, 'i')
+			binary.Write(args, binary.BigEndian, int32(v))
+		case int32:
+			tags = append(tags, 'i')
+			binary.Write(args, binary.BigEndian, v)
+		case int64:
+			tags = append(tags, 'h')
+			binary.Write(args, binary.BigEndian, v)
+		case float32:
+			tags = append(tags, 'f')
+			binary.Write(args, binary.BigEndian, v)
+		case float64:
+			tags = append(tags, 'f')
+			binary.Write(args, binary.BigEndian, float32(v))
+		case string:
+			tags = append(tags, 's')
+			writeString(args, v)
+		case []byte:
+			tags = append(tags, 'b')
+			binary.Write(args, binary.BigEndian, int32(len(v)))
+			args.Write(v)
+			args.Write(make([]byte, padded(len(v))-len(v)))
+		case bool:
+			if v {
+				tags = append(tags, 'T')
+			} else {
+				tags = append(tags, 'F')
+			}
+		default:
+			return nil, fmt.Errorf("cannot encode osc argument of type %T", arg)
+		}
+	}
+	writeString(buf, string(tags))
+	buf.Write(args.Bytes())
+	return buf.Bytes(), nil
+}
+
+// strings are null terminated, and padded to a multiple of 4 bytes
+func writeString(buf *bytes.Buffer, s string) {
+	buf.WriteString(s)
+	buf.Write(make([]byte, padded(len(s)+1)-len(s)))
+}
+
+func readString(b []byte) (string, []byte, error) {
+	end := bytes.IndexByte(b, 0)
+	if end < 0 {
+		return "", nil, errors.New("unterminated string")
+	}
+	size := padded(end + 1)
+	if size > len(b) {
+		return "", nil, errors.New("message too short")
+	}
+	return string(b[:end]), b[size:], nil
+}
+
+func padded(n int) int {
+	return (n + 3) &^ 3
+}
@@ -0,0 +1,197 @@
+package osc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/LedFx/ledfx/pkg/config"
+	"github.com/LedFx/ledfx/pkg/controller"
+	"github.com/LedFx/ledfx/pkg/effect"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	m := Message{Address: "/ledfx/test", Args: []interface{}{int32(7), float32(0.5), "hello", true, false, []byte{1, 2, 3}, int64(-2)}}
+	b, err := m.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b)%4 != 0 {
+		t.Errorf("Encoded message is not a multiple of 4 bytes: %d", len(b))
+	}
+	// bundle the message twice
+	bundle := &bytes.Buffer{}
+	writeString(bundle, bundleTag)
+	bundle.Write(make([]byte, 8))
+	for i := 0; i < 2; i++ {
+		binary.Write(bundle, binary.BigEndian, int32(len(b)))
+		bundle.Write(b)
+	}
+	messages, err := Decode(bundle.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("Expected 2 bundled messages, got %d", len(messages))
+	}
+	got := messages[1]
+	if got.Address != m.Address || len(got.Args) != len(m.Args) {
+		t.Fatalf("Expected %v, got %v", m, got)
+	}
+	for i := range m.Args {
+		if b, ok := m.Args[i].([]byte); ok {
+			if !bytes.Equal(b, got.Args[i].([]byte)) {
+				t.Errorf("Arg %d: expected %v, got %v", i, b, got.Args[i])
+			}
+		} else if got.Args[i] != m.Args[i] {
+			t.Errorf("Arg %d: expected %v, got %v", i, m.Args[i], got.Args[i])
+		}
+	}
+
+	if _, err = Decode([]byte("/no/terminator")); err == nil {
+		t.Error("Expected an error decoding an unterminated address")
+	}
+
+	// sizes which don't fit in an int on 32 bit systems are refused rather than sliced
+	blob := &bytes.Buffer{}
+	writeString(blob, "/blob")
+	writeString(blob, ",b")
+	binary.Write(blob, binary.BigEndian, uint32(0xffffffff))
+	if _, err = Decode(blob.Bytes()); err == nil {
+		t.Error("Expected an error decoding a blob larger than the message")
+	}
+	bundle.Reset()
+	writeString(bundle, bundleTag)
+	bundle.Write(make([]byte, 8))
+	binary.Write(bundle, binary.BigEndian, uint32(0xffffffff))
+	bundle.Write(b)
+	if _, err = Decode(bundle.Bytes()); err == nil {
+		t.Error("Expected an error decoding a bundle element larger than the bundle")
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	m, _ := Message{Address: "/ledfx/test", Args: []interface{}{int32(7), float32(0.5), "hello", true, []byte{1, 2, 3}, int64(-2)}}.Encode()
+	f.Add(m)
+	bundle := &bytes.Buffer{}
+	writeString(bundle, bundleTag)
+	bundle.Write(make([]byte, 8))
+	binary.Write(bundle, binary.BigEndian, int32(len(m)))
+	bundle.Write(m)
+	f.Add(bundle.Bytes())
+	f.Fuzz(func(t *testing.T, b []byte) {
+		// must not panic, whatever arrives
+		Decode(b)
+	})
+}
+
+func freePort(t *testing.T) int {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+// sends a message and waits for a message to the given address with the given value
+func exchange(t *testing.T, conn *net.UDPConn, m Message, address string, value interface{}) {
+	b, err := m.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = conn.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatalf("No reply of %v to %s: %v", value, address, err)
+		}
+		ms, err := Decode(buf[:n])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ms[0].Address == address && len(ms[0].Args) == 1 && ms[0].Args[0] == value {
+			return
+		}
+	}
+}
+
+func TestServer(t *testing.T) {
+	defer config.DisableSaving()()
+	if _, _, err := effect.New("osc_test", "energy", 10, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer effect.Destroy("osc_test")
+	if _, _, err := controller.New("osc_test", map[string]interface{}{"name": "OSC Test"}); err != nil {
+		t.Fatal(err)
+	}
+	defer controller.Destroy("osc_test")
+
+	port := freePort(t)
+	if err := config.SetOsc(map[string]interface{}{"enabled": true, "port": port}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer Stop()
+	conn, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// setting a value gives feedback to the client
+	address := "/ledfx/effect/osc_test/brightness"
+	exchange(t, conn, Message{Address: address, Args: []interface{}{float32(0.25)}}, address, float32(0.25))
+	exchange(t, conn, Message{Address: address}, address, float32(0.25))
+
+	// bools are set with 1 or 0, and a message without args asks for the value
+	address = "/ledfx/effect/osc_test/flip"
+	exchange(t, conn, Message{Address: address, Args: []interface{}{int32(1)}}, address, float32(1))
+	exchange(t, conn, Message{Address: address}, address, float32(1))
+
+	address = "/ledfx/controller/osc_test/state"
+	exchange(t, conn, Message{Address: address}, address, float32(0))
+
+	if _, err = configValue("not_a_key", float32(1)); err == nil {
+		t.Error("Expected an error for an unknown config key")
+	}
+}
+
+func TestAllowedSources(t *testing.T) {
+	allowed, err := parseSources([]string{"192.168.1.20", "10.0.0.0/8", "::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &server{allowed: allowed}
+	for addr, expected := range map[string]bool{
+		"192.168.1.20": true,
+		"192.168.1.21": false,
+		"10.1.2.3":     true,
+		"::1":          true,
+		"127.0.0.1":    false,
+	} {
+		if s.allows(&net.UDPAddr{IP: net.ParseIP(addr)}) != expected {
+			t.Errorf("Expected %s allowed to be %v", addr, expected)
+		}
+	}
+	if !(&server{}).allows(&net.UDPAddr{IP: net.ParseIP("1.2.3.4")}) {
+		t.Error("Expected any source to be allowed without an allow list")
+	}
+	if _, err = parseSources([]string{"not an address"}); err == nil {
+		t.Error("Expected an error for an invalid source")
+	}
+	if err = config.SetOsc(map[string]interface{}{"allowed_sources": []string{"nope"}}); err == nil {
+		t.Error("Expected the config to reject an invalid source")
+	}
+	if schema, err := config.OscSchema(); err != nil || schema["allowed_sources"] == nil {
+		t.Errorf("Expected the allowed sources in the schema, got %v %v", schema, err)
+	}
+}
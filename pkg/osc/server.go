@@ -0,0 +1,393 @@
+package osc
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/LedFx/ledfx/pkg/config"
+	"github.com/LedFx/ledfx/pkg/controller"
+	"github.com/LedFx/ledfx/pkg/effect"
+	"github.com/LedFx/ledfx/pkg/event"
+	log "github.com/LedFx/ledfx/pkg/logger"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+/*
+Address space:
+	/ledfx/controller/<id>/state   1 or 0 (or true, false) turns the controller on or off
+	/ledfx/effect/<id>/<key>       sets a key of the effect's base config, eg. /ledfx/effect/wave/brightness 0.5
+	/ledfx/global/<key>            sets a key of every effect's config, as the global effect settings
+A message with no arguments asks for the current value, which is sent back to the client.
+Feedback is sent to clients whenever a value changes, from OSC or anywhere else.
+Bools are sent as 1 or 0 floats, so toggles and faders can show them.
+*/
+
+// clients which sent messages get feedback. the least recent is forgotten past this many.
+const maxClients = 16
+
+type server struct {
+	conn      *net.UDPConn
+	feedback  *net.UDPAddr // configured feedback target. nil if none
+	allowed   []*net.IPNet // networks messages are accepted from. any if empty
+	mu        sync.Mutex
+	clients   []*net.UDPAddr         // most recent last
+	sent      map[string]interface{} // latest value sent, by address
+	unsubs    []func()
+	closeOnce sync.Once
+}
+
+var srv *server
+var srvMu sync.Mutex
+
+// Starts listening for OSC messages with the osc config, if it's enabled. Replaces any running server.
+func Start() error {
+	Stop()
+	c := config.GetOsc()
+	if !c.Enabled {
+		return nil
+	}
+	allowed, err := parseSources(c.AllowedSources)
+	if err != nil {
+		return err
+	}
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP(c.Host), Port: c.Port})
+	if err != nil {
+		return fmt.Errorf("error starting OSC server: %w", err)
+	}
+	s := &server{
+		conn:    conn,
+		allowed: allowed,
+		sent:    map[string]interface{}{},
+	}
+	if c.FeedbackHost != "" {
+		if s.feedback, err = net.ResolveUDPAddr("udp", net.JoinHostPort(c.FeedbackHost, fmt.Sprint(c.FeedbackPort))); err != nil {
+			conn.Close()
+			return fmt.Errorf("invalid OSC feedback address: %w", err)
+		}
+	}
+	s.unsubs = []func(){
+		event.Subscribe(event.ControllerUpdate, s.handleControllerUpdate),
+		event.Subscribe(event.EffectUpdate, s.handleEffectUpdate),
+		event.Subscribe(event.GlobalEffectUpdate, s.handleGlobalEffectUpdate),
+	}
+	srvMu.Lock()
+	srv = s
+	srvMu.Unlock()
+	go s.serve()
+	log.Logger.WithField("context", "OSC").Infof("Listening for OSC messages on %s", conn.LocalAddr())
+	if config.GetAuth().Enabled {
+		log.Logger.WithField("context", "OSC").Warn("OSC messages are not authenticated, so anyone who can reach the OSC port can control LedFx. Limit it with the osc host or allowed sources")
+	}
+	return nil
+}
+
+// Parses addresses and networks into networks. A single address is a network of just itself.
+func parseSources(sources []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(sources))
+	for _, src := range sources {
+		if ip := net.ParseIP(src); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(src)
+		if err != nil {
+			return nil, fmt.Errorf("invalid OSC allowed source '%s'", src)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// Whether messages are accepted from the address
+func (s *server) allows(addr *net.UDPAddr) bool {
+	if len(s.allowed) == 0 {
+		return true
+	}
+	for _, n := range s.allowed {
+		if n.Contains(addr.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+func Stop() {
+	srvMu.Lock()
+	s := srv
+	srv = nil
+	srvMu.Unlock()
+	if s != nil {
+		s.close()
+	}
+}
+
+func (s *server) close() {
+	s.closeOnce.Do(func() {
+		for _, unsub := range s.unsubs {
+			unsub()
+		}
+		s.conn.Close()
+	})
+}
+
+func (s *server) serve() {
+	buf := make([]byte, 65536)
+	for {
+		n, from, err := s.conn.ReadFromUDP(buf)
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			log.Logger.WithField("context", "OSC").Warn(err)
+			continue
+		}
+		if !s.allows(from) {
+			log.Logger.WithField("context", "OSC").Debugf("Ignored OSC packet from %s, which is not an allowed source", from)
+			continue
+		}
+		messages, err := Decode(buf[:n])
+		if err != nil {
+			log.Logger.WithField("context", "OSC").Debugf("Invalid OSC packet from %s: %v", from, err)
+			continue
+		}
+		s.addClient(from)
+		for _, m := range messages {
+			if err = s.handle(m, from); err != nil {
+				log.Logger.WithField("context", "OSC").Warnf("%s: %v", m.Address, err)
+			}
+		}
+	}
+}
+
+func (s *server) addClient(addr *net.UDPAddr) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, c := range s.clients {
+		if c.String() == addr.String() {
+			s.clients = append(s.clients[:i], s.clients[i+1:]...)
+			break
+		}
+	}
+	s.clients = append(s.clients, addr)
+	if len(s.clients) > maxClients {
+		s.clients = s.clients[1:]
+	}
+}
+
+func (s *server) handle(m Message, from *net.UDPAddr) error {
+	parts := strings.Split(strings.TrimPrefix(m.Address, "/"), "/")
+	if len(parts) < 3 || parts[0] != "ledfx" {
+		return errors.New("unknown address")
+	}
+	var arg interface{}
+	if len(m.Args) > 0 {
+		arg = m.Args[0]
+	}
+	switch {
+	case parts[1] == "controller" && len(parts) == 4 && parts[3] == "state":
+		id := parts[2]
+		v, err := controller.Get(id)
+		if err != nil {
+			return err
+		}
+		if arg == nil {
+			return s.sendTo(from, m.Address, v.State)
+		}
+		state, err := toBool(arg)
+		if err != nil {
+			return err
+		}
+		return controller.SetStates(map[string]bool{id: state})
+
+	case parts[1] == "effect" && len(parts) == 4:
+		e, err := effect.Get(parts[2])
+		if err != nil {
+			return err
+		}
+		key := parts[3]
+		if arg == nil {
+			current := map[string]interface{}{}
+			if err = mapstructure.Decode(e.GetConfig(), &current); err != nil {
+				return err
+			}
+			value, ok := current[key]
+			if !ok {
+				return fmt.Errorf("unknown effect config key '%s'", key)
+			}
+			return s.sendTo(from, m.Address, value)
+		}
+		value, err := configValue(key, arg)
+		if err != nil {
+			return err
+		}
+		return e.UpdateBaseConfig(map[string]interface{}{key: value})
+
+	case parts[1] == "global" && len(parts) == 3:
+		key := parts[2]
+		if arg == nil {
+			value, ok := config.GetEffectsGlobal()[key]
+			if !ok {
+				return fmt.Errorf("global setting '%s' has not been set", key)
+			}
+			return s.sendTo(from, m.Address, value)
+		}
+		value, err := configValue(key, arg)
+		if err != nil {
+			return err
+		}
+		return effect.SetGlobalSettings(map[string]interface{}{key: value})
+	}
+	return errors.New("unknown address")
+}
+
+// Converts an OSC argument to the type of the effect config field with the given key
+func configValue(key string, arg interface{}) (interface{}, error) {
+	t := reflect.TypeOf(effect.BaseEffectConfig{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Tag.Get("mapstructure") != key {
+			continue
+		}
+		switch field.Type.Kind() {
+		case reflect.Bool:
+			return toBool(arg)
+		case reflect.Int:
+			f, err := toFloat(arg)
+			return int(math.Round(f)), err
+		case reflect.Float64:
+			return toFloat(arg)
+		case reflect.String:
+			if s, ok := arg.(string); ok {
+				return s, nil
+			}
+			return nil, fmt.Errorf("'%s' takes a string, got %T", key, arg)
+		}
+		return nil, fmt.Errorf("'%s' cannot be set over OSC", key)
+	}
+	return nil, fmt.Errorf("unknown effect config key '%s'", key)
+}
+
+func toFloat(arg interface{}) (float64, error) {
+	switch v := arg.(type) {
+	case int32:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case float32:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	return 0, fmt.Errorf("expected a number, got %T", arg)
+}
+
+// toggles send 1 or 0, some tools send true or false
+func toBool(arg interface{}) (bool, error) {
+	if b, ok := arg.(bool); ok {
+		return b, nil
+	}
+	f, err := toFloat(arg)
+	return f >= 0.5, err
+}
+
+// converts a config value to an OSC argument
+func oscValue(v interface{}) (interface{}, bool) {
+	switch x := v.(type) {
+	case bool:
+		if x {
+			return float32(1), true
+		}
+		return float32(0), true
+	case int:
+		return int32(x), true
+	case float64:
+		return float32(x), true
+	case string:
+		return x, true
+	}
+	return nil, false
+}
+
+func (s *server) sendTo(addr *net.UDPAddr, address string, value interface{}) error {
+	arg, ok := oscValue(value)
+	if !ok {
+		return fmt.Errorf("cannot send value of type %T", value)
+	}
+	b, err := Message{Address: address, Args: []interface{}{arg}}.Encode()
+	if err != nil {
+		return err
+	}
+	_, err = s.conn.WriteToUDP(b, addr)
+	return err
+}
+
+// sends a value to every client and the feedback target, if it has changed since it was last sent
+func (s *server) feedbackValue(address string, value interface{}) {
+	arg, ok := oscValue(value)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	if prev, exists := s.sent[address]; exists && prev == arg {
+		s.mu.Unlock()
+		return
+	}
+	s.sent[address] = arg
+	targets := append([]*net.UDPAddr{}, s.clients...)
+	s.mu.Unlock()
+	if s.feedback != nil {
+		targets = append(targets, s.feedback)
+	}
+	b, err := Message{Address: address, Args: []interface{}{arg}}.Encode()
+	if err != nil {
+		return
+	}
+	for _, addr := range targets {
+		if _, err = s.conn.WriteToUDP(b, addr); err != nil {
+			log.Logger.WithField("context", "OSC").Debugf("Cannot send feedback to %s: %v", addr, err)
+		}
+	}
+}
+
+func (s *server) handleControllerUpdate(e *event.Event) {
+	if data, ok := e.Data.(event.ControllerUpdateData); ok {
+		s.feedbackValue("/ledfx/controller/"+data.ID+"/state", data.Active)
+	}
+}
+
+func (s *server) handleEffectUpdate(e *event.Event) {
+	data, ok := e.Data.(event.EffectUpdateData)
+	if !ok {
+		return
+	}
+	c := map[string]interface{}{}
+	if err := mapstructure.Decode(data.BaseConfig, &c); err != nil {
+		return
+	}
+	for key, value := range c {
+		s.feedbackValue("/ledfx/effect/"+data.ID+"/"+key, value)
+	}
+}
+
+func (s *server) handleGlobalEffectUpdate(e *event.Event) {
+	if data, ok := e.Data.(event.GlobalEffectUpdateData); ok {
+		for key, value := range data.Config {
+			s.feedbackValue("/ledfx/global/"+key, value)
+		}
+	}
+}
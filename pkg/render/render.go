@@ -103,3 +103,14 @@ func (pg *PixelGroup) CloneToAll(id string) {
 		color.Interpolate(cloneFrom, pg.Group[cloneTo])
 	}
 }
+
+// Deep copy of the pixel group, eg. to hand a frame to another goroutine
+func (pg *PixelGroup) Copy() *PixelGroup {
+	c := *pg
+	c.Group = make(map[string]color.Pixels, len(pg.Group))
+	for id, p := range pg.Group {
+		c.Group[id] = append(color.Pixels{}, p...)
+	}
+	c.Order = append([]string{}, pg.Order...)
+	return &c
+}
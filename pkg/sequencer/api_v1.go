@@ -0,0 +1,62 @@
+package sequencer
+
+import (
+	"io"
+
+	"github.com/LedFx/ledfx/pkg/api"
+)
+
+type seekRequest struct {
+	Position float64 `json:"position"` // seconds
+}
+
+func NewV1API(r *api.Router) {
+	r.Schema("Timeline", api.TypeSchema(Timeline{}))
+	statusSchema := api.TypeSchema(Status{})
+	statusSchema["properties"].(map[string]interface{})["timeline"] = api.Ref("Timeline")
+	r.Schema("SequencerStatus", statusSchema)
+
+	r.Get("/sequencer", func(*api.Request) (interface{}, error) {
+		return GetStatus(), nil
+	}).Doc("Sequencer", "Get the loaded timeline, and its position").Returns(api.Ref("SequencerStatus"))
+
+	r.Post("/sequencer/load", func(req *api.Request) (interface{}, error) {
+		b, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, api.BadRequest(err)
+		}
+		t, err := ParseTimeline(b)
+		if err != nil {
+			return nil, api.BadRequest(err)
+		}
+		if err = Load(t); err != nil {
+			return nil, api.BadRequest(err)
+		}
+		return GetStatus(), nil
+	}).Doc("Sequencer", "Load a timeline, stopping any which is playing").
+		Accepts(api.Ref("Timeline")).Returns(api.Ref("SequencerStatus"))
+
+	r.Post("/sequencer/play", func(*api.Request) (interface{}, error) {
+		if err := Play(); err != nil {
+			return nil, api.BadRequest(err)
+		}
+		return GetStatus(), nil
+	}).Doc("Sequencer", "Play the loaded timeline").Returns(api.Ref("SequencerStatus"))
+
+	r.Post("/sequencer/pause", func(*api.Request) (interface{}, error) {
+		Pause()
+		return GetStatus(), nil
+	}).Doc("Sequencer", "Pause the timeline").Returns(api.Ref("SequencerStatus"))
+
+	r.Post("/sequencer/seek", func(req *api.Request) (interface{}, error) {
+		data := seekRequest{}
+		if err := req.Decode(&data); err != nil {
+			return nil, err
+		}
+		if err := Seek(data.Position); err != nil {
+			return nil, api.BadRequest(err)
+		}
+		return GetStatus(), nil
+	}).Doc("Sequencer", "Seek to a position in seconds").
+		Accepts(api.TypeSchema(seekRequest{})).Returns(api.Ref("SequencerStatus"))
+}
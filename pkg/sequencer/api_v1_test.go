@@ -0,0 +1,30 @@
+package sequencer
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/LedFx/ledfx/pkg/api"
+	"github.com/LedFx/ledfx/pkg/api/apitest"
+	"github.com/LedFx/ledfx/pkg/config"
+)
+
+func TestV1API(t *testing.T) {
+	defer config.DisableSaving()()
+	r := api.NewRouter("/api/v1")
+	NewV1API(r)
+
+	apitest.Run(t, r, []apitest.Case{
+		{Method: http.MethodGet, Path: "/api/v1/sequencer", Code: http.StatusOK},
+		{Method: http.MethodPost, Path: "/api/v1/sequencer/load", Body: `{"cues": [{"time": 1, "action": "nope"}]}`, Code: http.StatusBadRequest},
+		{Method: http.MethodPost, Path: "/api/v1/sequencer/load", Body: `{"duration": 10, "cues": []}`, Code: http.StatusOK},
+		{Method: http.MethodPost, Path: "/api/v1/sequencer/seek", Body: `{"position": 5}`, Code: http.StatusOK},
+		{Method: http.MethodPost, Path: "/api/v1/sequencer/seek", Body: `{"position": "five"}`, Code: http.StatusBadRequest},
+		{Method: http.MethodPost, Path: "/api/v1/sequencer/play", Code: http.StatusOK},
+		{Method: http.MethodPost, Path: "/api/v1/sequencer/pause", Code: http.StatusOK},
+	})
+	if status := GetStatus(); status.Playing || status.Position < 5 {
+		t.Errorf("Expected the timeline to be paused after the seek, got %+v", status)
+	}
+	apitest.Documents(t, r, "/api/v1", []string{"/sequencer", "/sequencer/load"}, []string{"SequencerStatus", "Timeline"})
+}
@@ -0,0 +1,83 @@
+package sequencer
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/LedFx/ledfx/pkg/audio"
+)
+
+const (
+	ClockWall    = "wall"    // played, paused and seeked by the sequencer
+	ClockYoutube = "youtube" // the youtube player's elapsed time
+	ClockReplay  = "replay"  // the position of an audio replay, by its source id
+)
+
+// External clocks give the position of whatever the timeline is synced to.
+// The sequencer can't seek them, and follows them when they jump.
+type ClockFunc func(source string) (time.Duration, error)
+
+var clocks = map[string]ClockFunc{
+	ClockReplay: func(source string) (time.Duration, error) { return audio.ReplayPosition(source) },
+}
+var clocksMu sync.Mutex
+
+// Registers an external clock which timelines can be played against.
+// Clocks which live outside of this package, eg. the youtube player, are registered at startup.
+func RegisterClock(name string, clock ClockFunc) {
+	clocksMu.Lock()
+	defer clocksMu.Unlock()
+	clocks[name] = clock
+}
+
+func clockExists(name string) bool {
+	if name == ClockWall {
+		return true
+	}
+	clocksMu.Lock()
+	defer clocksMu.Unlock()
+	_, exists := clocks[name]
+	return exists
+}
+
+func externalPosition(name, source string) (time.Duration, error) {
+	clocksMu.Lock()
+	clock, exists := clocks[name]
+	clocksMu.Unlock()
+	if !exists {
+		return 0, fmt.Errorf("unknown clock '%s'", name)
+	}
+	return clock(source)
+}
+
+// the wall clock counts from when play was pressed, from the position it was paused or seeked to
+type wallClock struct {
+	offset  time.Duration
+	started time.Time
+	running bool
+}
+
+func (w *wallClock) position() time.Duration {
+	if !w.running {
+		return w.offset
+	}
+	return w.offset + time.Since(w.started)
+}
+
+func (w *wallClock) start() {
+	if !w.running {
+		w.started = time.Now()
+		w.running = true
+	}
+}
+
+func (w *wallClock) stop() {
+	w.offset = w.position()
+	w.running = false
+}
+
+func (w *wallClock) seek(pos time.Duration) {
+	w.offset = pos
+	w.started = time.Now()
+}
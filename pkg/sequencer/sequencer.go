@@ -0,0 +1,387 @@
+package sequencer
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/LedFx/ledfx/pkg/controller"
+	"github.com/LedFx/ledfx/pkg/effect"
+	log "github.com/LedFx/ledfx/pkg/logger"
+	"github.com/LedFx/ledfx/pkg/util"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+/*
+The sequencer plays one timeline at a time. Each frame it reads the clock, fires the cues
+which are due and steps the running ramps. When the clock jumps backwards, cues after the
+new position are rearmed, so seeking, looping and scrubbing the youtube player all work the same way.
+Values set before a cue fired are not restored by seeking back past it.
+*/
+type player struct {
+	timeline *Timeline
+	fired    []bool
+	ramps    []*ramp
+	wall     wallClock
+	lastPos  time.Duration
+	playing  bool
+	finished bool
+	done     chan struct{}
+}
+
+// a running ramp of the numeric values of an effect or global cue
+type ramp struct {
+	cue   *Cue
+	start map[string]float64
+	end   map[string]float64
+}
+
+type Status struct {
+	Loaded     bool      `json:"loaded"`
+	Playing    bool      `json:"playing"`
+	Finished   bool      `json:"finished"`
+	Position   float64   `json:"position"` // seconds
+	Length     float64   `json:"length"`   // seconds
+	ClockError string    `json:"clock_error,omitempty"`
+	Timeline   *Timeline `json:"timeline"`
+}
+
+var current *player
+var mu sync.Mutex
+
+// kinds of the BaseEffectConfig fields, by mapstructure key
+var configKinds = map[string]reflect.Kind{}
+
+func init() {
+	for _, f := range util.DeepFields(reflect.TypeOf(effect.BaseEffectConfig{})) {
+		configKinds[f.Tag.Get("mapstructure")] = f.Type.Kind()
+	}
+}
+
+// Loads a timeline, stopping any timeline which is playing
+func Load(t *Timeline) error {
+	if t == nil {
+		return errors.New("no timeline given")
+	}
+	if err := t.validate(); err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if current != nil {
+		current.pause()
+	}
+	current = &player{timeline: t, fired: make([]bool, len(t.Cues))}
+	log.Logger.WithField("context", "Sequencer").Infof("Loaded timeline '%s' with %d cues on the %s clock", t.Name, len(t.Cues), t.Clock)
+	return nil
+}
+
+// Plays the loaded timeline from its current position
+func Play() error {
+	mu.Lock()
+	defer mu.Unlock()
+	if current == nil {
+		return errors.New("no timeline loaded")
+	}
+	current.play()
+	return nil
+}
+
+// Pauses the loaded timeline. Ramps hold their current value.
+func Pause() {
+	mu.Lock()
+	defer mu.Unlock()
+	if current != nil {
+		current.pause()
+	}
+}
+
+// Seeks the loaded timeline to a position in seconds. Only the wall clock can be seeked,
+// other clocks are followed wherever they go.
+func Seek(position float64) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if current == nil {
+		return errors.New("no timeline loaded")
+	}
+	if current.timeline.Clock != ClockWall {
+		return fmt.Errorf("the %s clock can't be seeked by the sequencer", current.timeline.Clock)
+	}
+	if position < 0 {
+		return errors.New("position cannot be negative")
+	}
+	pos := seconds(position)
+	current.wall.seek(pos)
+	current.finished = false
+	current.rewind(pos)
+	if current.playing {
+		current.tick()
+	}
+	return nil
+}
+
+// Get the state of the sequencer
+func GetStatus() Status {
+	mu.Lock()
+	defer mu.Unlock()
+	if current == nil {
+		return Status{}
+	}
+	status := Status{
+		Loaded:   true,
+		Playing:  current.playing,
+		Finished: current.finished,
+		Length:   current.timeline.Length().Seconds(),
+		Timeline: current.timeline,
+	}
+	pos, err := current.position()
+	if err != nil {
+		status.ClockError = err.Error()
+	}
+	status.Position = pos.Seconds()
+	return status
+}
+
+func (p *player) position() (time.Duration, error) {
+	if p.timeline.Clock == ClockWall {
+		return p.wall.position(), nil
+	}
+	return externalPosition(p.timeline.Clock, p.timeline.ClockSource)
+}
+
+func (p *player) play() {
+	if p.playing {
+		return
+	}
+	if p.finished {
+		p.wall.seek(0)
+		p.rewind(0)
+		p.finished = false
+	}
+	p.playing = true
+	p.wall.start()
+	p.done = make(chan struct{})
+	go p.run(p.done, time.Second/time.Duration(p.timeline.Framerate))
+	log.Logger.WithField("context", "Sequencer").Infof("Playing timeline '%s'", p.timeline.Name)
+}
+
+func (p *player) pause() {
+	if !p.playing {
+		return
+	}
+	p.playing = false
+	p.wall.stop()
+	close(p.done)
+	log.Logger.WithField("context", "Sequencer").Infof("Paused timeline '%s'", p.timeline.Name)
+}
+
+func (p *player) run(done chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			mu.Lock()
+			// a pause and play between ticks replaces done, so check it's still ours
+			if p.done == done {
+				p.tick()
+			}
+			mu.Unlock()
+		case <-done:
+			return
+		}
+	}
+}
+
+// rearms the cues from pos onwards and drops their ramps
+func (p *player) rewind(pos time.Duration) {
+	for i, c := range p.timeline.Cues {
+		if seconds(c.Time) >= pos {
+			p.fired[i] = false
+		}
+	}
+	ramps := p.ramps[:0]
+	for _, r := range p.ramps {
+		if seconds(r.cue.Time) < pos {
+			ramps = append(ramps, r)
+		}
+	}
+	p.ramps = ramps
+	p.lastPos = pos
+}
+
+// evaluates the timeline at the current clock position. must hold mu
+func (p *player) tick() {
+	pos, err := p.position()
+	if err != nil {
+		// external clocks come and go, eg. when a youtube track ends. wait for it to return.
+		return
+	}
+	if pos < p.lastPos {
+		p.rewind(pos)
+	}
+	p.lastPos = pos
+
+	for i := range p.timeline.Cues {
+		c := &p.timeline.Cues[i]
+		if !p.fired[i] && seconds(c.Time) <= pos {
+			p.fired[i] = true
+			if err := p.fire(c); err != nil {
+				log.Logger.WithField("context", "Sequencer").Warnf("Error firing %s cue at %vs: %v", c.Action, c.Time, err)
+			}
+		}
+	}
+
+	ramps := p.ramps[:0]
+	for _, r := range p.ramps {
+		progress := (pos - seconds(r.cue.Time)).Seconds() / r.cue.Ramp
+		if err := r.step(progress); err != nil {
+			log.Logger.WithField("context", "Sequencer").Warnf("Error ramping %s cue at %vs: %v", r.cue.Action, r.cue.Time, err)
+			continue
+		}
+		if progress < 1 {
+			ramps = append(ramps, r)
+		}
+	}
+	p.ramps = ramps
+
+	if p.timeline.Clock == ClockWall && pos >= p.timeline.Length() {
+		if p.timeline.Loop {
+			p.wall.seek(0)
+			p.rewind(0)
+			return
+		}
+		p.pause()
+		p.finished = true
+		log.Logger.WithField("context", "Sequencer").Infof("Finished timeline '%s'", p.timeline.Name)
+	}
+}
+
+func (p *player) fire(c *Cue) error {
+	switch c.Action {
+	case ActionConnect:
+		return controller.ConnectEffect(c.Effect, c.Target)
+	case ActionState:
+		return controller.SetStates(map[string]bool{c.Target: c.Active})
+	}
+
+	// effect and global cues set their other values now, and ramp their numeric values
+	var startConfig effect.BaseEffectConfig
+	if c.Action == ActionEffect {
+		e, err := effect.Get(c.Target)
+		if err != nil {
+			return err
+		}
+		startConfig = e.GetConfig()
+	} else {
+		startConfig = effect.GetGlobalSettings()
+	}
+	startMap := map[string]interface{}{}
+	if err := mapstructure.Decode(startConfig, &startMap); err != nil {
+		return err
+	}
+
+	now := map[string]interface{}{}
+	r := &ramp{cue: c, start: map[string]float64{}, end: map[string]float64{}}
+	for k, v := range c.Config {
+		target, ok := toFloat(v)
+		if c.Ramp == 0 || !ok || !rampable(configKinds[k]) {
+			now[k] = v
+			continue
+		}
+		start, _ := toFloat(startMap[k])
+		r.start[k] = start
+		r.end[k] = target
+	}
+	p.takeOver(r)
+	if len(now) > 0 {
+		if err := r.apply(now, true); err != nil {
+			return err
+		}
+	}
+	if len(r.end) > 0 {
+		p.ramps = append(p.ramps, r)
+	}
+	return nil
+}
+
+// a newer ramp takes over the values it sets from any older ramp on the same target
+func (p *player) takeOver(newer *ramp) {
+	ramps := p.ramps[:0]
+	for _, r := range p.ramps {
+		if r.cue.Action == newer.cue.Action && r.cue.Target == newer.cue.Target {
+			for k := range newer.cue.Config {
+				delete(r.start, k)
+				delete(r.end, k)
+			}
+		}
+		if len(r.end) > 0 {
+			ramps = append(ramps, r)
+		}
+	}
+	p.ramps = ramps
+}
+
+// applies the ramp at progress 0-1. the final value is saved, values along the way are not
+func (r *ramp) step(progress float64) error {
+	if progress > 1 {
+		progress = 1
+	}
+	values := map[string]interface{}{}
+	for k, end := range r.end {
+		v := end
+		if progress < 1 {
+			v = r.start[k] + (end-r.start[k])*progress
+		}
+		if configKinds[k] == reflect.Int {
+			values[k] = int(v + 0.5)
+		} else {
+			values[k] = v
+		}
+	}
+	return r.apply(values, progress >= 1)
+}
+
+func (r *ramp) apply(values map[string]interface{}, save bool) error {
+	if r.cue.Action == ActionGlobal {
+		if save {
+			return effect.SetGlobalSettings(values)
+		}
+		for _, id := range effect.GetIDs() {
+			if e, err := effect.Get(id); err == nil {
+				e.ApplyBaseConfig(values)
+			}
+		}
+		return nil
+	}
+	e, err := effect.Get(r.cue.Target)
+	if err != nil {
+		return err
+	}
+	if save {
+		return e.UpdateBaseConfig(values)
+	}
+	return e.ApplyBaseConfig(values)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch t := v.(type) {
+	case float64:
+		return t, true
+	case int:
+		return float64(t), true
+	}
+	return 0, false
+}
+
+// decodes a config map, erroring on keys which aren't in the config
+func decodeStrict(m map[string]interface{}, out interface{}) error {
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{ErrorUnused: true, Result: out})
+	if err != nil {
+		return err
+	}
+	return decoder.Decode(m)
+}
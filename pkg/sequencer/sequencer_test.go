@@ -0,0 +1,237 @@
+package sequencer
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/LedFx/ledfx/pkg/config"
+	"github.com/LedFx/ledfx/pkg/effect"
+)
+
+func TestParseTimeline(t *testing.T) {
+	tl, err := ParseTimeline([]byte(`{
+		"name": "test",
+		"duration": 5,
+		"cues": [
+			{"time": 3, "action": "effect", "target": "a", "config": {"brightness": 1}, "ramp": 4},
+			{"time": 1, "action": "state", "target": "b", "active": true},
+			{"time": 1, "action": "connect", "target": "b", "effect": "a"}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tl.Clock != ClockWall || tl.Framerate != 60 {
+		t.Errorf("Expected default clock and framerate, got %s and %d", tl.Clock, tl.Framerate)
+	}
+	if tl.Cues[0].Action != ActionState || tl.Cues[1].Action != ActionConnect || tl.Cues[2].Time != 3 {
+		t.Errorf("Cues should be sorted by time, keeping the order of cues at the same time: %+v", tl.Cues)
+	}
+	if tl.Length() != 7*time.Second {
+		t.Errorf("Expected the length to include the last ramp, got %v", tl.Length())
+	}
+
+	invalid := []string{
+		`{"clock": "sundial"}`,
+		`{"clock": "replay"}`,
+		`{"framerate": 1000}`,
+		`{"cues": [{"time": -1, "action": "state", "target": "b"}]}`,
+		`{"cues": [{"time": 0, "action": "explode", "target": "b"}]}`,
+		`{"cues": [{"time": 0, "action": "effect", "config": {"brightness": 1}}]}`,
+		`{"cues": [{"time": 0, "action": "effect", "target": "a", "config": {"sparkle": 1}}]}`,
+		`{"cues": [{"time": 0, "action": "global", "config": {"brightness": "very"}}]}`,
+		`{"cues": [{"time": 0, "action": "connect", "target": "b"}]}`,
+	}
+	for _, s := range invalid {
+		if _, err := ParseTimeline([]byte(s)); err == nil {
+			t.Errorf("Expected an error parsing %s", s)
+		}
+	}
+}
+
+// evaluates the loaded timeline at a wall clock position
+func tickAt(pos float64) {
+	mu.Lock()
+	defer mu.Unlock()
+	current.wall.seek(seconds(pos))
+	current.tick()
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func savedBrightness(t *testing.T) interface{} {
+	entry, err := config.GetEffect("sequencer_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entry.BaseConfig["brightness"]
+}
+
+func TestRamps(t *testing.T) {
+	defer config.DisableSaving()()
+	e, _, err := effect.New("sequencer_test", "energy", 10, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer effect.Destroy("sequencer_test")
+
+	tl, err := ParseTimeline([]byte(`{
+		"cues": [
+			{"time": 0, "action": "effect", "target": "sequencer_test", "config": {"palette": "Ocean", "brightness": 0}},
+			{"time": 1, "action": "effect", "target": "sequencer_test", "config": {"brightness": 1, "freq_max": 10020}, "ramp": 2}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = Load(tl); err != nil {
+		t.Fatal(err)
+	}
+
+	tickAt(0.5)
+	if e.Config.Palette != "Ocean" || e.Config.Brightness != 0 {
+		t.Errorf("Expected the first cue to set its values, got %+v", e.Config)
+	}
+	tickAt(2)
+	if !near(e.Config.Brightness, 0.5) || e.Config.FreqMax != 15010 {
+		t.Errorf("Expected the ramp to be halfway, got brightness %v and freq_max %v", e.Config.Brightness, e.Config.FreqMax)
+	}
+	if saved := savedBrightness(t); saved != 0.0 {
+		t.Errorf("Values along a ramp should not be saved, saved brightness is %v", saved)
+	}
+	tickAt(5)
+	if e.Config.Brightness != 1 || e.Config.FreqMax != 10020 {
+		t.Errorf("Expected the ramp to finish, got brightness %v and freq_max %v", e.Config.Brightness, e.Config.FreqMax)
+	}
+	if saved := savedBrightness(t); saved != 1.0 {
+		t.Errorf("The end of a ramp should be saved, saved brightness is %v", saved)
+	}
+
+	// going back to the start fires the first cue again
+	tickAt(0)
+	if e.Config.Brightness != 0 {
+		t.Errorf("Expected the first cue to fire again after a rewind, got brightness %v", e.Config.Brightness)
+	}
+	// seeking past a ramp applies its end
+	if err = Seek(10); err != nil {
+		t.Fatal(err)
+	}
+	tickAt(10)
+	if e.Config.Brightness != 1 {
+		t.Errorf("Expected the ramp to be applied after seeking past it, got brightness %v", e.Config.Brightness)
+	}
+	if !GetStatus().Finished {
+		t.Error("Expected the timeline to finish at its end")
+	}
+}
+
+func TestExternalClock(t *testing.T) {
+	defer config.DisableSaving()()
+	e, _, err := effect.New("sequencer_test", "energy", 10, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer effect.Destroy("sequencer_test")
+
+	var clockPos time.Duration
+	var clockErr error
+	RegisterClock("test", func(source string) (time.Duration, error) {
+		if source != "track" {
+			return 0, errors.New("wrong source")
+		}
+		return clockPos, clockErr
+	})
+	tl, err := ParseTimeline([]byte(`{
+		"clock": "test",
+		"clock_source": "track",
+		"cues": [{"time": 10, "action": "effect", "target": "sequencer_test", "config": {"brightness": 0.25}}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = Load(tl); err != nil {
+		t.Fatal(err)
+	}
+	if err = Seek(5); err == nil {
+		t.Error("External clocks should not be seekable")
+	}
+
+	tick := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		current.tick()
+	}
+	e.UpdateBaseConfig(map[string]interface{}{"brightness": 1.0})
+	clockPos = 5 * time.Second
+	tick()
+	if e.Config.Brightness != 1 {
+		t.Errorf("Cue fired early, brightness is %v", e.Config.Brightness)
+	}
+	clockErr = errors.New("no track playing")
+	clockPos = 20 * time.Second
+	tick()
+	if e.Config.Brightness != 1 {
+		t.Errorf("Cues should not fire while the clock is unavailable, brightness is %v", e.Config.Brightness)
+	}
+	if GetStatus().ClockError == "" {
+		t.Error("Expected the clock error in the status")
+	}
+	clockErr = nil
+	tick()
+	if e.Config.Brightness != 0.25 {
+		t.Errorf("Expected the cue to fire when the clock passed it, brightness is %v", e.Config.Brightness)
+	}
+	// the clock jumping back rearms the cue
+	e.UpdateBaseConfig(map[string]interface{}{"brightness": 1.0})
+	clockPos = 2 * time.Second
+	tick()
+	clockPos = 11 * time.Second
+	tick()
+	if e.Config.Brightness != 0.25 {
+		t.Errorf("Expected the cue to fire again after the clock jumped back, brightness is %v", e.Config.Brightness)
+	}
+	if GetStatus().Finished {
+		t.Error("Timelines on external clocks should not finish by themselves")
+	}
+}
+
+func TestPlay(t *testing.T) {
+	defer config.DisableSaving()()
+	if _, _, err := effect.New("sequencer_test", "energy", 10, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer effect.Destroy("sequencer_test")
+
+	tl, err := ParseTimeline([]byte(`{
+		"framerate": 100,
+		"cues": [{"time": 0.05, "action": "effect", "target": "sequencer_test", "config": {"brightness": 0.3}, "ramp": 0.05}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = Load(tl); err != nil {
+		t.Fatal(err)
+	}
+	if err = Play(); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for !GetStatus().Finished {
+		if time.Now().After(deadline) {
+			t.Fatal("Timeline didn't finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	status := GetStatus()
+	if status.Playing || status.Position < 0.1 {
+		t.Errorf("Expected the timeline to stop at its end, got %+v", status)
+	}
+	e, _ := effect.Get("sequencer_test")
+	if e.Config.Brightness != 0.3 {
+		t.Errorf("Expected the ramp to finish, brightness is %v", e.Config.Brightness)
+	}
+}
@@ -0,0 +1,153 @@
+package sequencer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/LedFx/ledfx/pkg/effect"
+)
+
+/*
+Timelines are JSON files of cues, played against a clock. Times and ramps are in seconds.
+
+	{
+	  "name": "Opening",
+	  "clock": "youtube",        // wall, youtube, or replay. see RegisterClock
+	  "clock_source": "",        // id of the replay audio source, for the replay clock
+	  "framerate": 60,           // how often cues and ramps are evaluated
+	  "loop": false,
+	  "cues": [
+	    {"time": 0, "action": "connect", "target": "<controller id>", "effect": "<effect id>"},
+	    {"time": 0, "action": "effect", "target": "<effect id>", "config": {"palette": "Ocean", "brightness": 0}},
+	    {"time": 2.5, "action": "effect", "target": "<effect id>", "config": {"brightness": 1}, "ramp": 4},
+	    {"time": 30, "action": "global", "config": {"hue_shift": 0.5}, "ramp": 10},
+	    {"time": 60, "action": "state", "target": "<controller id>", "active": false}
+	  ]
+	}
+
+Numeric config values ramp linearly from their value when the cue fires. Other values are set
+when the cue fires. There are no scenes in LedFx yet, so a scene switch is a group of connect,
+effect and state cues at the same time.
+*/
+type Timeline struct {
+	Name        string  `json:"name"`
+	Clock       string  `json:"clock"`
+	ClockSource string  `json:"clock_source"`
+	Framerate   int     `json:"framerate"`
+	Loop        bool    `json:"loop"`
+	Duration    float64 `json:"duration"` // optional, defaults to the end of the last cue
+	Cues        []Cue   `json:"cues"`
+}
+
+type Cue struct {
+	Time   float64                `json:"time"`
+	Action string                 `json:"action"`
+	Target string                 `json:"target,omitempty"`
+	Config map[string]interface{} `json:"config,omitempty"`
+	Ramp   float64                `json:"ramp,omitempty"`
+	Effect string                 `json:"effect,omitempty"`
+	Active bool                   `json:"active,omitempty"`
+}
+
+const (
+	ActionEffect  = "effect"  // update the config of an effect
+	ActionGlobal  = "global"  // update the global effect settings
+	ActionConnect = "connect" // connect an effect to a controller
+	ActionState   = "state"   // start or stop a controller
+)
+
+// Parses a timeline from json, validating it and sorting its cues by time
+func ParseTimeline(b []byte) (*Timeline, error) {
+	t := &Timeline{}
+	if err := json.Unmarshal(b, t); err != nil {
+		return nil, err
+	}
+	return t, t.validate()
+}
+
+func (t *Timeline) validate() error {
+	if t.Clock == "" {
+		t.Clock = ClockWall
+	}
+	if !clockExists(t.Clock) {
+		return fmt.Errorf("unknown clock '%s'", t.Clock)
+	}
+	if t.Clock == ClockReplay && t.ClockSource == "" {
+		return errors.New("the replay clock needs a clock_source")
+	}
+	if t.Framerate == 0 {
+		t.Framerate = 60
+	}
+	if t.Framerate < 5 || t.Framerate > 120 {
+		return fmt.Errorf("framerate %d must be between 5 and 120", t.Framerate)
+	}
+	if t.Duration < 0 {
+		return errors.New("duration cannot be negative")
+	}
+	for i, c := range t.Cues {
+		if err := c.validate(); err != nil {
+			return fmt.Errorf("cue %d: %w", i, err)
+		}
+	}
+	// stable, so cues at the same time fire in the order they were written
+	sort.SliceStable(t.Cues, func(i, j int) bool { return t.Cues[i].Time < t.Cues[j].Time })
+	return nil
+}
+
+func (c *Cue) validate() error {
+	if c.Time < 0 {
+		return errors.New("time cannot be negative")
+	}
+	if c.Ramp < 0 {
+		return errors.New("ramp cannot be negative")
+	}
+	switch c.Action {
+	case ActionEffect, ActionGlobal:
+		if c.Action == ActionEffect && c.Target == "" {
+			return errors.New("effect cues need a target effect id")
+		}
+		if len(c.Config) == 0 {
+			return fmt.Errorf("%s cues need a config", c.Action)
+		}
+		// catch unknown keys and bad values before the show, rather than during it
+		base := effect.BaseEffectConfig{}
+		if err := decodeStrict(c.Config, &base); err != nil {
+			return err
+		}
+	case ActionConnect:
+		if c.Target == "" || c.Effect == "" {
+			return errors.New("connect cues need a target controller id and an effect id")
+		}
+	case ActionState:
+		if c.Target == "" {
+			return errors.New("state cues need a target controller id")
+		}
+	default:
+		return fmt.Errorf("unknown action '%s'", c.Action)
+	}
+	return nil
+}
+
+// Length of the timeline, including ramps
+func (t *Timeline) Length() time.Duration {
+	end := t.Duration
+	for _, c := range t.Cues {
+		if c.Time+c.Ramp > end {
+			end = c.Time + c.Ramp
+		}
+	}
+	return seconds(end)
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// numeric kinds of BaseEffectConfig fields, which can be ramped
+func rampable(k reflect.Kind) bool {
+	return k == reflect.Float64 || k == reflect.Int
+}
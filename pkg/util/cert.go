@@ -0,0 +1,96 @@
+package util
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// how long self-signed certificates last before they're remade
+const certLifetime = 365 * 24 * time.Hour
+
+/*
+Makes sure there's a usable self-signed certificate and key at the given paths.
+A new one is made for the hosts if they're missing, don't load, expire within a day,
+or no longer cover all the hosts, eg. because the machine's address changed.
+*/
+func EnsureSelfSignedCert(certPath, keyPath string, hosts []string) error {
+	if pair, err := tls.LoadX509KeyPair(certPath, keyPath); err == nil {
+		if cert, err := x509.ParseCertificate(pair.Certificate[0]); err == nil && certUsable(cert, hosts) {
+			return nil
+		}
+	}
+	certPEM, keyPEM, err := SelfSignedCert(hosts, certLifetime)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(certPath), 0744); err != nil {
+		return err
+	}
+	if err = os.WriteFile(keyPath, keyPEM, 0600); err != nil {
+		return err
+	}
+	return os.WriteFile(certPath, certPEM, 0644)
+}
+
+// whether a certificate is a server certificate for all the hosts, which lasts at least another day
+func certUsable(cert *x509.Certificate, hosts []string) bool {
+	if cert.IsCA || !time.Now().Add(24*time.Hour).Before(cert.NotAfter) {
+		return false
+	}
+	for _, h := range hosts {
+		if h != "" && cert.VerifyHostname(h) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Generates a PEM encoded self-signed certificate and key for the hosts, which may be names or ips
+func SelfSignedCert(hosts []string, lifetime time.Duration) (certPEM, keyPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"LedFx"}, CommonName: "LedFx"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(lifetime),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  false,
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else if h != "" {
+			template.DNSNames = append(template.DNSNames, h)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
@@ -0,0 +1,66 @@
+package util
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEnsureSelfSignedCert(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath := filepath.Join(dir, "ledfx.crt"), filepath.Join(dir, "ledfx.key")
+	if err := EnsureSelfSignedCert(certPath, keyPath, []string{"localhost", "127.0.0.1"}); err != nil {
+		t.Fatal(err)
+	}
+	pair, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cert.VerifyHostname("localhost"); err != nil {
+		t.Error(err)
+	}
+	if err = cert.VerifyHostname("127.0.0.1"); err != nil {
+		t.Error(err)
+	}
+	if cert.IsCA || cert.KeyUsage&x509.KeyUsageCertSign != 0 {
+		t.Error("Expected a server certificate, not a CA")
+	}
+	if info, err := os.Stat(keyPath); err == nil && info.Mode().Perm()&0077 != 0 {
+		t.Errorf("Expected the key to be private, got %v", info.Mode())
+	}
+
+	// a usable certificate is kept
+	if err = EnsureSelfSignedCert(certPath, keyPath, []string{"localhost"}); err != nil {
+		t.Fatal(err)
+	}
+	again, _ := tls.LoadX509KeyPair(certPath, keyPath)
+	if string(again.Certificate[0]) != string(pair.Certificate[0]) {
+		t.Error("Expected the existing certificate to be kept")
+	}
+
+	// one which doesn't cover a new address is replaced
+	if err = EnsureSelfSignedCert(certPath, keyPath, []string{"localhost", "192.168.1.20"}); err != nil {
+		t.Fatal(err)
+	}
+	again, _ = tls.LoadX509KeyPair(certPath, keyPath)
+	if cert, err = x509.ParseCertificate(again.Certificate[0]); err != nil || cert.VerifyHostname("192.168.1.20") != nil {
+		t.Errorf("Expected the certificate to be remade for the new address, got %v", err)
+	}
+
+	// a broken one is replaced
+	if err = os.WriteFile(certPath, []byte("nope"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = EnsureSelfSignedCert(certPath, keyPath, []string{"localhost"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = tls.LoadX509KeyPair(certPath, keyPath); err != nil {
+		t.Errorf("Expected a broken certificate to be replaced, got %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package util
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+)
+
+/*
+A registry of instances by id, eg. effects, devices or controllers, which is safe to use
+from any goroutine. Reads return snapshots, so ranging over them doesn't hold the lock and
+isn't affected by instances being added or removed meanwhile.
+*/
+type Registry[T any] struct {
+	mu        sync.RWMutex
+	instances map[string]T
+}
+
+func NewRegistry[T any]() *Registry[T] {
+	return &Registry[T]{instances: map[string]T{}}
+}
+
+// Get an instance by id
+func (r *Registry[T]) Get(id string) (instance T, exists bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	instance, exists = r.instances[id]
+	return instance, exists
+}
+
+// Whether an instance exists with the id
+func (r *Registry[T]) Has(id string) bool {
+	_, exists := r.Get(id)
+	return exists
+}
+
+// Sets the instance with the id, returning the instance it replaced, if any
+func (r *Registry[T]) Set(id string, instance T) (previous T, replaced bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	previous, replaced = r.instances[id]
+	r.instances[id] = instance
+	return previous, replaced
+}
+
+// Adds an instance with the first free id of prefix0, prefix1, ... and returns the id
+func (r *Registry[T]) Add(prefix string, instance T) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := 0; ; i++ {
+		id := prefix + strconv.Itoa(i)
+		if _, exists := r.instances[id]; !exists {
+			r.instances[id] = instance
+			return id
+		}
+	}
+}
+
+// Removes an instance, returning it if it existed
+func (r *Registry[T]) Delete(id string) (instance T, existed bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	instance, existed = r.instances[id]
+	delete(r.instances, id)
+	return instance, existed
+}
+
+// A copy of the registry, by id
+func (r *Registry[T]) Snapshot() map[string]T {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	s := make(map[string]T, len(r.instances))
+	for id, instance := range r.instances {
+		s[id] = instance
+	}
+	return s
+}
+
+// The ids of all instances, sorted
+func (r *Registry[T]) IDs() []string {
+	r.mu.RLock()
+	ids := make([]string, 0, len(r.instances))
+	for id := range r.instances {
+		ids = append(ids, id)
+	}
+	r.mu.RUnlock()
+	sort.Strings(ids)
+	return ids
+}
+
+// All instances, sorted by id
+func (r *Registry[T]) Values() []T {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ids := make([]string, 0, len(r.instances))
+	for id := range r.instances {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	values := make([]T, len(ids))
+	for i, id := range ids {
+		values[i] = r.instances[id]
+	}
+	return values
+}
+
+func (r *Registry[T]) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.instances)
+}
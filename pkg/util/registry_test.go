@@ -0,0 +1,69 @@
+package util
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry[int]()
+	if id := r.Add("n", 1); id != "n0" {
+		t.Errorf("Expected the first free id n0, got %s", id)
+	}
+	if id := r.Add("n", 2); id != "n1" {
+		t.Errorf("Expected the next free id n1, got %s", id)
+	}
+	if prev, replaced := r.Set("n0", 3); !replaced || prev != 1 {
+		t.Errorf("Expected to replace 1, got %d %v", prev, replaced)
+	}
+	snapshot := r.Snapshot()
+	r.Delete("n1")
+	if len(snapshot) != 2 || r.Has("n1") {
+		t.Errorf("Expected the snapshot to be unaffected by the delete, got %v", snapshot)
+	}
+	if values := r.Values(); len(values) != 1 || values[0] != 3 {
+		t.Errorf("Expected the remaining value, got %v", values)
+	}
+}
+
+// run with -race
+func TestRegistryConcurrency(t *testing.T) {
+	r := NewRegistry[*int]()
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(2)
+		// writers add, replace and delete
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				n := i
+				id := r.Add("w"+strconv.Itoa(w)+"_", &n)
+				r.Set(id, &n)
+				if i%2 == 0 {
+					r.Delete(id)
+				}
+			}
+		}(w)
+		// readers range over snapshots while the registry changes
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				for id, v := range r.Snapshot() {
+					if v == nil {
+						t.Errorf("Expected a value for %s", id)
+					}
+				}
+				for _, id := range r.IDs() {
+					r.Get(id)
+				}
+				r.Values()
+				r.Len()
+			}
+		}()
+	}
+	wg.Wait()
+	if r.Len() != 8*250 {
+		t.Errorf("Expected %d instances, got %d", 8*250, r.Len())
+	}
+}
@@ -117,8 +117,7 @@ func CreateSchema(t reflect.Type) (map[string]interface{}, error) {
 			if hasDef {
 				def = strings.TrimLeft(def, "[")
 				def = strings.TrimRight(def, "]")
-				defs := strings.Split(def, " ")
-				x = append(x, defs...)
+				x = append(x, strings.Fields(def)...)
 			}
 			schemaEntry["default"] = x
 			dataType = "list"
@@ -156,6 +155,17 @@ func CreateSchema(t reflect.Type) (map[string]interface{}, error) {
 					return schema, err
 				}
 				validation["min"] = x
+			case "min": // the length of strings, as with the validator
+				x, err := strconv.Atoi(value)
+				if err != nil {
+					logger.Logger.WithField("context", "Schema Builder").Error(err)
+					return schema, err
+				}
+				if dataType == "string" {
+					validation["min_length"] = x
+				} else {
+					validation["min"] = x
+				}
 			case "lte":
 				x, err := strconv.Atoi(value)
 				if err != nil {
@@ -169,6 +179,10 @@ func CreateSchema(t reflect.Type) (map[string]interface{}, error) {
 				validation["special"] = "palette"
 			case "ip":
 				validation["special"] = "ip"
+			case "ip|cidr":
+				validation["special"] = "ip_or_cidr"
+			case "audio_source":
+				validation["special"] = "audio_source"
 			case "oneof":
 				opts := strings.Split(value, " ")
 				switch dataType {
@@ -0,0 +1,436 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/LedFx/ledfx/pkg/config"
+	"github.com/LedFx/ledfx/pkg/controller"
+	"github.com/LedFx/ledfx/pkg/device"
+	"github.com/LedFx/ledfx/pkg/effect"
+	"github.com/LedFx/ledfx/pkg/event"
+)
+
+/*
+Clients send requests as json:
+	{"id": 1, "type": "effects.create", "data": {...}}
+Every request gets a response with the same id:
+	{"id": 1, "type": "response", "success": true, "data": {...}}
+	{"id": 1, "type": "response", "success": false, "error": {"code": "not_found", "message": "..."}}
+The id can be any json value. It is only echoed back, so clients can match responses to requests.
+*/
+
+type request struct {
+	ID   json.RawMessage `json:"id"`
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+type response struct {
+	ID      json.RawMessage `json:"id"`
+	Type    string          `json:"type"`
+	Success bool            `json:"success"`
+	Data    interface{}     `json:"data,omitempty"`
+	Error   *commandError   `json:"error,omitempty"`
+}
+
+// Error codes given in responses
+const (
+	errBadRequest     = "bad_request"
+	errNotFound       = "not_found"
+	errUnknownCommand = "unknown_command"
+	errForbidden      = "forbidden"
+	errInternal       = "internal_error"
+)
+
+type commandError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *commandError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func newError(code string, err error) *commandError {
+	return &commandError{Code: code, Message: err.Error()}
+}
+
+type command func(w *webSocket, data json.RawMessage) (interface{}, *commandError)
+
+var commands = map[string]command{
+	"subscribe":              subscribeCommand,
+	"unsubscribe":            unsubscribeCommand,
+	"events.history":         historyCommand,
+	"effects.get":            getEffects,
+	"effects.create":         createEffect,
+	"effects.update":         updateEffect,
+	"effects.delete":         deleteEffect,
+	"devices.get":            getDevices,
+	"devices.create":         createDevice,
+	"devices.update":         updateDevice,
+	"devices.delete":         deleteDevice,
+	"controllers.get":        getControllers,
+	"controllers.create":     createController,
+	"controllers.update":     updateController,
+	"controllers.delete":     deleteController,
+	"controllers.connect":    connectController,
+	"controllers.disconnect": disconnectController,
+	"controllers.state":      setControllerStates,
+}
+
+// Commands clients with the read role can use, besides those which get things
+var readOnlyCommands = map[string]bool{
+	"subscribe":      true,
+	"unsubscribe":    true,
+	"events.history": true,
+}
+
+// Handles a request and builds its response
+func (w *webSocket) handleRequest(p []byte) response {
+	req := request{}
+	if err := json.Unmarshal(p, &req); err != nil {
+		return response{Type: "response", Error: newError(errBadRequest, err)}
+	}
+	res := response{ID: req.ID, Type: "response"}
+	cmd, ok := commands[req.Type]
+	if !ok {
+		res.Error = newError(errUnknownCommand, fmt.Errorf("unknown command type '%s'", req.Type))
+		return res
+	}
+	if w.readOnly && !readOnlyCommands[req.Type] && !strings.HasSuffix(req.Type, ".get") {
+		res.Error = newError(errForbidden, fmt.Errorf("%s needs an admin token or user", req.Type))
+		return res
+	}
+	res.Data, res.Error = cmd(w, req.Data)
+	res.Success = res.Error == nil
+	return res
+}
+
+// decodes request data, which may be left out for commands that don't need any
+func decode(data json.RawMessage, v interface{}) *commandError {
+	if len(data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return newError(errBadRequest, err)
+	}
+	return nil
+}
+
+type idJSON struct {
+	ID string `json:"id"`
+}
+
+type subscribeJSON struct {
+	// event types, given as their number or title eg. 3 or "Effect Update"
+	EventTypes []interface{} `json:"event_types"`
+}
+
+func parseEventTypes(data json.RawMessage) ([]event.EventType, *commandError) {
+	sub := subscribeJSON{}
+	if err := decode(data, &sub); err != nil {
+		return nil, err
+	}
+	ets := make([]event.EventType, 0, len(sub.EventTypes))
+	for _, x := range sub.EventTypes {
+		et, err := parseEventType(x)
+		if err != nil {
+			return nil, newError(errBadRequest, err)
+		}
+		ets = append(ets, et)
+	}
+	return ets, nil
+}
+
+func parseEventType(x interface{}) (event.EventType, error) {
+	switch t := x.(type) {
+	case float64:
+		et := event.EventType(t)
+		if float64(et) == t && et.String() != "Unknown" {
+			return et, nil
+		}
+	case string:
+		for _, et := range event.Types() {
+			if et.String() == t {
+				return et, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("unknown event type %v", x)
+}
+
+func subscribeCommand(w *webSocket, data json.RawMessage) (interface{}, *commandError) {
+	ets, err := parseEventTypes(data)
+	if err != nil {
+		return nil, err
+	}
+	for _, et := range ets {
+		w.subscribe(et)
+	}
+	return w.subscriptions(), nil
+}
+
+func unsubscribeCommand(w *webSocket, data json.RawMessage) (interface{}, *commandError) {
+	ets, err := parseEventTypes(data)
+	if err != nil {
+		return nil, err
+	}
+	for _, et := range ets {
+		w.unsubscribe(et)
+	}
+	return w.subscriptions(), nil
+}
+
+// EFFECTS
+
+func getEffects(w *webSocket, data json.RawMessage) (interface{}, *commandError) {
+	return config.GetEffects(), nil
+}
+
+func createEffect(w *webSocket, data json.RawMessage) (interface{}, *commandError) {
+	entry := config.EffectEntry{}
+	if err := decode(data, &entry); err != nil {
+		return nil, err
+	}
+	_, id, err := effect.New(entry.ID, entry.Type, 100, entry.BaseConfig)
+	if err != nil {
+		return nil, newError(errBadRequest, err)
+	}
+	c, err := config.GetEffect(id)
+	if err != nil {
+		return nil, newError(errInternal, err)
+	}
+	return c, nil
+}
+
+func updateEffect(w *webSocket, data json.RawMessage) (interface{}, *commandError) {
+	entry := config.EffectEntry{}
+	if err := decode(data, &entry); err != nil {
+		return nil, err
+	}
+	e, err := effect.Get(entry.ID)
+	if err != nil {
+		return nil, newError(errNotFound, err)
+	}
+	if entry.BaseConfig != nil {
+		if err = e.UpdateBaseConfig(entry.BaseConfig); err != nil {
+			return nil, newError(errBadRequest, err)
+		}
+	}
+	if entry.ExtraConfig != nil {
+		if err = e.UpdateExtraConfig(entry.ExtraConfig); err != nil {
+			return nil, newError(errBadRequest, err)
+		}
+	}
+	if entry.Modulators != nil {
+		if err = e.SetModulators(entry.Modulators); err != nil {
+			return nil, newError(errBadRequest, err)
+		}
+	}
+	c, err := config.GetEffect(entry.ID)
+	if err != nil {
+		return nil, newError(errInternal, err)
+	}
+	return c, nil
+}
+
+func deleteEffect(w *webSocket, data json.RawMessage) (interface{}, *commandError) {
+	target := idJSON{}
+	if err := decode(data, &target); err != nil {
+		return nil, err
+	}
+	if _, err := effect.Get(target.ID); err != nil {
+		return nil, newError(errNotFound, err)
+	}
+	effect.Destroy(target.ID)
+	return nil, nil
+}
+
+// DEVICES
+
+func getDevices(w *webSocket, data json.RawMessage) (interface{}, *commandError) {
+	return config.GetDevices(), nil
+}
+
+func createDevice(w *webSocket, data json.RawMessage) (interface{}, *commandError) {
+	entry := config.DeviceEntry{}
+	if err := decode(data, &entry); err != nil {
+		return nil, err
+	}
+	_, id, err := device.New(entry.ID, entry.Type, entry.BaseConfig, entry.ImplConfig)
+	if err != nil {
+		return nil, newError(errBadRequest, err)
+	}
+	c, err := config.GetDevice(id)
+	if err != nil {
+		return nil, newError(errInternal, err)
+	}
+	return c, nil
+}
+
+// Devices are updated by recreating them with the same id, keeping their connection.
+// Any config left out of the request is kept from the existing device.
+func updateDevice(w *webSocket, data json.RawMessage) (interface{}, *commandError) {
+	entry := config.DeviceEntry{}
+	if err := decode(data, &entry); err != nil {
+		return nil, err
+	}
+	existing, err := config.GetDevice(entry.ID)
+	if err != nil {
+		return nil, newError(errNotFound, err)
+	}
+	if entry.Type == "" {
+		entry.Type = existing.Type
+	}
+	if entry.BaseConfig == nil {
+		entry.BaseConfig = existing.BaseConfig
+	}
+	if entry.ImplConfig == nil {
+		entry.ImplConfig = existing.ImplConfig
+	}
+	if err = controller.ReplaceDevice(entry.ID, entry.Type, entry.BaseConfig, entry.ImplConfig); err != nil {
+		return nil, newError(errBadRequest, err)
+	}
+	c, err := config.GetDevice(entry.ID)
+	if err != nil {
+		return nil, newError(errInternal, err)
+	}
+	return c, nil
+}
+
+func deleteDevice(w *webSocket, data json.RawMessage) (interface{}, *commandError) {
+	target := idJSON{}
+	if err := decode(data, &target); err != nil {
+		return nil, err
+	}
+	if _, err := device.Get(target.ID); err != nil {
+		return nil, newError(errNotFound, err)
+	}
+	device.Destroy(target.ID)
+	return nil, nil
+}
+
+// CONTROLLERS
+
+func getControllers(w *webSocket, data json.RawMessage) (interface{}, *commandError) {
+	return config.GetControllers(), nil
+}
+
+func createController(w *webSocket, data json.RawMessage) (interface{}, *commandError) {
+	entry := config.ControllerEntry{}
+	if err := decode(data, &entry); err != nil {
+		return nil, err
+	}
+	_, id, err := controller.New(entry.ID, entry.Config)
+	if err != nil {
+		return nil, newError(errBadRequest, err)
+	}
+	c, err := config.GetController(id)
+	if err != nil {
+		return nil, newError(errInternal, err)
+	}
+	return c, nil
+}
+
+// Only the settings given are changed. The controller keeps its effect, devices and state
+func updateController(w *webSocket, data json.RawMessage) (interface{}, *commandError) {
+	entry := config.ControllerEntry{}
+	if err := decode(data, &entry); err != nil {
+		return nil, err
+	}
+	v, err := controller.Get(entry.ID)
+	if err != nil {
+		return nil, newError(errNotFound, err)
+	}
+	saved, err := config.GetController(v.ID)
+	if err != nil {
+		return nil, newError(errInternal, err)
+	}
+	c := make(map[string]interface{}, len(saved.Config)+len(entry.Config))
+	for k, val := range saved.Config {
+		c[k] = val
+	}
+	for k, val := range entry.Config {
+		c[k] = val
+	}
+	if err = v.UpdateConfig(c); err != nil {
+		return nil, newError(errBadRequest, err)
+	}
+	if saved, err = config.GetController(v.ID); err != nil {
+		return nil, newError(errInternal, err)
+	}
+	return saved, nil
+}
+
+func deleteController(w *webSocket, data json.RawMessage) (interface{}, *commandError) {
+	target := idJSON{}
+	if err := decode(data, &target); err != nil {
+		return nil, err
+	}
+	if _, err := controller.Get(target.ID); err != nil {
+		return nil, newError(errNotFound, err)
+	}
+	controller.Destroy(target.ID)
+	return nil, nil
+}
+
+type connectJSON struct {
+	EffectID     string `json:"effect_id"`
+	ControllerID string `json:"controller_id"`
+	DeviceID     string `json:"device_id"`
+}
+
+func connectController(w *webSocket, data json.RawMessage) (interface{}, *commandError) {
+	c := connectJSON{}
+	if err := decode(data, &c); err != nil {
+		return nil, err
+	}
+	if _, err := controller.Get(c.ControllerID); err != nil {
+		return nil, newError(errNotFound, err)
+	}
+	if c.DeviceID != "" {
+		if err := controller.ConnectDevice(c.DeviceID, c.ControllerID); err != nil {
+			return nil, newError(errBadRequest, err)
+		}
+	}
+	if c.EffectID != "" {
+		if err := controller.ConnectEffect(c.EffectID, c.ControllerID); err != nil {
+			return nil, newError(errBadRequest, err)
+		}
+	}
+	return nil, nil
+}
+
+func disconnectController(w *webSocket, data json.RawMessage) (interface{}, *commandError) {
+	c := connectJSON{}
+	if err := decode(data, &c); err != nil {
+		return nil, err
+	}
+	if _, err := controller.Get(c.ControllerID); err != nil {
+		return nil, newError(errNotFound, err)
+	}
+	if c.DeviceID != "" {
+		if err := controller.DisconnectDevice(c.DeviceID, c.ControllerID); err != nil {
+			return nil, newError(errBadRequest, err)
+		}
+	}
+	if c.EffectID != "" {
+		if err := controller.DisconnectEffect(c.EffectID, c.ControllerID); err != nil {
+			return nil, newError(errBadRequest, err)
+		}
+	}
+	return nil, nil
+}
+
+func setControllerStates(w *webSocket, data json.RawMessage) (interface{}, *commandError) {
+	states := map[string]bool{}
+	if err := decode(data, &states); err != nil {
+		return nil, err
+	}
+	if err := controller.SetStates(states); err != nil {
+		return nil, newError(errInternal, err)
+	}
+	return controller.GetStates(), nil
+}
@@ -0,0 +1,105 @@
+package websocket
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LedFx/ledfx/pkg/event"
+	"github.com/LedFx/ledfx/pkg/util"
+)
+
+/*
+Clients which (re)connect can fetch the events they missed, by sequence id or time.
+	GET /api/events?seq=120&since=2022-08-01T12:00:00Z&event_types=Log,3
+	{"id": 1, "type": "events.history", "data": {"seq": 120, "since": "2022-08-01T12:00:00Z", "event_types": ["Log", 3]}}
+All fields are optional. Only kept events are numbered, so when all types are fetched,
+a gap between seq and the first returned event means events were pushed out of the history.
+*/
+
+type historyJSON struct {
+	Seq        uint64        `json:"seq"`   // get events after this sequence id
+	Since      time.Time     `json:"since"` // get events after this time
+	EventTypes []interface{} `json:"event_types"`
+}
+
+type historyResponse struct {
+	LatestSeq uint64         `json:"latest_seq"`
+	Events    []*event.Event `json:"events"`
+}
+
+func getHistory(h historyJSON) (historyResponse, error) {
+	ets := make([]event.EventType, 0, len(h.EventTypes))
+	for _, x := range h.EventTypes {
+		et, err := parseEventType(x)
+		if err != nil {
+			return historyResponse{}, err
+		}
+		ets = append(ets, et)
+	}
+	// latest first, so no event in the history is newer than latest_seq
+	latest := event.LatestSeq()
+	events := []*event.Event{}
+	for _, e := range event.History(h.Seq, h.Since, ets...) {
+		if e.Seq <= latest {
+			events = append(events, e)
+		}
+	}
+	return historyResponse{LatestSeq: latest, Events: events}, nil
+}
+
+func historyCommand(w *webSocket, data json.RawMessage) (interface{}, *commandError) {
+	h := historyJSON{}
+	if err := decode(data, &h); err != nil {
+		return nil, err
+	}
+	res, err := getHistory(h)
+	if err != nil {
+		return nil, newError(errBadRequest, err)
+	}
+	return res, nil
+}
+
+func historyHandler(writer http.ResponseWriter, request *http.Request) {
+	switch request.Method {
+	case http.MethodGet:
+		h := historyJSON{}
+		q := request.URL.Query()
+		var err error
+		if s := q.Get("seq"); s != "" {
+			h.Seq, err = strconv.ParseUint(s, 10, 64)
+			if util.BadRequest("Events API", err, writer) {
+				return
+			}
+		}
+		if s := q.Get("since"); s != "" {
+			h.Since, err = time.Parse(time.RFC3339Nano, s)
+			if util.BadRequest("Events API", err, writer) {
+				return
+			}
+		}
+		if s := q.Get("event_types"); s != "" {
+			for _, t := range strings.Split(s, ",") {
+				// types may be given as their number or title, as in websocket requests
+				if n, err := strconv.Atoi(t); err == nil {
+					h.EventTypes = append(h.EventTypes, float64(n))
+				} else {
+					h.EventTypes = append(h.EventTypes, t)
+				}
+			}
+		}
+		res, err := getHistory(h)
+		if util.BadRequest("Events API", err, writer) {
+			return
+		}
+		b, err := json.Marshal(res)
+		if util.InternalError("Events API", err, writer) {
+			return
+		}
+		writer.Write(b)
+	default:
+		writer.WriteHeader(http.StatusNotImplemented)
+	}
+}
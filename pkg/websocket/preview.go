@@ -0,0 +1,79 @@
+package websocket
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/LedFx/ledfx/pkg/controller"
+	"github.com/LedFx/ledfx/pkg/logger"
+	"github.com/LedFx/ledfx/pkg/util"
+
+	"github.com/gorilla/websocket"
+)
+
+/*
+Streams a controller's frames as binary messages of uint8 RGB triplets.
+Query parameters:
+
+	controller: id of the controller to preview (required)
+	pixels: number of pixels to downsample the frame to
+	fps: max frames per second
+
+Frames are dropped, not queued, if the client can't keep up.
+*/
+func NewPreview(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	v, err := controller.Get(query.Get("controller"))
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	pixels, fps := controller.PreviewMaxPixels, controller.PreviewMaxFps
+	if s := query.Get("pixels"); s != "" {
+		pixels, err = strconv.Atoi(s)
+		if util.BadRequest("Websocket Preview", err, w) {
+			return
+		}
+	}
+	if s := query.Get("fps"); s != "" {
+		fps, err = strconv.Atoi(s)
+		if util.BadRequest("Websocket Preview", err, w) {
+			return
+		}
+	}
+
+	conn, err := Upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		logger.Logger.WithField("context", "Websocket Preview").Error(err)
+		return
+	}
+	defer conn.Close()
+	preview, stop := v.AddPreview(pixels, fps)
+	defer stop()
+	logger.Logger.WithField("context", "Websocket Preview").Debugf("Previewing %s to %s", v.ID, r.RemoteAddr)
+
+	// the client doesn't send anything, but reading is needed to notice when it goes away
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case frame := <-preview.Frames:
+			if err := conn.WriteMessage(websocket.BinaryMessage, frame); err != nil {
+				logger.Logger.WithField("context", "Websocket Preview").Debug(err)
+				return
+			}
+		case <-closed:
+			logger.Logger.WithField("context", "Websocket Preview").Debugf("Closed preview of %s to %s", v.ID, r.RemoteAddr)
+			return
+		}
+	}
+}
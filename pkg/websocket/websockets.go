@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"sync"
 
+	"github.com/LedFx/ledfx/pkg/auth"
+	"github.com/LedFx/ledfx/pkg/config"
 	"github.com/LedFx/ledfx/pkg/event"
 	"github.com/LedFx/ledfx/pkg/logger"
 
@@ -17,16 +19,18 @@ var Upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
 
-	// We'll need to check the origin of our connection
-	// this will allow us to make requests from our React
-	// development server to here.
-	// For now, we'll do no checking and just allow any connection
-	CheckOrigin: func(r *http.Request) bool { return true },
+	// only allowed origins can connect. any can if none are configured,
+	// so the React development server can connect
+	CheckOrigin: auth.CheckOrigin,
 }
 
 type webSocket struct {
-	conn *websocket.Conn
-	mu   sync.Mutex
+	conn   *websocket.Conn
+	mu     sync.Mutex
+	subsMu sync.Mutex
+	subs   map[event.EventType]func() // unsubscribe funcs of the events this client wants
+	// clients with the read role can only get things and subscribe to events
+	readOnly bool
 }
 
 func (w *webSocket) handleEvent(e *event.Event) {
@@ -48,7 +52,57 @@ func (w *webSocket) Send(v any) {
 	}
 }
 
-// Read will listen indefinitely for new messages
+func (w *webSocket) subscribe(et event.EventType) {
+	w.subsMu.Lock()
+	defer w.subsMu.Unlock()
+	if _, exists := w.subs[et]; exists {
+		return
+	}
+	w.subs[et] = event.SubscribeWithOptions(et, w.handleEvent, subscribeOptions(et))
+}
+
+// a slow client only needs the latest frame or state of each thing. other events must not be merged
+func subscribeOptions(et event.EventType) event.Options {
+	switch et {
+	case event.EffectRender, event.EffectUpdate, event.ControllerUpdate, event.DeviceUpdate:
+		return event.Options{Policy: event.Coalesce}
+	default:
+		return event.Options{Policy: event.DropOldest}
+	}
+}
+
+func (w *webSocket) unsubscribe(et event.EventType) {
+	w.subsMu.Lock()
+	defer w.subsMu.Unlock()
+	if unsub, exists := w.subs[et]; exists {
+		unsub()
+		delete(w.subs, et)
+	}
+}
+
+func (w *webSocket) unsubscribeAll() {
+	w.subsMu.Lock()
+	defer w.subsMu.Unlock()
+	for et, unsub := range w.subs {
+		unsub()
+		delete(w.subs, et)
+	}
+}
+
+// the event types this client is subscribed to
+func (w *webSocket) subscriptions() []event.EventType {
+	w.subsMu.Lock()
+	defer w.subsMu.Unlock()
+	ets := []event.EventType{}
+	for _, et := range event.Types() {
+		if _, exists := w.subs[et]; exists {
+			ets = append(ets, et)
+		}
+	}
+	return ets
+}
+
+// Read will listen indefinitely for new messages, and respond to each
 func (w *webSocket) Read() {
 	for {
 		// read in a message
@@ -57,14 +111,19 @@ func (w *webSocket) Read() {
 			logger.Logger.WithField("context", "Websocket").Debug(err, messageType)
 			return
 		}
-		// print out that message for clarity
 		logger.Logger.WithField("context", "Websocket").Debug(string(p))
-		// TODO websockets API
+		res := w.handleRequest(p)
+		if res.Error != nil {
+			logger.Logger.WithField("context", "Websocket").Debugf("Request failed: %v", res.Error)
+		}
+		w.Send(res)
 	}
 }
 
 func Serve(mux *http.ServeMux) {
 	mux.HandleFunc("/websocket", New)
+	mux.HandleFunc("/websocket/preview", NewPreview)
+	mux.HandleFunc("/api/events", historyHandler)
 }
 
 func New(w http.ResponseWriter, r *http.Request) {
@@ -75,18 +134,21 @@ func New(w http.ResponseWriter, r *http.Request) {
 		logger.Logger.WithField("context", "Websocket").Error(err)
 		return
 	}
-	ws := webSocket{
-		conn: conn,
-		mu:   sync.Mutex{},
+	ws := &webSocket{
+		conn:     conn,
+		subs:     map[event.EventType]func(){},
+		readOnly: auth.RequestRole(r) != config.RoleAdmin,
 	}
 	logger.Logger.WithField("context", "Websocket").Debugf("Connection established with %s", r.RemoteAddr)
-	// subscribe to the events we want
-	// there are eight event types so we'll just ask for all of them
-	var i event.EventType
-	for i = 0; i <= 11; i++ {
-		// sub and also defer calling the unsubscribe function
-		defer event.Subscribe(i, ws.handleEvent)()
+	// clients start subscribed to every event, and can unsubscribe from those they don't want.
+	// effect renders are sent every frame, so clients must ask for them. see also the preview stream.
+	for _, et := range event.Types() {
+		if et == event.EffectRender {
+			continue
+		}
+		ws.subscribe(et)
 	}
+	defer ws.unsubscribeAll()
 	// listen indefinitely for new messages coming through on our WebSocket connection
 	ws.Read()
 	logger.Logger.WithField("context", "Websocket").Debugf("Closed connection with %s", r.RemoteAddr)
@@ -0,0 +1,197 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/LedFx/ledfx/pkg/color"
+	"github.com/LedFx/ledfx/pkg/config"
+	"github.com/LedFx/ledfx/pkg/controller"
+	"github.com/LedFx/ledfx/pkg/effect"
+	"github.com/LedFx/ledfx/pkg/event"
+	"github.com/LedFx/ledfx/pkg/render"
+
+	"github.com/gorilla/websocket"
+)
+
+func dial(t *testing.T) *websocket.Conn {
+	mux := http.NewServeMux()
+	Serve(mux)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/websocket", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// reads messages until the response to the request, skipping any events
+func roundTrip(t *testing.T, conn *websocket.Conn, req map[string]interface{}) map[string]interface{} {
+	if err := conn.WriteJSON(req); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		msg := map[string]interface{}{}
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg["type"] == "response" {
+			if msg["id"] != req["id"] {
+				t.Errorf("Response id %v does not match request id %v", msg["id"], req["id"])
+			}
+			return msg
+		}
+	}
+}
+
+func TestWebsocketCommands(t *testing.T) {
+	defer config.DisableSaving()()
+	conn := dial(t)
+
+	res := roundTrip(t, conn, map[string]interface{}{"id": 1., "type": "not.a.command"})
+	if res["success"] != false {
+		t.Error("Unknown command should fail")
+	}
+	if e, ok := res["error"].(map[string]interface{}); !ok || e["code"] != errUnknownCommand {
+		t.Errorf("Unknown command should give a structured error, got %v", res["error"])
+	}
+
+	res = roundTrip(t, conn, map[string]interface{}{"id": "unsub", "type": "unsubscribe", "data": map[string]interface{}{"event_types": []interface{}{"Effect Update", float64(event.Log)}}})
+	if res["success"] != true {
+		t.Fatalf("Unsubscribe failed: %v", res["error"])
+	}
+	for _, et := range res["data"].([]interface{}) {
+		if et == float64(event.EffectUpdate) || et == float64(event.Log) {
+			t.Errorf("Still subscribed to event type %v", et)
+		}
+	}
+
+	res = roundTrip(t, conn, map[string]interface{}{"id": 2., "type": "subscribe", "data": map[string]interface{}{"event_types": []interface{}{"Not An Event"}}})
+	if e, ok := res["error"].(map[string]interface{}); !ok || e["code"] != errBadRequest {
+		t.Errorf("Subscribing to an unknown event should be a bad request, got %v", res["error"])
+	}
+
+	res = roundTrip(t, conn, map[string]interface{}{"id": 3., "type": "effects.update", "data": map[string]interface{}{"id": "not_an_effect"}})
+	if e, ok := res["error"].(map[string]interface{}); !ok || e["code"] != errNotFound {
+		t.Errorf("Updating a missing effect should be not found, got %v", res["error"])
+	}
+
+	res = roundTrip(t, conn, map[string]interface{}{"id": 4., "type": "controllers.create", "data": map[string]interface{}{"id": "ws_test", "base_config": map[string]interface{}{"name": "Websocket Test"}}})
+	if res["success"] != true {
+		t.Fatalf("Creating a controller failed: %v", res["error"])
+	}
+
+	// updating a controller keeps its connections, and only changes the settings given
+	res = roundTrip(t, conn, map[string]interface{}{"id": "fx", "type": "effects.create", "data": map[string]interface{}{"id": "ws_fx", "type": "energy"}})
+	if res["success"] != true {
+		t.Fatalf("Creating an effect failed: %v", res["error"])
+	}
+	defer effect.Destroy("ws_fx")
+	res = roundTrip(t, conn, map[string]interface{}{"id": "connect", "type": "controllers.connect", "data": map[string]interface{}{"controller_id": "ws_test", "effect_id": "ws_fx"}})
+	if res["success"] != true {
+		t.Fatalf("Connecting an effect failed: %v", res["error"])
+	}
+	res = roundTrip(t, conn, map[string]interface{}{"id": "update", "type": "controllers.update", "data": map[string]interface{}{"id": "ws_test", "base_config": map[string]interface{}{"brightness": 0.5}}})
+	if res["success"] != true {
+		t.Fatalf("Updating a controller failed: %v", res["error"])
+	}
+	if c := res["data"].(map[string]interface{})["base_config"].(map[string]interface{}); c["name"] != "Websocket Test" || c["brightness"] != 0.5 {
+		t.Errorf("Expected the given settings to be merged into the config, got %v", c)
+	}
+	if v, err := controller.Get("ws_test"); err != nil || v.EffectID() != "ws_fx" {
+		t.Errorf("Expected the controller to keep its effect, got %v", err)
+	}
+	res = roundTrip(t, conn, map[string]interface{}{"id": "missing", "type": "controllers.update", "data": map[string]interface{}{"id": "not_a_controller"}})
+	if e, ok := res["error"].(map[string]interface{}); !ok || e["code"] != errNotFound {
+		t.Errorf("Updating a missing controller should be not found, got %v", res["error"])
+	}
+
+	// extra config is passed on rather than dropped, so it's refused by effects without any
+	res = roundTrip(t, conn, map[string]interface{}{"id": "extra", "type": "effects.update", "data": map[string]interface{}{"id": "ws_fx", "extra_config": map[string]interface{}{"script": ""}}})
+	if e, ok := res["error"].(map[string]interface{}); !ok || e["code"] != errBadRequest {
+		t.Errorf("Expected extra config to be refused by an energy effect, got %v", res["error"])
+	}
+
+	res = roundTrip(t, conn, map[string]interface{}{"id": 5., "type": "controllers.delete", "data": map[string]interface{}{"id": "ws_test"}})
+	if res["success"] != true {
+		t.Errorf("Deleting a controller failed: %v", res["error"])
+	}
+}
+
+func TestPreview(t *testing.T) {
+	mux := http.NewServeMux()
+	Serve(mux)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	_, res, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/websocket/preview?controller=not_a_controller", nil)
+	if err == nil || res == nil || res.StatusCode != http.StatusNotFound {
+		t.Error("Previewing a missing controller should not be found")
+	}
+
+	// frames are downsampled to uint8 rgb
+	pg := &render.PixelGroup{
+		Group: map[string]color.Pixels{
+			"a": {{1, 0, 0}, {1, 0, 0}},
+			"b": {{0, 0, 1}, {0, 0, 0.5}},
+		},
+		Order: []string{"a", "b"},
+	}
+	frame := controller.EncodePreview(pg, 2)
+	expected := []byte{255, 0, 0, 0, 0, 191}
+	if string(frame) != string(expected) {
+		t.Errorf("Expected preview frame %v but got %v", expected, frame)
+	}
+}
+
+func TestEventHistory(t *testing.T) {
+	start := event.LatestSeq()
+	event.Invoke(event.EffectDeleteData{ID: "history_test"})
+
+	mux := http.NewServeMux()
+	Serve(mux)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/events?seq=%d&event_types=Effect%%20Delete,%d", start, event.ControllerDelete), nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	res := struct {
+		LatestSeq uint64 `json:"latest_seq"`
+		Events    []struct{ Seq uint64 }
+	}{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Events) != 1 || res.Events[0].Seq != start+1 || res.LatestSeq < start+1 {
+		t.Errorf("Expected the effect delete after seq %d, got %+v", start, res)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/events?event_types=Not%20An%20Event", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Unknown event types should be a bad request, got %d", rec.Code)
+	}
+
+	conn := dial(t)
+	msg := roundTrip(t, conn, map[string]interface{}{"id": 1., "type": "events.history", "data": map[string]interface{}{"seq": start, "event_types": []interface{}{"Effect Delete"}}})
+	data, ok := msg["data"].(map[string]interface{})
+	if !ok || len(data["events"].([]interface{})) != 1 {
+		t.Errorf("Expected one event from the websocket history, got %v", msg)
+	}
+}
+
+func TestWebsocketReadOnly(t *testing.T) {
+	w := &webSocket{subs: map[event.EventType]func(){}, readOnly: true}
+	if res := w.handleRequest([]byte(`{"id": 1, "type": "effects.get"}`)); !res.Success {
+		t.Errorf("Expected read only clients to get effects, got %v", res.Error)
+	}
+	res := w.handleRequest([]byte(`{"id": 2, "type": "effects.delete", "data": {"id": "x"}}`))
+	if res.Success || res.Error.Code != errForbidden {
+		t.Errorf("Expected read only clients to be forbidden from deleting, got %+v", res)
+	}
+}